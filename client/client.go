@@ -0,0 +1,450 @@
+package client
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/model"
+)
+
+var (
+	ErrClientNotFound       = errors.New("client not found")
+	ErrInvalidClientID      = errors.New("invalid client id")
+	ErrInvalidSecret        = errors.New("invalid client secret")
+	ErrPublicClient         = errors.New("public client has no secret")
+	ErrNameEmpty            = errors.New("client name empty")
+	ErrInvalidRedirectURI   = errors.New("invalid redirect uri")
+	ErrGrantTypeUnsupported = errors.New("grant type not supported")
+	ErrGrantNotAllowed      = errors.New("grant type not allowed")
+	ErrScopeNotAllowed      = errors.New("scope not allowed")
+	ErrAudienceNotAllowed   = errors.New("audience not allowed")
+	ErrConfidentialOnly     = errors.New("grant type requires a confidential client")
+)
+
+// the grant types of RFC 6749 and RFC 8693 a client may be allowed
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+	GrantTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+var grantTypes = []string{
+	GrantAuthorizationCode,
+	GrantRefreshToken,
+	GrantClientCredentials,
+	GrantTokenExchange,
+}
+
+// the grant types only a confidential client may be allowed
+var confidentialGrantTypes = []string{
+	GrantClientCredentials,
+	GrantTokenExchange,
+}
+
+type Type int
+
+const (
+	Confidential Type = iota
+	Public
+)
+
+func ParseType(typ string) (Type, error) {
+	switch strings.ToLower(typ) {
+	case "confidential":
+		return Confidential, nil
+	case "public":
+		return Public, nil
+	default:
+		return -1, errors.New("invalid client type")
+	}
+}
+
+func (t Type) String() string {
+	switch t {
+	case Confidential:
+		return "confidential"
+	case Public:
+		return "public"
+	default:
+		return "unknown"
+	}
+}
+
+func (t Type) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *Type) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	typ, err := ParseType(raw)
+	if err != nil {
+		return err
+	}
+
+	*t = typ
+	return nil
+}
+
+// ClientID is the client_id of OAuth 2.0, the registered clients are
+// given a ULID while the seeded ones keep the ID of the configuration.
+type ClientID string // AggregateRoot
+
+func MakeID() ClientID {
+	return ClientID(ulid.Make().String())
+}
+
+// ParseID rejects the IDs which can't be a token of the topics.
+func ParseID(id string) (ClientID, error) {
+	if id == "" || strings.ContainsAny(id, ".*> \t\r\n") {
+		return "", ErrInvalidClientID
+	}
+
+	return ClientID(id), nil
+}
+
+func (id ClientID) String() string {
+	return string(id)
+}
+
+// Lifetimes overrides the lifetimes of the tokens issued to the client,
+// zero falls back to the configuration.
+type Lifetimes struct {
+	AccessToken  time.Duration
+	RefreshToken time.Duration
+}
+
+func (l Lifetimes) MarshalJSON() ([]byte, error) {
+	raw := map[string]string{}
+	if l.AccessToken > 0 {
+		raw["access_token"] = l.AccessToken.String()
+	}
+
+	if l.RefreshToken > 0 {
+		raw["refresh_token"] = l.RefreshToken.String()
+	}
+
+	return json.Marshal(raw)
+}
+
+func (l *Lifetimes) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var lifetimes Lifetimes
+	if raw.AccessToken != "" {
+		ttl, err := time.ParseDuration(raw.AccessToken)
+		if err != nil {
+			return err
+		}
+
+		lifetimes.AccessToken = ttl
+	}
+
+	if raw.RefreshToken != "" {
+		ttl, err := time.ParseDuration(raw.RefreshToken)
+		if err != nil {
+			return err
+		}
+
+		lifetimes.RefreshToken = ttl
+	}
+
+	*l = lifetimes
+	return nil
+}
+
+// Settings are what an admin registers and updates of a client, the roles
+// are granted to the client itself by the client credentials grant. The
+// audiences are the services the client may exchange the tokens for.
+type Settings struct {
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	GrantTypes   []string  `json:"grant_types"`
+	Scopes       []string  `json:"scopes"`
+	Roles        []string  `json:"roles"`
+	Audiences    []string  `json:"audiences"`
+	Lifetimes    Lifetimes `json:"lifetimes"`
+}
+
+func (s Settings) Validate() error {
+	if s.Name == "" {
+		return ErrNameEmpty
+	}
+
+	for _, uri := range s.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			return ErrInvalidRedirectURI
+		}
+	}
+
+	for _, grantType := range s.GrantTypes {
+		if !slices.Contains(grantTypes, grantType) {
+			return ErrGrantTypeUnsupported
+		}
+	}
+
+	if slices.Contains(s.GrantTypes, GrantAuthorizationCode) && len(s.RedirectURIs) == 0 {
+		return ErrInvalidRedirectURI
+	}
+
+	if s.Lifetimes.AccessToken < 0 || s.Lifetimes.RefreshToken < 0 {
+		return errors.New("invalid lifetimes")
+	}
+
+	return nil
+}
+
+// validateFor validates the settings of the type, only a confidential
+// client acts on its own behalf or on behalf of others.
+func (s Settings) validateFor(typ Type) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	if typ == Public {
+		for _, grantType := range confidentialGrantTypes {
+			if slices.Contains(s.GrantTypes, grantType) {
+				return ErrConfidentialOnly
+			}
+		}
+	}
+
+	return nil
+}
+
+// Client is an application registered to request the tokens, a public
+// client presents no secret and relies on PKCE.
+type Client struct {
+	ID   ClientID `json:"client_id"`
+	Type Type     `json:"type"`
+	Settings
+
+	// SHA-256 of the secret, never exposed
+	Secret string `json:"-"`
+
+	model.Model
+
+	events.EventStore `json:"-"`
+}
+
+// NewClient registers a client, the secret of a confidential client is
+// returned once and only its hash is kept.
+func NewClient(typ Type, settings Settings) (*Client, string, error) {
+	return newClient(MakeID(), typ, settings)
+}
+
+func newClient(id ClientID, typ Type, settings Settings) (*Client, string, error) {
+	if err := settings.validateFor(typ); err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	c := &Client{
+		ID:       id,
+		Type:     typ,
+		Settings: settings.clone(),
+		Model: model.Model{
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+
+		EventStore: events.NewEventStore(),
+	}
+
+	var secret string
+	if typ == Confidential {
+		s, err := generate()
+		if err != nil {
+			return nil, "", err
+		}
+
+		secret = s
+		c.Secret = Hash(secret)
+	}
+
+	c.AddEvent(NewClientRegisteredEvent(c))
+	return c, secret, nil
+}
+
+// NewStaticClient builds the client seeded by the configuration with its
+// given secret, no event is recorded since every instance seeds its own.
+func NewStaticClient(id string, secret string, settings Settings) (*Client, error) {
+	clientID, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if settings.Name == "" {
+		settings.Name = id
+	}
+
+	typ := Public
+	if secret != "" {
+		typ = Confidential
+	}
+
+	if err := settings.validateFor(typ); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	c := &Client{
+		ID:       clientID,
+		Type:     typ,
+		Settings: settings.clone(),
+		Model: model.Model{
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+
+		EventStore: events.NewEventStore(),
+	}
+
+	if secret != "" {
+		c.Secret = Hash(secret)
+	}
+
+	return c, nil
+}
+
+// Update replaces the settings, the type and the secret are kept.
+func (c *Client) Update(settings Settings) error {
+	if err := settings.validateFor(c.Type); err != nil {
+		return err
+	}
+
+	c.Settings = settings.clone()
+	c.UpdatedAt = time.Now()
+
+	e := NewClientUpdatedEvent(c)
+	c.AddEvent(e)
+	return nil
+}
+
+// RotateSecret replaces the secret of a confidential client at once, the
+// new secret is returned once.
+func (c *Client) RotateSecret() (string, error) {
+	if c.Type == Public {
+		return "", ErrPublicClient
+	}
+
+	secret, err := generate()
+	if err != nil {
+		return "", err
+	}
+
+	c.Secret = Hash(secret)
+	c.UpdatedAt = time.Now()
+
+	e := NewClientSecretRotatedEvent(c)
+	c.AddEvent(e)
+	return secret, nil
+}
+
+func (c *Client) Delete() {
+	now := time.Now()
+	c.UpdatedAt = now
+	c.DeletedAt = now
+
+	e := NewClientDeletedEvent(c)
+	c.AddEvent(e)
+}
+
+// Authenticate compares the secret in constant time, a public client
+// presents none.
+func (c *Client) Authenticate(secret string) error {
+	if c.Type == Public {
+		if secret != "" {
+			return ErrInvalidSecret
+		}
+
+		return nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(Hash(secret)), []byte(c.Secret)) != 1 {
+		return ErrInvalidSecret
+	}
+
+	return nil
+}
+
+func (c *Client) Confidential() bool {
+	return c.Type == Confidential
+}
+
+func (c *Client) AllowsGrant(grantType string) bool {
+	return slices.Contains(c.GrantTypes, grantType)
+}
+
+// AllowsRedirectURI matches the registered redirect URIs exactly.
+func (c *Client) AllowsRedirectURI(uri string) bool {
+	return slices.Contains(c.RedirectURIs, uri)
+}
+
+func (c *Client) AllowsScopes(scopes []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AllowsAudiences allows the client itself and the audiences registered.
+func (c *Client) AllowsAudiences(audiences []string) bool {
+	for _, aud := range audiences {
+		if aud != c.ID.String() && !slices.Contains(c.Audiences, aud) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s Settings) clone() Settings {
+	s.RedirectURIs = append(make([]string, 0, len(s.RedirectURIs)), s.RedirectURIs...)
+	s.GrantTypes = append(make([]string, 0, len(s.GrantTypes)), s.GrantTypes...)
+	s.Scopes = append(make([]string, 0, len(s.Scopes)), s.Scopes...)
+	s.Roles = append(make([]string, 0, len(s.Roles)), s.Roles...)
+	s.Audiences = append(make([]string, 0, len(s.Audiences)), s.Audiences...)
+	return s
+}
+
+func generate() (string, error) {
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
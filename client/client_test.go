@@ -0,0 +1,137 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotateSecret(t *testing.T) {
+	assert := assert.New(t)
+
+	c, first, err := NewClient(Confidential, Settings{
+		Name:         "Console",
+		RedirectURIs: []string{"https://console.example.com/callback"},
+		GrantTypes:   []string{GrantAuthorizationCode, GrantRefreshToken},
+		Scopes:       []string{"openid", "profile"},
+	})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.NotEmpty(first)
+	assert.Equal(Hash(first), c.Secret)
+	assert.NoError(c.Authenticate(first))
+	assert.ErrorIs(c.Authenticate(""), ErrInvalidSecret)
+
+	second, err := c.RotateSecret()
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.ErrorIs(c.Authenticate(first), ErrInvalidSecret)
+	assert.NoError(c.Authenticate(second))
+
+	assert.True(c.AllowsGrant(GrantRefreshToken))
+	assert.True(c.AllowsRedirectURI("https://console.example.com/callback"))
+	assert.False(c.AllowsRedirectURI("https://console.example.com/callback/"))
+	assert.True(c.AllowsScopes([]string{"openid"}))
+	assert.False(c.AllowsScopes([]string{"openid", "email"}))
+
+	names := make([]string, 0)
+	for _, e := range c.Events() {
+		names = append(names, e.EventName())
+	}
+
+	assert.Equal([]string{
+		ClientRegistered.String(),
+		ClientSecretRotated.String(),
+	}, names)
+
+	assert.Equal("clients."+c.ID.String()+".secret_rotated", c.Events()[1].Topic())
+	assert.Equal(ClientSecretRotated, ParseTopicName("secret_rotated"))
+}
+
+func TestPublicClient(t *testing.T) {
+	assert := assert.New(t)
+
+	c, secret, err := NewClient(Public, Settings{
+		Name:         "SPA",
+		RedirectURIs: []string{"https://spa.example.com/callback"},
+		GrantTypes:   []string{GrantAuthorizationCode},
+	})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Empty(secret)
+	assert.Empty(c.Secret)
+	assert.NoError(c.Authenticate(""))
+	assert.ErrorIs(c.Authenticate("secret"), ErrInvalidSecret)
+
+	_, err = c.RotateSecret()
+	assert.ErrorIs(err, ErrPublicClient)
+}
+
+func TestValidateSettings(t *testing.T) {
+	assert := assert.New(t)
+
+	_, _, err := NewClient(Confidential, Settings{})
+	assert.ErrorIs(err, ErrNameEmpty)
+
+	_, _, err = NewClient(Confidential, Settings{
+		Name:       "App",
+		GrantTypes: []string{"password"},
+	})
+	assert.ErrorIs(err, ErrGrantTypeUnsupported)
+
+	// the authorization code grant redirects
+	_, _, err = NewClient(Public, Settings{
+		Name:       "App",
+		GrantTypes: []string{GrantAuthorizationCode},
+	})
+	assert.ErrorIs(err, ErrInvalidRedirectURI)
+
+	_, _, err = NewClient(Public, Settings{
+		Name:         "App",
+		RedirectURIs: []string{"/callback"},
+	})
+	assert.ErrorIs(err, ErrInvalidRedirectURI)
+
+	// a public client never acts on its own behalf
+	_, _, err = NewClient(Public, Settings{
+		Name:       "App",
+		GrantTypes: []string{GrantClientCredentials},
+	})
+	assert.ErrorIs(err, ErrConfidentialOnly)
+
+	_, err = NewStaticClient("gateway.internal", "secret", Settings{})
+	assert.ErrorIs(err, ErrInvalidClientID)
+}
+
+func TestLifetimesJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	bs, err := json.Marshal(Lifetimes{AccessToken: 5 * time.Minute})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.JSONEq(`{"access_token":"5m0s"}`, string(bs))
+
+	var l Lifetimes
+	err = json.Unmarshal([]byte(`{"access_token":"10m","refresh_token":"24h"}`), &l)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(10*time.Minute, l.AccessToken)
+	assert.Equal(24*time.Hour, l.RefreshToken)
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/mirror520/identity/events"
+)
+
+type EventName int
+
+const (
+	Unknown EventName = iota
+	ClientRegistered
+	ClientUpdated
+	ClientSecretRotated
+	ClientDeleted
+)
+
+func ParseEventName(s string) EventName {
+	switch s {
+	case "client_registered":
+		return ClientRegistered
+	case "client_updated":
+		return ClientUpdated
+	case "client_secret_rotated":
+		return ClientSecretRotated
+	case "client_deleted":
+		return ClientDeleted
+	default:
+		return Unknown
+	}
+}
+
+func (name EventName) String() string {
+	switch name {
+	case ClientRegistered:
+		return "client_registered"
+	case ClientUpdated:
+		return "client_updated"
+	case ClientSecretRotated:
+		return "client_secret_rotated"
+	case ClientDeleted:
+		return "client_deleted"
+	default:
+		return ""
+	}
+}
+
+func (name EventName) MarshalJSON() ([]byte, error) {
+	return json.Marshal(name.String())
+}
+
+func (name *EventName) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	*name = ParseEventName(s)
+	return nil
+}
+
+type Event struct {
+	Domain    string    `json:"domain"`
+	Name      EventName `json:"name"`
+	ClientID  ClientID  `json:"client_id"` // AggreagateRoot
+	OccuredAt time.Time `json:"occured_at"`
+}
+
+func NewEvent(name EventName, c *Client) *Event {
+	return &Event{
+		Domain:    "identity:clients",
+		Name:      name,
+		ClientID:  c.ID,
+		OccuredAt: c.UpdatedAt,
+	}
+}
+
+func (e *Event) EventName() string {
+	return e.Name.String()
+}
+
+func (e *Event) Topic() string {
+	return "clients." + e.ClientID.String() + "." + e.Name.TopicName()
+}
+
+// TopicName is the last token of the topic, "clients.<id>.<name>".
+func (name EventName) TopicName() string {
+	return strings.TrimPrefix(name.String(), "client_")
+}
+
+func ParseTopicName(s string) EventName {
+	return ParseEventName("client_" + s)
+}
+
+// ClientRegisteredEvent carries the hash of the secret as well, so that
+// every instance authenticates the client.
+type ClientRegisteredEvent struct {
+	*Event
+	Client *Client `json:"client"`
+	Secret string  `json:"secret_hash,omitempty"`
+}
+
+func NewClientRegisteredEvent(c *Client) events.DomainEvent {
+	return &ClientRegisteredEvent{
+		Event:  NewEvent(ClientRegistered, c),
+		Client: c,
+		Secret: c.Secret,
+	}
+}
+
+type ClientUpdatedEvent struct {
+	*Event
+	Settings Settings `json:"settings"`
+}
+
+func NewClientUpdatedEvent(c *Client) events.DomainEvent {
+	return &ClientUpdatedEvent{
+		Event:    NewEvent(ClientUpdated, c),
+		Settings: c.Settings,
+	}
+}
+
+type ClientSecretRotatedEvent struct {
+	*Event
+	Secret string `json:"secret_hash"`
+}
+
+func NewClientSecretRotatedEvent(c *Client) events.DomainEvent {
+	return &ClientSecretRotatedEvent{
+		Event:  NewEvent(ClientSecretRotated, c),
+		Secret: c.Secret,
+	}
+}
+
+type ClientDeletedEvent struct {
+	*Event
+}
+
+func NewClientDeletedEvent(c *Client) events.DomainEvent {
+	return &ClientDeletedEvent{
+		Event: NewEvent(ClientDeleted, c),
+	}
+}
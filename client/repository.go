@@ -0,0 +1,15 @@
+package client
+
+type Repository interface {
+	// Command
+
+	Store(c *Client) error
+	Remove(id ClientID) error
+
+	// Query
+
+	Find(id ClientID) (*Client, error)
+	List() ([]*Client, error)
+
+	Close() error
+}
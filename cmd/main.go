@@ -23,12 +23,25 @@ import (
 	"github.com/mirror520/identity"
 	"github.com/mirror520/identity/conf"
 	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/keys"
+	"github.com/mirror520/identity/mail"
 	"github.com/mirror520/identity/model"
+	"github.com/mirror520/identity/oidc"
 	"github.com/mirror520/identity/persistence"
 	"github.com/mirror520/identity/policy"
 	"github.com/mirror520/identity/pubsub"
 	"github.com/mirror520/identity/pubsub/nats"
+	"github.com/mirror520/identity/social"
+	"github.com/mirror520/identity/throttle"
+	"github.com/mirror520/identity/token"
 	"github.com/mirror520/identity/transport"
+	"github.com/mirror520/identity/user"
+	"github.com/mirror520/identity/verifier"
+
+	_ "github.com/mirror520/identity/mail/file"
+	_ "github.com/mirror520/identity/mail/inmem"
+	_ "github.com/mirror520/identity/mail/smtp"
+	_ "github.com/mirror520/identity/social/google"
 
 	transHTTP "github.com/mirror520/identity/transport/http"
 	transPubSub "github.com/mirror520/identity/transport/pubsub"
@@ -62,6 +75,47 @@ var versionCmd = &cli.Command{
 	},
 }
 
+var keysCmd = &cli.Command{
+	Name:  "keys",
+	Usage: "Manage the signing keys",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "rotate",
+			Usage: "Generate a new signing key and schedule it",
+			Flags: []cli.Flag{
+				&cli.DurationFlag{
+					Name:  "activate-in",
+					Usage: "Activates the new key after the duration",
+					Value: 0,
+				},
+				&cli.DurationFlag{
+					Name:  "overlap",
+					Usage: "Verifies with the retired keys for the duration after the activation (default: jwt.timeout)",
+				},
+			},
+			Action: rotateKey,
+		},
+		{
+			Name:   "list",
+			Usage:  "List the keys in the key ring",
+			Action: listKeys,
+		},
+	},
+}
+
+var adminCmd = &cli.Command{
+	Name:  "admin",
+	Usage: "Manage the administrators",
+	Subcommands: []*cli.Command{
+		{
+			Name:      "grant",
+			Usage:     "Grant the admin role to an activated user",
+			ArgsUsage: "<user id>",
+			Action:    grantAdmin,
+		},
+	},
+}
+
 func main() {
 	cli.VersionPrinter = func(cli *cli.Context) {
 		fmt.Println("Version: " + cli.App.Version)
@@ -73,7 +127,7 @@ func main() {
 		Name:     "identity",
 		Usage:    "Scalable and decentralized user identity management",
 		Version:  Version,
-		Commands: []*cli.Command{versionCmd},
+		Commands: []*cli.Command{versionCmd, keysCmd, adminCmd},
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:    "path",
@@ -119,6 +173,18 @@ func run(cli *cli.Context) error {
 
 	ctx := context.WithValue(context.Background(), model.LOGGER, log)
 
+	// Add Key Ring
+	ring, err := keys.LoadRing(cfg.JWT)
+	if err != nil {
+		log.Error(err.Error(),
+			zap.String("infra", "keys"),
+			zap.String("algorithm", cfg.JWT.Algorithm),
+		)
+		return err
+	}
+
+	keys.ReplaceGlobals(ring)
+
 	// Add Persistence
 	repo, err := persistence.NewUserRepository(cfg.Persistence)
 	if err != nil {
@@ -130,11 +196,128 @@ func run(cli *cli.Context) error {
 	}
 	defer repo.Close()
 
+	tokens, err := persistence.NewTokenRepository(cfg.Persistence, repo)
+	if err != nil {
+		log.Error(err.Error(),
+			zap.String("infra", "persistence"),
+			zap.String("driver", cfg.Persistence.Driver.String()),
+		)
+		return err
+	}
+	defer tokens.Close()
+
+	revocations, err := persistence.NewRevocationRepository(cfg.Persistence, repo)
+	if err != nil {
+		log.Error(err.Error(),
+			zap.String("infra", "persistence"),
+			zap.String("driver", cfg.Persistence.Driver.String()),
+		)
+		return err
+	}
+	defer revocations.Close()
+
+	clients, err := persistence.NewClientRepository(cfg.Persistence, repo)
+	if err != nil {
+		log.Error(err.Error(),
+			zap.String("infra", "persistence"),
+			zap.String("driver", cfg.Persistence.Driver.String()),
+		)
+		return err
+	}
+	defer clients.Close()
+
+	groups, err := persistence.NewGroupRepository(cfg.Persistence, repo)
+	if err != nil {
+		log.Error(err.Error(),
+			zap.String("infra", "persistence"),
+			zap.String("driver", cfg.Persistence.Driver.String()),
+		)
+		return err
+	}
+	defer groups.Close()
+
+	tenants, err := persistence.NewTenantRepository(cfg.Persistence, repo)
+	if err != nil {
+		log.Error(err.Error(),
+			zap.String("infra", "persistence"),
+			zap.String("driver", cfg.Persistence.Driver.String()),
+		)
+		return err
+	}
+	defer tenants.Close()
+
+	// the clients of the configuration are seeded by every instance
+	if err := persistence.SeedClients(clients, cfg.Clients); err != nil {
+		log.Error(err.Error(), zap.String("infra", "persistence"))
+		return err
+	}
+
+	// Add Denylist, the revocations are shared through the event bus
+	denylist, err := token.NewDenylist(revocations)
+	if err != nil {
+		log.Error(err.Error(), zap.String("infra", "denylist"))
+		return err
+	}
+
+	// Add Mailer
+	mailer, err := mail.NewMailer(cfg.Mail)
+	if err != nil {
+		log.Error(err.Error(),
+			zap.String("infra", "mail"),
+			zap.String("driver", cfg.Mail.Driver.String()),
+		)
+		return err
+	}
+	defer mailer.Close()
+
+	// Add Social Providers, the providers are added by importing their verifiers
+	providers, err := social.NewRegistry(cfg.Providers)
+	if err != nil {
+		log.Error(err.Error(), zap.String("infra", "social"))
+		return err
+	}
+
+	// Add Throttle, the failures are shared through the event bus
+	attempts := throttle.NewTracker(throttle.NewInMemStore(), cfg.Throttle, cfg.Name)
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	// Add Authorization Codes, the codes are shared through the event bus
+	codes := oidc.NewCodes(oidc.NewInMemStore(), cfg.OIDC.CodeTTL, cfg.Name)
+
+	// Add Verifier
+	v := verifier.NewVerifier(cfg.Issuer(), ring, denylist)
+
+	// Add Policy, the token exchange decides the impersonation by it
+	policy, err := policy.NewRegoPolicy(ctx, conf.Path)
+	if err != nil {
+		return err
+	}
+
+	// Add Event Bus, the leases are granted through it
+	ps, err := nats.NewNATSPubSub(cfg.Transports.NATS.Internal)
+	if err != nil {
+		log.Error(err.Error(),
+			zap.String("infra", "pubsub"),
+			zap.String("provider", cfg.EventBus.Provider.String()),
+		)
+		return err
+	}
+	defer ps.Close()
+
+	// Add Leases, the usernames and the refresh tokens are claimed across the instances
+	leases, err := ps.LeaseStore(cfg.EventBus.Leases.Bucket, cfg.EventBus.Leases.TTL)
+	if err != nil {
+		log.Error(err.Error(),
+			zap.String("infra", "leases"),
+			zap.String("bucket", cfg.EventBus.Leases.Bucket),
+		)
+		return err
+	}
+
 	// Add Service and Middlewares
-	svc := identity.NewService(repo, cfg.Providers)
+	svc := identity.NewService(repo, tokens, clients, groups, tenants, denylist, v, policy, codes, mailer, providers, attempts, leases, cfg)
 
 	if cfg.Transports.LoadBalancing.Enabled {
 		ch := make(chan identity.Instance, 1)
@@ -147,11 +330,62 @@ func run(cli *cli.Context) error {
 
 	// Add Endpoints
 	endpoints := identity.EndpointSet{
-		Register:         identity.RegisterEndpoint(svc),
-		SignIn:           identity.SignInEndpoint(svc),
-		OTPVerify:        identity.OTPVerifyEndpoint(svc),
-		AddSocialAccount: identity.AddSocialAccountEndpoint(svc),
-		CheckHealth:      identity.CheckHealth(svc),
+		Register:            identity.RegisterEndpoint(svc),
+		SignIn:              identity.SignInEndpoint(svc),
+		SignInWithPassword:  identity.SignInWithPasswordEndpoint(svc),
+		ChangePassword:      identity.ChangePasswordEndpoint(svc),
+		OTPEnroll:           identity.OTPEnrollEndpoint(svc),
+		VerifyUser:          identity.VerifyUserEndpoint(svc),
+		OTPVerify:           identity.OTPVerifyEndpoint(svc),
+		AddSocialAccount:    identity.AddSocialAccountEndpoint(svc),
+		RemoveSocialAccount: identity.RemoveSocialAccountEndpoint(svc),
+		Lock:                identity.LockEndpoint(svc),
+		Unlock:              identity.UnlockEndpoint(svc),
+		Revoke:              identity.RevokeEndpoint(svc),
+		GrantRole:           identity.GrantRoleEndpoint(svc),
+		RevokeRole:          identity.RevokeRoleEndpoint(svc),
+		UpdateProfile:       identity.UpdateProfileEndpoint(svc),
+		ChangeUsername:      identity.ChangeUsernameEndpoint(svc),
+		DeleteUser:          identity.DeleteUserEndpoint(svc),
+		RequestEmailChange:  identity.RequestEmailChangeEndpoint(svc),
+		ConfirmEmailChange:  identity.ConfirmEmailChangeEndpoint(svc),
+		ListUsers:           identity.ListUsersEndpoint(svc),
+		RefreshToken:        identity.RefreshTokenEndpoint(svc),
+		RevokeToken:         identity.RevokeTokenEndpoint(svc),
+		Introspect:          identity.IntrospectEndpoint(svc),
+		Authorize:           identity.AuthorizeEndpoint(svc),
+		ExchangeCode:        identity.ExchangeCodeEndpoint(svc),
+		ClientCredentials:   identity.ClientCredentialsEndpoint(svc),
+		ExchangeToken:       identity.ExchangeTokenEndpoint(svc),
+		UserInfo:            identity.UserInfoEndpoint(svc),
+		RegisterClient:      identity.RegisterClientEndpoint(svc),
+		UpdateClient:        identity.UpdateClientEndpoint(svc),
+		RotateClientSecret:  identity.RotateClientSecretEndpoint(svc),
+		DeleteClient:        identity.DeleteClientEndpoint(svc),
+		FindClient:          identity.FindClientEndpoint(svc),
+		ListClients:         identity.ListClientsEndpoint(svc),
+		CreateGroup:         identity.CreateGroupEndpoint(svc),
+		UpdateGroup:         identity.UpdateGroupEndpoint(svc),
+		DeleteGroup:         identity.DeleteGroupEndpoint(svc),
+		FindGroup:           identity.FindGroupEndpoint(svc),
+		ListGroups:          identity.ListGroupsEndpoint(svc),
+		AddGroupMember:      identity.AddGroupMemberEndpoint(svc),
+		RemoveGroupMember:   identity.RemoveGroupMemberEndpoint(svc),
+		GrantGroupRole:      identity.GrantGroupRoleEndpoint(svc),
+		RevokeGroupRole:     identity.RevokeGroupRoleEndpoint(svc),
+		Memberships:         identity.MembershipsEndpoint(svc),
+		CreateTenant:        identity.CreateTenantEndpoint(svc),
+		UpdateTenant:        identity.UpdateTenantEndpoint(svc),
+		DeleteTenant:        identity.DeleteTenantEndpoint(svc),
+		FindTenant:          identity.FindTenantEndpoint(svc),
+		ListTenants:         identity.ListTenantsEndpoint(svc),
+		JoinTenant:          identity.JoinTenantEndpoint(svc),
+		LeaveTenant:         identity.LeaveTenantEndpoint(svc),
+		GrantTenantRole:     identity.GrantTenantRoleEndpoint(svc),
+		RevokeTenantRole:    identity.RevokeTenantRoleEndpoint(svc),
+		Tenants:             identity.TenantsEndpoint(svc),
+		CheckStatus:         identity.CheckStatusEndpoint(svc),
+		CheckHealth:         identity.CheckHealth(svc),
 	}
 
 	// Add Transports
@@ -164,13 +398,6 @@ func run(cli *cli.Context) error {
 			zap.String("provider", cfg.EventBus.Provider.String()),
 		)
 
-		ps, err := nats.NewNATSPubSub(cfg.Transports.NATS.Internal)
-		if err != nil {
-			log.Error(err.Error())
-			return err
-		}
-		defer ps.Close()
-
 		stream := cfg.EventBus.Users.Stream
 		if err := ps.AddStream(stream.Name, stream.Config); err != nil {
 			log.Error(err.Error(),
@@ -197,22 +424,49 @@ func run(cli *cli.Context) error {
 			transPubSub.EventHandler(endpoint),
 		)
 
+		// SUB attempts.>
+		if err := ps.Subscribe("attempts.>", transPubSub.AttemptEventHandler(attempts)); err != nil {
+			log.Error(err.Error(),
+				zap.String("phase", "subscribe"),
+				zap.String("topic", "attempts.>"),
+			)
+			return err
+		}
+
+		// SUB codes.>
+		if err := ps.Subscribe("codes.>", transPubSub.CodeEventHandler(codes)); err != nil {
+			log.Error(err.Error(),
+				zap.String("phase", "subscribe"),
+				zap.String("topic", "codes.>"),
+			)
+			return err
+		}
+
+		// SUB keys.>
+		if err := ps.Subscribe("keys.>", transPubSub.KeyEventHandler(ring)); err != nil {
+			log.Error(err.Error(),
+				zap.String("phase", "subscribe"),
+				zap.String("topic", "keys.>"),
+			)
+			return err
+		}
+
 		pubSub = ps
 	}
 
 	events.ReplaceGlobals(pubSub)
 
-	policy, err := policy.NewRegoPolicy(ctx, conf.Path)
-	if err != nil {
-		return err
-	}
-
 	if nats := cfg.Transports.NATS; nats.Enabled {
 		// SUB identity.signin and identity.$INSTANCE.signin
 		signInHandler := transPubSub.SignInHandler(endpoints.SignIn)
 		pubSub.Subscribe("identity.signin", signInHandler)        // NATS LB
 		pubSub.Subscribe(nats.ReqPrefix+".signin", signInHandler) // NATS Direct
 
+		// SUB identity.introspect and identity.$INSTANCE.introspect
+		introspectHandler := transPubSub.IntrospectHandler(endpoints.Introspect)
+		pubSub.Subscribe("identity.introspect", introspectHandler)        // NATS LB
+		pubSub.Subscribe(nats.ReqPrefix+".introspect", introspectHandler) // NATS Direct
+
 		// SUB identity.$INSTANCE.health
 		checkHealthHandler := transPubSub.CheckHealthHandler(endpoints.CheckHealth)
 		pubSub.Subscribe(nats.ReqPrefix+".health", checkHealthHandler)
@@ -220,10 +474,15 @@ func run(cli *cli.Context) error {
 
 	// Add HTTP Transport
 	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.Transports.HTTP.TrustedProxies); err != nil {
+		log.Error(err.Error(), zap.String("transport", "http"))
+		return err
+	}
+
 	r.Use(ginzap.Ginzap(log, time.RFC3339, true))
 	r.Use(gin.Recovery())
 
-	auth := transHTTP.Authorizator(policy)
+	auth := transHTTP.Authorizator(v, policy, endpoints.CheckStatus, endpoints.Memberships, endpoints.Tenants)
 
 	r.GET("/hello", auth("identity::hello.view"), func(ctx *gin.Context) {
 		ctx.String(http.StatusOK, "Hello World\n")
@@ -238,34 +497,307 @@ func run(cli *cli.Context) error {
 
 	r.GET("/health", transHTTP.CheckHealthHandler(endpoints.CheckHealth))
 
+	// GET /.well-known/jwks.json
+	r.GET("/.well-known/jwks.json", transHTTP.JWKSHandler())
+
+	// GET /.well-known/openid-configuration
+	r.GET("/.well-known/openid-configuration", transHTTP.DiscoveryHandler())
+
 	apiV1 := r.Group("/identity/v1")
 	{
 		// PATCH /signin
 		apiV1.PATCH("/signin", transHTTP.SignInHandler(endpoints.SignIn))
 
+		// PATCH /signin/password
+		apiV1.PATCH("/signin/password", transHTTP.SignInWithPasswordHandler(endpoints.SignInWithPassword))
+
 		// POST /users
 		apiV1.POST("/users", transHTTP.RegisterHandler(endpoints.Register))
 
-		// PATCH /users/:id/verify
-		apiV1.POST("/users/:id/verify",
+		// GET /users
+		apiV1.GET("/users",
+			auth("identity::users.list", transHTTP.Admin),
+			transHTTP.ListUsersHandler(endpoints.ListUsers),
+		)
+
+		// PATCH /users/:id
+		apiV1.PATCH("/users/:id",
+			auth("identity::users.update", transHTTP.Owner|transHTTP.Admin),
+			transHTTP.UpdateProfileHandler(endpoints.UpdateProfile),
+		)
+
+		// DELETE /users/:id
+		apiV1.DELETE("/users/:id",
+			auth("identity::users.remove", transHTTP.Admin),
+			transHTTP.DeleteUserHandler(endpoints.DeleteUser),
+		)
+
+		// PUT /users/:id/username
+		apiV1.PUT("/users/:id/username",
+			auth("identity::users.update", transHTTP.Owner|transHTTP.Admin),
+			transHTTP.ChangeUsernameHandler(endpoints.ChangeUsername),
+		)
+
+		// PUT /users/:id/email, the code is sent to the new email
+		apiV1.PUT("/users/:id/email",
+			auth("identity::users.update", transHTTP.Owner),
+			transHTTP.RequestEmailChangeHandler(endpoints.RequestEmailChange),
+		)
+
+		// POST /users/:id/email/confirm
+		apiV1.POST("/users/:id/email/confirm",
+			auth("identity::users.update", transHTTP.Owner),
+			transHTTP.ConfirmEmailChangeHandler(endpoints.ConfirmEmailChange),
+		)
+
+		// POST /users/:id/otp
+		apiV1.POST("/users/:id/otp",
+			auth("identity::users.update", transHTTP.Owner),
+			transHTTP.OTPEnrollHandler(endpoints.OTPEnroll),
+		)
+
+		// POST /users/:id/verify, the code sent by email activates the user registered
+		apiV1.POST("/users/:id/verify", transHTTP.VerifyUserHandler(endpoints.VerifyUser))
+
+		// POST /users/:id/otp/verify, the step-up of the user signed in
+		apiV1.POST("/users/:id/otp/verify",
 			auth("identity::users.update", transHTTP.Owner),
 			transHTTP.OTPVerifyHandler(endpoints.OTPVerify),
 		)
 
+		// PUT /users/:id/password
+		apiV1.PUT("/users/:id/password",
+			auth("identity::users.update", transHTTP.Owner),
+			transHTTP.ChangePasswordHandler(endpoints.ChangePassword),
+		)
+
 		// PUT /users/id/socials
 		apiV1.POST("/users/:id/socials",
 			auth("identity::users.update", transHTTP.Owner|transHTTP.Admin),
 			transHTTP.AddSocialAccountHandler(endpoints.AddSocialAccount),
 		)
 
-		// PATCH /token/refresh
-		apiV1.PATCH("/token/refresh", transHTTP.RefreshHandler)
+		// DELETE /users/:id/socials/:provider/:socialID
+		apiV1.DELETE("/users/:id/socials/:provider/:socialID",
+			auth("identity::users.update", transHTTP.Owner|transHTTP.Admin),
+			transHTTP.RemoveSocialAccountHandler(endpoints.RemoveSocialAccount),
+		)
+
+		// PATCH /users/:id/lock
+		apiV1.PATCH("/users/:id/lock",
+			auth("identity::users.lock", transHTTP.Admin),
+			transHTTP.LockHandler(endpoints.Lock),
+		)
+
+		// PATCH /users/:id/unlock
+		apiV1.PATCH("/users/:id/unlock",
+			auth("identity::users.unlock", transHTTP.Admin),
+			transHTTP.UnlockHandler(endpoints.Unlock),
+		)
+
+		// PATCH /users/:id/revoke
+		apiV1.PATCH("/users/:id/revoke",
+			auth("identity::users.revoke", transHTTP.Admin),
+			transHTTP.RevokeHandler(endpoints.Revoke),
+		)
+
+		// POST /users/:id/roles
+		apiV1.POST("/users/:id/roles",
+			auth("identity::users.grant_role", transHTTP.Admin),
+			transHTTP.GrantRoleHandler(endpoints.GrantRole),
+		)
+
+		// DELETE /users/:id/roles/:role
+		apiV1.DELETE("/users/:id/roles/:role",
+			auth("identity::users.revoke_role", transHTTP.Admin),
+			transHTTP.RevokeRoleHandler(endpoints.RevokeRole),
+		)
+
+		// GET /users/:id/groups
+		apiV1.GET("/users/:id/groups",
+			auth("identity::users.view", transHTTP.Owner|transHTTP.Group|transHTTP.Admin),
+			transHTTP.MembershipsHandler(endpoints.Memberships),
+		)
+
+		// GET /groups
+		apiV1.GET("/groups",
+			auth("identity::groups.list", transHTTP.Admin),
+			transHTTP.ListGroupsHandler(endpoints.ListGroups),
+		)
+
+		// POST /groups
+		apiV1.POST("/groups",
+			auth("identity::groups.create", transHTTP.Admin),
+			transHTTP.CreateGroupHandler(endpoints.CreateGroup),
+		)
+
+		// GET /groups/:id
+		apiV1.GET("/groups/:id",
+			auth("identity::groups.view", transHTTP.Group|transHTTP.Admin),
+			transHTTP.FindGroupHandler(endpoints.FindGroup),
+		)
+
+		// PUT /groups/:id
+		apiV1.PUT("/groups/:id",
+			auth("identity::groups.update", transHTTP.Admin),
+			transHTTP.UpdateGroupHandler(endpoints.UpdateGroup),
+		)
+
+		// DELETE /groups/:id
+		apiV1.DELETE("/groups/:id",
+			auth("identity::groups.remove", transHTTP.Admin),
+			transHTTP.DeleteGroupHandler(endpoints.DeleteGroup),
+		)
+
+		// POST /groups/:id/members
+		apiV1.POST("/groups/:id/members",
+			auth("identity::groups.add_member", transHTTP.Admin),
+			transHTTP.AddGroupMemberHandler(endpoints.AddGroupMember),
+		)
+
+		// DELETE /groups/:id/members/:user
+		apiV1.DELETE("/groups/:id/members/:user",
+			auth("identity::groups.remove_member", transHTTP.Admin),
+			transHTTP.RemoveGroupMemberHandler(endpoints.RemoveGroupMember),
+		)
+
+		// POST /groups/:id/roles
+		apiV1.POST("/groups/:id/roles",
+			auth("identity::groups.grant_role", transHTTP.Admin),
+			transHTTP.GrantGroupRoleHandler(endpoints.GrantGroupRole),
+		)
+
+		// DELETE /groups/:id/roles/:role
+		apiV1.DELETE("/groups/:id/roles/:role",
+			auth("identity::groups.revoke_role", transHTTP.Admin),
+			transHTTP.RevokeGroupRoleHandler(endpoints.RevokeGroupRole),
+		)
+
+		// GET /users/:id/tenants
+		apiV1.GET("/users/:id/tenants",
+			auth("identity::users.view", transHTTP.Owner|transHTTP.Admin),
+			transHTTP.TenantsHandler(endpoints.Tenants),
+		)
+
+		// GET /tenants
+		apiV1.GET("/tenants",
+			auth("identity::tenants.list", transHTTP.Admin),
+			transHTTP.ListTenantsHandler(endpoints.ListTenants),
+		)
+
+		// POST /tenants
+		apiV1.POST("/tenants",
+			auth("identity::tenants.create", transHTTP.Admin),
+			transHTTP.CreateTenantHandler(endpoints.CreateTenant),
+		)
+
+		// GET /tenants/:id
+		apiV1.GET("/tenants/:id",
+			auth("identity::tenants.view", transHTTP.Admin),
+			transHTTP.FindTenantHandler(endpoints.FindTenant),
+		)
+
+		// PUT /tenants/:id
+		apiV1.PUT("/tenants/:id",
+			auth("identity::tenants.update", transHTTP.Admin),
+			transHTTP.UpdateTenantHandler(endpoints.UpdateTenant),
+		)
+
+		// DELETE /tenants/:id
+		apiV1.DELETE("/tenants/:id",
+			auth("identity::tenants.remove", transHTTP.Admin),
+			transHTTP.DeleteTenantHandler(endpoints.DeleteTenant),
+		)
+
+		// POST /tenants/:id/members
+		apiV1.POST("/tenants/:id/members",
+			auth("identity::tenants.add_member", transHTTP.Admin),
+			transHTTP.JoinTenantHandler(endpoints.JoinTenant),
+		)
+
+		// DELETE /tenants/:id/members/:user
+		apiV1.DELETE("/tenants/:id/members/:user",
+			auth("identity::tenants.remove_member", transHTTP.Admin),
+			transHTTP.LeaveTenantHandler(endpoints.LeaveTenant),
+		)
+
+		// POST /tenants/:id/members/:user/roles
+		apiV1.POST("/tenants/:id/members/:user/roles",
+			auth("identity::tenants.grant_role", transHTTP.Admin),
+			transHTTP.GrantTenantRoleHandler(endpoints.GrantTenantRole),
+		)
+
+		// DELETE /tenants/:id/members/:user/roles/:role
+		apiV1.DELETE("/tenants/:id/members/:user/roles/:role",
+			auth("identity::tenants.revoke_role", transHTTP.Admin),
+			transHTTP.RevokeTenantRoleHandler(endpoints.RevokeTenantRole),
+		)
+
+		// GET /clients
+		apiV1.GET("/clients",
+			auth("identity::clients.list", transHTTP.Admin),
+			transHTTP.ListClientsHandler(endpoints.ListClients),
+		)
+
+		// POST /clients
+		apiV1.POST("/clients",
+			auth("identity::clients.create", transHTTP.Admin),
+			transHTTP.RegisterClientHandler(endpoints.RegisterClient),
+		)
+
+		// GET /clients/:id
+		apiV1.GET("/clients/:id",
+			auth("identity::clients.view", transHTTP.Admin),
+			transHTTP.FindClientHandler(endpoints.FindClient),
+		)
+
+		// PUT /clients/:id
+		apiV1.PUT("/clients/:id",
+			auth("identity::clients.update", transHTTP.Admin),
+			transHTTP.UpdateClientHandler(endpoints.UpdateClient),
+		)
+
+		// PATCH /clients/:id/secret
+		apiV1.PATCH("/clients/:id/secret",
+			auth("identity::clients.rotate", transHTTP.Admin),
+			transHTTP.RotateClientSecretHandler(endpoints.RotateClientSecret),
+		)
+
+		// DELETE /clients/:id
+		apiV1.DELETE("/clients/:id",
+			auth("identity::clients.remove", transHTTP.Admin),
+			transHTTP.DeleteClientHandler(endpoints.DeleteClient),
+		)
+
+		// GET, POST /authorize
+		authorizeHandler := transHTTP.AuthorizeHandler(v, endpoints.Authorize)
+		apiV1.GET("/authorize", authorizeHandler)
+		apiV1.POST("/authorize", authorizeHandler)
+
+		// GET, POST /userinfo
+		userInfoHandler := transHTTP.UserInfoHandler(v, endpoints.UserInfo)
+		apiV1.GET("/userinfo", userInfoHandler)
+		apiV1.POST("/userinfo", userInfoHandler)
+
+		// POST /token
+		apiV1.POST("/token", transHTTP.TokenHandler(endpoints.RefreshToken, endpoints.ExchangeCode, endpoints.ClientCredentials, endpoints.ExchangeToken))
+
+		// PATCH /token/refresh, deprecated by POST /token
+		apiV1.PATCH("/token/refresh", transHTTP.RefreshHandler(endpoints.RefreshToken))
+
+		// POST /token/introspect
+		apiV1.POST("/token/introspect", transHTTP.IntrospectHandler(endpoints.Introspect))
+
+		// POST /token/revoke
+		apiV1.POST("/token/revoke", transHTTP.RevokeTokenHandler(endpoints.RevokeToken))
 	}
 
 	go r.Run(":" + strconv.Itoa(conf.Port))
 
 	go Registry(ctx, cfg)
 
+	go Purge(ctx, svc, cfg.Deletion.Interval)
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
@@ -275,6 +807,191 @@ func run(cli *cli.Context) error {
 	return nil
 }
 
+func loadRing(cli *cli.Context) (*keys.Ring, *conf.Config, error) {
+	if err := conf.LoadEnv(cli); err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := conf.LoadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ring, err := keys.OpenRing(cfg.JWT)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ring, cfg, nil
+}
+
+func rotateKey(cli *cli.Context) error {
+	ring, cfg, err := loadRing(cli)
+	if err != nil {
+		return err
+	}
+
+	overlap := cli.Duration("overlap")
+	if !cli.IsSet("overlap") {
+		overlap = cfg.JWT.Timeout
+	}
+
+	activatedAt := time.Now().Add(cli.Duration("activate-in"))
+
+	key, err := ring.Rotate(activatedAt, overlap)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("key %s scheduled at %s\n", key.ID, key.ActivatedAt.Format(time.RFC3339))
+
+	// PUB keys.rotated, the instances reload the key ring
+	ps, err := nats.NewNATSPubSub(cfg.Transports.NATS.Internal)
+	if err != nil {
+		return fmt.Errorf("key saved but not published: %w", err)
+	}
+	defer ps.Close()
+
+	events.ReplaceGlobals(ps)
+
+	es := events.NewEventStore()
+	es.AddEvent(keys.NewKeyRotatedEvent(key))
+	return es.Notify()
+}
+
+func listKeys(cli *cli.Context) error {
+	ring, _, err := loadRing(cli)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	active, _ := ring.Active(now)
+
+	for _, key := range ring.Keys(now) {
+		status := "scheduled"
+		switch {
+		case key == active:
+			status = "active"
+		case key.Valid(now):
+			status = "retired"
+		}
+
+		expiredAt := "-"
+		if !key.ExpiredAt.IsZero() {
+			expiredAt = key.ExpiredAt.Format(time.RFC3339)
+		}
+
+		fmt.Printf("%s\t%s\t%-9s\t%s\t%s\n",
+			key.ID,
+			key.Method.Alg(),
+			status,
+			key.ActivatedAt.Format(time.RFC3339),
+			expiredAt,
+		)
+	}
+
+	return nil
+}
+
+// grantAdmin designates an administrator once, the user is granted by
+// the ID rather than the username, which anyone may register.
+func grantAdmin(cli *cli.Context) error {
+	if err := conf.LoadEnv(cli); err != nil {
+		return err
+	}
+
+	cfg, err := conf.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	id, err := user.ParseID(cli.Args().First())
+	if err != nil {
+		return err
+	}
+
+	users, err := persistence.NewUserRepository(cfg.Persistence)
+	if err != nil {
+		return err
+	}
+	defer users.Close()
+
+	u, err := grantAdminRole(users, id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("admin role granted to %s (%s)\n", u.Username, u.ID)
+
+	if !cfg.Transports.NATS.Enabled {
+		return nil
+	}
+
+	// PUB users.role_granted, the instances replicate the grant
+	ps, err := nats.NewNATSPubSub(cfg.Transports.NATS.Internal)
+	if err != nil {
+		return fmt.Errorf("role granted but not published: %w", err)
+	}
+	defer ps.Close()
+
+	events.ReplaceGlobals(ps)
+
+	return u.Notify()
+}
+
+// grantAdminRole grants the admin role to the user activated, the grant
+// is stored at once for the instances not running.
+func grantAdminRole(users user.Repository, id user.UserID) (*user.User, error) {
+	u, err := users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Status != user.Activated {
+		return nil, user.ErrUserNotActivated
+	}
+
+	if err := u.GrantRole(user.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if err := users.Store(u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// Purge removes the users deleted before the retention on every interval,
+// the instances purge the same users harmlessly.
+func Purge(ctx context.Context, svc identity.Service, interval time.Duration) {
+	log, ok := ctx.Value(model.LOGGER).(*zap.Logger)
+	if !ok {
+		log = zap.L()
+	}
+	log = log.With(zap.String("action", "purge_users"))
+
+	if interval <= 0 {
+		log.Warn("users purge ignored")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("done")
+			return
+
+		case <-ticker.C:
+			svc.PurgeUsers() // logged by the middleware
+		}
+	}
+}
+
 func Registry(ctx context.Context, cfg *conf.Config) {
 	log, ok := ctx.Value(model.LOGGER).(*zap.Logger)
 	if !ok {
@@ -1,21 +1,56 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/suite"
 
 	"github.com/mirror520/identity"
+	"github.com/mirror520/identity/client"
 	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/group"
+	"github.com/mirror520/identity/keys"
+	"github.com/mirror520/identity/lease"
+	"github.com/mirror520/identity/mail/inmem"
+	"github.com/mirror520/identity/model"
+	"github.com/mirror520/identity/oidc"
+	"github.com/mirror520/identity/otp"
+	"github.com/mirror520/identity/persistence"
 	"github.com/mirror520/identity/persistence/db"
+	"github.com/mirror520/identity/policy"
+	"github.com/mirror520/identity/social"
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/throttle"
+	"github.com/mirror520/identity/token"
 	"github.com/mirror520/identity/user"
+	"github.com/mirror520/identity/verifier"
 )
 
 type identityTestSuite struct {
 	suite.Suite
-	svc   identity.Service
-	users user.Repository
-	token string
+	cfg       *conf.Config
+	svc       identity.Service
+	users     user.Repository
+	tokens    token.Repository
+	clients   client.Repository
+	groups    group.Repository
+	tenants   tenant.Repository
+	denied    *token.Denylist
+	ring      *keys.Ring
+	policy    policy.Policy
+	codes     *oidc.Codes
+	mailer    inmem.InMemMailer
+	providers *social.Registry
+	leases    lease.Store
+	token     string
 }
 
 func (suite *identityTestSuite) SetupSuite() {
@@ -39,12 +74,102 @@ func (suite *identityTestSuite) SetupSuite() {
 		return
 	}
 
-	suite.svc = identity.NewService(users, cfg.Providers)
+	tokens, err := db.NewTokenRepository(users.(db.Database).DB())
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	revocations, err := db.NewRevocationRepository(users.(db.Database).DB())
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	denylist, err := token.NewDenylist(revocations)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	key, err := keys.GenerateKey("ES256")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	ring := keys.NewRing(key)
+	v := verifier.NewVerifier(cfg.Issuer(), ring, denylist)
+
+	clients, err := db.NewClientRepository(users.(db.Database).DB())
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	groups, err := db.NewGroupRepository(users.(db.Database).DB())
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	tenants, err := db.NewTenantRepository(users.(db.Database).DB())
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	cfg.Clients = []conf.Client{
+		{ID: "gateway", Secret: "gateway_secret"},
+		{
+			ID:           "console",
+			RedirectURIs: []string{"https://console.example.com/callback"},
+			GrantTypes:   []string{"authorization_code", "refresh_token"},
+			Scopes:       []string{"openid", "profile", "email"},
+		},
+	}
+
+	if err := persistence.SeedClients(clients, cfg.Clients); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	p, err := policy.NewRegoPolicy(context.TODO(), "../policy")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	codes := oidc.NewCodes(oidc.NewInMemStore(), time.Minute, cfg.Name)
+
+	mailer := inmem.NewInMemMailer(cfg.Mail)
+	attempts := throttle.NewTracker(throttle.NewInMemStore(), cfg.Throttle, cfg.Name)
+	leases := lease.NewInMemStore()
+
+	providers, err := social.NewRegistry(cfg.Providers)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.svc = identity.NewService(users, tokens, clients, groups, tenants, denylist, v, p, codes, mailer, providers, attempts, leases, cfg)
+	suite.cfg = cfg
 	suite.users = users
+	suite.tokens = tokens
+	suite.clients = clients
+	suite.groups = groups
+	suite.tenants = tenants
+	suite.denied = denylist
+	suite.ring = ring
+	suite.policy = p
+	suite.codes = codes
+	suite.mailer = mailer
+	suite.providers = providers
+	suite.leases = leases
 }
 
 func (suite *identityTestSuite) TestRegister() {
-	u, err := suite.svc.Register("user01", "User01", "user01@example.com")
+	u, err := suite.svc.Register(tenant.Default, "user01", "User01", "user01@example.com", "")
 	if err != nil {
 		suite.Fail(err.Error())
 		return
@@ -54,11 +179,13 @@ func (suite *identityTestSuite) TestRegister() {
 	suite.Equal("user01@example.com", u.Email)
 	suite.Equal(user.Registered, u.Status)
 
+	// the code is issued once the registration is handled
+	suite.Len(u.Events(), 1)
 	suite.Equal(user.UserRegistered.String(), u.Events()[0].EventName())
 }
 
 func (suite *identityTestSuite) TestRegisterAndVerify() {
-	u, err := suite.svc.Register("user02", "User02", "user02@example.com")
+	u, err := suite.svc.Register(tenant.Default, "user02", "User02", "user02@example.com", "")
 	if err != nil {
 		suite.Fail(err.Error())
 		return
@@ -69,12 +196,42 @@ func (suite *identityTestSuite) TestRegisterAndVerify() {
 	suite.Equal(user.Registered, u.Status)
 	suite.Equal(user.UserRegistered.String(), u.Events()[0].EventName())
 
+	totp := otp.NewTOTP(suite.cfg.OTP)
+
+	secret, err := otp.GenerateSecret()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	sealed, err := totp.Seal(secret, u.ID.String())
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// the events carry the sealed secret only
+	u.EnrollOTP(sealed)
+	suite.NotEqual(secret, u.PendingOTP.Secret)
+	suite.Nil(u.OTP)
+
 	if err := suite.users.Store(u); err != nil {
 		suite.Fail(err.Error())
 		return
 	}
 
-	u, err = suite.svc.OTPVerify("TODO", u.ID)
+	_, err = suite.svc.OTPVerify(context.TODO(), "000000", u.ID)
+	suite.Error(err)
+
+	step := totp.Step(time.Now())
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// the first code puts the pending secret in use
+	u, err = suite.svc.OTPVerify(context.TODO(), code, u.ID)
 	if err != nil {
 		suite.Fail(err.Error())
 		return
@@ -83,11 +240,2105 @@ func (suite *identityTestSuite) TestRegisterAndVerify() {
 	suite.Equal("user02", u.Username)
 	suite.Equal("user02@example.com", u.Email)
 	suite.Equal(user.Activated, u.Status)
-	suite.Equal(user.UserActivated.String(), u.Events()[0].EventName())
+	suite.Nil(u.PendingOTP)
+	suite.NotNil(u.OTP)
+	suite.Equal(user.UserOTPConfirmed.String(), u.Events()[0].EventName())
+	suite.Equal(user.UserActivated.String(), u.Events()[1].EventName())
+
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// the secret in use is proved by a current code before re-enrolling
+	_, err = suite.svc.OTPEnroll("", u.ID)
+	suite.ErrorIs(err, otp.ErrInvalidCode)
+
+	code, err = totp.Code(secret, step+1)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	enrollment, err := suite.svc.OTPEnroll(code, u.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.NotEqual(secret, enrollment.Secret)
+}
+
+func (suite *identityTestSuite) TestRegisterAndVerifyEmailCode() {
+	sent := len(suite.mailer.Messages())
+
+	u, err := suite.svc.Register(tenant.Default, "user03", "User03", "user03@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// sent once the registration is handled
+	suite.Len(suite.mailer.Messages(), sent)
+
+	handler, err := suite.svc.Handler()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	e := u.Events()[0].(*user.UserRegisteredEvent)
+	if err := handler.UserRegisteredHandler(e); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// replayed
+	suite.NoError(handler.UserRegisteredHandler(e))
+	suite.Len(suite.mailer.Messages(), sent+1)
+
+	messages := suite.mailer.Messages()
+	msg := messages[len(messages)-1]
+	suite.Equal([]string{"user03@example.com"}, msg.To)
+
+	code := regexp.MustCompile(`\d{6}`).FindString(msg.Subject)
+	suite.Len(code, 6)
+
+	u, err = suite.svc.VerifyUser(context.TODO(), code, u.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(user.Activated, u.Status)
+	suite.Equal(user.UserVerificationCodeVerified.String(), u.Events()[0].EventName())
+	suite.Equal(user.UserActivated.String(), u.Events()[1].EventName())
+
+	// only the users registered are verified without signing in
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.VerifyUser(context.TODO(), code, u.ID)
+	suite.ErrorIs(err, user.ErrUserNotRegistered)
+}
+
+func (suite *identityTestSuite) TestSignInWithPassword() {
+	u, err := suite.svc.Register(tenant.Default, "user04", "User04", "user04@example.com", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.NotNil(u.Password)
+	suite.Equal(user.UserPasswordChanged.String(), u.Events()[1].EventName())
+
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.SignInWithPassword(context.TODO(), tenant.Default, "user04", "p@ssw0rd")
+	suite.ErrorIs(err, user.ErrUserNotActivated)
+
+	u.Activate()
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.SignInWithPassword(context.TODO(), tenant.Default, "user04", "password")
+	suite.ErrorIs(err, identity.ErrInvalidCredentials)
+
+	_, err = suite.svc.SignInWithPassword(context.TODO(), tenant.Default, "nobody", "p@ssw0rd")
+	suite.ErrorIs(err, identity.ErrInvalidCredentials)
+
+	u, err = suite.svc.SignInWithPassword(context.TODO(), tenant.Default, "user04", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal("user04", u.Username)
+}
+
+func (suite *identityTestSuite) TestLockAndRevoke() {
+	u, err := suite.svc.Register(tenant.Default, "user05", "User05", "user05@example.com", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u.Activate()
+	if err := u.Lock("suspicious activity"); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.SignInWithPassword(context.TODO(), tenant.Default, "user05", "p@ssw0rd")
+	suite.ErrorIs(err, user.ErrUserLocked)
+	suite.ErrorIs(suite.svc.CheckStatus(u.ID), user.ErrUserLocked)
+
+	u, err = suite.svc.UnlockUser(u.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(user.Activated, u.Status)
+	suite.Equal(user.UserUnlocked.String(), u.Events()[0].EventName())
+
+	if err := u.Revoke("terminated"); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.ErrorIs(suite.svc.CheckStatus(u.ID), user.ErrUserRevoked)
+
+	_, err = suite.svc.UnlockUser(u.ID)
+	suite.ErrorIs(err, user.ErrUserNotLocked)
+}
+
+func (suite *identityTestSuite) TestRoles() {
+	u, err := suite.svc.Register(tenant.Default, "user12", "User12", "user12@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal([]string{user.RoleUser}, u.Roles)
+
+	u.Activate()
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	handler, err := suite.svc.Handler()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u, err = suite.svc.GrantRole(u.ID, "support")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	e, ok := u.Events()[0].(*user.UserRoleGrantedEvent)
+	if !ok {
+		suite.Fail("invalid event")
+		return
+	}
+
+	suite.Equal("support", e.Role)
+
+	if err := handler.UserRoleGrantedHandler(e); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.GrantRole(u.ID, "support")
+	suite.ErrorIs(err, user.ErrRoleGranted)
+
+	_, err = suite.svc.GrantRole(u.ID, "users.>")
+	suite.ErrorIs(err, user.ErrInvalidRole)
+
+	found, err := suite.users.Find(u.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal([]string{user.RoleUser, "support"}, found.Roles)
+
+	u, err = suite.svc.RevokeRole(u.ID, "support")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := handler.UserRoleRevokedHandler(u.Events()[0].(*user.UserRoleRevokedEvent)); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.RevokeRole(u.ID, user.RoleAdmin)
+	suite.ErrorIs(err, user.ErrRoleNotGranted)
+
+	found, err = suite.users.Find(u.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal([]string{user.RoleUser}, found.Roles)
+}
+
+func (suite *identityTestSuite) TestGrantAdminRole() {
+	u, err := suite.svc.Register(tenant.Default, "user29", "User29", "user29@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// the users not activated are not granted
+	_, err = grantAdminRole(suite.users, u.ID)
+	suite.ErrorIs(err, user.ErrUserNotActivated)
+
+	u.Activate()
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if _, err := grantAdminRole(suite.users, u.ID); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	found, err := suite.users.Find(u.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(found.HasRole(user.RoleAdmin))
+
+	_, err = grantAdminRole(suite.users, u.ID)
+	suite.ErrorIs(err, user.ErrRoleGranted)
+}
+
+func (suite *identityTestSuite) TestGroups() {
+	u, err := suite.svc.Register(tenant.Default, "user13", "User13", "user13@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u.Activate()
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	handler, err := suite.svc.Handler()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	parent, err := suite.svc.CreateGroup("Engineering", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := handler.GroupCreatedHandler(parent.Events()[0].(*group.GroupCreatedEvent)); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	child, err := suite.svc.CreateGroup("Platform", parent.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := handler.GroupCreatedHandler(child.Events()[0].(*group.GroupCreatedEvent)); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.UpdateGroup(parent.ID, "Engineering", child.ID)
+	suite.ErrorIs(err, group.ErrCyclicGroup)
+
+	_, err = suite.svc.CreateGroup("Orphan", group.MakeID())
+	suite.ErrorIs(err, group.ErrParentNotFound)
+
+	child, err = suite.svc.AddGroupMember(child.ID, u.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := handler.GroupMemberAddedHandler(child.Events()[0].(*group.GroupMemberAddedEvent)); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.AddGroupMember(child.ID, u.ID)
+	suite.ErrorIs(err, group.ErrMemberExists)
+
+	_, err = suite.svc.AddGroupMember(child.ID, user.MakeID())
+	suite.ErrorIs(err, user.ErrUserNotFound)
+
+	parent, err = suite.svc.GrantGroupRole(parent.ID, "support")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := handler.GroupRoleGrantedHandler(parent.Events()[0].(*group.GroupRoleGrantedEvent)); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// the member of the subgroup is a member of the parent
+	groups, err := suite.svc.Memberships(u.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	ids := make([]group.GroupID, 0)
+	for _, g := range groups {
+		ids = append(ids, g.ID)
+	}
+
+	suite.ElementsMatch([]group.GroupID{child.ID, parent.ID}, ids)
+	for _, g := range groups {
+		if g.ID == parent.ID {
+			suite.Equal([]string{"support"}, g.Roles)
+		}
+	}
+
+	suite.ErrorIs(suite.svc.DeleteGroup(parent.ID), group.ErrHasSubgroups)
+}
+
+func (suite *identityTestSuite) TestTenants() {
+	handler, err := suite.svc.Handler()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	acme, err := suite.svc.CreateTenant("acme", "ACME")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := handler.TenantCreatedHandler(acme.Events()[0].(*tenant.TenantCreatedEvent)); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.CreateTenant("acme", "ACME")
+	suite.ErrorIs(err, tenant.ErrTenantExists)
+
+	_, err = suite.svc.CreateTenant(tenant.Default, "Default")
+	suite.ErrorIs(err, tenant.ErrTenantExists)
+
+	suite.ErrorIs(suite.svc.DeleteTenant(tenant.Default), tenant.ErrDefaultTenant)
+
+	u, err := suite.svc.Register(tenant.Default, "user14", "User14", "user14@example.com", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u.Activate()
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.Register(tenant.Default, "user14", "User14", "", "")
+	suite.ErrorIs(err, identity.ErrUserExists)
+
+	_, err = suite.svc.Register(tenant.Default, "user15", "User15", "user14@example.com", "")
+	suite.ErrorIs(err, identity.ErrEmailExists)
+
+	_, err = suite.svc.Register("nowhere", "user14", "User14", "", "")
+	suite.ErrorIs(err, tenant.ErrTenantNotFound)
+
+	// the usernames are unique per tenant
+	other, err := suite.svc.Register("acme", "user14", "User14", "user14@example.com", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	other.Activate()
+	if err := suite.users.Store(other); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	signedIn, err := suite.svc.SignInWithPassword(context.TODO(), "acme", "user14", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(other.ID, signedIn.ID)
+	suite.Equal(tenant.TenantID("acme"), signedIn.Token.TenantID)
+
+	signedIn, err = suite.svc.SignInWithPassword(context.TODO(), "", "user14", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(u.ID, signedIn.ID)
+	suite.Equal(tenant.Default, signedIn.Token.TenantID)
+
+	// the username of the user is taken in acme
+	_, err = suite.svc.JoinTenant("acme", u.ID)
+	suite.ErrorIs(err, identity.ErrUserExists)
+
+	_, err = suite.svc.JoinTenant(tenant.Default, other.ID)
+	suite.ErrorIs(err, identity.ErrUserExists)
+
+	other, err = suite.svc.GrantTenantRole("acme", other.ID, "admin")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := handler.UserTenantRoleGrantedHandler(other.Events()[0].(*user.UserTenantRoleGrantedEvent)); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.GrantTenantRole(tenant.Default, other.ID, "admin")
+	suite.ErrorIs(err, user.ErrTenantNotJoined)
+
+	other, err = suite.users.Find(other.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Contains(other.RolesIn("acme"), "admin")
+	suite.NotContains(other.RolesIn(tenant.Default), "admin")
+
+	tenants, err := suite.svc.Tenants(other.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal([]tenant.TenantID{"acme"}, tenants)
+}
+
+func (suite *identityTestSuite) TestListUsers() {
+	ids := make([]user.UserID, 3)
+	for i := range ids {
+		username := fmt.Sprintf("user%02d", 16+i)
+
+		u, err := suite.svc.Register(tenant.Default, username, "Lister", username+"@example.com", "")
+		if err != nil {
+			suite.Fail(err.Error())
+			return
+		}
+
+		if err := suite.users.Store(u); err != nil {
+			suite.Fail(err.Error())
+			return
+		}
+
+		ids[i] = u.ID
+	}
+
+	page, err := suite.svc.ListUsers(user.Query{Text: "lister", Limit: 2})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Len(page.Users, 2)
+	suite.Equal(ids[1].String(), page.Next)
+
+	after, err := user.ParseID(page.Next)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	page, err = suite.svc.ListUsers(user.Query{Text: "lister", After: &after, Limit: 2})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Len(page.Users, 1)
+	suite.Equal(ids[2], page.Users[0].ID)
+	suite.Empty(page.Next)
+}
+
+func (suite *identityTestSuite) TestUpdateProfile() {
+	u, err := suite.svc.Register(tenant.Default, "user19", "User19", "user19@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	name := "User 19"
+	avatar := "https://example.com/user19.png"
+
+	u, err = suite.svc.UpdateProfile(u.ID, user.Profile{Name: &name, Avatar: &avatar})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	handler, err := suite.svc.Handler()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := handler.UserProfileUpdatedHandler(u.Events()[0].(*user.UserProfileUpdatedEvent)); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u, err = suite.users.Find(u.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(name, u.Name)
+	suite.Equal(avatar, u.Avatar)
+	suite.True(u.ProfileEdited)
+
+	empty := ""
+	_, err = suite.svc.UpdateProfile(u.ID, user.Profile{Name: &empty})
+	suite.ErrorIs(err, user.ErrNameEmpty)
+}
+
+func (suite *identityTestSuite) TestChangeUsername() {
+	u, err := suite.svc.Register(tenant.Default, "user22", "User22", "user22@example.com", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u.Activate()
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u, err = suite.svc.ChangeUsername(u.ID, "user22a")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	handler, err := suite.svc.Handler()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := handler.UserUsernameChangedHandler(u.Events()[0].(*user.UserUsernameChangedEvent)); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u, err = suite.svc.SignInWithPassword(context.TODO(), tenant.Default, "user22a", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal("user22a", u.Username)
+
+	_, err = suite.svc.SignInWithPassword(context.TODO(), tenant.Default, "user22", "p@ssw0rd")
+	suite.ErrorIs(err, identity.ErrInvalidCredentials)
+
+	// the old username is held from the others in the cool-down
+	_, err = suite.svc.Register(tenant.Default, "user22", "User22", "user22@example.org", "")
+	suite.ErrorIs(err, user.ErrUsernameReserved)
+
+	_, err = suite.svc.Register(tenant.Default, "user22a", "User22", "user22@example.org", "")
+	suite.ErrorIs(err, identity.ErrUserExists)
+
+	// but not from the user
+	u, err = suite.svc.ChangeUsername(u.ID, "user22")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal("user22", u.Username)
+}
+
+// the instances apply the registration of another after its event, the
+// username is claimed across them in between
+func (suite *identityTestSuite) TestReserveUsernameAcrossInstances() {
+	users, err := persistence.NewUserRepository(conf.Persistence{Driver: conf.InMem})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	other := identity.NewService(users, suite.tokens, suite.clients, suite.groups, suite.tenants, suite.denied, verifier.NewVerifier(suite.cfg.Issuer(), suite.ring, suite.denied), suite.policy, suite.codes, suite.mailer, suite.providers, throttle.NewTracker(throttle.NewInMemStore(), suite.cfg.Throttle, suite.cfg.Name), suite.leases, suite.cfg)
+
+	_, err = suite.svc.Register(tenant.Default, "user30", "User30", "user30@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = other.Register(tenant.Default, "user30", "User30", "user30@example.org", "")
+	suite.ErrorIs(err, user.ErrUsernameReserved)
+}
+
+// a rename failing in a later tenant releases the usernames claimed in the
+// earlier ones
+func (suite *identityTestSuite) TestChangeUsernameReleased() {
+	handler, err := suite.svc.Handler()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	globex, err := suite.svc.CreateTenant("globex", "Globex")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := handler.TenantCreatedHandler(globex.Events()[0].(*tenant.TenantCreatedEvent)); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	taken, err := suite.svc.Register("globex", "user35", "User35", "user35@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.users.Store(taken); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u, err := suite.svc.Register(tenant.Default, "user34", "User34", "user34@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u, err = suite.svc.JoinTenant("globex", u.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := handler.UserTenantJoinedHandler(u.Events()[0].(*user.UserTenantJoinedEvent)); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.ChangeUsername(u.ID, "user35")
+	suite.ErrorIs(err, identity.ErrUserExists)
+
+	users, err := persistence.NewUserRepository(conf.Persistence{Driver: conf.InMem})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	other := identity.NewService(users, suite.tokens, suite.clients, suite.groups, suite.tenants, suite.denied, verifier.NewVerifier(suite.cfg.Issuer(), suite.ring, suite.denied), suite.policy, suite.codes, suite.mailer, suite.providers, throttle.NewTracker(throttle.NewInMemStore(), suite.cfg.Throttle, suite.cfg.Name), suite.leases, suite.cfg)
+
+	_, err = other.Register(tenant.Default, "user35", "User35", "user35@example.org", "")
+	suite.NoError(err)
+}
+
+func (suite *identityTestSuite) TestDeleteUser() {
+	u, err := suite.svc.Register(tenant.Default, "user23", "User23", "user23@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.svc.DeleteUser(u.ID); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	handler, err := suite.svc.Handler()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// the event published by DeleteUser
+	e := user.NewUserDeletedEvent(u).(*user.UserDeletedEvent)
+	if err := handler.UserDeletedHandler(e); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// replayed
+	suite.NoError(handler.UserDeletedHandler(e))
+
+	err = suite.svc.DeleteUser(u.ID)
+	suite.ErrorIs(err, user.ErrUserNotFound)
+
+	// the email is free, the username is held for the cool-down
+	_, err = suite.svc.Register(tenant.Default, "user23", "User23", "user23@example.org", "")
+	suite.ErrorIs(err, user.ErrUsernameReserved)
+
+	_, err = suite.svc.Register(tenant.Default, "user24", "User24", "user23@example.com", "")
+	suite.NoError(err)
+
+	// purged after the retention
+	ids, err := suite.svc.PurgeUsers()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.NotContains(ids, u.ID)
+}
+
+// the families and the memberships of the purged users go along
+func (suite *identityTestSuite) TestPurgeUsers() {
+	u, err := suite.svc.Register(tenant.Default, "user33", "User33", "user33@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	g, err := group.NewGroup("Purged", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	g.AddMember(u.ID)
+	if err := suite.groups.Store(g); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	f, _, err := token.Issue(u.ID, tenant.Default, token.Grant{}, time.Hour)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.tokens.Store(f); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u.Delete()
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	cfg := *suite.cfg
+	cfg.Deletion.Retention = 0
+
+	svc := identity.NewService(suite.users, suite.tokens, suite.clients, suite.groups, suite.tenants, suite.denied, verifier.NewVerifier(suite.cfg.Issuer(), suite.ring, suite.denied), suite.policy, suite.codes, suite.mailer, suite.providers, throttle.NewTracker(throttle.NewInMemStore(), suite.cfg.Throttle, suite.cfg.Name), suite.leases, &cfg)
+
+	ids, err := svc.PurgeUsers()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Contains(ids, u.ID)
+
+	_, err = suite.tokens.Find(f.ID)
+	suite.ErrorIs(err, token.ErrFamilyNotFound)
+
+	g, err = suite.groups.Find(g.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.NotContains(g.Members, u.ID)
+}
+
+func (suite *identityTestSuite) TestChangeEmail() {
+	u, err := suite.svc.Register(tenant.Default, "user20", "User20", "user20@example.com", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u.Activate()
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	other, err := suite.svc.Register(tenant.Default, "user21", "User21", "user21@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.users.Store(other); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.RequestEmailChange(u.ID, other.Email)
+	suite.ErrorIs(err, identity.ErrEmailExists)
+
+	u, err = suite.svc.RequestEmailChange(u.ID, "user20@example.org")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	handler, err := suite.svc.Handler()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := handler.UserEmailChangeRequestedHandler(u.Events()[0].(*user.UserEmailChangeRequestedEvent)); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// the code to the new email, the notice to the old one
+	messages := suite.mailer.Messages()
+	suite.Equal([]string{"user20@example.org"}, messages[len(messages)-2].To)
+	suite.Equal([]string{"user20@example.com"}, messages[len(messages)-1].To)
+
+	code := regexp.MustCompile(`\d{6}`).FindString(messages[len(messages)-2].Subject)
+	suite.Len(code, 6)
+
+	// the old email signs in until the change is confirmed
+	_, err = suite.svc.SignInWithPassword(context.TODO(), tenant.Default, "user20@example.com", "p@ssw0rd")
+	suite.NoError(err)
+
+	_, err = suite.svc.SignInWithPassword(context.TODO(), tenant.Default, "user20@example.org", "p@ssw0rd")
+	suite.ErrorIs(err, identity.ErrInvalidCredentials)
+
+	u, err = suite.svc.ConfirmEmailChange(context.TODO(), code, u.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := handler.UserEmailChangedHandler(u.Events()[0].(*user.UserEmailChangedEvent)); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u, err = suite.svc.SignInWithPassword(context.TODO(), tenant.Default, "user20@example.org", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal("user20", u.Username)
+	suite.Nil(u.EmailChange)
+
+	_, err = suite.svc.SignInWithPassword(context.TODO(), tenant.Default, "user20@example.com", "p@ssw0rd")
+	suite.ErrorIs(err, identity.ErrInvalidCredentials)
+
+	_, err = suite.svc.ConfirmEmailChange(context.TODO(), code, u.ID)
+	suite.ErrorIs(err, user.ErrEmailChangeNotFound)
+}
+
+func (suite *identityTestSuite) TestRemoveSocialAccount() {
+	u, err := suite.svc.Register(tenant.Default, "user25", "User25", "user25@example.com", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u.AddSocialAccount(user.GOOGLE, "user25")
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.RemoveSocialAccount(u.ID, user.GOOGLE, "unknown")
+	suite.ErrorIs(err, user.ErrSocialAccountNotFound)
+
+	u, err = suite.svc.RemoveSocialAccount(u.ID, user.GOOGLE, "user25")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	handler, err := suite.svc.Handler()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	e := u.Events()[0].(*user.UserSocialAccountRemovedEvent)
+	if err := handler.UserSocialAccountRemovedHandler(e); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// replayed
+	suite.NoError(handler.UserSocialAccountRemovedHandler(e))
+
+	_, err = suite.users.FindBySocialID(tenant.Default, "user25")
+	suite.ErrorIs(err, user.ErrUserNotFound)
+
+	u, err = suite.users.Find(u.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Empty(u.Accounts)
+
+	// the social account is the only credential of the user
+	other, err := suite.svc.Register(tenant.Default, "user26", "User26", "user26@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	other.AddSocialAccount(user.GOOGLE, "user26")
+	if err := suite.users.Store(other); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.RemoveSocialAccount(other.ID, user.GOOGLE, "user26")
+	suite.ErrorIs(err, user.ErrLastCredential)
+}
+
+func (suite *identityTestSuite) TestAddSocialAccount() {
+	providers, err := social.NewRegistry(suite.cfg.Providers)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	providers.Register(user.LINE, stubVerifier{
+		"user27": {SocialID: "line27"},
+		"user28": {SocialID: "line28", Email: "user28@example.com", Name: "User28"},
+	})
+
+	svc := identity.NewService(suite.users, suite.tokens, suite.clients, suite.groups, suite.tenants, suite.denied, verifier.NewVerifier(suite.cfg.Issuer(), suite.ring, suite.denied), suite.policy, suite.codes, suite.mailer, providers, throttle.NewTracker(throttle.NewInMemStore(), suite.cfg.Throttle, suite.cfg.Name), suite.leases, suite.cfg)
+
+	u, err := svc.Register(tenant.Default, "user27", "User27", "user27@example.com", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = svc.AddSocialAccount("user27", user.FACEBOOK, u.ID)
+	suite.ErrorIs(err, identity.ErrProviderNotSupported)
+
+	_, err = svc.AddSocialAccount("unknown", user.LINE, u.ID)
+	suite.Error(err)
+
+	u, err = svc.AddSocialAccount("user27", user.LINE, u.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// linked to the provider requested
+	suite.Len(u.Accounts, 1)
+	suite.Equal(user.LINE, u.Accounts[0].Provider)
+	suite.Equal(user.SocialID("line27"), u.Accounts[0].SocialID)
+
+	// registered by the provider
+	u, err = svc.SignIn(tenant.Default, "user28", user.LINE)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal("user28", u.Username)
+	suite.Equal(user.Activated, u.Status)
+	suite.Equal(user.LINE, u.Accounts[0].Provider)
+
+	_, err = svc.SignIn(tenant.Default, "user28", user.FACEBOOK)
+	suite.ErrorIs(err, identity.ErrProviderNotSupported)
+}
+
+// stubVerifier verifies the credentials by the identities it holds.
+type stubVerifier map[string]*social.Identity
+
+func (v stubVerifier) Verify(ctx context.Context, credential string) (*social.Identity, error) {
+	identity, ok := v[credential]
+	if !ok {
+		return nil, errors.New("invalid credential")
+	}
+
+	return identity, nil
+}
+
+func (suite *identityTestSuite) TestBruteForceLockout() {
+	cfg := conf.Throttle{
+		Window:    time.Minute,
+		Threshold: 3,
+		Backoff: conf.Backoff{
+			Base: time.Minute,
+			Max:  time.Hour,
+		},
+		LockAfter: 3,
+	}
+
+	attempts := throttle.NewTracker(throttle.NewInMemStore(), cfg, "test")
+	svc := identity.NewService(suite.users, suite.tokens, suite.clients, suite.groups, suite.tenants, suite.denied, verifier.NewVerifier(suite.cfg.Issuer(), suite.ring, suite.denied), suite.policy, suite.codes, suite.mailer, suite.providers, attempts, suite.leases, suite.cfg)
+
+	u, err := svc.Register(tenant.Default, "user06", "User06", "user06@example.com", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u.Activate()
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	info := &identity.RequestInfo{ClientIP: "192.0.2.1"}
+	ctx := context.WithValue(context.Background(), model.REQUEST_INFO, info)
+
+	for i := 0; i < 3; i++ {
+		_, err = svc.SignInWithPassword(ctx, tenant.Default, "user06", "wrong")
+		suite.ErrorIs(err, identity.ErrInvalidCredentials)
+	}
+
+	// the client IP backs off, the user is never locked by anyone unknown
+	_, err = svc.SignInWithPassword(ctx, tenant.Default, "user06", "p@ssw0rd")
+	suite.ErrorIs(err, throttle.ErrTooManyAttempts)
+
+	// nor guessed from the other client IPs
+	other := &identity.RequestInfo{ClientIP: "192.0.2.2"}
+	otherCtx := context.WithValue(context.Background(), model.REQUEST_INFO, other)
+
+	_, err = svc.SignInWithPassword(otherCtx, tenant.Default, "user06", "p@ssw0rd")
+	suite.ErrorIs(err, throttle.ErrTooManyAttempts)
+
+	u, err = suite.users.Find(u.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(user.Activated, u.Status)
+
+	// the step-up of the user signed in locks the user
+	totp := otp.NewTOTP(suite.cfg.OTP)
+
+	secret, err := otp.GenerateSecret()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	sealed, err := totp.Seal(secret, u.ID.String())
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u.EnrollOTP(sealed)
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = svc.OTPVerify(ctx, "000000", u.ID)
+		suite.Error(err)
+	}
+
+	_, err = svc.OTPVerify(ctx, "000000", u.ID)
+	suite.ErrorIs(err, user.ErrUserLocked)
+}
+
+func (suite *identityTestSuite) TestChangePassword() {
+	u, err := suite.svc.Register(tenant.Default, "user31", "User31", "user31@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u.Activate()
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// the first password is set by a recent sign-in only
+	_, err = suite.svc.ChangePassword(u.ID, time.Time{}, "", "p@ssw0rd")
+	suite.ErrorIs(err, identity.ErrReauthRequired)
+
+	_, err = suite.svc.ChangePassword(u.ID, time.Now().Add(-time.Hour), "", "p@ssw0rd")
+	suite.ErrorIs(err, identity.ErrReauthRequired)
+
+	u, err = suite.svc.ChangePassword(u.ID, time.Now(), "", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// then by the old one
+	_, err = suite.svc.ChangePassword(u.ID, time.Now(), "", "n3w_p@ssw0rd")
+	suite.ErrorIs(err, identity.ErrInvalidCredentials)
+
+	_, err = suite.svc.ChangePassword(u.ID, time.Time{}, "p@ssw0rd", "n3w_p@ssw0rd")
+	suite.NoError(err)
+}
+
+func (suite *identityTestSuite) TestRefreshToken() {
+	u, err := suite.svc.Register(tenant.Default, "user07", "User07", "user07@example.com", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u.Activate()
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u, err = suite.svc.SignInWithPassword(context.TODO(), tenant.Default, "user07", "p@ssw0rd")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.NotEmpty(u.Token.RefreshToken)
+
+	f, refreshToken, err := token.Issue(u.ID, tenant.Default, token.Grant{}, time.Hour)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.tokens.Store(f); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	granted, err := suite.svc.RefreshToken("", "", refreshToken)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u = granted.User
+	suite.Equal("user07", u.Username)
+	suite.NotEqual(refreshToken, u.Token.RefreshToken)
+
+	_, err = suite.svc.RefreshToken("", "", "invalid")
+	suite.ErrorIs(err, token.ErrInvalidToken)
+
+	// the families of the clients are refreshed by the clients only
+	g := token.Grant{
+		ClientID: "console",
+		Scope:    []string{"openid", "email"},
+		AuthTime: time.Now().Add(-time.Minute),
+		AMR:      []string{"pwd"},
+	}
+
+	f, clientToken, err := token.Issue(u.ID, tenant.Default, g, time.Hour)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.tokens.Store(f); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.RefreshToken("", "", clientToken)
+	suite.ErrorIs(err, identity.ErrInvalidClient)
+
+	_, err = suite.svc.RefreshToken("gateway", "gateway_secret", clientToken)
+	suite.ErrorIs(err, token.ErrInvalidToken)
+
+	granted, err = suite.svc.RefreshToken("console", "", clientToken)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal("console", granted.Client.ID.String())
+	suite.Equal(g.Scope, granted.Grant.Scope)
+	suite.Equal(g.AMR, granted.Grant.AMR)
+	suite.Equal(g.AuthTime.Unix(), granted.Grant.AuthTime.Unix())
+
+	u.Revoke("test")
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// rotated by the first refresh
+	_, err = suite.svc.RefreshToken("", "", u.Token.RefreshToken)
+	suite.ErrorIs(err, user.ErrUserRevoked)
+}
+
+// the rotation is stored before the next token is handed out, the same
+// token is rotated once by any instance
+func (suite *identityTestSuite) TestRefreshTokenOnce() {
+	u, err := suite.svc.Register(tenant.Default, "user32", "User32", "user32@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u.Activate()
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	f, refreshToken, err := token.Issue(u.ID, tenant.Default, token.Grant{}, time.Hour)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.tokens.Store(f); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// another instance whose store is yet to apply the rotation
+	tokens, err := persistence.NewTokenRepository(conf.Persistence{Driver: conf.InMem}, nil)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := tokens.Store(f); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	other := identity.NewService(suite.users, tokens, suite.clients, suite.groups, suite.tenants, suite.denied, verifier.NewVerifier(suite.cfg.Issuer(), suite.ring, suite.denied), suite.policy, suite.codes, suite.mailer, suite.providers, throttle.NewTracker(throttle.NewInMemStore(), suite.cfg.Throttle, suite.cfg.Name), suite.leases, suite.cfg)
+
+	granted, err := suite.svc.RefreshToken("", "", refreshToken)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	next := granted.User.Token.RefreshToken
+
+	stored, err := suite.tokens.Find(f.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(token.Hash(next), stored.Hash)
+
+	_, err = other.RefreshToken("", "", refreshToken)
+	suite.ErrorIs(err, token.ErrTokenReused)
+
+	_, err = suite.svc.RefreshToken("", "", refreshToken)
+	suite.ErrorIs(err, token.ErrTokenReused)
+
+	// the rotation replayed by the event bus is applied once
+	handler, err := suite.svc.Handler()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	e := token.NewFamilyRotatedEvent(stored, stored.Hash).(*token.FamilyRotatedEvent)
+	if err := handler.FamilyRotatedHandler(e); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	stored, err = suite.tokens.Find(f.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Len(stored.Used, 1)
+}
+
+func (suite *identityTestSuite) TestRevokeToken() {
+	now := time.Now()
+	claims := verifier.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    suite.cfg.Issuer(),
+			Subject:   user.MakeID().String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        ulid.Make().String(),
+		},
+	}
+
+	tokenStr, err := suite.ring.SignAccessToken(claims)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// the tokens of the first party are revoked without a client
+	err = suite.svc.RevokeToken("gateway", "gateway_secret", tokenStr)
+	suite.ErrorIs(err, identity.ErrTokenNotIssued)
+
+	if err := suite.svc.RevokeToken("", "", tokenStr); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// the tokens of a client are revoked by the client only
+	clientClaims := claims
+	clientClaims.ID = ulid.Make().String()
+	clientClaims.Audience = jwt.ClaimStrings{"console"}
+	clientClaims.ClientID = "console"
+
+	clientToken, err := suite.ring.SignAccessToken(clientClaims)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	err = suite.svc.RevokeToken("", "", clientToken)
+	suite.ErrorIs(err, identity.ErrInvalidClient)
+
+	err = suite.svc.RevokeToken("gateway", "wrong", clientToken)
+	suite.ErrorIs(err, identity.ErrInvalidClient)
+
+	err = suite.svc.RevokeToken("gateway", "gateway_secret", clientToken)
+	suite.ErrorIs(err, identity.ErrTokenNotIssued)
+
+	suite.NoError(suite.svc.RevokeToken("console", "", clientToken))
+
+	// replicated by the event bus
+	handler, err := suite.svc.Handler()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	jti := claims.ID
+	expiredAt := claims.ExpiresAt.Time
+
+	e := token.NewRevocation(jti, expiredAt).Events()[0].(*token.TokenDeniedEvent)
+	if err := handler.TokenDeniedHandler(e); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(suite.denied.Denied(jti, time.Now()))
+	suite.False(suite.denied.Denied(jti, expiredAt))
+	suite.False(suite.denied.Denied(ulid.Make().String(), time.Now()))
+
+	// the denied token is ignored
+	suite.NoError(suite.svc.RevokeToken("gateway", "gateway_secret", tokenStr))
+}
+
+func (suite *identityTestSuite) TestRevokeRefreshToken() {
+	f, refreshToken, err := token.Issue(user.MakeID(), tenant.Default, token.Grant{}, time.Hour)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.tokens.Store(f); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// the unknown tokens are ignored
+	suite.NoError(suite.svc.RevokeToken("", "", "invalid"))
+	suite.NoError(suite.svc.RevokeToken("", "", f.ID.String()+".invalid"))
+
+	err = suite.svc.RevokeToken("gateway", "gateway_secret", refreshToken)
+	suite.ErrorIs(err, identity.ErrTokenNotIssued)
+
+	suite.NoError(suite.svc.RevokeToken("", "", refreshToken))
+
+	// the families of the clients are revoked by the clients only
+	f, clientToken, err := token.Issue(user.MakeID(), tenant.Default, token.Grant{ClientID: "console"}, time.Hour)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.tokens.Store(f); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	err = suite.svc.RevokeToken("", "", clientToken)
+	suite.ErrorIs(err, identity.ErrInvalidClient)
+
+	err = suite.svc.RevokeToken("gateway", "gateway_secret", clientToken)
+	suite.ErrorIs(err, identity.ErrTokenNotIssued)
+
+	suite.NoError(suite.svc.RevokeToken("console", "", clientToken))
+}
+
+func (suite *identityTestSuite) TestIntrospect() {
+	u, err := suite.svc.Register(tenant.Default, "user08", "User08", "user08@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u.Activate()
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	now := time.Now()
+	claims := verifier.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    suite.cfg.Issuer(),
+			Subject:   u.ID.String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        ulid.Make().String(),
+		},
+		Roles: []string{"user"},
+	}
+
+	tokenStr, err := suite.ring.SignAccessToken(claims)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.Introspect("gateway", "wrong", tokenStr)
+	suite.ErrorIs(err, identity.ErrInvalidClient)
+
+	result, err := suite.svc.Introspect("gateway", "gateway_secret", tokenStr)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(result.Active)
+	suite.Equal("user08", result.Username)
+	suite.Equal(user.Activated.String(), result.Status)
+	suite.Equal(claims.ID, result.TokenID)
+
+	result, err = suite.svc.Introspect("gateway", "gateway_secret", "invalid")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(result.Active)
+
+	// the locked user
+	u.Lock("test")
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	result, err = suite.svc.Introspect("gateway", "gateway_secret", tokenStr)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(result.Active)
+	suite.Empty(result.Username)
+}
+
+func (suite *identityTestSuite) TestAuthorizationCode() {
+	u, err := suite.svc.Register(tenant.Default, "user09", "User09", "user09@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u.Activate()
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	now := time.Now()
+	claims := &verifier.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:  u.ID.String(),
+			IssuedAt: jwt.NewNumericDate(now),
+		},
+		AuthTime: jwt.NewNumericDate(now.Add(-time.Minute)),
+		AMR:      []string{"pwd"},
+	}
+
+	codeVerifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	req := &oidc.AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            "console",
+		RedirectURI:         "https://console.example.com/callback",
+		Scope:               "openid email",
+		Nonce:               "n-0S6_WzA2Mj",
+		CodeChallenge:       oidc.Challenge(codeVerifier),
+		CodeChallengeMethod: "S256",
+	}
+
+	_, err = suite.svc.Authorize(claims, &oidc.AuthorizationRequest{
+		ResponseType: "code",
+		ClientID:     "console",
+		RedirectURI:  "https://evil.example.com/callback",
+	})
+	suite.ErrorIs(err, oidc.ErrInvalidRedirectURI)
+
+	scoped := *req
+	scoped.Scope = "openid admin"
+	_, err = suite.svc.Authorize(claims, &scoped)
+	suite.ErrorIs(err, client.ErrScopeNotAllowed)
+
+	// an actor never authorizes on behalf of the subject
+	delegated := *claims
+	delegated.Act = &verifier.Actor{Subject: "support", TokenUse: verifier.TokenUseService}
+	_, err = suite.svc.Authorize(&delegated, req)
+	suite.ErrorIs(err, identity.ErrDelegatedToken)
+
+	code, err := suite.svc.Authorize(claims, req)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.ExchangeCode("gateway", "gateway_secret", code, req.RedirectURI, codeVerifier)
+	suite.ErrorIs(err, client.ErrGrantNotAllowed) // the gateway only introspects
+
+	_, err = suite.svc.ExchangeCode("console", "", code, "https://console.example.com/other", codeVerifier)
+	suite.ErrorIs(err, oidc.ErrCodeNotFound) // redirected elsewhere, and consumed
+
+	code, err = suite.svc.Authorize(claims, req)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	grant, err := suite.svc.ExchangeCode("console", "", code, req.RedirectURI, codeVerifier)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(u.ID, grant.User.ID)
+	suite.Equal("n-0S6_WzA2Mj", grant.Code.Nonce)
+	suite.Equal([]string{"pwd"}, grant.Code.AMR)
+	suite.Equal(claims.AuthTime.Unix(), grant.Code.AuthTime.Unix())
+
+	idClaims := oidc.NewIDClaims(suite.cfg.Issuer(), time.Hour, grant.Code, grant.User)
+	suite.Equal(jwt.ClaimStrings{"console"}, idClaims.Audience)
+	suite.Equal("user09@example.com", idClaims.Email)
+	suite.Empty(idClaims.Name) // profile not granted
+
+	_, err = suite.svc.ExchangeCode("console", "", code, req.RedirectURI, codeVerifier)
+	suite.ErrorIs(err, oidc.ErrCodeNotFound)
+
+	info, err := suite.svc.UserInfo(u.ID, oidc.ParseScope("openid profile"))
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(u.ID.String(), info.Subject)
+	suite.Equal("user09", info.PreferredUsername)
+	suite.Empty(info.Email)
+}
+
+func (suite *identityTestSuite) TestClients() {
+	registered, err := suite.svc.RegisterClient(client.Confidential, client.Settings{
+		Name:       "Reports",
+		GrantTypes: []string{"refresh_token"},
+		Scopes:     []string{"openid"},
+		Lifetimes: client.Lifetimes{
+			AccessToken: 5 * time.Minute,
+		},
+	})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.NotEmpty(registered.ClientSecret)
+
+	// replicated by the event bus, the hash of the secret as well
+	handler, err := suite.svc.Handler()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	data, err := json.Marshal(registered.Events()[0])
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	var e *client.ClientRegisteredEvent
+	if err := json.Unmarshal(data, &e); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := handler.ClientRegisteredHandler(e); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	c, err := suite.svc.FindClient(registered.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal("Reports", c.Name)
+	suite.Equal(5*time.Minute, c.Lifetimes.AccessToken)
+	suite.NoError(c.Authenticate(registered.ClientSecret))
+
+	_, err = suite.svc.Introspect(c.ID.String(), registered.ClientSecret, "invalid")
+	suite.NoError(err)
+
+	// public clients never introspect
+	_, err = suite.svc.Introspect("console", "", "invalid")
+	suite.ErrorIs(err, identity.ErrInvalidClient)
+
+	rotated, err := suite.svc.RotateClientSecret(c.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	err = handler.ClientSecretRotatedHandler(rotated.Events()[0].(*client.ClientSecretRotatedEvent))
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.Introspect(c.ID.String(), registered.ClientSecret, "invalid")
+	suite.ErrorIs(err, identity.ErrInvalidClient)
+
+	_, err = suite.svc.Introspect(c.ID.String(), rotated.ClientSecret, "invalid")
+	suite.NoError(err)
+
+	_, err = suite.svc.UpdateClient(c.ID, client.Settings{Name: "Reports", GrantTypes: []string{"password"}})
+	suite.ErrorIs(err, client.ErrGrantTypeUnsupported)
+
+	if err := suite.svc.DeleteClient(c.ID); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := handler.ClientDeletedHandler(&client.ClientDeletedEvent{
+		Event: &client.Event{ClientID: c.ID},
+	}); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.FindClient(c.ID)
+	suite.ErrorIs(err, client.ErrClientNotFound)
+
+	_, err = suite.svc.Introspect(c.ID.String(), rotated.ClientSecret, "invalid")
+	suite.ErrorIs(err, identity.ErrInvalidClient)
+
+	clients, err := suite.svc.ListClients()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	ids := make([]client.ClientID, len(clients))
+	for i, c := range clients {
+		ids[i] = c.ID
+	}
+
+	// seeded by the configuration
+	suite.Contains(ids, client.ClientID("gateway"))
+	suite.Contains(ids, client.ClientID("console"))
+	suite.NotContains(ids, registered.ID)
+}
+
+func (suite *identityTestSuite) TestClientCredentials() {
+	registered, err := suite.svc.RegisterClient(client.Confidential, client.Settings{
+		Name:       "Worker",
+		GrantTypes: []string{"client_credentials"},
+		Scopes:     []string{"reports.read", "reports.write"},
+		Roles:      []string{"worker"},
+	})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	handler, err := suite.svc.Handler()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	err = handler.ClientRegisteredHandler(registered.Events()[0].(*client.ClientRegisteredEvent))
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	id := registered.ID.String()
+
+	_, err = suite.svc.ClientCredentials(id, "wrong", nil)
+	suite.ErrorIs(err, identity.ErrInvalidClient)
+
+	_, err = suite.svc.ClientCredentials("console", "", nil)
+	suite.ErrorIs(err, identity.ErrInvalidClient) // public
+
+	_, err = suite.svc.ClientCredentials("gateway", "gateway_secret", nil)
+	suite.ErrorIs(err, client.ErrGrantNotAllowed)
+
+	_, err = suite.svc.ClientCredentials(id, registered.ClientSecret, oidc.ParseScope("users.write"))
+	suite.ErrorIs(err, client.ErrScopeNotAllowed)
+
+	grant, err := suite.svc.ClientCredentials(id, registered.ClientSecret, nil)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(oidc.Scope{"reports.read", "reports.write"}, grant.Scope)
+
+	now := time.Now()
+	claims := verifier.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    suite.cfg.Issuer(),
+			Subject:   id,
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        ulid.Make().String(),
+		},
+		Roles:    grant.Client.Roles,
+		Scope:    grant.Scope.String(),
+		TokenUse: verifier.TokenUseService,
+		ClientID: id,
+	}
+
+	suite.Equal("service", claims.Map()["token_use"])
+
+	tokenStr, err := suite.ring.SignAccessToken(claims)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	result, err := suite.svc.Introspect("gateway", "gateway_secret", tokenStr)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(result.Active)
+	suite.Equal(verifier.TokenUseService, result.TokenUse)
+	suite.Equal(id, result.ClientID)
+	suite.Equal([]string{"worker"}, result.Roles)
+	suite.Empty(result.Username)
+
+	// the token of a deleted client
+	err = handler.ClientDeletedHandler(&client.ClientDeletedEvent{
+		Event: &client.Event{ClientID: registered.ID},
+	})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	result, err = suite.svc.Introspect("gateway", "gateway_secret", tokenStr)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(result.Active)
+}
+
+func (suite *identityTestSuite) TestTokenExchange() {
+	registered, err := suite.svc.RegisterClient(client.Confidential, client.Settings{
+		Name:       "Support Desk",
+		GrantTypes: []string{client.GrantTokenExchange},
+		Scopes:     []string{"openid", "profile", "email"},
+		Roles:      []string{"support", "user"},
+		Audiences:  []string{"orders"},
+	})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	handler, err := suite.svc.Handler()
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	err = handler.ClientRegisteredHandler(registered.Events()[0].(*client.ClientRegisteredEvent))
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	customer, err := suite.svc.Register(tenant.Default, "user10", "User10", "user10@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	agent, err := suite.svc.Register(tenant.Default, "user11", "User11", "user11@example.com", "")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	for _, u := range []*user.User{customer, agent} {
+		u.Activate()
+		if err := suite.users.Store(u); err != nil {
+			suite.Fail(err.Error())
+			return
+		}
+	}
+
+	id := registered.ID.String()
+	secret := registered.ClientSecret
+
+	expiredAt := time.Now().Add(10 * time.Minute)
+	sign := func(sub string, roles []string, act *verifier.Actor) string {
+		tokenStr, err := suite.ring.SignAccessToken(verifier.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    suite.cfg.Issuer(),
+				Subject:   sub,
+				Audience:  jwt.ClaimStrings{id},
+				ExpiresAt: jwt.NewNumericDate(expiredAt),
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ID:        ulid.Make().String(),
+			},
+			Roles:    roles,
+			Scope:    "openid profile",
+			TokenUse: verifier.TokenUseUser,
+			Act:      act,
+		})
+		if err != nil {
+			suite.Fail(err.Error())
+		}
+
+		return tokenStr
+	}
+
+	subjectToken := sign(customer.ID.String(), []string{"user"}, nil)
+	req := &token.ExchangeRequest{
+		SubjectToken:     subjectToken,
+		SubjectTokenType: token.TokenTypeAccessToken,
+	}
+
+	_, err = suite.svc.ExchangeToken(context.TODO(), "gateway", "gateway_secret", req)
+	suite.ErrorIs(err, client.ErrGrantNotAllowed)
+
+	_, err = suite.svc.ExchangeToken(context.TODO(), id, secret, &token.ExchangeRequest{
+		SubjectToken:     subjectToken,
+		SubjectTokenType: "urn:ietf:params:oauth:token-type:id_token",
+	})
+	suite.ErrorIs(err, token.ErrUnsupportedTokenType)
+
+	// the client acts on behalf of the subject
+	x, err := suite.svc.ExchangeToken(context.TODO(), id, secret, req)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(customer.ID.String(), x.Subject)
+	suite.Equal(id, x.Actor.Subject)
+	suite.Equal(verifier.TokenUseService, x.Actor.TokenUse)
+	suite.Equal([]string{id}, x.Audience)
+	suite.Equal([]string{"user"}, x.Roles)
+	suite.Equal([]string{"openid", "profile"}, x.Scope)
+	suite.False(x.ExpiredAt.After(expiredAt))
+	suite.Equal(token.TokenExchanged.String(), x.Events()[0].EventName())
+
+	// never widens the scope of the subject token
+	_, err = suite.svc.ExchangeToken(context.TODO(), id, secret, &token.ExchangeRequest{
+		SubjectToken:     subjectToken,
+		SubjectTokenType: token.TokenTypeAccessToken,
+		Scope:            []string{"email"},
+	})
+	suite.ErrorIs(err, client.ErrScopeNotAllowed)
+
+	// the support agent impersonates the customer
+	actorToken := sign(agent.ID.String(), []string{"support", "user"}, nil)
+	x, err = suite.svc.ExchangeToken(context.TODO(), id, secret, &token.ExchangeRequest{
+		SubjectToken:     subjectToken,
+		SubjectTokenType: token.TokenTypeAccessToken,
+		ActorToken:       actorToken,
+		ActorTokenType:   token.TokenTypeAccessToken,
+		Scope:            []string{"profile"},
+	})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(agent.ID.String(), x.Actor.Subject)
+	suite.Equal(verifier.TokenUseUser, x.Actor.TokenUse)
+	suite.Nil(x.Actor.Act)
+	suite.Equal([]string{"user"}, x.Roles)
+	suite.Equal([]string{"profile"}, x.Scope)
+
+	// the actors of the subject token are chained
+	delegated := sign(customer.ID.String(), []string{"user"}, x.Actor)
+	x, err = suite.svc.ExchangeToken(context.TODO(), id, secret, &token.ExchangeRequest{
+		SubjectToken:     delegated,
+		SubjectTokenType: token.TokenTypeAccessToken,
+	})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(id, x.Actor.Subject)
+	suite.Equal(agent.ID.String(), x.Actor.Act.Subject)
+
+	// nobody without the permission impersonates
+	_, err = suite.svc.ExchangeToken(context.TODO(), id, secret, &token.ExchangeRequest{
+		SubjectToken:     subjectToken,
+		SubjectTokenType: token.TokenTypeAccessToken,
+		ActorToken:       sign(agent.ID.String(), []string{"user"}, nil),
+		ActorTokenType:   token.TokenTypeAccessToken,
+	})
+	suite.ErrorIs(err, token.ErrImpersonationDenied)
+
+	// nor impersonates a subject holding a role the actor doesn't hold
+	_, err = suite.svc.ExchangeToken(context.TODO(), id, secret, &token.ExchangeRequest{
+		SubjectToken:     sign(customer.ID.String(), []string{"admin", "user"}, nil),
+		SubjectTokenType: token.TokenTypeAccessToken,
+		ActorToken:       actorToken,
+		ActorTokenType:   token.TokenTypeAccessToken,
+	})
+	suite.ErrorIs(err, token.ErrImpersonationDenied)
+
+	// the client exchanges for the audiences registered only
+	x, err = suite.svc.ExchangeToken(context.TODO(), id, secret, &token.ExchangeRequest{
+		SubjectToken:     subjectToken,
+		SubjectTokenType: token.TokenTypeAccessToken,
+		Audience:         []string{"orders"},
+	})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal([]string{"orders"}, x.Audience)
+
+	_, err = suite.svc.ExchangeToken(context.TODO(), id, secret, &token.ExchangeRequest{
+		SubjectToken:     subjectToken,
+		SubjectTokenType: token.TokenTypeAccessToken,
+		Audience:         []string{"billing"},
+	})
+	suite.ErrorIs(err, client.ErrAudienceNotAllowed)
+
+	// nor exchanges the tokens issued to the others
+	other, err := suite.ring.SignAccessToken(verifier.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    suite.cfg.Issuer(),
+			Subject:   customer.ID.String(),
+			Audience:  jwt.ClaimStrings{"console"},
+			ExpiresAt: jwt.NewNumericDate(expiredAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        ulid.Make().String(),
+		},
+		Roles:    []string{"user"},
+		TokenUse: verifier.TokenUseUser,
+	})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.ExchangeToken(context.TODO(), id, secret, &token.ExchangeRequest{
+		SubjectToken:     other,
+		SubjectTokenType: token.TokenTypeAccessToken,
+	})
+	suite.ErrorIs(err, token.ErrSubjectTokenAudience)
+
+	// a client acting itself is authorized as the actors are
+	updated, err := suite.svc.UpdateClient(registered.ID, client.Settings{
+		Name:       "Support Desk",
+		GrantTypes: []string{client.GrantTokenExchange},
+		Scopes:     []string{"openid", "profile", "email"},
+		Roles:      []string{"user"},
+	})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := handler.ClientUpdatedHandler(updated.Events()[0].(*client.ClientUpdatedEvent)); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err = suite.svc.ExchangeToken(context.TODO(), id, secret, req)
+	suite.ErrorIs(err, token.ErrImpersonationDenied)
 }
 
 func (suite *identityTestSuite) TestSignInWithGoogle() {
-	u, err := suite.svc.SignIn(suite.token, user.GOOGLE)
+	u, err := suite.svc.SignIn(tenant.Default, suite.token, user.GOOGLE)
 	if err != nil {
 		suite.Error(err)
 		suite.T().Skip()
@@ -106,6 +2357,7 @@ func (suite *identityTestSuite) TestSignInWithGoogle() {
 
 func (suite *identityTestSuite) TearDownSuite() {
 	suite.users.Close()
+	suite.tokens.Close()
 }
 
 func TestIdentityTestSuite(t *testing.T) {
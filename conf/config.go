@@ -5,6 +5,7 @@ import (
 	"errors"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/urfave/cli/v2"
@@ -63,6 +64,11 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
+	// the TOTP secrets are sealed and the codes hashed by it
+	if len(cfg.OTP.Key) == 0 {
+		return nil, errors.New("otp key not set")
+	}
+
 	return cfg, nil
 }
 
@@ -70,27 +76,41 @@ type Config struct {
 	Name        string      `yaml:"name"`
 	BaseURL     string      `yaml:"baseUrl"`
 	JWT         JWT         `yaml:"jwt"`
+	OTP         OTP         `yaml:"otp"`
+	Password    Password    `yaml:"password"`
+	Throttle    Throttle    `yaml:"throttle"`
+	Usernames   Usernames   `yaml:"usernames"`
+	Deletion    Deletion    `yaml:"deletion"`
+	OIDC        OIDC        `yaml:"oidc"`
+	Clients     []Client    `yaml:"clients"`
 	Transports  Transports  `yaml:"transports"`
 	Persistence Persistence `yaml:"persistence"`
 	EventBus    EventBus    `yaml:"eventBus"`
+	Mail        Mail        `yaml:"mail"`
 	Providers   Providers   `yaml:"providers"`
 	Test        Test        `yaml:"test"`
 }
 
 type JWT struct {
-	Secret  []byte
-	Timeout time.Duration
-	Refresh struct {
-		Enabled bool
-		Maximum time.Duration
-	}
+	Algorithm string // HS256, RS256, ES256 or EdDSA
+	Secret    []byte // HS256 only
+	Keys      string // PEM key ring directory shared by every instance, see `keys rotate`
+	Timeout   time.Duration
+	Refresh   Refresh
+}
+
+type Refresh struct {
+	Enabled bool
+	Maximum time.Duration // lifetime of a refresh token family
 }
 
 func (cfg *JWT) UnmarshalYAML(value *yaml.Node) error {
 	var raw struct {
-		Secret  string
-		Timeout string
-		Refresh struct {
+		Algorithm string
+		Secret    string
+		Keys      string
+		Timeout   string
+		Refresh   struct {
 			Enabled bool
 			Maximum string
 		}
@@ -100,8 +120,18 @@ func (cfg *JWT) UnmarshalYAML(value *yaml.Node) error {
 		return err
 	}
 
+	cfg.Algorithm = raw.Algorithm
+	if raw.Algorithm == "" {
+		cfg.Algorithm = "HS256"
+	}
+
 	cfg.Secret = []byte(raw.Secret)
 
+	cfg.Keys = raw.Keys
+	if raw.Keys == "" {
+		cfg.Keys = Path + "/keys"
+	}
+
 	if raw.Timeout == "" {
 		cfg.Timeout = 1 * time.Hour
 	} else {
@@ -133,6 +163,334 @@ func (cfg *JWT) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
+type OTP struct {
+	Issuer string
+	Period time.Duration
+	Digits int
+	Skew   uint
+	Key    []byte // server key, seals the TOTP secrets
+	Email  EmailOTP
+}
+
+type EmailOTP struct {
+	Length      int
+	TTL         time.Duration
+	MaxAttempts int
+}
+
+func (cfg *OTP) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		Issuer string
+		Period string
+		Digits int
+		Skew   *uint
+		Key    string
+		Email  struct {
+			Length      int    `yaml:"length"`
+			TTL         string `yaml:"ttl"`
+			MaxAttempts int    `yaml:"maxAttempts"`
+		}
+	}
+
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	cfg.Issuer = raw.Issuer
+
+	if raw.Period == "" {
+		cfg.Period = 30 * time.Second
+	} else {
+		period, err := time.ParseDuration(raw.Period)
+		if err != nil {
+			return err
+		}
+
+		cfg.Period = period
+	}
+
+	cfg.Digits = raw.Digits
+	if raw.Digits == 0 {
+		cfg.Digits = 6
+	}
+
+	cfg.Skew = 1
+	if raw.Skew != nil {
+		cfg.Skew = *raw.Skew
+	}
+
+	cfg.Key = []byte(raw.Key)
+
+	cfg.Email.Length = raw.Email.Length
+	if raw.Email.Length == 0 {
+		cfg.Email.Length = 6
+	}
+
+	if raw.Email.TTL == "" {
+		cfg.Email.TTL = 10 * time.Minute
+	} else {
+		ttl, err := time.ParseDuration(raw.Email.TTL)
+		if err != nil {
+			return err
+		}
+
+		cfg.Email.TTL = ttl
+	}
+
+	cfg.Email.MaxAttempts = raw.Email.MaxAttempts
+	if raw.Email.MaxAttempts == 0 {
+		cfg.Email.MaxAttempts = 5
+	}
+
+	return nil
+}
+
+type Password struct {
+	Argon2     Argon2
+	MaxAuthAge time.Duration // of the sign-in setting the first password
+}
+
+func (cfg *Password) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		Argon2     Argon2 `yaml:"argon2"`
+		MaxAuthAge string `yaml:"maxAuthAge"`
+	}
+
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	cfg.Argon2 = raw.Argon2
+
+	if raw.MaxAuthAge == "" {
+		cfg.MaxAuthAge = 5 * time.Minute
+	} else {
+		maxAuthAge, err := time.ParseDuration(raw.MaxAuthAge)
+		if err != nil {
+			return err
+		}
+
+		cfg.MaxAuthAge = maxAuthAge
+	}
+
+	return nil
+}
+
+type Argon2 struct {
+	Time       uint32 `yaml:"time"`
+	Memory     uint32 `yaml:"memory"` // KiB
+	Threads    uint8  `yaml:"threads"`
+	KeyLength  uint32 `yaml:"keyLength"`
+	SaltLength uint32 `yaml:"saltLength"`
+}
+
+type Throttle struct {
+	Window    time.Duration // sliding window of the failures
+	Threshold int           // failures allowed before backing off
+	Backoff   Backoff
+	LockAfter int // failures of a user signed in before locking, 0 disables
+}
+
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func (cfg *Throttle) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		Window    string `yaml:"window"`
+		Threshold *int   `yaml:"threshold"`
+		Backoff   struct {
+			Base string `yaml:"base"`
+			Max  string `yaml:"max"`
+		} `yaml:"backoff"`
+		LockAfter *int `yaml:"lockAfter"`
+	}
+
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	if raw.Window == "" {
+		cfg.Window = 15 * time.Minute
+	} else {
+		window, err := time.ParseDuration(raw.Window)
+		if err != nil {
+			return err
+		}
+
+		cfg.Window = window
+	}
+
+	if raw.Backoff.Base == "" {
+		cfg.Backoff.Base = time.Second
+	} else {
+		base, err := time.ParseDuration(raw.Backoff.Base)
+		if err != nil {
+			return err
+		}
+
+		cfg.Backoff.Base = base
+	}
+
+	if raw.Backoff.Max == "" {
+		cfg.Backoff.Max = 5 * time.Minute
+	} else {
+		max, err := time.ParseDuration(raw.Backoff.Max)
+		if err != nil {
+			return err
+		}
+
+		cfg.Backoff.Max = max
+	}
+
+	cfg.Threshold = 3
+	if raw.Threshold != nil {
+		cfg.Threshold = *raw.Threshold
+	}
+
+	cfg.LockAfter = 10
+	if raw.LockAfter != nil {
+		cfg.LockAfter = *raw.LockAfter
+	}
+
+	return nil
+}
+
+type Usernames struct {
+	Reservation time.Duration // held by a change across the instances until applied
+	Cooldown    time.Duration // held from the others once changed
+}
+
+func (cfg *Usernames) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		Reservation string `yaml:"reservation"`
+		Cooldown    string `yaml:"cooldown"`
+	}
+
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	if raw.Reservation == "" {
+		cfg.Reservation = 1 * time.Minute
+	} else {
+		reservation, err := time.ParseDuration(raw.Reservation)
+		if err != nil {
+			return err
+		}
+
+		cfg.Reservation = reservation
+	}
+
+	if raw.Cooldown == "" {
+		cfg.Cooldown = 30 * 24 * time.Hour
+	} else {
+		cooldown, err := time.ParseDuration(raw.Cooldown)
+		if err != nil {
+			return err
+		}
+
+		cfg.Cooldown = cooldown
+	}
+
+	return nil
+}
+
+type Deletion struct {
+	Retention time.Duration // the deleted users are purged after
+	Interval  time.Duration // between the purges
+}
+
+func (cfg *Deletion) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		Retention string `yaml:"retention"`
+		Interval  string `yaml:"interval"`
+	}
+
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	if raw.Retention == "" {
+		cfg.Retention = 30 * 24 * time.Hour
+	} else {
+		retention, err := time.ParseDuration(raw.Retention)
+		if err != nil {
+			return err
+		}
+
+		cfg.Retention = retention
+	}
+
+	if raw.Interval == "" {
+		cfg.Interval = 1 * time.Hour
+	} else {
+		interval, err := time.ParseDuration(raw.Interval)
+		if err != nil {
+			return err
+		}
+
+		cfg.Interval = interval
+	}
+
+	return nil
+}
+
+// Issuer is the issuer of the tokens, BaseURL is served over HTTPS
+// unless it has a scheme.
+func (cfg *Config) Issuer() string {
+	if strings.Contains(cfg.BaseURL, "://") {
+		return cfg.BaseURL
+	}
+
+	return "https://" + cfg.BaseURL
+}
+
+type OIDC struct {
+	LoginURL string        // page signing in the users of the authorization requests
+	CodeTTL  time.Duration // lifetime of an authorization code
+}
+
+func (cfg *OIDC) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		LoginURL string `yaml:"loginUrl"`
+		CodeTTL  string `yaml:"codeTtl"`
+	}
+
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	cfg.LoginURL = raw.LoginURL
+
+	if raw.CodeTTL == "" {
+		cfg.CodeTTL = 1 * time.Minute
+	} else {
+		ttl, err := time.ParseDuration(raw.CodeTTL)
+		if err != nil {
+			return err
+		}
+
+		cfg.CodeTTL = ttl
+	}
+
+	return nil
+}
+
+// Client seeds a client application not registered yet, a public client
+// has no secret.
+type Client struct {
+	ID           string   `yaml:"id"`
+	Name         string   `yaml:"name"`
+	Secret       string   `yaml:"secret"`
+	RedirectURIs []string `yaml:"redirectUris"`
+	GrantTypes   []string `yaml:"grantTypes"`
+	Scopes       []string `yaml:"scopes"`
+	Roles        []string `yaml:"roles"`
+	Audiences    []string `yaml:"audiences"`
+}
+
 type Transports struct {
 	HTTP          RegisterHTTP  `yaml:"http"`
 	NATS          RegisterNATS  `yaml:"nats"`
@@ -143,13 +501,17 @@ type RegisterHTTP struct {
 	Enabled  bool
 	Internal Instance
 	External *Instance
+
+	// the proxies whose X-Forwarded-For tells the client IP, none by default
+	TrustedProxies []string
 }
 
 func (r *RegisterHTTP) UnmarshalYAML(value *yaml.Node) error {
 	var raw struct {
-		Enabled  bool      `yaml:"enabled"`
-		Internal Instance  `yaml:"internal"`
-		External *Instance `yaml:"external"`
+		Enabled        bool      `yaml:"enabled"`
+		Internal       Instance  `yaml:"internal"`
+		External       *Instance `yaml:"external"`
+		TrustedProxies []string  `yaml:"trustedProxies"`
 	}
 
 	if err := value.Decode(&raw); err != nil {
@@ -159,6 +521,7 @@ func (r *RegisterHTTP) UnmarshalYAML(value *yaml.Node) error {
 	r.Enabled = raw.Enabled
 	r.Internal = raw.Internal
 	r.External = raw.External
+	r.TrustedProxies = raw.TrustedProxies
 
 	// default
 	if r.Internal.Scheme == "" {
@@ -308,12 +671,14 @@ func (p TransportProvider) String() string {
 type EventBus struct {
 	Provider TransportProvider
 	Users    Users
+	Leases   Leases
 }
 
 func (e *EventBus) UnmarshalYAML(value *yaml.Node) error {
 	var raw struct {
 		Provider string `yaml:"provider"`
 		Users    Users  `yaml:"users"`
+		Leases   Leases `yaml:"leases"`
 	}
 
 	if err := value.Decode(&raw); err != nil {
@@ -327,6 +692,46 @@ func (e *EventBus) UnmarshalYAML(value *yaml.Node) error {
 
 	e.Provider = provider
 	e.Users = raw.Users
+	e.Leases = raw.Leases
+
+	if e.Leases.Bucket == "" {
+		e.Leases.Bucket = "LEASES"
+	}
+
+	if e.Leases.TTL == 0 {
+		e.Leases.TTL = 1 * time.Hour
+	}
+
+	return nil
+}
+
+// Leases are granted across the instances by a key-value bucket, the TTL
+// outlasts the longest lease.
+type Leases struct {
+	Bucket string
+	TTL    time.Duration
+}
+
+func (l *Leases) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		Bucket string `yaml:"bucket"`
+		TTL    string `yaml:"ttl"`
+	}
+
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	l.Bucket = raw.Bucket
+
+	if raw.TTL != "" {
+		ttl, err := time.ParseDuration(raw.TTL)
+		if err != nil {
+			return err
+		}
+
+		l.TTL = ttl
+	}
 
 	return nil
 }
@@ -381,7 +786,106 @@ func (c *Consumer) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
+type MailDriver int
+
+const (
+	SMTP MailDriver = iota
+	FileSink
+	InMemSink
+)
+
+func ParseMailDriver(driver string) (MailDriver, error) {
+	switch driver {
+	case "smtp":
+		return SMTP, nil
+	case "file":
+		return FileSink, nil
+	case "inmem":
+		return InMemSink, nil
+	default:
+		return -1, errors.New("driver not supported")
+	}
+}
+
+func (driver MailDriver) String() string {
+	switch driver {
+	case SMTP:
+		return "smtp"
+	case FileSink:
+		return "file"
+	case InMemSink:
+		return "inmem"
+	default:
+		return "unknown"
+	}
+}
+
+type Mail struct {
+	Driver    MailDriver
+	From      string
+	SMTP      SMTPServer
+	Path      string
+	Templates MailTemplates
+}
+
+func (m *Mail) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		Driver    string        `yaml:"driver"`
+		From      string        `yaml:"from"`
+		SMTP      SMTPServer    `yaml:"smtp"`
+		Path      string        `yaml:"path"`
+		Templates MailTemplates `yaml:"templates"`
+	}
+
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	driver, err := ParseMailDriver(raw.Driver)
+	if err != nil {
+		return err
+	}
+
+	m.Driver = driver
+	m.From = raw.From
+	m.SMTP = raw.SMTP
+
+	if m.SMTP.Port == 0 {
+		m.SMTP.Port = 25
+	}
+
+	m.Path = raw.Path
+	if raw.Path == "" {
+		m.Path = Path + "/mails"
+	}
+
+	m.Templates = raw.Templates
+	return nil
+}
+
+type SMTPServer struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+type MailTemplates struct {
+	Verification      MailTemplate `yaml:"verification"`
+	EmailChange       MailTemplate `yaml:"emailChange"`       // sent to the new email
+	EmailChangeNotice MailTemplate `yaml:"emailChangeNotice"` // sent to the old email
+}
+
+type MailTemplate struct {
+	Subject string `yaml:"subject"`
+	Body    string `yaml:"body"`
+}
+
 type Providers struct {
+	// the names and the avatars of the providers overwrite the ones edited
+	// by the users on signing in
+	OverwriteProfile bool `yaml:"overwriteProfile"`
+
 	Google struct {
 		Client struct {
 			ID     string
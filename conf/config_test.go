@@ -24,11 +24,48 @@ func TestLoadConfig(t *testing.T) {
 
 	assert.Equal("identity", cfg.Name)
 	assert.Equal("identity.linyc.idv.tw", cfg.BaseURL)
+	assert.Equal("https://identity.linyc.idv.tw", cfg.Issuer())
 
+	assert.Equal("ES256", cfg.JWT.Algorithm)
+	assert.Equal("../keys", cfg.JWT.Keys)
 	assert.Equal(1*time.Hour, cfg.JWT.Timeout)
 	assert.True(cfg.JWT.Refresh.Enabled)
 	assert.Equal(1*time.Hour+30*time.Minute, cfg.JWT.Refresh.Maximum)
 
+	assert.Equal("identity", cfg.OTP.Issuer)
+	assert.Equal(30*time.Second, cfg.OTP.Period)
+	assert.Equal(6, cfg.OTP.Digits)
+	assert.Equal(uint(1), cfg.OTP.Skew)
+	assert.Equal([]byte("otp_secret_key"), cfg.OTP.Key)
+	assert.Equal(10*time.Minute, cfg.OTP.Email.TTL)
+
+	assert.Equal(5*time.Minute, cfg.Password.MaxAuthAge)
+
+	assert.Equal(15*time.Minute, cfg.Throttle.Window)
+	assert.Equal(3, cfg.Throttle.Threshold)
+	assert.Equal(time.Second, cfg.Throttle.Backoff.Base)
+	assert.Equal(10, cfg.Throttle.LockAfter)
+
+	assert.Equal(time.Minute, cfg.Usernames.Reservation)
+	assert.Equal(720*time.Hour, cfg.Usernames.Cooldown)
+	assert.Equal(720*time.Hour, cfg.Deletion.Retention)
+	assert.Equal(time.Hour, cfg.Deletion.Interval)
+
+	assert.Equal("LEASES", cfg.EventBus.Leases.Bucket)
+	assert.Equal(time.Hour, cfg.EventBus.Leases.TTL)
+
+	assert.Equal(time.Minute, cfg.OIDC.CodeTTL)
+	assert.Len(cfg.Clients, 2)
+	assert.Equal([]string{"https://console.linyc.idv.tw/callback"}, cfg.Clients[1].RedirectURIs)
+	assert.Equal([]string{"authorization_code", "refresh_token"}, cfg.Clients[1].GrantTypes)
+
+	assert.Equal(FileSink, cfg.Mail.Driver)
+	assert.Equal("../mails", cfg.Mail.Path)
+
+	assert.False(cfg.Providers.OverwriteProfile)
+
+	assert.Empty(cfg.Transports.HTTP.TrustedProxies)
+
 	assert.Equal(BadgerDB, cfg.Persistence.Driver)
 	assert.Equal("users", cfg.Persistence.Name)
 }
@@ -3,24 +3,84 @@ package identity
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
 
+	"github.com/mirror520/identity/client"
+	"github.com/mirror520/identity/group"
+	"github.com/mirror520/identity/oidc"
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/token"
 	"github.com/mirror520/identity/user"
+	"github.com/mirror520/identity/verifier"
 )
 
 type EndpointSet struct {
-	Register         endpoint.Endpoint
-	SignIn           endpoint.Endpoint
-	OTPVerify        endpoint.Endpoint
-	AddSocialAccount endpoint.Endpoint
-	CheckHealth      endpoint.Endpoint
+	Register            endpoint.Endpoint
+	SignIn              endpoint.Endpoint
+	SignInWithPassword  endpoint.Endpoint
+	ChangePassword      endpoint.Endpoint
+	OTPEnroll           endpoint.Endpoint
+	VerifyUser          endpoint.Endpoint
+	OTPVerify           endpoint.Endpoint
+	AddSocialAccount    endpoint.Endpoint
+	RemoveSocialAccount endpoint.Endpoint
+	Lock                endpoint.Endpoint
+	Unlock              endpoint.Endpoint
+	Revoke              endpoint.Endpoint
+	GrantRole           endpoint.Endpoint
+	RevokeRole          endpoint.Endpoint
+	UpdateProfile       endpoint.Endpoint
+	ChangeUsername      endpoint.Endpoint
+	DeleteUser          endpoint.Endpoint
+	RequestEmailChange  endpoint.Endpoint
+	ConfirmEmailChange  endpoint.Endpoint
+	ListUsers           endpoint.Endpoint
+	RefreshToken        endpoint.Endpoint
+	RevokeToken         endpoint.Endpoint
+	Introspect          endpoint.Endpoint
+	Authorize           endpoint.Endpoint
+	ExchangeCode        endpoint.Endpoint
+	ClientCredentials   endpoint.Endpoint
+	ExchangeToken       endpoint.Endpoint
+	UserInfo            endpoint.Endpoint
+	RegisterClient      endpoint.Endpoint
+	UpdateClient        endpoint.Endpoint
+	RotateClientSecret  endpoint.Endpoint
+	DeleteClient        endpoint.Endpoint
+	FindClient          endpoint.Endpoint
+	ListClients         endpoint.Endpoint
+	CreateGroup         endpoint.Endpoint
+	UpdateGroup         endpoint.Endpoint
+	DeleteGroup         endpoint.Endpoint
+	FindGroup           endpoint.Endpoint
+	ListGroups          endpoint.Endpoint
+	AddGroupMember      endpoint.Endpoint
+	RemoveGroupMember   endpoint.Endpoint
+	GrantGroupRole      endpoint.Endpoint
+	RevokeGroupRole     endpoint.Endpoint
+	Memberships         endpoint.Endpoint
+	CreateTenant        endpoint.Endpoint
+	UpdateTenant        endpoint.Endpoint
+	DeleteTenant        endpoint.Endpoint
+	FindTenant          endpoint.Endpoint
+	ListTenants         endpoint.Endpoint
+	JoinTenant          endpoint.Endpoint
+	LeaveTenant         endpoint.Endpoint
+	GrantTenantRole     endpoint.Endpoint
+	RevokeTenantRole    endpoint.Endpoint
+	Tenants             endpoint.Endpoint
+	CheckStatus         endpoint.Endpoint
+	CheckHealth         endpoint.Endpoint
 }
 
 type RegisterRequest struct {
+	TenantID tenant.TenantID
 	Username string
 	Name     string
 	Email    string
+	Password string
 }
 
 func RegisterEndpoint(svc Service) endpoint.Endpoint {
@@ -30,7 +90,52 @@ func RegisterEndpoint(svc Service) endpoint.Endpoint {
 			return nil, errors.New("invalid request")
 		}
 
-		u, err := svc.Register(req.Username, req.Name, req.Email)
+		u, err := svc.Register(req.TenantID, req.Username, req.Name, req.Email, req.Password)
+		if err != nil {
+			return nil, err
+		}
+
+		return u, nil
+	}
+}
+
+// OTPEnrollRequest carries a current code once a secret is in use.
+type OTPEnrollRequest struct {
+	OTP    string
+	UserID user.UserID
+}
+
+func OTPEnrollEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(OTPEnrollRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		enrollment, err := svc.OTPEnroll(req.OTP, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		return enrollment, nil
+	}
+}
+
+// VerifyUserRequest is bound as the one of OTPVerify, the code is sent
+// as the OTP.
+type VerifyUserRequest struct {
+	OTP    string
+	UserID user.UserID
+}
+
+func VerifyUserEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(VerifyUserRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		u, err := svc.VerifyUser(ctx, req.OTP, req.UserID)
 		if err != nil {
 			return nil, err
 		}
@@ -51,7 +156,7 @@ func OTPVerifyEndpoint(svc Service) endpoint.Endpoint {
 			return nil, errors.New("invalid request")
 		}
 
-		u, err := svc.OTPVerify(req.OTP, req.UserID)
+		u, err := svc.OTPVerify(ctx, req.OTP, req.UserID)
 		if err != nil {
 			return nil, err
 		}
@@ -61,6 +166,7 @@ func OTPVerifyEndpoint(svc Service) endpoint.Endpoint {
 }
 
 type SignInRequest struct {
+	TenantID   tenant.TenantID
 	Credential string
 	Provider   user.SocialProvider
 }
@@ -72,7 +178,52 @@ func SignInEndpoint(svc Service) endpoint.Endpoint {
 			return nil, errors.New("invalid request")
 		}
 
-		u, err := svc.SignIn(req.Credential, req.Provider)
+		u, err := svc.SignIn(req.TenantID, req.Credential, req.Provider)
+		if err != nil {
+			return nil, err
+		}
+
+		return u, nil
+	}
+}
+
+type SignInWithPasswordRequest struct {
+	TenantID tenant.TenantID
+	Username string
+	Password string
+}
+
+func SignInWithPasswordEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(SignInWithPasswordRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		u, err := svc.SignInWithPassword(ctx, req.TenantID, req.Username, req.Password)
+		if err != nil {
+			return nil, err
+		}
+
+		return u, nil
+	}
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string
+	NewPassword string
+	UserID      user.UserID
+	AuthTime    time.Time `json:"-" form:"-"` // of the access token
+}
+
+func ChangePasswordEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(ChangePasswordRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		u, err := svc.ChangePassword(req.UserID, req.AuthTime, req.OldPassword, req.NewPassword)
 		if err != nil {
 			return nil, err
 		}
@@ -81,6 +232,23 @@ func SignInEndpoint(svc Service) endpoint.Endpoint {
 	}
 }
 
+type RemoveSocialAccountRequest struct {
+	UserID   user.UserID
+	Provider user.SocialProvider
+	SocialID user.SocialID
+}
+
+func RemoveSocialAccountEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(RemoveSocialAccountRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.RemoveSocialAccount(req.UserID, req.Provider, req.SocialID)
+	}
+}
+
 type AddSocialAccountRequest struct {
 	Credential string
 	Provider   user.SocialProvider
@@ -103,6 +271,676 @@ func AddSocialAccountEndpoint(svc Service) endpoint.Endpoint {
 	}
 }
 
+type LockRequest struct {
+	Reason string
+	UserID user.UserID
+}
+
+func LockEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(LockRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		u, err := svc.LockUser(req.UserID, req.Reason)
+		if err != nil {
+			return nil, err
+		}
+
+		return u, nil
+	}
+}
+
+type UnlockRequest struct {
+	UserID user.UserID
+}
+
+func UnlockEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(UnlockRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		u, err := svc.UnlockUser(req.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		return u, nil
+	}
+}
+
+type RevokeRequest struct {
+	Reason string
+	UserID user.UserID
+}
+
+func RevokeEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(RevokeRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		u, err := svc.RevokeUser(req.UserID, req.Reason)
+		if err != nil {
+			return nil, err
+		}
+
+		return u, nil
+	}
+}
+
+type RoleRequest struct {
+	UserID user.UserID
+	Role   string `json:"role"`
+}
+
+func GrantRoleEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(RoleRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		u, err := svc.GrantRole(req.UserID, req.Role)
+		if err != nil {
+			return nil, err
+		}
+
+		return u, nil
+	}
+}
+
+func RevokeRoleEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(RoleRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		u, err := svc.RevokeRole(req.UserID, req.Role)
+		if err != nil {
+			return nil, err
+		}
+
+		return u, nil
+	}
+}
+
+type UpdateProfileRequest struct {
+	UserID  user.UserID
+	Profile user.Profile
+}
+
+func UpdateProfileEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(UpdateProfileRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.UpdateProfile(req.UserID, req.Profile)
+	}
+}
+
+type ChangeUsernameRequest struct {
+	UserID   user.UserID
+	Username string
+}
+
+func ChangeUsernameEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(ChangeUsernameRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.ChangeUsername(req.UserID, req.Username)
+	}
+}
+
+func DeleteUserEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		id, ok := request.(user.UserID)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		err = svc.DeleteUser(id)
+		return
+	}
+}
+
+type EmailChangeRequest struct {
+	UserID user.UserID
+	Email  string
+}
+
+func RequestEmailChangeEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(EmailChangeRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.RequestEmailChange(req.UserID, req.Email)
+	}
+}
+
+type ConfirmEmailChangeRequest struct {
+	UserID user.UserID
+	Code   string
+}
+
+func ConfirmEmailChangeEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(ConfirmEmailChangeRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.ConfirmEmailChange(ctx, req.Code, req.UserID)
+	}
+}
+
+func ListUsersEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		q, ok := request.(user.Query)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.ListUsers(q)
+	}
+}
+
+type RefreshTokenRequest struct {
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+}
+
+func RefreshTokenEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(RefreshTokenRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		grant, err := svc.RefreshToken(req.ClientID, req.ClientSecret, req.RefreshToken)
+		if err != nil {
+			return nil, err
+		}
+
+		return grant, nil
+	}
+}
+
+type RevokeTokenRequest struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	Token        string `json:"token"`
+}
+
+func RevokeTokenEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(RevokeTokenRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		err = svc.RevokeToken(req.ClientID, req.ClientSecret, req.Token)
+		return nil, err
+	}
+}
+
+type IntrospectRequest struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	Token        string `json:"token"`
+}
+
+func IntrospectEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(IntrospectRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.Introspect(req.ClientID, req.ClientSecret, req.Token)
+	}
+}
+
+type AuthorizeRequest struct {
+	Claims *verifier.Claims
+	oidc.AuthorizationRequest
+}
+
+func AuthorizeEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(AuthorizeRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.Authorize(req.Claims, &req.AuthorizationRequest)
+	}
+}
+
+type ExchangeCodeRequest struct {
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+}
+
+func ExchangeCodeEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(ExchangeCodeRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.ExchangeCode(req.ClientID, req.ClientSecret, req.Code, req.RedirectURI, req.CodeVerifier)
+	}
+}
+
+type ClientCredentialsRequest struct {
+	ClientID     string
+	ClientSecret string
+	Scope        oidc.Scope
+}
+
+func ClientCredentialsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(ClientCredentialsRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.ClientCredentials(req.ClientID, req.ClientSecret, req.Scope)
+	}
+}
+
+type ExchangeTokenRequest struct {
+	ClientID     string
+	ClientSecret string
+	token.ExchangeRequest
+}
+
+func ExchangeTokenEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(ExchangeTokenRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.ExchangeToken(ctx, req.ClientID, req.ClientSecret, &req.ExchangeRequest)
+	}
+}
+
+type UserInfoRequest struct {
+	UserID user.UserID
+	Scope  oidc.Scope
+}
+
+func UserInfoEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(UserInfoRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.UserInfo(req.UserID, req.Scope)
+	}
+}
+
+type RegisterClientRequest struct {
+	Type     client.Type
+	Settings client.Settings
+}
+
+func RegisterClientEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(RegisterClientRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.RegisterClient(req.Type, req.Settings)
+	}
+}
+
+type UpdateClientRequest struct {
+	ClientID client.ClientID
+	Settings client.Settings
+}
+
+func UpdateClientEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(UpdateClientRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.UpdateClient(req.ClientID, req.Settings)
+	}
+}
+
+func RotateClientSecretEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		id, ok := request.(client.ClientID)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.RotateClientSecret(id)
+	}
+}
+
+func DeleteClientEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		id, ok := request.(client.ClientID)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		err = svc.DeleteClient(id)
+		return
+	}
+}
+
+func FindClientEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		id, ok := request.(client.ClientID)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.FindClient(id)
+	}
+}
+
+func ListClientsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		return svc.ListClients()
+	}
+}
+
+// GroupRequest creates a group, or updates the group of the ID.
+type GroupRequest struct {
+	GroupID group.GroupID
+	Name    string
+	Parent  group.GroupID
+}
+
+func CreateGroupEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(GroupRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.CreateGroup(req.Name, req.Parent)
+	}
+}
+
+func UpdateGroupEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(GroupRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.UpdateGroup(req.GroupID, req.Name, req.Parent)
+	}
+}
+
+func DeleteGroupEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		id, ok := request.(group.GroupID)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		err = svc.DeleteGroup(id)
+		return
+	}
+}
+
+func FindGroupEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		id, ok := request.(group.GroupID)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.FindGroup(id)
+	}
+}
+
+func ListGroupsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		return svc.ListGroups()
+	}
+}
+
+type GroupMemberRequest struct {
+	GroupID group.GroupID
+	UserID  user.UserID
+}
+
+func AddGroupMemberEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(GroupMemberRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.AddGroupMember(req.GroupID, req.UserID)
+	}
+}
+
+func RemoveGroupMemberEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(GroupMemberRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.RemoveGroupMember(req.GroupID, req.UserID)
+	}
+}
+
+type GroupRoleRequest struct {
+	GroupID group.GroupID
+	Role    string
+}
+
+func GrantGroupRoleEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(GroupRoleRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.GrantGroupRole(req.GroupID, req.Role)
+	}
+}
+
+func RevokeGroupRoleEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(GroupRoleRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.RevokeGroupRole(req.GroupID, req.Role)
+	}
+}
+
+func MembershipsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		id, ok := request.(user.UserID)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.Memberships(id)
+	}
+}
+
+// TenantRequest creates a tenant, or updates the tenant of the ID.
+type TenantRequest struct {
+	TenantID tenant.TenantID
+	Name     string
+}
+
+func CreateTenantEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(TenantRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.CreateTenant(req.TenantID, req.Name)
+	}
+}
+
+func UpdateTenantEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(TenantRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.UpdateTenant(req.TenantID, req.Name)
+	}
+}
+
+func DeleteTenantEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		id, ok := request.(tenant.TenantID)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		err = svc.DeleteTenant(id)
+		return
+	}
+}
+
+func FindTenantEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		id, ok := request.(tenant.TenantID)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.FindTenant(id)
+	}
+}
+
+func ListTenantsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		return svc.ListTenants()
+	}
+}
+
+type TenantMemberRequest struct {
+	TenantID tenant.TenantID
+	UserID   user.UserID
+}
+
+func JoinTenantEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(TenantMemberRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.JoinTenant(req.TenantID, req.UserID)
+	}
+}
+
+func LeaveTenantEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(TenantMemberRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.LeaveTenant(req.TenantID, req.UserID)
+	}
+}
+
+type TenantRoleRequest struct {
+	TenantID tenant.TenantID
+	UserID   user.UserID
+	Role     string
+}
+
+func GrantTenantRoleEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(TenantRoleRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.GrantTenantRole(req.TenantID, req.UserID, req.Role)
+	}
+}
+
+func RevokeTenantRoleEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(TenantRoleRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.RevokeTenantRole(req.TenantID, req.UserID, req.Role)
+	}
+}
+
+func TenantsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		id, ok := request.(user.UserID)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		return svc.Tenants(id)
+	}
+}
+
+// CheckStatusRequest checks either the user or the client of a service
+// token.
+type CheckStatusRequest struct {
+	UserID   user.UserID
+	ClientID client.ClientID
+}
+
+func CheckStatusEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (response any, err error) {
+		req, ok := request.(CheckStatusRequest)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		if req.ClientID != "" {
+			err = svc.CheckClient(req.ClientID)
+			return
+		}
+
+		err = svc.CheckStatus(req.UserID)
+		return
+	}
+}
+
 type RequestInfo struct {
 	ClientIP  string `json:"client_ip"`
 	UserAgent string `json:"user_agent"`
@@ -129,6 +967,88 @@ func EventEndpoint(svc Service) endpoint.Endpoint {
 			err = handler.UserActivatedHandler(e)
 		case *user.UserSocialAccountAddedEvent:
 			err = handler.UserSocialAccountAddedHandler(e)
+		case *user.UserOTPEnrolledEvent:
+			err = handler.UserOTPEnrolledHandler(e)
+		case *user.UserOTPVerifiedEvent:
+			err = handler.UserOTPVerifiedHandler(e)
+		case *user.UserOTPConfirmedEvent:
+			err = handler.UserOTPConfirmedHandler(e)
+		case *user.UserVerificationCodeIssuedEvent:
+			err = handler.UserVerificationCodeIssuedHandler(e)
+		case *user.UserVerificationCodeFailedEvent:
+			err = handler.UserVerificationCodeFailedHandler(e)
+		case *user.UserVerificationCodeVerifiedEvent:
+			err = handler.UserVerificationCodeVerifiedHandler(e)
+		case *user.UserPasswordChangedEvent:
+			err = handler.UserPasswordChangedHandler(e)
+		case *user.UserLockedEvent:
+			err = handler.UserLockedHandler(e)
+		case *user.UserUnlockedEvent:
+			err = handler.UserUnlockedHandler(e)
+		case *user.UserRevokedEvent:
+			err = handler.UserRevokedHandler(e)
+		case *user.UserRoleGrantedEvent:
+			err = handler.UserRoleGrantedHandler(e)
+		case *user.UserRoleRevokedEvent:
+			err = handler.UserRoleRevokedHandler(e)
+		case *user.UserTenantJoinedEvent:
+			err = handler.UserTenantJoinedHandler(e)
+		case *user.UserTenantLeftEvent:
+			err = handler.UserTenantLeftHandler(e)
+		case *user.UserTenantRoleGrantedEvent:
+			err = handler.UserTenantRoleGrantedHandler(e)
+		case *user.UserTenantRoleRevokedEvent:
+			err = handler.UserTenantRoleRevokedHandler(e)
+		case *user.UserProfileUpdatedEvent:
+			err = handler.UserProfileUpdatedHandler(e)
+		case *user.UserEmailChangeRequestedEvent:
+			err = handler.UserEmailChangeRequestedHandler(e)
+		case *user.UserEmailChangedEvent:
+			err = handler.UserEmailChangedHandler(e)
+		case *user.UserUsernameChangedEvent:
+			err = handler.UserUsernameChangedHandler(e)
+		case *user.UserDeletedEvent:
+			err = handler.UserDeletedHandler(e)
+		case *user.UserSocialAccountRemovedEvent:
+			err = handler.UserSocialAccountRemovedHandler(e)
+		case *token.FamilyIssuedEvent:
+			err = handler.FamilyIssuedHandler(e)
+		case *token.FamilyRotatedEvent:
+			err = handler.FamilyRotatedHandler(e)
+		case *token.FamilyRevokedEvent:
+			err = handler.FamilyRevokedHandler(e)
+		case *token.TokenDeniedEvent:
+			err = handler.TokenDeniedHandler(e)
+		case *token.TokenExchangedEvent:
+			err = handler.TokenExchangedHandler(e)
+		case *client.ClientRegisteredEvent:
+			err = handler.ClientRegisteredHandler(e)
+		case *client.ClientUpdatedEvent:
+			err = handler.ClientUpdatedHandler(e)
+		case *client.ClientSecretRotatedEvent:
+			err = handler.ClientSecretRotatedHandler(e)
+		case *client.ClientDeletedEvent:
+			err = handler.ClientDeletedHandler(e)
+		case *group.GroupCreatedEvent:
+			err = handler.GroupCreatedHandler(e)
+		case *group.GroupUpdatedEvent:
+			err = handler.GroupUpdatedHandler(e)
+		case *group.GroupMemberAddedEvent:
+			err = handler.GroupMemberAddedHandler(e)
+		case *group.GroupMemberRemovedEvent:
+			err = handler.GroupMemberRemovedHandler(e)
+		case *group.GroupRoleGrantedEvent:
+			err = handler.GroupRoleGrantedHandler(e)
+		case *group.GroupRoleRevokedEvent:
+			err = handler.GroupRoleRevokedHandler(e)
+		case *group.GroupDeletedEvent:
+			err = handler.GroupDeletedHandler(e)
+		case *tenant.TenantCreatedEvent:
+			err = handler.TenantCreatedHandler(e)
+		case *tenant.TenantUpdatedEvent:
+			err = handler.TenantUpdatedHandler(e)
+		case *tenant.TenantDeletedEvent:
+			err = handler.TenantDeletedHandler(e)
 		default:
 			err = errors.New("invalid request")
 		}
@@ -0,0 +1,196 @@
+package group
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/user"
+)
+
+type EventName int
+
+const (
+	Unknown EventName = iota
+	GroupCreated
+	GroupUpdated
+	GroupMemberAdded
+	GroupMemberRemoved
+	GroupRoleGranted
+	GroupRoleRevoked
+	GroupDeleted
+)
+
+func ParseEventName(s string) EventName {
+	switch s {
+	case "group_created":
+		return GroupCreated
+	case "group_updated":
+		return GroupUpdated
+	case "group_member_added":
+		return GroupMemberAdded
+	case "group_member_removed":
+		return GroupMemberRemoved
+	case "group_role_granted":
+		return GroupRoleGranted
+	case "group_role_revoked":
+		return GroupRoleRevoked
+	case "group_deleted":
+		return GroupDeleted
+	default:
+		return Unknown
+	}
+}
+
+func (name EventName) String() string {
+	switch name {
+	case GroupCreated:
+		return "group_created"
+	case GroupUpdated:
+		return "group_updated"
+	case GroupMemberAdded:
+		return "group_member_added"
+	case GroupMemberRemoved:
+		return "group_member_removed"
+	case GroupRoleGranted:
+		return "group_role_granted"
+	case GroupRoleRevoked:
+		return "group_role_revoked"
+	case GroupDeleted:
+		return "group_deleted"
+	default:
+		return ""
+	}
+}
+
+func (name EventName) MarshalJSON() ([]byte, error) {
+	return json.Marshal(name.String())
+}
+
+func (name *EventName) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	*name = ParseEventName(s)
+	return nil
+}
+
+type Event struct {
+	Domain    string    `json:"domain"`
+	Name      EventName `json:"name"`
+	GroupID   GroupID   `json:"group_id"` // AggreagateRoot
+	OccuredAt time.Time `json:"occured_at"`
+}
+
+func NewEvent(name EventName, g *Group) *Event {
+	return &Event{
+		Domain:    "identity:groups",
+		Name:      name,
+		GroupID:   g.ID,
+		OccuredAt: g.UpdatedAt,
+	}
+}
+
+func (e *Event) EventName() string {
+	return e.Name.String()
+}
+
+func (e *Event) Topic() string {
+	return "groups." + e.GroupID.String() + "." + e.Name.TopicName()
+}
+
+// TopicName is the last token of the topic, "groups.<id>.<name>".
+func (name EventName) TopicName() string {
+	return strings.TrimPrefix(name.String(), "group_")
+}
+
+func ParseTopicName(s string) EventName {
+	return ParseEventName("group_" + s)
+}
+
+type GroupCreatedEvent struct {
+	*Event
+	Group *Group `json:"group"`
+}
+
+func NewGroupCreatedEvent(g *Group) events.DomainEvent {
+	return &GroupCreatedEvent{
+		Event: NewEvent(GroupCreated, g),
+		Group: g,
+	}
+}
+
+type GroupUpdatedEvent struct {
+	*Event
+	GroupName string  `json:"group_name"`
+	Parent    GroupID `json:"parent"`
+}
+
+func NewGroupUpdatedEvent(g *Group) events.DomainEvent {
+	return &GroupUpdatedEvent{
+		Event:     NewEvent(GroupUpdated, g),
+		GroupName: g.Name,
+		Parent:    g.Parent,
+	}
+}
+
+type GroupMemberAddedEvent struct {
+	*Event
+	UserID user.UserID `json:"user_id"`
+}
+
+func NewGroupMemberAddedEvent(g *Group, id user.UserID) events.DomainEvent {
+	return &GroupMemberAddedEvent{
+		Event:  NewEvent(GroupMemberAdded, g),
+		UserID: id,
+	}
+}
+
+type GroupMemberRemovedEvent struct {
+	*Event
+	UserID user.UserID `json:"user_id"`
+}
+
+func NewGroupMemberRemovedEvent(g *Group, id user.UserID) events.DomainEvent {
+	return &GroupMemberRemovedEvent{
+		Event:  NewEvent(GroupMemberRemoved, g),
+		UserID: id,
+	}
+}
+
+type GroupRoleGrantedEvent struct {
+	*Event
+	Role string `json:"role"`
+}
+
+func NewGroupRoleGrantedEvent(g *Group, role string) events.DomainEvent {
+	return &GroupRoleGrantedEvent{
+		Event: NewEvent(GroupRoleGranted, g),
+		Role:  role,
+	}
+}
+
+type GroupRoleRevokedEvent struct {
+	*Event
+	Role string `json:"role"`
+}
+
+func NewGroupRoleRevokedEvent(g *Group, role string) events.DomainEvent {
+	return &GroupRoleRevokedEvent{
+		Event: NewEvent(GroupRoleRevoked, g),
+		Role:  role,
+	}
+}
+
+type GroupDeletedEvent struct {
+	*Event
+}
+
+func NewGroupDeletedEvent(g *Group) events.DomainEvent {
+	return &GroupDeletedEvent{
+		Event: NewEvent(GroupDeleted, g),
+	}
+}
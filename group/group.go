@@ -0,0 +1,189 @@
+package group
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/model"
+	"github.com/mirror520/identity/user"
+)
+
+var (
+	ErrGroupNotFound  = errors.New("group not found")
+	ErrInvalidGroupID = errors.New("invalid group id")
+	ErrNameEmpty      = errors.New("group name empty")
+	ErrCyclicGroup    = errors.New("group can't be nested in itself")
+	ErrHasSubgroups   = errors.New("group has subgroups")
+	ErrMemberExists   = errors.New("member exists")
+	ErrMemberNotFound = errors.New("member not found")
+	ErrParentNotFound = errors.New("parent group not found")
+	ErrNestedTooDeep  = errors.New("groups nested too deep")
+)
+
+// MaxDepth bounds the nesting of the groups, the memberships are resolved
+// through the ancestors on every authorization.
+const MaxDepth = 16
+
+type GroupID string // AggregateRoot
+
+func MakeID() GroupID {
+	return GroupID(ulid.Make().String())
+}
+
+// ParseID rejects the IDs which can't be a token of the topics.
+func ParseID(id string) (GroupID, error) {
+	if id == "" || strings.ContainsAny(id, ".*> \t\r\n") {
+		return "", ErrInvalidGroupID
+	}
+
+	return GroupID(id), nil
+}
+
+func (id GroupID) String() string {
+	return string(id)
+}
+
+// Group gathers the users, a member of a subgroup is a member of all its
+// ancestors and is granted the roles of them.
+type Group struct {
+	ID      GroupID       `json:"id"`
+	Name    string        `json:"name"`
+	Parent  GroupID       `json:"parent,omitempty"`
+	Members []user.UserID `json:"members"`
+	Roles   []string      `json:"roles"`
+
+	model.Model
+
+	events.EventStore `json:"-"`
+}
+
+// NewGroup creates a group under the parent, an empty parent creates a
+// root group. The parent is checked by the service.
+func NewGroup(name string, parent GroupID) (*Group, error) {
+	if name == "" {
+		return nil, ErrNameEmpty
+	}
+
+	now := time.Now()
+	g := &Group{
+		ID:      MakeID(),
+		Name:    name,
+		Parent:  parent,
+		Members: make([]user.UserID, 0),
+		Roles:   make([]string, 0),
+		Model: model.Model{
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+
+		EventStore: events.NewEventStore(),
+	}
+
+	g.AddEvent(NewGroupCreatedEvent(g))
+	return g, nil
+}
+
+// Update renames the group and moves it under the parent, the service
+// checks that the parent isn't a descendant of the group.
+func (g *Group) Update(name string, parent GroupID) error {
+	if name == "" {
+		return ErrNameEmpty
+	}
+
+	if parent == g.ID {
+		return ErrCyclicGroup
+	}
+
+	g.Name = name
+	g.Parent = parent
+	g.UpdatedAt = time.Now()
+
+	e := NewGroupUpdatedEvent(g)
+	g.AddEvent(e)
+	return nil
+}
+
+func (g *Group) HasMember(id user.UserID) bool {
+	return slices.Contains(g.Members, id)
+}
+
+func (g *Group) AddMember(id user.UserID) error {
+	if g.HasMember(id) {
+		return ErrMemberExists
+	}
+
+	g.Members = append(slices.Clone(g.Members), id)
+	g.UpdatedAt = time.Now()
+
+	e := NewGroupMemberAddedEvent(g, id)
+	g.AddEvent(e)
+	return nil
+}
+
+func (g *Group) RemoveMember(id user.UserID) error {
+	if !g.HasMember(id) {
+		return ErrMemberNotFound
+	}
+
+	g.Members = slices.DeleteFunc(slices.Clone(g.Members), func(member user.UserID) bool {
+		return member == id
+	})
+	g.UpdatedAt = time.Now()
+
+	e := NewGroupMemberRemovedEvent(g, id)
+	g.AddEvent(e)
+	return nil
+}
+
+func (g *Group) HasRole(role string) bool {
+	return slices.Contains(g.Roles, role)
+}
+
+// GrantRole grants the role to every member of the group and of its
+// subgroups.
+func (g *Group) GrantRole(role string) error {
+	role, err := user.ParseRole(role)
+	if err != nil {
+		return err
+	}
+
+	if g.HasRole(role) {
+		return user.ErrRoleGranted
+	}
+
+	g.Roles = append(slices.Clone(g.Roles), role)
+	g.UpdatedAt = time.Now()
+
+	e := NewGroupRoleGrantedEvent(g, role)
+	g.AddEvent(e)
+	return nil
+}
+
+func (g *Group) RevokeRole(role string) error {
+	if !g.HasRole(role) {
+		return user.ErrRoleNotGranted
+	}
+
+	g.Roles = slices.DeleteFunc(slices.Clone(g.Roles), func(r string) bool {
+		return r == role
+	})
+	g.UpdatedAt = time.Now()
+
+	e := NewGroupRoleRevokedEvent(g, role)
+	g.AddEvent(e)
+	return nil
+}
+
+func (g *Group) Delete() {
+	now := time.Now()
+	g.UpdatedAt = now
+	g.DeletedAt = now
+
+	e := NewGroupDeletedEvent(g)
+	g.AddEvent(e)
+}
@@ -0,0 +1,92 @@
+package group
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mirror520/identity/user"
+)
+
+func TestMembers(t *testing.T) {
+	assert := assert.New(t)
+
+	g, err := NewGroup("Engineering", "")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	id := user.MakeID()
+
+	assert.NoError(g.AddMember(id))
+	assert.True(g.HasMember(id))
+	assert.ErrorIs(g.AddMember(id), ErrMemberExists)
+
+	assert.NoError(g.GrantRole("support"))
+	assert.ErrorIs(g.GrantRole("support"), user.ErrRoleGranted)
+	assert.ErrorIs(g.GrantRole(""), user.ErrInvalidRole)
+
+	assert.NoError(g.RemoveMember(id))
+	assert.Empty(g.Members)
+	assert.ErrorIs(g.RemoveMember(id), ErrMemberNotFound)
+
+	assert.NoError(g.RevokeRole("support"))
+	assert.ErrorIs(g.RevokeRole("support"), user.ErrRoleNotGranted)
+
+	names := make([]string, 0)
+	for _, e := range g.Events() {
+		names = append(names, e.EventName())
+	}
+
+	assert.Equal([]string{
+		GroupCreated.String(),
+		GroupMemberAdded.String(),
+		GroupRoleGranted.String(),
+		GroupMemberRemoved.String(),
+		GroupRoleRevoked.String(),
+	}, names)
+
+	assert.Equal("groups."+g.ID.String()+".member_added", g.Events()[1].Topic())
+	assert.Equal(GroupMemberAdded, ParseTopicName("member_added"))
+
+	bs, err := json.Marshal(g.Events()[1])
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	var e *GroupMemberAddedEvent
+	if err := json.Unmarshal(bs, &e); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(id, e.UserID)
+	assert.Equal(GroupMemberAdded, e.Name)
+}
+
+func TestUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewGroup("", "")
+	assert.ErrorIs(err, ErrNameEmpty)
+
+	g, err := NewGroup("Engineering", "")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.ErrorIs(g.Update("Engineering", g.ID), ErrCyclicGroup)
+	assert.ErrorIs(g.Update("", ""), ErrNameEmpty)
+
+	parent := MakeID()
+	assert.NoError(g.Update("Platform", parent))
+	assert.Equal("Platform", g.Name)
+	assert.Equal(parent, g.Parent)
+
+	_, err = ParseID("groups.*")
+	assert.ErrorIs(err, ErrInvalidGroupID)
+}
@@ -0,0 +1,19 @@
+package group
+
+import "github.com/mirror520/identity/user"
+
+type Repository interface {
+	// Command
+
+	Store(g *Group) error
+	Remove(id GroupID) error
+
+	// Query
+
+	Find(id GroupID) (*Group, error)
+	FindByMember(id user.UserID) ([]*Group, error) // the groups the user is a direct member of
+	FindByParent(id GroupID) ([]*Group, error)
+	List() ([]*Group, error)
+
+	Close() error
+}
@@ -0,0 +1,87 @@
+package keys
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/mirror520/identity/events"
+)
+
+type EventName int
+
+const (
+	Unknown EventName = iota
+	KeyRotated
+)
+
+func ParseEventName(s string) EventName {
+	switch s {
+	case "key_rotated":
+		return KeyRotated
+	default:
+		return Unknown
+	}
+}
+
+func (name EventName) String() string {
+	switch name {
+	case KeyRotated:
+		return "key_rotated"
+	default:
+		return ""
+	}
+}
+
+func (name EventName) MarshalJSON() ([]byte, error) {
+	return json.Marshal(name.String())
+}
+
+func (name *EventName) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	*name = ParseEventName(s)
+	return nil
+}
+
+type Event struct {
+	Domain    string    `json:"domain"`
+	Name      EventName `json:"name"`
+	KeyID     string    `json:"kid"`
+	OccuredAt time.Time `json:"occured_at"`
+}
+
+func NewEvent(name EventName, k *Key) *Event {
+	return &Event{
+		Domain:    "identity:keys",
+		Name:      name,
+		KeyID:     k.ID,
+		OccuredAt: time.Now(),
+	}
+}
+
+func (e *Event) EventName() string {
+	return e.Name.String()
+}
+
+func (e *Event) Topic() string {
+	name := strings.TrimPrefix(e.Name.String(), "key_")
+	return "keys." + name
+}
+
+// KeyRotatedEvent tells the instances to reload the key ring, the private
+// key itself never leaves the shared directory.
+type KeyRotatedEvent struct {
+	*Event
+	ActivatedAt time.Time `json:"activated_at"`
+}
+
+func NewKeyRotatedEvent(k *Key) events.DomainEvent {
+	return &KeyRotatedEvent{
+		Event:       NewEvent(KeyRotated, k),
+		ActivatedAt: k.ActivatedAt,
+	}
+}
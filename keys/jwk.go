@@ -0,0 +1,188 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+)
+
+var encoding = base64.RawURLEncoding
+
+// JWK is the public key in RFC 7517.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC and OKP
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK returns the public key, or false for a symmetric key.
+func (k *Key) JWK() (*JWK, bool) {
+	jwk := &JWK{
+		KeyID:     k.ID,
+		Use:       "sig",
+		Algorithm: k.Method.Alg(),
+	}
+
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encoding.EncodeToString(pub.N.Bytes())
+		jwk.E = encoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+
+		jwk.KeyType = "EC"
+		jwk.Curve = pub.Curve.Params().Name
+		jwk.X = encoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = encoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = encoding.EncodeToString(pub)
+
+	default:
+		return nil, false
+	}
+
+	return jwk, true
+}
+
+// PublicKey parses the public key of the algorithms signing the tokens.
+func (jwk *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := encoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+
+		e, err := encoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, ErrInvalidKey
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+		if jwk.Curve != elliptic.P256().Params().Name {
+			return nil, ErrAlgorithmNotSupported
+		}
+
+		x, err := encoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+
+		y, err := encoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, ErrInvalidKey
+		}
+
+		return pub, nil
+
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return nil, ErrAlgorithmNotSupported
+		}
+
+		x, err := encoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, ErrInvalidKey
+		}
+
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, ErrAlgorithmNotSupported
+	}
+}
+
+// Thumbprint computes the SHA-256 thumbprint of RFC 7638.
+func (jwk *JWK) Thumbprint() (string, error) {
+	// the required members only, in lexicographic order
+	var members any
+	switch jwk.KeyType {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Curve, jwk.KeyType, jwk.X, jwk.Y}
+
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+
+	default:
+		return "", ErrAlgorithmNotSupported
+	}
+
+	bs, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(bs)
+	return encoding.EncodeToString(sum[:]), nil
+}
+
+// NewJWKS publishes the public keys, the symmetric ones are left out.
+func NewJWKS(keys ...*Key) *JWKS {
+	jwks := &JWKS{
+		Keys: make([]JWK, 0),
+	}
+
+	for _, k := range keys {
+		if jwk, ok := k.JWK(); ok {
+			jwks.Keys = append(jwks.Keys, *jwk)
+		}
+	}
+
+	return jwks
+}
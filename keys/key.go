@@ -0,0 +1,288 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrAlgorithmNotSupported = errors.New("algorithm not supported")
+	ErrKeyMismatched         = errors.New("key mismatched")
+	ErrInvalidKey            = errors.New("invalid key")
+	ErrKeyNotFound           = errors.New("key not found")
+	ErrKeyExpired            = errors.New("key expired")
+	ErrRingEmpty             = errors.New("key ring empty")
+)
+
+// AccessTokenType is the typ header of the access tokens of RFC 9068.
+const AccessTokenType = "at+jwt"
+
+// Key signs the tokens, and publishes its public half if it's asymmetric.
+type Key struct {
+	ID          string // kid
+	Method      jwt.SigningMethod
+	ActivatedAt time.Time
+	ExpiredAt   time.Time // zero if not retired
+
+	private any // crypto.Signer or []byte
+	public  any // crypto.PublicKey or []byte
+	file    string
+}
+
+func NewKey(alg string, private any) (*Key, error) {
+	method := jwt.GetSigningMethod(alg)
+	if method == nil {
+		return nil, ErrAlgorithmNotSupported
+	}
+
+	if alg == "HS256" {
+		secret, ok := private.([]byte)
+		if !ok || len(secret) == 0 {
+			return nil, ErrKeyMismatched
+		}
+
+		return &Key{
+			ID:      "hmac", // never derived from the secret
+			Method:  method,
+			private: secret,
+			public:  secret,
+		}, nil
+	}
+
+	var public crypto.PublicKey
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		if alg != "RS256" {
+			return nil, ErrKeyMismatched
+		}
+		public = &k.PublicKey
+
+	case *ecdsa.PrivateKey:
+		if alg != "ES256" || k.Curve != elliptic.P256() {
+			return nil, ErrKeyMismatched
+		}
+		public = &k.PublicKey
+
+	case ed25519.PrivateKey:
+		if alg != "EdDSA" {
+			return nil, ErrKeyMismatched
+		}
+		public = k.Public()
+
+	default:
+		return nil, ErrAlgorithmNotSupported
+	}
+
+	key := &Key{
+		Method:  method,
+		private: private,
+		public:  public,
+	}
+
+	jwk, _ := key.JWK()
+	kid, err := jwk.Thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = kid
+
+	return key, nil
+}
+
+func GenerateKey(alg string) (*Key, error) {
+	var (
+		private any
+		err     error
+	)
+
+	switch alg {
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, ErrAlgorithmNotSupported
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKey(alg, private)
+}
+
+// ReadKey reads the private key and its validity from the PEM file.
+func ReadKey(path string) (*Key, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(bs)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	private, err := parsePrivateKey(block)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := NewKey(algorithm(private), private)
+	if err != nil {
+		return nil, err
+	}
+	key.file = path
+
+	if v, ok := block.Headers["Activated-At"]; ok {
+		if key.ActivatedAt, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, err
+		}
+	}
+
+	if v, ok := block.Headers["Expired-At"]; ok {
+		if key.ExpiredAt, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+func algorithm(private any) string {
+	switch private.(type) {
+	case *rsa.PrivateKey:
+		return "RS256"
+	case *ecdsa.PrivateKey:
+		return "ES256"
+	case ed25519.PrivateKey:
+		return "EdDSA"
+	default:
+		return ""
+	}
+}
+
+// parsePrivateKey accepts PKCS #8, and PKCS #1 or SEC 1 from OpenSSL.
+func parsePrivateKey(block *pem.Block) (any, error) {
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, ErrInvalidKey
+	}
+}
+
+// Save writes the private key in PKCS #8 into the directory, named by
+// its kid, with the validity in the PEM headers.
+func (k *Key) Save(dir string) error {
+	if _, ok := k.private.([]byte); ok {
+		return ErrAlgorithmNotSupported
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	block := &pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: make(map[string]string),
+		Bytes:   der,
+	}
+
+	if !k.ActivatedAt.IsZero() {
+		block.Headers["Activated-At"] = k.ActivatedAt.UTC().Format(time.RFC3339)
+	}
+
+	if !k.ExpiredAt.IsZero() {
+		block.Headers["Expired-At"] = k.ExpiredAt.UTC().Format(time.RFC3339)
+	}
+
+	path := filepath.Join(dir, k.ID+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		return err
+	}
+
+	// renamed by its kid
+	if k.file != "" && k.file != path {
+		if err := os.Remove(k.file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	k.file = path
+	return nil
+}
+
+func (k *Key) Remove() error {
+	if k.file == "" {
+		return nil
+	}
+
+	return os.Remove(k.file)
+}
+
+// Valid reports whether the key has been activated and not expired yet.
+func (k *Key) Valid(at time.Time) bool {
+	return !at.Before(k.ActivatedAt) && !k.Expired(at)
+}
+
+func (k *Key) Expired(at time.Time) bool {
+	return !k.ExpiredAt.IsZero() && !at.Before(k.ExpiredAt)
+}
+
+func (k *Key) Sign(claims jwt.Claims) (string, error) {
+	return k.sign(claims, "")
+}
+
+// SignAccessToken signs the access token typed as RFC 9068, the other
+// tokens signed by the key are never taken for one.
+func (k *Key) SignAccessToken(claims jwt.Claims) (string, error) {
+	return k.sign(claims, AccessTokenType)
+}
+
+func (k *Key) sign(claims jwt.Claims, typ string) (string, error) {
+	token := jwt.NewWithClaims(k.Method, claims)
+	token.Header["kid"] = k.ID
+
+	if typ != "" {
+		token.Header["typ"] = typ
+	}
+
+	return token.SignedString(k.private)
+}
+
+// Keyfunc verifies the token with the public key.
+func (k *Key) Keyfunc(t *jwt.Token) (any, error) {
+	if t.Method.Alg() != k.Method.Alg() {
+		return nil, ErrKeyMismatched
+	}
+
+	if kid, ok := t.Header["kid"].(string); ok && kid != k.ID {
+		return nil, ErrKeyMismatched
+	}
+
+	return k.public, nil
+}
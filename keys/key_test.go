@@ -0,0 +1,200 @@
+package keys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mirror520/identity/conf"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, alg := range []string{"RS256", "ES256", "EdDSA"} {
+		key, err := GenerateKey(alg)
+		if err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+
+		claims := jwt.RegisteredClaims{
+			Subject:   "user01",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}
+
+		tokenStr, err := key.Sign(claims)
+		if err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+
+		token, err := jwt.ParseWithClaims(tokenStr, &jwt.RegisteredClaims{}, key.Keyfunc)
+		if err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+
+		assert.Equal(alg, token.Method.Alg())
+		assert.Equal(key.ID, token.Header["kid"])
+
+		jwk, ok := key.JWK()
+		assert.True(ok)
+		assert.Equal(key.ID, jwk.KeyID)
+		assert.Equal(alg, jwk.Algorithm)
+
+		// verified by the public key of the JWK
+		public, err := jwk.PublicKey()
+		if err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+
+		_, err = jwt.ParseWithClaims(tokenStr, &jwt.RegisteredClaims{}, func(t *jwt.Token) (any, error) {
+			return public, nil
+		})
+		assert.NoError(err)
+	}
+}
+
+func TestKeyMismatched(t *testing.T) {
+	assert := assert.New(t)
+
+	key, _ := GenerateKey("ES256")
+	other, _ := GenerateKey("ES256")
+
+	tokenStr, _ := other.Sign(jwt.RegisteredClaims{Subject: "user01"})
+
+	_, err := jwt.ParseWithClaims(tokenStr, &jwt.RegisteredClaims{}, key.Keyfunc)
+	assert.ErrorIs(err, ErrKeyMismatched)
+}
+
+func TestThumbprint(t *testing.T) {
+	assert := assert.New(t)
+
+	// RFC 7638 §3.1
+	jwk := &JWK{
+		KeyType: "RSA",
+		N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn6" +
+			"4tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n9" +
+			"1CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E: "AQAB",
+	}
+
+	kid, err := jwk.Thumbprint()
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", kid)
+}
+
+func TestLoadRing(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := conf.JWT{
+		Algorithm: "EdDSA",
+		Keys:      t.TempDir() + "/keys",
+	}
+
+	// never generated by an instance
+	_, err := LoadRing(cfg)
+	assert.ErrorIs(err, ErrRingEmpty)
+
+	ring, err := OpenRing(cfg)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	now := time.Now()
+	expected, err := ring.Rotate(now, 0)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	loaded, err := LoadRing(cfg)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	active, _ := loaded.Active(now)
+	assert.Equal(expected.ID, active.ID)
+
+	hmac, _ := NewKey("HS256", []byte("secret"))
+	jwks := NewJWKS(active, hmac)
+	assert.Len(jwks.Keys, 1)
+	assert.Equal("OKP", jwks.Keys[0].KeyType)
+}
+
+func TestRotate(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := conf.JWT{
+		Algorithm: "ES256",
+		Keys:      t.TempDir(),
+	}
+
+	ring, err := OpenRing(cfg)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	now := time.Now()
+	retired, err := ring.Rotate(now, 0)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	tokenStr, err := ring.Sign(jwt.RegisteredClaims{Subject: "user01"})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	// scheduled
+	key, err := ring.Rotate(now.Add(time.Hour), 30*time.Minute)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	active, _ := ring.Active(now)
+	assert.Equal(retired.ID, active.ID)
+	assert.Len(ring.Keys(now), 2)
+
+	// activated
+	active, _ = ring.Active(now.Add(time.Hour))
+	assert.Equal(key.ID, active.ID)
+
+	// the retired key verifies within the overlap
+	_, err = jwt.ParseWithClaims(tokenStr, &jwt.RegisteredClaims{}, ring.Keyfunc)
+	assert.NoError(err)
+
+	_, err = ring.Find(retired.ID, now.Add(90*time.Minute))
+	assert.ErrorIs(err, ErrKeyExpired)
+
+	// reloaded by the other instances
+	other := &Ring{dir: cfg.Keys, alg: cfg.Algorithm}
+	if err := other.Reload(); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Len(other.Keys(now), 2)
+
+	k, err := other.Find(retired.ID, now)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(now.Add(90*time.Minute).Truncate(time.Second).Unix(), k.ExpiredAt.Unix())
+}
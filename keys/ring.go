@@ -0,0 +1,280 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/mirror520/identity/conf"
+)
+
+var instance *Ring
+
+func ReplaceGlobals(r *Ring) {
+	instance = r
+}
+
+func G() *Ring {
+	if instance == nil {
+		panic("key ring not loaded")
+	}
+
+	return instance
+}
+
+// Ring holds the active signing key, the scheduled keys and the retired
+// keys which still verify the tokens they signed until they expire.
+type Ring struct {
+	dir  string
+	alg  string
+	keys []*Key // sorted by ActivatedAt
+	sync.RWMutex
+}
+
+func NewRing(keys ...*Key) *Ring {
+	r := new(Ring)
+	r.set(keys)
+	return r
+}
+
+// LoadRing loads the key ring of the configuration for serving. The
+// directory is shared by every instance and never generated into, a ring
+// without a valid key fails with ErrRingEmpty until `keys rotate` runs.
+func LoadRing(cfg conf.JWT) (*Ring, error) {
+	r, err := OpenRing(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.Active(time.Now()); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrRingEmpty, cfg.Keys)
+	}
+
+	return r, nil
+}
+
+// OpenRing opens the key ring of the configuration as it is, even empty.
+func OpenRing(cfg conf.JWT) (*Ring, error) {
+	if cfg.Algorithm == "HS256" {
+		key, err := NewKey(cfg.Algorithm, cfg.Secret)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewRing(key), nil
+	}
+
+	r := &Ring{
+		dir: cfg.Keys,
+		alg: cfg.Algorithm,
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload reads the keys from the directory again.
+func (r *Ring) Reload() error {
+	if r.dir == "" {
+		return nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(r.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	keys := make([]*Key, 0, len(paths))
+	for _, path := range paths {
+		key, err := ReadKey(path)
+		if err != nil {
+			return err
+		}
+
+		if key.Expired(now) {
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	r.Lock()
+	r.set(keys)
+	r.Unlock()
+
+	return nil
+}
+
+func (r *Ring) set(keys []*Key) {
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].ActivatedAt.Before(keys[j].ActivatedAt)
+	})
+
+	r.keys = keys
+}
+
+// Rotate generates a key activated at the given time, the keys signing
+// until then are retired after the overlap.
+func (r *Ring) Rotate(activatedAt time.Time, overlap time.Duration) (*Key, error) {
+	if r.dir == "" {
+		return nil, ErrAlgorithmNotSupported
+	}
+
+	key, err := GenerateKey(r.alg)
+	if err != nil {
+		return nil, err
+	}
+	key.ActivatedAt = activatedAt.Truncate(time.Second)
+
+	r.Lock()
+	defer r.Unlock()
+
+	now := time.Now()
+	expiredAt := key.ActivatedAt.Add(overlap)
+
+	keys := make([]*Key, 0, len(r.keys)+1)
+	for _, k := range r.keys {
+		if k.Expired(now) {
+			if err := k.Remove(); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+			continue
+		}
+
+		retired := !k.ExpiredAt.IsZero() && !k.ExpiredAt.After(expiredAt)
+		if k.ActivatedAt.Before(key.ActivatedAt) && !retired {
+			k.ExpiredAt = expiredAt
+			if err := k.Save(r.dir); err != nil {
+				return nil, err
+			}
+		}
+
+		keys = append(keys, k)
+	}
+
+	if err := key.Save(r.dir); err != nil {
+		return nil, err
+	}
+
+	r.set(append(keys, key))
+	return key, nil
+}
+
+// Active returns the latest activated key to sign.
+func (r *Ring) Active(at time.Time) (*Key, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	for i := len(r.keys) - 1; i >= 0; i-- {
+		if k := r.keys[i]; k.Valid(at) {
+			return k, nil
+		}
+	}
+
+	return nil, ErrKeyNotFound
+}
+
+// Find returns the key to verify, including the retired ones.
+func (r *Ring) Find(kid string, at time.Time) (*Key, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	for _, k := range r.keys {
+		if k.ID != kid {
+			continue
+		}
+
+		if k.Expired(at) {
+			return nil, ErrKeyExpired
+		}
+
+		return k, nil
+	}
+
+	return nil, ErrKeyNotFound
+}
+
+// Keys returns the keys not expired yet, the scheduled ones are included
+// so that the consumers have them cached before the activation.
+func (r *Ring) Keys(at time.Time) []*Key {
+	r.RLock()
+	defer r.RUnlock()
+
+	keys := make([]*Key, 0, len(r.keys))
+	for _, k := range r.keys {
+		if !k.Expired(at) {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+func (r *Ring) Algorithms() []string {
+	r.RLock()
+	defer r.RUnlock()
+
+	algs := make([]string, 0, 1)
+	seen := make(map[string]struct{})
+	for _, k := range r.keys {
+		alg := k.Method.Alg()
+		if _, ok := seen[alg]; ok {
+			continue
+		}
+
+		seen[alg] = struct{}{}
+		algs = append(algs, alg)
+	}
+
+	return algs
+}
+
+func (r *Ring) Sign(claims jwt.Claims) (string, error) {
+	key, err := r.Active(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	return key.Sign(claims)
+}
+
+func (r *Ring) SignAccessToken(claims jwt.Claims) (string, error) {
+	key, err := r.Active(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	return key.SignAccessToken(claims)
+}
+
+// Keyfunc picks the key by the kid of the token, the tokens without
+// kid are signed before the key ring.
+func (r *Ring) Keyfunc(t *jwt.Token) (any, error) {
+	var (
+		key *Key
+		err error
+	)
+
+	now := time.Now()
+	if kid, ok := t.Header["kid"].(string); ok {
+		key, err = r.Find(kid, now)
+	} else {
+		key, err = r.Active(now)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return key.Keyfunc(t)
+}
@@ -0,0 +1,71 @@
+package lease
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrHeld = errors.New("held by another")
+
+// Store grants a key to a single holder until the lease expires. The
+// shared stores grant it by a compare-and-set, so a key is held by one
+// instance at a time.
+type Store interface {
+	// Acquire holds the key for the holder until the time, it renews the
+	// lease of the same holder, ErrHeld if another holder holds it
+	Acquire(key string, holder string, until time.Time) error
+
+	// Release drops the lease of the holder before it expires, the key held
+	// by another holder is left alone
+	Release(key string, holder string) error
+}
+
+type lease struct {
+	holder string
+	until  time.Time
+}
+
+type inMemStore struct {
+	leases map[string]lease // map[key]lease
+	sync.Mutex
+}
+
+// NewInMemStore holds the leases of a single instance.
+func NewInMemStore() Store {
+	return &inMemStore{
+		leases: make(map[string]lease),
+	}
+}
+
+func (s *inMemStore) Acquire(key string, holder string, until time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+
+	// drop the expired ones while here
+	for k, l := range s.leases {
+		if !now.Before(l.until) {
+			delete(s.leases, k)
+		}
+	}
+
+	if l, ok := s.leases[key]; ok && l.holder != holder {
+		return ErrHeld
+	}
+
+	s.leases[key] = lease{holder, until}
+	return nil
+}
+
+func (s *inMemStore) Release(key string, holder string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if l, ok := s.leases[key]; ok && l.holder == holder {
+		delete(s.leases, key)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package lease
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquire(t *testing.T) {
+	assert := assert.New(t)
+
+	leases := NewInMemStore()
+	until := time.Now().Add(time.Minute)
+
+	err := leases.Acquire("username:user01", "A", until)
+	assert.NoError(err)
+
+	// renewed by the same holder
+	err = leases.Acquire("username:user01", "A", until.Add(time.Minute))
+	assert.NoError(err)
+
+	err = leases.Acquire("username:user01", "B", until)
+	assert.ErrorIs(err, ErrHeld)
+
+	err = leases.Acquire("username:user02", "B", until)
+	assert.NoError(err)
+}
+
+func TestAcquireExpired(t *testing.T) {
+	assert := assert.New(t)
+
+	leases := NewInMemStore()
+
+	err := leases.Acquire("username:user01", "A", time.Now().Add(-time.Second))
+	assert.NoError(err)
+
+	err = leases.Acquire("username:user01", "B", time.Now().Add(time.Minute))
+	assert.NoError(err)
+}
+
+func TestRelease(t *testing.T) {
+	assert := assert.New(t)
+
+	leases := NewInMemStore()
+	until := time.Now().Add(time.Minute)
+
+	err := leases.Acquire("username:user01", "A", until)
+	assert.NoError(err)
+
+	// left alone for another holder
+	assert.NoError(leases.Release("username:user01", "B"))
+
+	err = leases.Acquire("username:user01", "B", until)
+	assert.ErrorIs(err, ErrHeld)
+
+	assert.NoError(leases.Release("username:user01", "A"))
+
+	err = leases.Acquire("username:user01", "B", until)
+	assert.NoError(err)
+}
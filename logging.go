@@ -2,11 +2,19 @@ package identity
 
 import (
 	"context"
+	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/mirror520/identity/client"
+	"github.com/mirror520/identity/group"
 	"github.com/mirror520/identity/model"
+	"github.com/mirror520/identity/oidc"
+	"github.com/mirror520/identity/otp"
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/token"
 	"github.com/mirror520/identity/user"
+	"github.com/mirror520/identity/verifier"
 )
 
 func LoggingMiddleware(log *zap.Logger) ServiceMiddleware {
@@ -26,12 +34,13 @@ type loggingMiddleware struct {
 	next Service
 }
 
-func (mw *loggingMiddleware) Register(username string, name string, email string) (*user.User, error) {
+func (mw *loggingMiddleware) Register(tenantID tenant.TenantID, username string, name string, email string, password string) (*user.User, error) {
 	log := mw.log.With(
 		zap.String("action", "register"),
+		zap.String("tenant_id", tenantID.String()),
 	)
 
-	u, err := mw.next.Register(username, name, email)
+	u, err := mw.next.Register(tenantID, username, name, email, password)
 	if err != nil {
 		log.Error(err.Error())
 		return nil, err
@@ -41,13 +50,53 @@ func (mw *loggingMiddleware) Register(username string, name string, email string
 	return u, nil
 }
 
-func (mw *loggingMiddleware) OTPVerify(otp string, id user.UserID) (*user.User, error) {
+func (mw *loggingMiddleware) OTPEnroll(code string, id user.UserID) (*otp.Enrollment, error) {
+	log := mw.log.With(
+		zap.String("action", "otp_enroll"),
+		zap.String("user_id", id.String()),
+	)
+
+	enrollment, err := mw.next.OTPEnroll(code, id)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("otp enrolled")
+	return enrollment, nil
+}
+
+func (mw *loggingMiddleware) VerifyUser(ctx context.Context, code string, id user.UserID) (*user.User, error) {
+	log := mw.log.With(
+		zap.String("action", "verify_user"),
+		zap.String("user_id", id.String()),
+	)
+
+	if info, ok := ctx.Value(model.REQUEST_INFO).(*RequestInfo); ok {
+		log = log.With(zap.String("remote", info.ClientIP))
+	}
+
+	u, err := mw.next.VerifyUser(ctx, code, id)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("user verified", zap.String("username", u.Username))
+	return u, nil
+}
+
+func (mw *loggingMiddleware) OTPVerify(ctx context.Context, otp string, id user.UserID) (*user.User, error) {
 	log := mw.log.With(
 		zap.String("action", "otp_verify"),
 		zap.String("user_id", id.String()),
 	)
 
-	u, err := mw.next.OTPVerify(otp, id)
+	if info, ok := ctx.Value(model.REQUEST_INFO).(*RequestInfo); ok {
+		log = log.With(zap.String("remote", info.ClientIP))
+	}
+
+	u, err := mw.next.OTPVerify(ctx, otp, id)
 	if err != nil {
 		log.Error(err.Error())
 		return nil, err
@@ -57,13 +106,14 @@ func (mw *loggingMiddleware) OTPVerify(otp string, id user.UserID) (*user.User,
 	return u, nil
 }
 
-func (mw *loggingMiddleware) SignIn(credential string, provider user.SocialProvider) (*user.User, error) {
+func (mw *loggingMiddleware) SignIn(tenantID tenant.TenantID, credential string, provider user.SocialProvider) (*user.User, error) {
 	log := mw.log.With(
 		zap.String("action", "signin"),
+		zap.String("tenant_id", tenantID.String()),
 		zap.String("provider", string(provider)),
 	)
 
-	u, err := mw.next.SignIn(credential, provider)
+	u, err := mw.next.SignIn(tenantID, credential, provider)
 	if err != nil {
 		log.Error(err.Error())
 		return nil, err
@@ -76,6 +126,43 @@ func (mw *loggingMiddleware) SignIn(credential string, provider user.SocialProvi
 	return u, nil
 }
 
+func (mw *loggingMiddleware) SignInWithPassword(ctx context.Context, tenantID tenant.TenantID, username string, password string) (*user.User, error) {
+	log := mw.log.With(
+		zap.String("action", "signin_with_password"),
+		zap.String("tenant_id", tenantID.String()),
+		zap.String("username", username),
+	)
+
+	if info, ok := ctx.Value(model.REQUEST_INFO).(*RequestInfo); ok {
+		log = log.With(zap.String("remote", info.ClientIP))
+	}
+
+	u, err := mw.next.SignInWithPassword(ctx, tenantID, username, password)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("user signed in", zap.String("user_id", u.ID.String()))
+	return u, nil
+}
+
+func (mw *loggingMiddleware) ChangePassword(id user.UserID, authTime time.Time, oldPassword string, newPassword string) (*user.User, error) {
+	log := mw.log.With(
+		zap.String("action", "change_password"),
+		zap.String("user_id", id.String()),
+	)
+
+	u, err := mw.next.ChangePassword(id, authTime, oldPassword, newPassword)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("password changed", zap.String("username", u.Username))
+	return u, nil
+}
+
 func (mw *loggingMiddleware) AddSocialAccount(credential string, provider user.SocialProvider, id user.UserID) (*user.User, error) {
 	log := mw.log.With(
 		zap.String("action", "add_social_account"),
@@ -95,83 +182,1662 @@ func (mw *loggingMiddleware) AddSocialAccount(credential string, provider user.S
 	return u, nil
 }
 
-func (mw *loggingMiddleware) CheckHealth(ctx context.Context) error {
+func (mw *loggingMiddleware) RemoveSocialAccount(id user.UserID, provider user.SocialProvider, socialID user.SocialID) (*user.User, error) {
 	log := mw.log.With(
-		zap.String("action", "check_health"),
+		zap.String("action", "remove_social_account"),
+		zap.String("user_id", id.String()),
+		zap.String("provider", string(provider)),
+		zap.String("social_id", string(socialID)),
 	)
 
-	if info, ok := ctx.Value(model.REQUEST_INFO).(*RequestInfo); ok {
-		log = log.With(zap.String("remote", info.ClientIP))
-		log = log.With(zap.String("user-agent", info.UserAgent))
+	u, err := mw.next.RemoveSocialAccount(id, provider, socialID)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
 	}
 
-	err := mw.next.CheckHealth(ctx)
+	log.Info("social account removed", zap.String("username", u.Username))
+	return u, nil
+}
+
+func (mw *loggingMiddleware) LockUser(id user.UserID, reason string) (*user.User, error) {
+	log := mw.log.With(
+		zap.String("action", "lock"),
+		zap.String("user_id", id.String()),
+		zap.String("reason", reason),
+	)
+
+	u, err := mw.next.LockUser(id, reason)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("user locked", zap.String("username", u.Username))
+	return u, nil
+}
+
+func (mw *loggingMiddleware) UnlockUser(id user.UserID) (*user.User, error) {
+	log := mw.log.With(
+		zap.String("action", "unlock"),
+		zap.String("user_id", id.String()),
+	)
+
+	u, err := mw.next.UnlockUser(id)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("user unlocked", zap.String("username", u.Username))
+	return u, nil
+}
+
+func (mw *loggingMiddleware) RevokeUser(id user.UserID, reason string) (*user.User, error) {
+	log := mw.log.With(
+		zap.String("action", "revoke"),
+		zap.String("user_id", id.String()),
+		zap.String("reason", reason),
+	)
+
+	u, err := mw.next.RevokeUser(id, reason)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("user revoked", zap.String("username", u.Username))
+	return u, nil
+}
+
+func (mw *loggingMiddleware) GrantRole(id user.UserID, role string) (*user.User, error) {
+	log := mw.log.With(
+		zap.String("action", "grant_role"),
+		zap.String("user_id", id.String()),
+		zap.String("role", role),
+	)
+
+	u, err := mw.next.GrantRole(id, role)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("role granted", zap.String("username", u.Username))
+	return u, nil
+}
+
+func (mw *loggingMiddleware) RevokeRole(id user.UserID, role string) (*user.User, error) {
+	log := mw.log.With(
+		zap.String("action", "revoke_role"),
+		zap.String("user_id", id.String()),
+		zap.String("role", role),
+	)
+
+	u, err := mw.next.RevokeRole(id, role)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("role revoked", zap.String("username", u.Username))
+	return u, nil
+}
+
+func (mw *loggingMiddleware) UpdateProfile(id user.UserID, profile user.Profile) (*user.User, error) {
+	log := mw.log.With(
+		zap.String("action", "update_profile"),
+		zap.String("user_id", id.String()),
+	)
+
+	u, err := mw.next.UpdateProfile(id, profile)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("profile updated", zap.String("username", u.Username))
+	return u, nil
+}
+
+func (mw *loggingMiddleware) ChangeUsername(id user.UserID, username string) (*user.User, error) {
+	log := mw.log.With(
+		zap.String("action", "change_username"),
+		zap.String("user_id", id.String()),
+		zap.String("username", username),
+	)
+
+	u, err := mw.next.ChangeUsername(id, username)
 	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("username changed")
+	return u, nil
+}
+
+func (mw *loggingMiddleware) DeleteUser(id user.UserID) error {
+	log := mw.log.With(
+		zap.String("action", "delete_user"),
+		zap.String("user_id", id.String()),
+	)
+
+	if err := mw.next.DeleteUser(id); err != nil {
 		log.Error(err.Error())
 		return err
 	}
 
-	log.Info("ok")
+	log.Info("user deleted")
 	return nil
 }
 
-func (mw *loggingMiddleware) Handler() (EventHandler, error) {
-	return mw, nil
+func (mw *loggingMiddleware) PurgeUsers() ([]user.UserID, error) {
+	log := mw.log.With(
+		zap.String("action", "purge_users"),
+	)
+
+	ids, err := mw.next.PurgeUsers()
+	if err != nil {
+		log.Error(err.Error(), zap.Int("count", len(ids)))
+		return ids, err
+	}
+
+	if len(ids) > 0 {
+		log.Info("users purged", zap.Int("count", len(ids)))
+	}
+
+	return ids, nil
 }
 
-func (mw *loggingMiddleware) UserRegisteredHandler(e *user.UserRegisteredEvent) error {
+func (mw *loggingMiddleware) RequestEmailChange(id user.UserID, email string) (*user.User, error) {
 	log := mw.log.With(
-		zap.String("event", e.EventName()),
-		zap.String("user_id", e.UserID.String()),
+		zap.String("action", "request_email_change"),
+		zap.String("user_id", id.String()),
+		zap.String("email", email),
 	)
 
-	handler, err := mw.next.Handler()
+	u, err := mw.next.RequestEmailChange(id, email)
 	if err != nil {
-		return err
+		log.Error(err.Error())
+		return nil, err
 	}
 
-	if err := handler.UserRegisteredHandler(e); err != nil {
+	log.Info("email change requested", zap.String("username", u.Username))
+	return u, nil
+}
+
+func (mw *loggingMiddleware) ConfirmEmailChange(ctx context.Context, code string, id user.UserID) (*user.User, error) {
+	log := mw.log.With(
+		zap.String("action", "confirm_email_change"),
+		zap.String("user_id", id.String()),
+	)
+
+	if info, ok := ctx.Value(model.REQUEST_INFO).(*RequestInfo); ok {
+		log = log.With(zap.String("remote", info.ClientIP))
+	}
+
+	u, err := mw.next.ConfirmEmailChange(ctx, code, id)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("email changed", zap.String("username", u.Username))
+	return u, nil
+}
+
+func (mw *loggingMiddleware) ListUsers(q user.Query) (*user.Page, error) {
+	page, err := mw.next.ListUsers(q)
+	if err != nil {
+		mw.log.Error(err.Error(),
+			zap.String("action", "list_users"),
+			zap.String("tenant_id", q.TenantID.String()),
+		)
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func (mw *loggingMiddleware) RefreshToken(clientID string, clientSecret string, refreshToken string) (*RefreshGrant, error) {
+	log := mw.log.With(
+		zap.String("action", "refresh_token"),
+		zap.String("client_id", clientID),
+	)
+
+	grant, err := mw.next.RefreshToken(clientID, clientSecret, refreshToken)
+	if err != nil {
 		log.Error(err.Error())
+		return nil, err
 	}
 
-	log.Info("user stored")
-	return nil
+	log.Info("token refreshed", zap.String("user_id", grant.User.ID.String()))
+	return grant, nil
 }
 
-func (mw *loggingMiddleware) UserActivatedHandler(e *user.UserActivatedEvent) error {
+func (mw *loggingMiddleware) RevokeToken(clientID string, clientSecret string, tokenStr string) error {
 	log := mw.log.With(
-		zap.String("event", e.EventName()),
-		zap.String("user_id", e.UserID.String()),
+		zap.String("action", "revoke_token"),
+		zap.String("client_id", clientID),
 	)
 
-	handler, err := mw.next.Handler()
+	err := mw.next.RevokeToken(clientID, clientSecret, tokenStr)
 	if err != nil {
+		log.Error(err.Error())
 		return err
 	}
 
-	if err := handler.UserActivatedHandler(e); err != nil {
+	log.Info("token revoked")
+	return nil
+}
+
+func (mw *loggingMiddleware) Introspect(clientID string, clientSecret string, tokenStr string) (*Introspection, error) {
+	log := mw.log.With(
+		zap.String("action", "introspect"),
+		zap.String("client_id", clientID),
+	)
+
+	result, err := mw.next.Introspect(clientID, clientSecret, tokenStr)
+	if err != nil {
 		log.Error(err.Error())
+		return nil, err
 	}
 
-	log.Info("user activated")
-	return nil
+	log.Info("token introspected", zap.Bool("active", result.Active))
+	return result, nil
 }
 
-func (mw *loggingMiddleware) UserSocialAccountAddedHandler(e *user.UserSocialAccountAddedEvent) error {
+func (mw *loggingMiddleware) Authorize(claims *verifier.Claims, req *oidc.AuthorizationRequest) (string, error) {
 	log := mw.log.With(
-		zap.String("event", e.EventName()),
-		zap.String("user_id", e.Event.UserID.String()),
+		zap.String("action", "authorize"),
+		zap.String("user_id", claims.Subject),
+		zap.String("client_id", req.ClientID),
+		zap.String("scope", req.Scope),
 	)
 
-	handler, err := mw.next.Handler()
+	code, err := mw.next.Authorize(claims, req)
 	if err != nil {
-		return err
+		log.Error(err.Error())
+		return "", err
 	}
 
-	if err := handler.UserSocialAccountAddedHandler(e); err != nil {
+	log.Info("authorization code issued")
+	return code, nil
+}
+
+func (mw *loggingMiddleware) ExchangeCode(clientID string, clientSecret string, code string, redirectURI string, codeVerifier string) (*oidc.Grant, error) {
+	log := mw.log.With(
+		zap.String("action", "exchange_code"),
+		zap.String("client_id", clientID),
+	)
+
+	grant, err := mw.next.ExchangeCode(clientID, clientSecret, code, redirectURI, codeVerifier)
+	if err != nil {
 		log.Error(err.Error())
+		return nil, err
 	}
 
-	log.Info("social account added")
+	log.Info("authorization code redeemed", zap.String("user_id", grant.User.ID.String()))
+	return grant, nil
+}
+
+func (mw *loggingMiddleware) ClientCredentials(clientID string, clientSecret string, scope oidc.Scope) (*ServiceGrant, error) {
+	log := mw.log.With(
+		zap.String("action", "client_credentials"),
+		zap.String("client_id", clientID),
+		zap.String("scope", scope.String()),
+	)
+
+	grant, err := mw.next.ClientCredentials(clientID, clientSecret, scope)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("client granted")
+	return grant, nil
+}
+
+func (mw *loggingMiddleware) ExchangeToken(ctx context.Context, clientID string, clientSecret string, req *token.ExchangeRequest) (*token.Exchange, error) {
+	log := mw.log.With(
+		zap.String("action", "exchange_token"),
+		zap.String("client_id", clientID),
+		zap.Bool("actor_token", req.ActorToken != ""),
+	)
+
+	x, err := mw.next.ExchangeToken(ctx, clientID, clientSecret, req)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("token exchanged",
+		zap.String("jti", x.ID),
+		zap.String("sub", x.Subject),
+		zap.String("act", x.Actor.Subject),
+	)
+	return x, nil
+}
+
+func (mw *loggingMiddleware) UserInfo(id user.UserID, scope oidc.Scope) (*oidc.UserInfo, error) {
+	info, err := mw.next.UserInfo(id, scope)
+	if err != nil {
+		mw.log.Error(err.Error(),
+			zap.String("action", "user_info"),
+			zap.String("user_id", id.String()),
+		)
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (mw *loggingMiddleware) RegisterClient(typ client.Type, settings client.Settings) (*RegisteredClient, error) {
+	log := mw.log.With(
+		zap.String("action", "register_client"),
+		zap.String("name", settings.Name),
+		zap.String("type", typ.String()),
+	)
+
+	c, err := mw.next.RegisterClient(typ, settings)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("client registered", zap.String("client_id", c.ID.String()))
+	return c, nil
+}
+
+func (mw *loggingMiddleware) UpdateClient(id client.ClientID, settings client.Settings) (*client.Client, error) {
+	log := mw.log.With(
+		zap.String("action", "update_client"),
+		zap.String("client_id", id.String()),
+	)
+
+	c, err := mw.next.UpdateClient(id, settings)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("client updated", zap.String("name", c.Name))
+	return c, nil
+}
+
+func (mw *loggingMiddleware) RotateClientSecret(id client.ClientID) (*RegisteredClient, error) {
+	log := mw.log.With(
+		zap.String("action", "rotate_client_secret"),
+		zap.String("client_id", id.String()),
+	)
+
+	c, err := mw.next.RotateClientSecret(id)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("client secret rotated")
+	return c, nil
+}
+
+func (mw *loggingMiddleware) DeleteClient(id client.ClientID) error {
+	log := mw.log.With(
+		zap.String("action", "delete_client"),
+		zap.String("client_id", id.String()),
+	)
+
+	if err := mw.next.DeleteClient(id); err != nil {
+		log.Error(err.Error())
+		return err
+	}
+
+	log.Info("client deleted")
+	return nil
+}
+
+func (mw *loggingMiddleware) FindClient(id client.ClientID) (*client.Client, error) {
+	c, err := mw.next.FindClient(id)
+	if err != nil {
+		mw.log.Error(err.Error(),
+			zap.String("action", "find_client"),
+			zap.String("client_id", id.String()),
+		)
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (mw *loggingMiddleware) ListClients() ([]*client.Client, error) {
+	clients, err := mw.next.ListClients()
+	if err != nil {
+		mw.log.Error(err.Error(),
+			zap.String("action", "list_clients"),
+		)
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+func (mw *loggingMiddleware) CreateGroup(name string, parent group.GroupID) (*group.Group, error) {
+	log := mw.log.With(
+		zap.String("action", "create_group"),
+		zap.String("name", name),
+		zap.String("parent", parent.String()),
+	)
+
+	g, err := mw.next.CreateGroup(name, parent)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("group created", zap.String("group_id", g.ID.String()))
+	return g, nil
+}
+
+func (mw *loggingMiddleware) UpdateGroup(id group.GroupID, name string, parent group.GroupID) (*group.Group, error) {
+	log := mw.log.With(
+		zap.String("action", "update_group"),
+		zap.String("group_id", id.String()),
+		zap.String("name", name),
+		zap.String("parent", parent.String()),
+	)
+
+	g, err := mw.next.UpdateGroup(id, name, parent)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("group updated")
+	return g, nil
+}
+
+func (mw *loggingMiddleware) DeleteGroup(id group.GroupID) error {
+	log := mw.log.With(
+		zap.String("action", "delete_group"),
+		zap.String("group_id", id.String()),
+	)
+
+	if err := mw.next.DeleteGroup(id); err != nil {
+		log.Error(err.Error())
+		return err
+	}
+
+	log.Info("group deleted")
+	return nil
+}
+
+func (mw *loggingMiddleware) FindGroup(id group.GroupID) (*group.Group, error) {
+	g, err := mw.next.FindGroup(id)
+	if err != nil {
+		mw.log.Error(err.Error(),
+			zap.String("action", "find_group"),
+			zap.String("group_id", id.String()),
+		)
+		return nil, err
+	}
+
+	return g, nil
+}
+
+func (mw *loggingMiddleware) ListGroups() ([]*group.Group, error) {
+	groups, err := mw.next.ListGroups()
+	if err != nil {
+		mw.log.Error(err.Error(),
+			zap.String("action", "list_groups"),
+		)
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+func (mw *loggingMiddleware) AddGroupMember(id group.GroupID, userID user.UserID) (*group.Group, error) {
+	log := mw.log.With(
+		zap.String("action", "add_group_member"),
+		zap.String("group_id", id.String()),
+		zap.String("user_id", userID.String()),
+	)
+
+	g, err := mw.next.AddGroupMember(id, userID)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("group member added")
+	return g, nil
+}
+
+func (mw *loggingMiddleware) RemoveGroupMember(id group.GroupID, userID user.UserID) (*group.Group, error) {
+	log := mw.log.With(
+		zap.String("action", "remove_group_member"),
+		zap.String("group_id", id.String()),
+		zap.String("user_id", userID.String()),
+	)
+
+	g, err := mw.next.RemoveGroupMember(id, userID)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("group member removed")
+	return g, nil
+}
+
+func (mw *loggingMiddleware) GrantGroupRole(id group.GroupID, role string) (*group.Group, error) {
+	log := mw.log.With(
+		zap.String("action", "grant_group_role"),
+		zap.String("group_id", id.String()),
+		zap.String("role", role),
+	)
+
+	g, err := mw.next.GrantGroupRole(id, role)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("group role granted")
+	return g, nil
+}
+
+func (mw *loggingMiddleware) RevokeGroupRole(id group.GroupID, role string) (*group.Group, error) {
+	log := mw.log.With(
+		zap.String("action", "revoke_group_role"),
+		zap.String("group_id", id.String()),
+		zap.String("role", role),
+	)
+
+	g, err := mw.next.RevokeGroupRole(id, role)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("group role revoked")
+	return g, nil
+}
+
+func (mw *loggingMiddleware) Memberships(id user.UserID) ([]*group.Group, error) {
+	groups, err := mw.next.Memberships(id)
+	if err != nil {
+		mw.log.Error(err.Error(),
+			zap.String("action", "memberships"),
+			zap.String("user_id", id.String()),
+		)
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+func (mw *loggingMiddleware) CreateTenant(id tenant.TenantID, name string) (*tenant.Tenant, error) {
+	log := mw.log.With(
+		zap.String("action", "create_tenant"),
+		zap.String("tenant_id", id.String()),
+		zap.String("name", name),
+	)
+
+	t, err := mw.next.CreateTenant(id, name)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("tenant created")
+	return t, nil
+}
+
+func (mw *loggingMiddleware) UpdateTenant(id tenant.TenantID, name string) (*tenant.Tenant, error) {
+	log := mw.log.With(
+		zap.String("action", "update_tenant"),
+		zap.String("tenant_id", id.String()),
+		zap.String("name", name),
+	)
+
+	t, err := mw.next.UpdateTenant(id, name)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("tenant updated")
+	return t, nil
+}
+
+func (mw *loggingMiddleware) DeleteTenant(id tenant.TenantID) error {
+	log := mw.log.With(
+		zap.String("action", "delete_tenant"),
+		zap.String("tenant_id", id.String()),
+	)
+
+	if err := mw.next.DeleteTenant(id); err != nil {
+		log.Error(err.Error())
+		return err
+	}
+
+	log.Info("tenant deleted")
+	return nil
+}
+
+func (mw *loggingMiddleware) FindTenant(id tenant.TenantID) (*tenant.Tenant, error) {
+	t, err := mw.next.FindTenant(id)
+	if err != nil {
+		mw.log.Error(err.Error(),
+			zap.String("action", "find_tenant"),
+			zap.String("tenant_id", id.String()),
+		)
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (mw *loggingMiddleware) ListTenants() ([]*tenant.Tenant, error) {
+	tenants, err := mw.next.ListTenants()
+	if err != nil {
+		mw.log.Error(err.Error(),
+			zap.String("action", "list_tenants"),
+		)
+		return nil, err
+	}
+
+	return tenants, nil
+}
+
+func (mw *loggingMiddleware) JoinTenant(id tenant.TenantID, userID user.UserID) (*user.User, error) {
+	log := mw.log.With(
+		zap.String("action", "join_tenant"),
+		zap.String("tenant_id", id.String()),
+		zap.String("user_id", userID.String()),
+	)
+
+	u, err := mw.next.JoinTenant(id, userID)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("tenant joined")
+	return u, nil
+}
+
+func (mw *loggingMiddleware) LeaveTenant(id tenant.TenantID, userID user.UserID) (*user.User, error) {
+	log := mw.log.With(
+		zap.String("action", "leave_tenant"),
+		zap.String("tenant_id", id.String()),
+		zap.String("user_id", userID.String()),
+	)
+
+	u, err := mw.next.LeaveTenant(id, userID)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("tenant left")
+	return u, nil
+}
+
+func (mw *loggingMiddleware) GrantTenantRole(id tenant.TenantID, userID user.UserID, role string) (*user.User, error) {
+	log := mw.log.With(
+		zap.String("action", "grant_tenant_role"),
+		zap.String("tenant_id", id.String()),
+		zap.String("user_id", userID.String()),
+		zap.String("role", role),
+	)
+
+	u, err := mw.next.GrantTenantRole(id, userID, role)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("tenant role granted")
+	return u, nil
+}
+
+func (mw *loggingMiddleware) RevokeTenantRole(id tenant.TenantID, userID user.UserID, role string) (*user.User, error) {
+	log := mw.log.With(
+		zap.String("action", "revoke_tenant_role"),
+		zap.String("tenant_id", id.String()),
+		zap.String("user_id", userID.String()),
+		zap.String("role", role),
+	)
+
+	u, err := mw.next.RevokeTenantRole(id, userID, role)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	log.Info("tenant role revoked")
+	return u, nil
+}
+
+func (mw *loggingMiddleware) Tenants(id user.UserID) ([]tenant.TenantID, error) {
+	tenants, err := mw.next.Tenants(id)
+	if err != nil {
+		mw.log.Error(err.Error(),
+			zap.String("action", "tenants"),
+			zap.String("user_id", id.String()),
+		)
+		return nil, err
+	}
+
+	return tenants, nil
+}
+
+func (mw *loggingMiddleware) CheckStatus(id user.UserID) error {
+	err := mw.next.CheckStatus(id)
+	if err != nil {
+		mw.log.Error(err.Error(),
+			zap.String("action", "check_status"),
+			zap.String("user_id", id.String()),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (mw *loggingMiddleware) CheckClient(id client.ClientID) error {
+	err := mw.next.CheckClient(id)
+	if err != nil {
+		mw.log.Error(err.Error(),
+			zap.String("action", "check_client"),
+			zap.String("client_id", id.String()),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (mw *loggingMiddleware) CheckHealth(ctx context.Context) error {
+	log := mw.log.With(
+		zap.String("action", "check_health"),
+	)
+
+	if info, ok := ctx.Value(model.REQUEST_INFO).(*RequestInfo); ok {
+		log = log.With(zap.String("remote", info.ClientIP))
+		log = log.With(zap.String("user-agent", info.UserAgent))
+	}
+
+	err := mw.next.CheckHealth(ctx)
+	if err != nil {
+		log.Error(err.Error())
+		return err
+	}
+
+	log.Info("ok")
+	return nil
+}
+
+func (mw *loggingMiddleware) Handler() (EventHandler, error) {
+	return mw, nil
+}
+
+func (mw *loggingMiddleware) UserRegisteredHandler(e *user.UserRegisteredEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserRegisteredHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("user stored")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserActivatedHandler(e *user.UserActivatedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserActivatedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("user activated")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserSocialAccountAddedHandler(e *user.UserSocialAccountAddedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.Event.UserID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserSocialAccountAddedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("social account added")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserOTPEnrolledHandler(e *user.UserOTPEnrolledEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserOTPEnrolledHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("otp enrolled")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserOTPVerifiedHandler(e *user.UserOTPVerifiedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserOTPVerifiedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("otp verified")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserOTPConfirmedHandler(e *user.UserOTPConfirmedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserOTPConfirmedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("otp confirmed")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserVerificationCodeIssuedHandler(e *user.UserVerificationCodeIssuedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserVerificationCodeIssuedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("verification code issued")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserVerificationCodeFailedHandler(e *user.UserVerificationCodeFailedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserVerificationCodeFailedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("verification code failed")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserVerificationCodeVerifiedHandler(e *user.UserVerificationCodeVerifiedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserVerificationCodeVerifiedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("verification code verified")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserPasswordChangedHandler(e *user.UserPasswordChangedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserPasswordChangedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("password changed")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserLockedHandler(e *user.UserLockedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserLockedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("user locked")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserUnlockedHandler(e *user.UserUnlockedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserUnlockedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("user unlocked")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserRevokedHandler(e *user.UserRevokedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserRevokedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("user revoked")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserRoleGrantedHandler(e *user.UserRoleGrantedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+		zap.String("role", e.Role),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserRoleGrantedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("role granted")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserRoleRevokedHandler(e *user.UserRoleRevokedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+		zap.String("role", e.Role),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserRoleRevokedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("role revoked")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserTenantJoinedHandler(e *user.UserTenantJoinedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+		zap.String("tenant_id", e.TenantID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserTenantJoinedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("tenant joined")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserTenantLeftHandler(e *user.UserTenantLeftEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+		zap.String("tenant_id", e.TenantID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserTenantLeftHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("tenant left")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserTenantRoleGrantedHandler(e *user.UserTenantRoleGrantedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+		zap.String("tenant_id", e.TenantID.String()),
+		zap.String("role", e.Role),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserTenantRoleGrantedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("tenant role granted")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserTenantRoleRevokedHandler(e *user.UserTenantRoleRevokedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+		zap.String("tenant_id", e.TenantID.String()),
+		zap.String("role", e.Role),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserTenantRoleRevokedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("tenant role revoked")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserProfileUpdatedHandler(e *user.UserProfileUpdatedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+		zap.Bool("edited", e.Provider == ""),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserProfileUpdatedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("profile updated")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserEmailChangeRequestedHandler(e *user.UserEmailChangeRequestedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+		zap.String("email", e.Change.Email),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserEmailChangeRequestedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("email change requested")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserEmailChangedHandler(e *user.UserEmailChangedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+		zap.String("email", e.Email),
+		zap.String("previous", e.Previous),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserEmailChangedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("email changed")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserUsernameChangedHandler(e *user.UserUsernameChangedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+		zap.String("username", e.Username),
+		zap.String("previous", e.Previous),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserUsernameChangedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("username changed")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserDeletedHandler(e *user.UserDeletedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+		zap.String("username", e.Username),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserDeletedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("user deleted")
+	return nil
+}
+
+func (mw *loggingMiddleware) UserSocialAccountRemovedHandler(e *user.UserSocialAccountRemovedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("user_id", e.UserID.String()),
+		zap.String("provider", string(e.Provider)),
+		zap.String("social_id", string(e.SocialID)),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.UserSocialAccountRemovedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("social account removed")
+	return nil
+}
+
+func (mw *loggingMiddleware) FamilyIssuedHandler(e *token.FamilyIssuedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("family_id", e.FamilyID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.FamilyIssuedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("token family issued")
+	return nil
+}
+
+func (mw *loggingMiddleware) FamilyRotatedHandler(e *token.FamilyRotatedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("family_id", e.FamilyID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.FamilyRotatedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("token family rotated")
+	return nil
+}
+
+func (mw *loggingMiddleware) FamilyRevokedHandler(e *token.FamilyRevokedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("family_id", e.FamilyID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.FamilyRevokedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("token family revoked")
+	return nil
+}
+
+func (mw *loggingMiddleware) TokenDeniedHandler(e *token.TokenDeniedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("jti", e.TokenID),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.TokenDeniedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("token denied")
+	return nil
+}
+
+func (mw *loggingMiddleware) ClientRegisteredHandler(e *client.ClientRegisteredEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("client_id", e.ClientID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.ClientRegisteredHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("client registered")
+	return nil
+}
+
+func (mw *loggingMiddleware) ClientUpdatedHandler(e *client.ClientUpdatedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("client_id", e.ClientID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.ClientUpdatedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("client updated")
+	return nil
+}
+
+func (mw *loggingMiddleware) ClientSecretRotatedHandler(e *client.ClientSecretRotatedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("client_id", e.ClientID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.ClientSecretRotatedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("client secret rotated")
+	return nil
+}
+
+func (mw *loggingMiddleware) ClientDeletedHandler(e *client.ClientDeletedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("client_id", e.ClientID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.ClientDeletedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("client deleted")
+	return nil
+}
+
+func (mw *loggingMiddleware) GroupCreatedHandler(e *group.GroupCreatedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("group_id", e.GroupID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.GroupCreatedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("group created")
+	return nil
+}
+
+func (mw *loggingMiddleware) GroupUpdatedHandler(e *group.GroupUpdatedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("group_id", e.GroupID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.GroupUpdatedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("group updated")
+	return nil
+}
+
+func (mw *loggingMiddleware) GroupMemberAddedHandler(e *group.GroupMemberAddedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("group_id", e.GroupID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.GroupMemberAddedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("group member added")
+	return nil
+}
+
+func (mw *loggingMiddleware) GroupMemberRemovedHandler(e *group.GroupMemberRemovedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("group_id", e.GroupID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.GroupMemberRemovedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("group member removed")
+	return nil
+}
+
+func (mw *loggingMiddleware) GroupRoleGrantedHandler(e *group.GroupRoleGrantedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("group_id", e.GroupID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.GroupRoleGrantedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("group role granted")
+	return nil
+}
+
+func (mw *loggingMiddleware) GroupRoleRevokedHandler(e *group.GroupRoleRevokedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("group_id", e.GroupID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.GroupRoleRevokedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("group role revoked")
+	return nil
+}
+
+func (mw *loggingMiddleware) GroupDeletedHandler(e *group.GroupDeletedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("group_id", e.GroupID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.GroupDeletedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("group deleted")
+	return nil
+}
+
+func (mw *loggingMiddleware) TenantCreatedHandler(e *tenant.TenantCreatedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("tenant_id", e.TenantID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.TenantCreatedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("tenant created")
+	return nil
+}
+
+func (mw *loggingMiddleware) TenantUpdatedHandler(e *tenant.TenantUpdatedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("tenant_id", e.TenantID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.TenantUpdatedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("tenant updated")
+	return nil
+}
+
+func (mw *loggingMiddleware) TenantDeletedHandler(e *tenant.TenantDeletedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("tenant_id", e.TenantID.String()),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.TenantDeletedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("tenant deleted")
+	return nil
+}
+
+func (mw *loggingMiddleware) TokenExchangedHandler(e *token.TokenExchangedEvent) error {
+	log := mw.log.With(
+		zap.String("event", e.EventName()),
+		zap.String("jti", e.TokenID),
+	)
+
+	handler, err := mw.next.Handler()
+	if err != nil {
+		return err
+	}
+
+	if err := handler.TokenExchangedHandler(e); err != nil {
+		log.Error(err.Error())
+	}
+
+	log.Info("token exchanged",
+		zap.String("client_id", e.Exchange.ClientID),
+		zap.String("sub", e.Exchange.Subject),
+		zap.Any("act", e.Exchange.Actor),
+	)
 	return nil
 }
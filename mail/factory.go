@@ -0,0 +1,24 @@
+package mail
+
+import (
+	"errors"
+
+	"github.com/mirror520/identity/conf"
+)
+
+type factory func(cfg conf.Mail) (Mailer, error)
+
+var factories = make(map[conf.MailDriver]factory)
+
+func AddFactory(driver conf.MailDriver, factory factory) {
+	factories[driver] = factory
+}
+
+func NewMailer(cfg conf.Mail) (Mailer, error) {
+	factory, ok := factories[cfg.Driver]
+	if !ok {
+		return nil, errors.New("driver not supported")
+	}
+
+	return factory(cfg)
+}
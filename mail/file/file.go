@@ -0,0 +1,44 @@
+package file
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/mail"
+)
+
+func init() {
+	mail.AddFactory(conf.FileSink, NewMailer)
+}
+
+// mailer writes every message as an .eml file, a local stand-in for SMTP.
+type mailer struct {
+	path string
+	from string
+}
+
+func NewMailer(cfg conf.Mail) (mail.Mailer, error) {
+	if err := os.MkdirAll(cfg.Path, 0o755); err != nil {
+		return nil, err
+	}
+
+	m := new(mailer)
+	m.path = cfg.Path
+	m.from = cfg.From
+	return m, nil
+}
+
+func (m *mailer) Send(msg *mail.Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+
+	filename := time.Now().Format("20060102T150405.000000000") + "_" + strings.Join(msg.To, ",") + ".eml"
+	return os.WriteFile(m.path+"/"+filename, msg.Bytes(), 0o644)
+}
+
+func (m *mailer) Close() error {
+	return nil
+}
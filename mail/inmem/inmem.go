@@ -0,0 +1,56 @@
+package inmem
+
+import (
+	"sync"
+
+	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/mail"
+)
+
+func init() {
+	mail.AddFactory(conf.InMemSink, NewMailer)
+}
+
+type InMemMailer interface {
+	mail.Mailer
+	Messages() []*mail.Message
+}
+
+func NewMailer(cfg conf.Mail) (mail.Mailer, error) {
+	return NewInMemMailer(cfg), nil
+}
+
+func NewInMemMailer(cfg conf.Mail) InMemMailer {
+	return &mailer{
+		from:     cfg.From,
+		messages: make([]*mail.Message, 0),
+	}
+}
+
+type mailer struct {
+	from     string
+	messages []*mail.Message
+	sync.RWMutex
+}
+
+func (m *mailer) Send(msg *mail.Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+
+	m.Lock()
+	m.messages = append(m.messages, msg)
+	m.Unlock()
+	return nil
+}
+
+func (m *mailer) Messages() []*mail.Message {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.messages
+}
+
+func (m *mailer) Close() error {
+	return nil
+}
@@ -0,0 +1,69 @@
+package mail
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/mirror520/identity/conf"
+)
+
+type Mailer interface {
+	Send(msg *Message) error
+	Close() error
+}
+
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Bytes returns the message in RFC 5322 format.
+func (msg *Message) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("From: " + msg.From + "\r\n")
+	for _, to := range msg.To {
+		buf.WriteString("To: " + to + "\r\n")
+	}
+	buf.WriteString("Subject: " + msg.Subject + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(msg.Body)
+
+	return buf.Bytes()
+}
+
+func NewMessage(tmpl conf.MailTemplate, data any, to ...string) (*Message, error) {
+	subject, err := render(tmpl.Subject, data)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := render(tmpl.Body, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		To:      to,
+		Subject: subject,
+		Body:    body,
+	}, nil
+}
+
+func render(text string, data any) (string, error) {
+	tmpl, err := template.New("").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
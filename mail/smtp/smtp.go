@@ -0,0 +1,52 @@
+package smtp
+
+import (
+	"errors"
+	"net/smtp"
+	"strconv"
+
+	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/mail"
+)
+
+var ErrHostNotSet = errors.New("smtp host not set")
+
+func init() {
+	mail.AddFactory(conf.SMTP, NewMailer)
+}
+
+type mailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewMailer fails without a host, the driver is the default of a
+// configuration without the mail.
+func NewMailer(cfg conf.Mail) (mail.Mailer, error) {
+	if cfg.SMTP.Host == "" {
+		return nil, ErrHostNotSet
+	}
+
+	m := new(mailer)
+	m.addr = cfg.SMTP.Host + ":" + strconv.Itoa(cfg.SMTP.Port)
+	m.from = cfg.From
+
+	if cfg.SMTP.Username != "" {
+		m.auth = smtp.PlainAuth("", cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.Host)
+	}
+
+	return m, nil
+}
+
+func (m *mailer) Send(msg *mail.Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+
+	return smtp.SendMail(m.addr, m.auth, msg.From, msg.To, msg.Bytes())
+}
+
+func (m *mailer) Close() error {
+	return nil
+}
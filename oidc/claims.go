@@ -0,0 +1,105 @@
+package oidc
+
+import (
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/mirror520/identity/client"
+	"github.com/mirror520/identity/user"
+)
+
+type Scope []string
+
+func ParseScope(s string) Scope {
+	return strings.Fields(s)
+}
+
+func (s Scope) Has(scope string) bool {
+	for _, v := range s {
+		if v == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s Scope) String() string {
+	return strings.Join(s, " ")
+}
+
+// Profile holds the standard claims granted by the profile and email scopes.
+type Profile struct {
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Picture           string `json:"picture,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+}
+
+func NewProfile(u *user.User, scope Scope) Profile {
+	var p Profile
+
+	if scope.Has("profile") {
+		p.Name = u.Name
+		p.PreferredUsername = u.Username
+		p.Picture = u.Avatar
+	}
+
+	if scope.Has("email") {
+		verified := u.Status == user.Activated
+
+		p.Email = u.Email
+		p.EmailVerified = &verified
+	}
+
+	return p
+}
+
+// UserInfo is the response of the UserInfo endpoint.
+type UserInfo struct {
+	Subject string `json:"sub"`
+	Profile
+}
+
+func NewUserInfo(u *user.User, scope Scope) *UserInfo {
+	return &UserInfo{
+		Subject: u.ID.String(),
+		Profile: NewProfile(u, scope),
+	}
+}
+
+// IDClaims are the claims of the ID token, OpenID Connect Core §2.
+type IDClaims struct {
+	jwt.RegisteredClaims
+	Nonce    string           `json:"nonce,omitempty"`
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	AMR      []string         `json:"amr,omitempty"`
+	Profile
+}
+
+func NewIDClaims(issuer string, ttl time.Duration, c *Code, u *user.User) *IDClaims {
+	now := time.Now()
+	return &IDClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   u.ID.String(),
+			Audience:  jwt.ClaimStrings{c.ClientID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		Nonce:    c.Nonce,
+		AuthTime: jwt.NewNumericDate(c.AuthTime),
+		AMR:      c.AMR,
+		Profile:  NewProfile(u, c.Scope),
+	}
+}
+
+// Grant is the authorization redeemed by a client.
+type Grant struct {
+	User   *user.User
+	Code   *Code
+	Client *client.Client
+}
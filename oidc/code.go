@@ -0,0 +1,97 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/user"
+)
+
+var (
+	ErrInvalidRequest          = errors.New("invalid request")
+	ErrUnsupportedResponseType = errors.New("response type not supported")
+	ErrInvalidRedirectURI      = errors.New("redirect uri not registered")
+	ErrCodeNotFound            = errors.New("authorization code not found")
+	ErrCodeExpired             = errors.New("authorization code expired")
+	ErrInvalidCodeVerifier     = errors.New("invalid code verifier")
+)
+
+// AuthorizationRequest is the authorization request of the code flow, PKCE
+// is required for every client.
+type AuthorizationRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	Nonce               string `form:"nonce" json:"nonce"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+}
+
+func (req *AuthorizationRequest) Validate() error {
+	if req.ResponseType != "code" {
+		return ErrUnsupportedResponseType
+	}
+
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return ErrInvalidRequest
+	}
+
+	return nil
+}
+
+// Code is the authorization code granted to a client, only its hash is kept.
+type Code struct {
+	Hash        string          `json:"hash"`
+	ClientID    string          `json:"client_id"`
+	RedirectURI string          `json:"redirect_uri"`
+	UserID      user.UserID     `json:"user_id"`
+	TenantID    tenant.TenantID `json:"tenant_id"`
+	Scope       Scope           `json:"scope"`
+	Nonce       string          `json:"nonce,omitempty"`
+	Challenge   string          `json:"code_challenge"`
+	AuthTime    time.Time       `json:"auth_time"`
+	AMR         []string        `json:"amr,omitempty"`
+	ExpiredAt   time.Time       `json:"expired_at"`
+}
+
+func (c *Code) Expired(at time.Time) bool {
+	return !at.Before(c.ExpiredAt)
+}
+
+// Verify checks the code verifier of RFC 7636 against the S256 challenge.
+func (c *Code) Verify(codeVerifier string) error {
+	challenge := Challenge(codeVerifier)
+	if subtle.ConstantTimeCompare([]byte(challenge), []byte(c.Challenge)) != 1 {
+		return ErrInvalidCodeVerifier
+	}
+
+	return nil
+}
+
+// Challenge returns the S256 code challenge of the verifier.
+func Challenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func generate() (string, error) {
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+func Hash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,90 @@
+package oidc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mirror520/identity/user"
+)
+
+func TestChallenge(t *testing.T) {
+	assert := assert.New(t)
+
+	// RFC 7636 Appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	assert.Equal("E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", Challenge(verifier))
+
+	c := &Code{Challenge: Challenge(verifier)}
+	assert.NoError(c.Verify(verifier))
+	assert.ErrorIs(c.Verify("invalid"), ErrInvalidCodeVerifier)
+}
+
+func TestIssueAndRedeem(t *testing.T) {
+	assert := assert.New(t)
+
+	codes := NewCodes(NewInMemStore(), time.Minute, "instance1")
+
+	code, err := codes.Issue(&Code{
+		ClientID: "console",
+		UserID:   user.MakeID(),
+		Scope:    ParseScope("openid profile"),
+	})
+
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	c, err := codes.Redeem(code)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("console", c.ClientID)
+	assert.True(c.Scope.Has("openid"))
+
+	// redeemed only once
+	_, err = codes.Redeem(code)
+	assert.ErrorIs(err, ErrCodeNotFound)
+
+	expired := NewCodes(NewInMemStore(), -time.Second, "instance1")
+
+	code, err = expired.Issue(&Code{ClientID: "console"})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	_, err = expired.Redeem(code)
+	assert.ErrorIs(err, ErrCodeExpired)
+}
+
+func TestApply(t *testing.T) {
+	assert := assert.New(t)
+
+	codes := NewCodes(NewInMemStore(), time.Minute, "instance1")
+
+	c := &Code{
+		Hash:      Hash("code"),
+		ClientID:  "console",
+		ExpiredAt: time.Now().Add(time.Minute),
+	}
+
+	// issued by itself
+	assert.NoError(codes.Apply(NewCodeIssuedEvent(c, "instance1")))
+	_, err := codes.Redeem("code")
+	assert.ErrorIs(err, ErrCodeNotFound)
+
+	// issued by another instance
+	assert.NoError(codes.Apply(NewCodeIssuedEvent(c, "instance2")))
+	assert.NoError(codes.Apply(NewCodeRedeemedEvent(c.Hash, "instance2")))
+	_, err = codes.Redeem("code")
+	assert.ErrorIs(err, ErrCodeNotFound)
+
+	assert.NoError(codes.Apply(NewCodeIssuedEvent(c, "instance2")))
+	_, err = codes.Redeem("code")
+	assert.NoError(err)
+}
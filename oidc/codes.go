@@ -0,0 +1,88 @@
+package oidc
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mirror520/identity/events"
+)
+
+// Codes issues and redeems the authorization codes. The codes live only
+// for a minute, so they are kept in memory and broadcast on the event bus
+// for the other instances to redeem.
+type Codes struct {
+	store  Store
+	ttl    time.Duration
+	origin string
+}
+
+func NewCodes(store Store, ttl time.Duration, origin string) *Codes {
+	return &Codes{
+		store:  store,
+		ttl:    ttl,
+		origin: origin,
+	}
+}
+
+// Issue returns the code granted by the authorization, it is never stored.
+func (cs *Codes) Issue(c *Code) (string, error) {
+	code, err := generate()
+	if err != nil {
+		return "", err
+	}
+
+	c.Hash = Hash(code)
+	c.ExpiredAt = time.Now().Add(cs.ttl)
+
+	if err := cs.store.Add(c); err != nil {
+		return "", err
+	}
+
+	cs.notify(NewCodeIssuedEvent(c, cs.origin))
+	return code, nil
+}
+
+func (cs *Codes) Redeem(code string) (*Code, error) {
+	hash := Hash(code)
+
+	c, err := cs.store.Take(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	cs.notify(NewCodeRedeemedEvent(hash, cs.origin))
+
+	if c.Expired(time.Now()) {
+		return nil, ErrCodeExpired
+	}
+
+	return c, nil
+}
+
+func (cs *Codes) notify(e events.DomainEvent) {
+	s := events.NewEventStore()
+	s.AddEvent(e)
+	s.Notify() // best effort, the local store is already updated
+}
+
+// Apply replays the codes issued and redeemed by the other instances.
+func (cs *Codes) Apply(e events.DomainEvent) error {
+	switch e := e.(type) {
+	case *CodeIssuedEvent:
+		if e.Origin == cs.origin {
+			return nil
+		}
+
+		return cs.store.Add(e.Code)
+
+	case *CodeRedeemedEvent:
+		if e.Origin == cs.origin {
+			return nil
+		}
+
+		return cs.store.Remove(e.Hash)
+
+	default:
+		return errors.New("invalid event")
+	}
+}
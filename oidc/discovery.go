@@ -0,0 +1,50 @@
+package oidc
+
+// Configuration is the provider metadata of OpenID Connect Discovery.
+type Configuration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+func NewConfiguration(issuer string, algs []string) *Configuration {
+	api := issuer + "/identity/v1"
+
+	return &Configuration{
+		Issuer:                 issuer,
+		AuthorizationEndpoint:  api + "/authorize",
+		TokenEndpoint:          api + "/token",
+		UserInfoEndpoint:       api + "/userinfo",
+		JWKSURI:                issuer + "/.well-known/jwks.json",
+		RevocationEndpoint:     api + "/token/revoke",
+		IntrospectionEndpoint:  api + "/token/introspect",
+		ScopesSupported:        []string{"openid", "profile", "email"},
+		ResponseTypesSupported: []string{"code"},
+		GrantTypesSupported: []string{
+			"authorization_code",
+			"refresh_token",
+			"client_credentials",
+			"urn:ietf:params:oauth:grant-type:token-exchange",
+		},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algs,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "amr",
+			"name", "preferred_username", "picture", "email", "email_verified",
+		},
+	}
+}
@@ -0,0 +1,102 @@
+package oidc
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/mirror520/identity/events"
+)
+
+type EventName int
+
+const (
+	Unknown EventName = iota
+	CodeIssued
+	CodeRedeemed
+)
+
+func ParseEventName(s string) EventName {
+	switch s {
+	case "code_issued":
+		return CodeIssued
+	case "code_redeemed":
+		return CodeRedeemed
+	default:
+		return Unknown
+	}
+}
+
+func (name EventName) String() string {
+	switch name {
+	case CodeIssued:
+		return "code_issued"
+	case CodeRedeemed:
+		return "code_redeemed"
+	default:
+		return ""
+	}
+}
+
+func (name EventName) MarshalJSON() ([]byte, error) {
+	return json.Marshal(name.String())
+}
+
+func (name *EventName) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	*name = ParseEventName(s)
+	return nil
+}
+
+type Event struct {
+	Domain    string    `json:"domain"`
+	Name      EventName `json:"name"`
+	Origin    string    `json:"origin"` // instance
+	OccuredAt time.Time `json:"occured_at"`
+}
+
+func NewEvent(name EventName, origin string) *Event {
+	return &Event{
+		Domain:    "identity:codes",
+		Name:      name,
+		Origin:    origin,
+		OccuredAt: time.Now(),
+	}
+}
+
+func (e *Event) EventName() string {
+	return e.Name.String()
+}
+
+func (e *Event) Topic() string {
+	name := strings.TrimPrefix(e.Name.String(), "code_")
+	return "codes." + name
+}
+
+type CodeIssuedEvent struct {
+	*Event
+	Code *Code `json:"code"`
+}
+
+func NewCodeIssuedEvent(c *Code, origin string) events.DomainEvent {
+	return &CodeIssuedEvent{
+		Event: NewEvent(CodeIssued, origin),
+		Code:  c,
+	}
+}
+
+type CodeRedeemedEvent struct {
+	*Event
+	Hash string `json:"hash"`
+}
+
+func NewCodeRedeemedEvent(hash string, origin string) events.DomainEvent {
+	return &CodeRedeemedEvent{
+		Event: NewEvent(CodeRedeemed, origin),
+		Hash:  hash,
+	}
+}
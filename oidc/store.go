@@ -0,0 +1,61 @@
+package oidc
+
+import (
+	"sync"
+	"time"
+)
+
+type Store interface {
+	Add(c *Code) error
+	// Take removes the code, so that it is redeemed only once.
+	Take(hash string) (*Code, error)
+	Remove(hash string) error
+}
+
+type inMemStore struct {
+	codes map[string]*Code // map[Hash]*Code
+	sync.Mutex
+}
+
+func NewInMemStore() Store {
+	return &inMemStore{
+		codes: make(map[string]*Code),
+	}
+}
+
+// Add drops the expired codes as well.
+func (s *inMemStore) Add(c *Code) error {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	for hash, code := range s.codes {
+		if code.Expired(now) {
+			delete(s.codes, hash)
+		}
+	}
+
+	s.codes[c.Hash] = c
+	return nil
+}
+
+func (s *inMemStore) Take(hash string) (*Code, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	c, ok := s.codes[hash]
+	if !ok {
+		return nil, ErrCodeNotFound
+	}
+
+	delete(s.codes, hash)
+	return c, nil
+}
+
+func (s *inMemStore) Remove(hash string) error {
+	s.Lock()
+	delete(s.codes, hash)
+	s.Unlock()
+
+	return nil
+}
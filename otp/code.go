@@ -0,0 +1,46 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+)
+
+var codeKeyLabel = []byte("identity:otp:code")
+
+// GenerateCode returns a random numeric code for out-of-band delivery,
+// such as email.
+func GenerateCode(length int) (string, error) {
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+
+		code[i] = byte('0' + n.Int64())
+	}
+
+	return string(code), nil
+}
+
+// CodeHasher keys the hashes of the codes by the server key, the codes are
+// too short to keep an unkeyed hash from being reversed.
+type CodeHasher struct {
+	key []byte
+}
+
+func NewCodeHasher(key []byte) *CodeHasher {
+	return &CodeHasher{
+		key: deriveKey(key, codeKeyLabel),
+	}
+}
+
+// Hash hashes the code with a salt (e.g. user id), only the hash is stored.
+func (h *CodeHasher) Hash(salt string, code string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(salt + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,79 @@
+package otp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+var (
+	ErrKeyNotSet   = errors.New("otp key not set")
+	ErrInvalidSeal = errors.New("invalid sealed secret")
+)
+
+var sealKeyLabel = []byte("identity:otp:seal")
+
+// deriveKey derives a key of the purpose from the server key, so that the
+// server key is never used by two algorithms.
+func deriveKey(key []byte, label []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(label)
+	return mac.Sum(nil)
+}
+
+func (t *TOTP) aead() (cipher.AEAD, error) {
+	if len(t.key) == 0 {
+		return nil, ErrKeyNotSet
+	}
+
+	block, err := aes.NewCipher(deriveKey(t.key, sealKeyLabel))
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts the secret of the account (e.g. user id) by the server key,
+// only the sealed secrets are stored and carried by the events.
+func (t *TOTP) Seal(secret string, account string) (string, error) {
+	aead, err := t.aead()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(secret), []byte(account))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts the sealed secret of the account, the secrets sealed for
+// another account fail to open.
+func (t *TOTP) Open(sealed string, account string) (string, error) {
+	aead, err := t.aead()
+	if err != nil {
+		return "", err
+	}
+
+	bs, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || len(bs) < aead.NonceSize() {
+		return "", ErrInvalidSeal
+	}
+
+	nonce, ciphertext := bs[:aead.NonceSize()], bs[aead.NonceSize():]
+
+	secret, err := aead.Open(nil, nonce, ciphertext, []byte(account))
+	if err != nil {
+		return "", ErrInvalidSeal
+	}
+
+	return string(secret), nil
+}
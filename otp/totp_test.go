@@ -0,0 +1,115 @@
+package otp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mirror520/identity/conf"
+)
+
+// RFC 6238 Appendix B, SHA1
+const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // 12345678901234567890
+
+func TestCode(t *testing.T) {
+	assert := assert.New(t)
+
+	totp := NewTOTP(conf.OTP{Digits: 8})
+
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+
+	for unix, expected := range vectors {
+		step := totp.Step(time.Unix(unix, 0))
+
+		code, err := totp.Code(secret, step)
+		if err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+
+		assert.Equal(expected, code)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	totp := NewTOTP(conf.OTP{Digits: 8, Skew: 1})
+	now := time.Unix(1111111111, 0)
+
+	step, err := totp.Validate(secret, "07081804", now) // previous step
+	assert.NoError(err)
+	assert.Equal(totp.Step(now)-1, step)
+
+	_, err = totp.Validate(secret, "94287082", now)
+	assert.ErrorIs(err, ErrInvalidCode)
+}
+
+func TestURI(t *testing.T) {
+	assert := assert.New(t)
+
+	totp := NewTOTP(conf.OTP{Issuer: "identity"})
+
+	uri := totp.URI(secret, "user01")
+	assert.True(strings.HasPrefix(uri, "otpauth://totp/identity:user01?"))
+	assert.Contains(uri, "secret="+secret)
+	assert.Contains(uri, "issuer=identity")
+}
+
+func TestGenerateCode(t *testing.T) {
+	assert := assert.New(t)
+
+	code, err := GenerateCode(6)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Len(code, 6)
+
+	h := NewCodeHasher([]byte("otp_secret_key"))
+	assert.Equal(h.Hash("salt", code), h.Hash("salt", code))
+	assert.NotEqual(h.Hash("salt", code), h.Hash("pepper", code))
+
+	// keyed by the server key
+	other := NewCodeHasher([]byte("another_key"))
+	assert.NotEqual(h.Hash("salt", code), other.Hash("salt", code))
+}
+
+func TestSeal(t *testing.T) {
+	assert := assert.New(t)
+
+	totp := NewTOTP(conf.OTP{Key: []byte("otp_secret_key")})
+
+	sealed, err := totp.Seal(secret, "user01")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.NotContains(sealed, secret)
+
+	opened, err := totp.Open(sealed, "user01")
+	assert.NoError(err)
+	assert.Equal(secret, opened)
+
+	// sealed for another user
+	_, err = totp.Open(sealed, "user02")
+	assert.ErrorIs(err, ErrInvalidSeal)
+
+	// sealed by another key
+	_, err = NewTOTP(conf.OTP{Key: []byte("another_key")}).Open(sealed, "user01")
+	assert.ErrorIs(err, ErrInvalidSeal)
+
+	_, err = NewTOTP(conf.OTP{}).Seal(secret, "user01")
+	assert.ErrorIs(err, ErrKeyNotSet)
+}
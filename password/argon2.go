@@ -0,0 +1,151 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/mirror520/identity/conf"
+)
+
+var ErrInvalidHash = errors.New("invalid hash")
+
+var encoding = base64.RawStdEncoding
+
+type Params struct {
+	Time       uint32
+	Memory     uint32 // KiB
+	Threads    uint8
+	KeyLength  uint32
+	SaltLength uint32
+}
+
+// Hasher hashes passwords with Argon2id, the parameters are encoded next
+// to the hash in the PHC string format:
+//
+//	$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>
+type Hasher struct {
+	params Params
+}
+
+func NewHasher(cfg conf.Argon2) *Hasher {
+	params := Params{
+		Time:       cfg.Time,
+		Memory:     cfg.Memory,
+		Threads:    cfg.Threads,
+		KeyLength:  cfg.KeyLength,
+		SaltLength: cfg.SaltLength,
+	}
+
+	// default: RFC 9106 §4
+	if params.Time == 0 {
+		params.Time = 3
+	}
+
+	if params.Memory == 0 {
+		params.Memory = 64 * 1024
+	}
+
+	if params.Threads == 0 {
+		params.Threads = 4
+	}
+
+	if params.KeyLength == 0 {
+		params.KeyLength = 32
+	}
+
+	if params.SaltLength == 0 {
+		params.SaltLength = 16
+	}
+
+	return &Hasher{params}
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	p := h.params
+	key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLength)
+
+	return encode(p, salt, key), nil
+}
+
+// Verify compares the password with the encoded hash in constant time.
+func (h *Hasher) Verify(password string, encoded string) (bool, error) {
+	p, salt, key, err := decode(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// Mimic spends as much time as Verify without a hash to compare with, so
+// that the timing doesn't reveal whether a credential exists.
+func (h *Hasher) Mimic(password string) {
+	p := h.params
+	salt := make([]byte, p.SaltLength)
+	argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLength)
+}
+
+// NeedsRehash reports whether the hash was made with other parameters
+// than the current ones, it should be upgraded on the next sign in.
+func (h *Hasher) NeedsRehash(encoded string) bool {
+	p, salt, _, err := decode(encoded)
+	if err != nil {
+		return true
+	}
+
+	p.SaltLength = uint32(len(salt))
+	return p != h.params
+}
+
+func encode(p Params, salt []byte, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Time, p.Threads,
+		encoding.EncodeToString(salt),
+		encoding.EncodeToString(key),
+	)
+}
+
+func decode(encoded string) (p Params, salt []byte, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	if version != argon2.Version {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	salt, err = encoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	key, err = encoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	p.KeyLength = uint32(len(key))
+	return p, salt, key, nil
+}
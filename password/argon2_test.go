@@ -0,0 +1,52 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mirror520/identity/conf"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	hasher := NewHasher(conf.Argon2{Time: 1, Memory: 8 * 1024, Threads: 1})
+
+	hash, err := hasher.Hash("p@ssw0rd")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.True(strings.HasPrefix(hash, "$argon2id$v=19$m=8192,t=1,p=1$"))
+
+	ok, err := hasher.Verify("p@ssw0rd", hash)
+	assert.NoError(err)
+	assert.True(ok)
+
+	ok, err = hasher.Verify("password", hash)
+	assert.NoError(err)
+	assert.False(ok)
+
+	assert.False(hasher.NeedsRehash(hash))
+}
+
+func TestNeedsRehash(t *testing.T) {
+	assert := assert.New(t)
+
+	old := NewHasher(conf.Argon2{Time: 1, Memory: 8 * 1024, Threads: 1})
+	hash, err := old.Hash("p@ssw0rd")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	hasher := NewHasher(conf.Argon2{Time: 2, Memory: 8 * 1024, Threads: 1})
+	assert.True(hasher.NeedsRehash(hash))
+
+	ok, err := hasher.Verify("p@ssw0rd", hash)
+	assert.NoError(err)
+	assert.True(ok)
+}
@@ -0,0 +1,72 @@
+package persistence
+
+import (
+	"errors"
+
+	"github.com/mirror520/identity/client"
+	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/persistence/db"
+	"github.com/mirror520/identity/persistence/inmem"
+	"github.com/mirror520/identity/persistence/kv"
+	"github.com/mirror520/identity/user"
+)
+
+// NewClientRepository shares the database opened by the user repository.
+func NewClientRepository(cfg conf.Persistence, users user.Repository) (client.Repository, error) {
+	switch cfg.Driver {
+	case conf.SQLite:
+		database, ok := users.(db.Database)
+		if !ok {
+			return nil, errors.New("database not found")
+		}
+
+		return db.NewClientRepository(database.DB())
+
+	case conf.BadgerDB:
+		database, ok := users.(kv.Database)
+		if !ok {
+			return nil, errors.New("database not found")
+		}
+
+		return kv.NewClientRepository(database.DB())
+
+	case conf.InMem:
+		return inmem.NewClientRepository()
+
+	default:
+		return nil, errors.New("driver not supported")
+	}
+}
+
+// SeedClients stores the clients of the configuration not registered yet,
+// the registered ones are left to the admins.
+func SeedClients(repo client.Repository, clients []conf.Client) error {
+	for _, c := range clients {
+		seeded, err := client.NewStaticClient(c.ID, c.Secret, client.Settings{
+			Name:         c.Name,
+			RedirectURIs: c.RedirectURIs,
+			GrantTypes:   c.GrantTypes,
+			Scopes:       c.Scopes,
+			Roles:        c.Roles,
+			Audiences:    c.Audiences,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = repo.Find(seeded.ID)
+		if err == nil {
+			continue
+		}
+
+		if !errors.Is(err, client.ErrClientNotFound) {
+			return err
+		}
+
+		if err := repo.Store(seeded); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/mirror520/identity/client"
+)
+
+type clientRepository struct {
+	db *gorm.DB
+}
+
+// NewClientRepository shares the database of the users.
+func NewClientRepository(db *gorm.DB) (client.Repository, error) {
+	if err := db.AutoMigrate(&Client{}); err != nil {
+		return nil, err
+	}
+
+	repo := new(clientRepository)
+	repo.db = db
+	return repo, nil
+}
+
+func (repo *clientRepository) Store(c *client.Client) error {
+	data := NewClient(c) // convert Domain to Data model
+
+	result := repo.db.Save(data)
+	if err := result.Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Remove deletes the client for good, so that its ID is free again.
+func (repo *clientRepository) Remove(id client.ClientID) error {
+	result := repo.db.Unscoped().Delete(&Client{}, "id = ?", id.String())
+	return result.Error
+}
+
+func (repo *clientRepository) Find(id client.ClientID) (*client.Client, error) {
+	var c *Client
+
+	result := repo.db.Take(&c, "id = ?", id.String())
+
+	err := result.Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, client.ErrClientNotFound
+		}
+
+		return nil, err
+	}
+
+	return c.reconstitute()
+}
+
+func (repo *clientRepository) List() ([]*client.Client, error) {
+	var clients []*Client
+
+	result := repo.db.Order("id").Find(&clients)
+	if err := result.Error; err != nil {
+		return nil, err
+	}
+
+	cs := make([]*client.Client, len(clients))
+	for i, c := range clients {
+		result, err := c.reconstitute()
+		if err != nil {
+			return nil, err
+		}
+
+		cs[i] = result
+	}
+
+	return cs, nil
+}
+
+func (repo *clientRepository) Close() error {
+	return nil
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/mirror520/identity/group"
+	"github.com/mirror520/identity/user"
+)
+
+type groupRepository struct {
+	db *gorm.DB
+}
+
+// NewGroupRepository shares the database of the users.
+func NewGroupRepository(db *gorm.DB) (group.Repository, error) {
+	if err := db.AutoMigrate(&Group{}, &GroupMember{}); err != nil {
+		return nil, err
+	}
+
+	repo := new(groupRepository)
+	repo.db = db
+	return repo, nil
+}
+
+// Store replaces the members of the group as a whole.
+func (repo *groupRepository) Store(g *group.Group) error {
+	data := NewGroup(g) // convert Domain to Data model
+
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Members").Save(data).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&GroupMember{}, "group_id = ?", data.ID).Error; err != nil {
+			return err
+		}
+
+		if len(data.Members) == 0 {
+			return nil
+		}
+
+		return tx.Create(data.Members).Error
+	})
+}
+
+func (repo *groupRepository) Remove(id group.GroupID) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&GroupMember{}, "group_id = ?", id.String()).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&Group{}, "id = ?", id.String()).Error
+	})
+}
+
+func (repo *groupRepository) Find(id group.GroupID) (*group.Group, error) {
+	var g *Group
+
+	result := repo.db.Preload("Members").Take(&g, "id = ?", id.String())
+
+	err := result.Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, group.ErrGroupNotFound
+		}
+
+		return nil, err
+	}
+
+	return g.reconstitute()
+}
+
+func (repo *groupRepository) FindByMember(id user.UserID) ([]*group.Group, error) {
+	ids := repo.db.Model(&GroupMember{}).Select("group_id").Where("user_id = ?", id.String())
+	return repo.find(repo.db.Where("id IN (?)", ids))
+}
+
+func (repo *groupRepository) FindByParent(id group.GroupID) ([]*group.Group, error) {
+	return repo.find(repo.db.Where("parent = ?", id.String()))
+}
+
+func (repo *groupRepository) List() ([]*group.Group, error) {
+	return repo.find(repo.db)
+}
+
+func (repo *groupRepository) find(query *gorm.DB) ([]*group.Group, error) {
+	var groups []*Group
+
+	result := query.Preload("Members").Order("id").Find(&groups)
+	if err := result.Error; err != nil {
+		return nil, err
+	}
+
+	gs := make([]*group.Group, len(groups))
+	for i, g := range groups {
+		result, err := g.reconstitute()
+		if err != nil {
+			return nil, err
+		}
+
+		gs[i] = result
+	}
+
+	return gs, nil
+}
+
+func (repo *groupRepository) Close() error {
+	return nil
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/group"
+	"github.com/mirror520/identity/user"
+)
+
+type groupRepositoryTestSuite struct {
+	suite.Suite
+	groups group.Repository
+	parent *group.Group
+	child  *group.Group
+	member user.UserID
+}
+
+func (suite *groupRepositoryTestSuite) SetupSuite() {
+	cfg := conf.Persistence{
+		Driver: conf.SQLite,
+		Name:   "groups",
+		InMem:  true,
+	}
+
+	users, err := NewUserRepository(cfg)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	groups, err := NewGroupRepository(users.(Database).DB())
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	parent, _ := group.NewGroup("Engineering", "")
+	parent.GrantRole("support")
+
+	child, _ := group.NewGroup("Platform", parent.ID)
+
+	member := user.MakeID()
+	child.AddMember(member)
+
+	groups.Store(parent)
+	groups.Store(child)
+
+	suite.groups = groups
+	suite.parent = parent
+	suite.child = child
+	suite.member = member
+}
+
+func (suite *groupRepositoryTestSuite) TestFind() {
+	g, err := suite.groups.Find(suite.parent.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal("Engineering", g.Name)
+	suite.Equal([]string{"support"}, g.Roles)
+	suite.Empty(g.Members)
+
+	_, err = suite.groups.Find(group.MakeID())
+	suite.ErrorIs(err, group.ErrGroupNotFound)
+}
+
+func (suite *groupRepositoryTestSuite) TestFindByMember() {
+	groups, err := suite.groups.FindByMember(suite.member)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Len(groups, 1)
+	suite.Equal(suite.child.ID, groups[0].ID)
+	suite.Equal([]user.UserID{suite.member}, groups[0].Members)
+}
+
+func (suite *groupRepositoryTestSuite) TestFindByParent() {
+	groups, err := suite.groups.FindByParent(suite.parent.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Len(groups, 1)
+	suite.Equal(suite.child.ID, groups[0].ID)
+}
+
+func (suite *groupRepositoryTestSuite) TestStoreReplacesMembers() {
+	g, err := suite.groups.Find(suite.child.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	other := user.MakeID()
+	g.AddMember(other)
+	g.RemoveMember(suite.member)
+
+	if err := suite.groups.Store(g); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	groups, err := suite.groups.FindByMember(suite.member)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Empty(groups)
+
+	// restored for the other tests
+	g.AddMember(suite.member)
+	g.RemoveMember(other)
+	suite.NoError(suite.groups.Store(g))
+}
+
+func (suite *groupRepositoryTestSuite) TearDownSuite() {
+	db := suite.groups.(*groupRepository).db
+	db.Exec("DROP TABLE group_members")
+	db.Exec("DROP TABLE groups")
+}
+
+func TestGroupRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(groupRepositoryTestSuite))
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/mirror520/identity/tenant"
+)
+
+// Migration records a data migration applied, each is applied once.
+type Migration struct {
+	ID        string `gorm:"primaryKey"`
+	AppliedAt time.Time
+}
+
+// migrate applies the migration unless applied, the migration and its
+// record are committed together.
+func migrate(db *gorm.DB, id string, apply func(tx *gorm.DB) error) error {
+	if err := db.AutoMigrate(&Migration{}); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var m *Migration
+
+		err := tx.Take(&m, "id = ?", id).Error
+		switch {
+		case err == nil:
+			return nil
+
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		if err := apply(tx); err != nil {
+			return err
+		}
+
+		return tx.Create(&Migration{
+			ID:        id,
+			AppliedAt: time.Now(),
+		}).Error
+	})
+}
+
+// joinDefaultTenant makes the users stored before the tenants the members
+// of the default tenant, joined as they were created.
+func joinDefaultTenant(tx *gorm.DB) error {
+	return tx.Exec(`INSERT INTO memberships (user_id, tenant_id, roles, joined_at)
+		SELECT users.id, ?, '[]', users.created_at FROM users
+		WHERE NOT EXISTS (SELECT 1 FROM memberships WHERE memberships.user_id = users.id)`,
+		tenant.Default.String(),
+	).Error
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/user"
+)
+
+// legacySchema is the users stored by the baseline, before the tenants.
+var legacySchema = []string{
+	"CREATE TABLE `users` (`id` text,`username` text,`name` text,`email` text,`status` integer,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,PRIMARY KEY (`id`))",
+	"INSERT INTO users VALUES('01M56HJYNYYDRYQR5Q51TQFYF3','legacy01','Legacy01','legacy01@example.com',1,'2026-10-18 03:41:17.886+00:00','2026-10-18 03:41:17.886483082+00:00',NULL)",
+	"CREATE TABLE `social_accounts` (`user_id` text,`social_id` text,`provider` text,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,PRIMARY KEY (`user_id`,`social_id`),CONSTRAINT `fk_users_accounts` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`))",
+	"INSERT INTO social_accounts VALUES('01M56HJYNYYDRYQR5Q51TQFYF3','100000000000000000001','google','2026-10-18 03:41:17.886372379+00:00','2026-10-18 03:41:17.886372486+00:00',NULL)",
+	"CREATE INDEX `idx_users_deleted_at` ON `users`(`deleted_at`)",
+	"CREATE INDEX `idx_social_accounts_deleted_at` ON `social_accounts`(`deleted_at`)",
+}
+
+func TestMigrateLegacyUsers(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := conf.Persistence{
+		Driver: conf.SQLite,
+		Host:   t.TempDir(),
+		Name:   "identity",
+	}
+
+	legacy, err := gorm.Open(sqlite.Open(cfg.Host+"/"+cfg.Name+".db"), &gorm.Config{})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	for _, stmt := range legacySchema {
+		if err := legacy.Exec(stmt).Error; err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+	}
+
+	if conn, err := legacy.DB(); err == nil {
+		conn.Close()
+	}
+
+	users, err := NewUserRepository(cfg)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	u, err := users.FindByUsername(tenant.Default, "legacy01")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("01M56HJYNYYDRYQR5Q51TQFYF3", u.ID.String())
+	assert.Equal(user.Registered, u.Status)
+	assert.Equal(u.CreatedAt, u.Membership(tenant.Default).JoinedAt)
+
+	_, err = users.FindBySocialID(tenant.Default, "100000000000000000001")
+	assert.NoError(err)
+
+	// a user leaving every tenant joins none again
+	u.Tenants = nil
+	if err := users.Store(u); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	users, err = NewUserRepository(cfg)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	_, err = users.FindByUsername(tenant.Default, "legacy01")
+	assert.ErrorIs(err, user.ErrUserNotFound)
+}
@@ -1,10 +1,16 @@
 package db
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 
+	"github.com/mirror520/identity/client"
 	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/group"
 	"github.com/mirror520/identity/model"
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/token"
 	"github.com/mirror520/identity/user"
 )
 
@@ -14,23 +20,83 @@ type User struct {
 	Name     string
 	Email    string
 	Status   user.Status
+	Roles    []string `gorm:"serializer:json"`
+	Tenants  []*Membership
 	Accounts []*SocialAccount
+	Avatar   string
+
+	ProfileEdited bool
+
+	Restriction  Restriction      `gorm:"embedded;embeddedPrefix:restriction_"`
+	Password     Password         `gorm:"embedded;embeddedPrefix:password_"`
+	OTP          OTP              `gorm:"embedded;embeddedPrefix:otp_"`
+	PendingOTP   OTP              `gorm:"embedded;embeddedPrefix:pending_otp_"`
+	Verification VerificationCode `gorm:"embedded;embeddedPrefix:verification_"`
+	EmailChange  EmailChange      `gorm:"embedded;embeddedPrefix:email_change_"`
 	model.DataModel
 }
 
 func NewUser(u *user.User) *User {
+	tenants := make([]*Membership, len(u.Tenants))
+	for i, m := range u.Tenants {
+		tenants[i] = NewMembership(m, u)
+	}
+
 	accounts := make([]*SocialAccount, len(u.Accounts))
 	for i, a := range u.Accounts {
 		accounts[i] = NewSocialAccount(a, u)
 	}
 
+	var restriction Restriction
+	if u.Restriction != nil {
+		restriction = NewRestriction(u.Restriction)
+	}
+
+	var password Password
+	if u.Password != nil {
+		password = NewPassword(u.Password)
+	}
+
+	var otp OTP
+	if u.OTP != nil {
+		otp = NewOTP(u.OTP)
+	}
+
+	var pendingOTP OTP
+	if u.PendingOTP != nil {
+		pendingOTP = NewOTP(u.PendingOTP)
+	}
+
+	var verification VerificationCode
+	if u.Verification != nil {
+		verification = NewVerificationCode(u.Verification)
+	}
+
+	var emailChange EmailChange
+	if u.EmailChange != nil {
+		emailChange = NewEmailChange(u.EmailChange)
+	}
+
 	return &User{
 		ID:       u.ID.String(),
 		Username: u.Username,
 		Name:     u.Name,
 		Email:    u.Email,
 		Status:   u.Status,
+		Roles:    u.Roles,
+		Tenants:  tenants,
 		Accounts: accounts,
+		Avatar:   u.Avatar,
+
+		ProfileEdited: u.ProfileEdited,
+
+		Restriction:  restriction,
+		Password:     password,
+		OTP:          otp,
+		PendingOTP:   pendingOTP,
+		Verification: verification,
+		EmailChange:  emailChange,
+
 		DataModel: model.DataModel{
 			CreatedAt: u.CreatedAt,
 			UpdatedAt: u.UpdatedAt,
@@ -48,6 +114,11 @@ func (u *User) reconstitute() *user.User {
 		panic(err.Error())
 	}
 
+	tenants := make([]*user.Membership, len(u.Tenants))
+	for i, m := range u.Tenants {
+		tenants[i] = m.reconstitute()
+	}
+
 	accounts := make([]*user.SocialAccount, len(u.Accounts))
 	for i, a := range u.Accounts {
 		accounts[i] = a.reconstitute()
@@ -59,7 +130,20 @@ func (u *User) reconstitute() *user.User {
 		Name:     u.Name,
 		Email:    u.Email,
 		Status:   u.Status,
+		Roles:    nonNil(u.Roles),
+		Tenants:  tenants,
 		Accounts: accounts,
+		Avatar:   u.Avatar,
+
+		ProfileEdited: u.ProfileEdited,
+
+		Restriction:  u.Restriction.reconstitute(),
+		Password:     u.Password.reconstitute(),
+		OTP:          u.OTP.reconstitute(),
+		PendingOTP:   u.PendingOTP.reconstitute(),
+		Verification: u.Verification.reconstitute(),
+		EmailChange:  u.EmailChange.reconstitute(),
+
 		Model: model.Model{
 			CreatedAt: u.CreatedAt,
 			UpdatedAt: u.UpdatedAt,
@@ -69,6 +153,32 @@ func (u *User) reconstitute() *user.User {
 	}
 }
 
+// Membership is stored apart from the users, the users are found by the
+// tenants they belong to.
+type Membership struct {
+	UserID   string   `gorm:"primaryKey"`
+	TenantID string   `gorm:"primaryKey;index"`
+	Roles    []string `gorm:"serializer:json"`
+	JoinedAt time.Time
+}
+
+func NewMembership(m *user.Membership, u *user.User) *Membership {
+	return &Membership{
+		UserID:   u.ID.String(),
+		TenantID: m.TenantID.String(),
+		Roles:    m.Roles,
+		JoinedAt: m.JoinedAt,
+	}
+}
+
+func (m *Membership) reconstitute() *user.Membership {
+	return &user.Membership{
+		TenantID: tenant.TenantID(m.TenantID),
+		Roles:    nonNil(m.Roles),
+		JoinedAt: m.JoinedAt,
+	}
+}
+
 type SocialAccount struct {
 	UserID   string        `gorm:"primaryKey"`
 	SocialID user.SocialID `gorm:"primaryKey"`
@@ -103,3 +213,413 @@ func (a *SocialAccount) reconstitute() *user.SocialAccount {
 		},
 	}
 }
+
+type Restriction struct {
+	Reason   string
+	Previous user.Status
+	Since    time.Time
+}
+
+func NewRestriction(r *user.Restriction) Restriction {
+	return Restriction{
+		Reason:   r.Reason,
+		Previous: r.Previous,
+		Since:    r.Since,
+	}
+}
+
+func (r Restriction) reconstitute() *user.Restriction {
+	if r.Since.IsZero() {
+		return nil
+	}
+
+	return &user.Restriction{
+		Reason:   r.Reason,
+		Previous: r.Previous,
+		Since:    r.Since,
+	}
+}
+
+type Password struct {
+	Hash      string
+	ChangedAt time.Time
+}
+
+func NewPassword(password *user.Password) Password {
+	return Password{
+		Hash:      password.Hash,
+		ChangedAt: password.ChangedAt,
+	}
+}
+
+func (password Password) reconstitute() *user.Password {
+	if password.Hash == "" {
+		return nil
+	}
+
+	return &user.Password{
+		Hash:      password.Hash,
+		ChangedAt: password.ChangedAt,
+	}
+}
+
+type OTP struct {
+	Secret     string
+	LastStep   uint64
+	EnrolledAt time.Time
+}
+
+func NewOTP(otp *user.OTP) OTP {
+	return OTP{
+		Secret:     otp.Secret,
+		LastStep:   otp.LastStep,
+		EnrolledAt: otp.EnrolledAt,
+	}
+}
+
+func (otp OTP) reconstitute() *user.OTP {
+	if otp.Secret == "" {
+		return nil
+	}
+
+	return &user.OTP{
+		Secret:     otp.Secret,
+		LastStep:   otp.LastStep,
+		EnrolledAt: otp.EnrolledAt,
+	}
+}
+
+type VerificationCode struct {
+	Hash        string
+	Attempts    int
+	MaxAttempts int
+	ExpiredAt   time.Time
+}
+
+func NewVerificationCode(code *user.VerificationCode) VerificationCode {
+	return VerificationCode{
+		Hash:        code.Hash,
+		Attempts:    code.Attempts,
+		MaxAttempts: code.MaxAttempts,
+		ExpiredAt:   code.ExpiredAt,
+	}
+}
+
+func (code VerificationCode) reconstitute() *user.VerificationCode {
+	if code.Hash == "" {
+		return nil
+	}
+
+	return &user.VerificationCode{
+		Hash:        code.Hash,
+		Attempts:    code.Attempts,
+		MaxAttempts: code.MaxAttempts,
+		ExpiredAt:   code.ExpiredAt,
+	}
+}
+
+type EmailChange struct {
+	Email     string
+	Hash      string
+	ExpiredAt time.Time
+}
+
+func NewEmailChange(change *user.EmailChange) EmailChange {
+	return EmailChange{
+		Email:     change.Email,
+		Hash:      change.Hash,
+		ExpiredAt: change.ExpiredAt,
+	}
+}
+
+func (change EmailChange) reconstitute() *user.EmailChange {
+	if change.Hash == "" {
+		return nil
+	}
+
+	return &user.EmailChange{
+		Email:     change.Email,
+		Hash:      change.Hash,
+		ExpiredAt: change.ExpiredAt,
+	}
+}
+
+// Reservation holds a username of a tenant for a user until it expires.
+type Reservation struct {
+	TenantID  string `gorm:"primaryKey"`
+	Username  string `gorm:"primaryKey"`
+	UserID    string
+	ExpiredAt time.Time
+}
+
+type Family struct {
+	ID        string `gorm:"primaryKey"`
+	UserID    string `gorm:"index"`
+	TenantID  string
+	Hash      string
+	Used      []string `gorm:"serializer:json"`
+	Status    token.Status
+	Reason    string
+	ExpiredAt time.Time
+	ClientID  string
+	Scope     []string `gorm:"serializer:json"`
+	AuthTime  time.Time
+	AMR       []string `gorm:"serializer:json"`
+	model.DataModel
+}
+
+func NewFamily(f *token.Family) *Family {
+	return &Family{
+		ID:        f.ID.String(),
+		UserID:    f.UserID.String(),
+		TenantID:  f.TenantID.String(),
+		Hash:      f.Hash,
+		Used:      f.Used,
+		Status:    f.Status,
+		Reason:    f.Reason,
+		ExpiredAt: f.ExpiredAt,
+		ClientID:  f.ClientID,
+		Scope:     f.Scope,
+		AuthTime:  f.AuthTime,
+		AMR:       f.AMR,
+		DataModel: model.DataModel{
+			CreatedAt: f.CreatedAt,
+			UpdatedAt: f.UpdatedAt,
+		},
+	}
+}
+
+func (f *Family) reconstitute() (*token.Family, error) {
+	id, err := token.ParseID(f.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := user.ParseID(f.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	used := f.Used
+	if used == nil {
+		used = make([]string, 0)
+	}
+
+	return &token.Family{
+		ID:        id,
+		UserID:    userID,
+		TenantID:  tenant.TenantID(f.TenantID),
+		Hash:      f.Hash,
+		Used:      used,
+		Status:    f.Status,
+		Reason:    f.Reason,
+		ExpiredAt: f.ExpiredAt,
+		Grant: token.Grant{
+			ClientID: f.ClientID,
+			Scope:    f.Scope,
+			AuthTime: f.AuthTime,
+			AMR:      f.AMR,
+		},
+		Model: model.Model{
+			CreatedAt: f.CreatedAt,
+			UpdatedAt: f.UpdatedAt,
+		},
+
+		EventStore: events.NewEventStore(),
+	}, nil
+}
+
+type Revocation struct {
+	ID        string    `gorm:"primaryKey"` // jti
+	ExpiredAt time.Time `gorm:"index"`
+	RevokedAt time.Time
+}
+
+func NewRevocation(r *token.Revocation) *Revocation {
+	return &Revocation{
+		ID:        r.ID,
+		ExpiredAt: r.ExpiredAt,
+		RevokedAt: r.RevokedAt,
+	}
+}
+
+func (r *Revocation) reconstitute() *token.Revocation {
+	return &token.Revocation{
+		ID:        r.ID,
+		ExpiredAt: r.ExpiredAt,
+		RevokedAt: r.RevokedAt,
+
+		EventStore: events.NewEventStore(),
+	}
+}
+
+type Client struct {
+	ID                   string `gorm:"primaryKey"`
+	Type                 client.Type
+	Name                 string
+	Secret               string
+	RedirectURIs         []string `gorm:"serializer:json"`
+	GrantTypes           []string `gorm:"serializer:json"`
+	Scopes               []string `gorm:"serializer:json"`
+	Roles                []string `gorm:"serializer:json"`
+	Audiences            []string `gorm:"serializer:json"`
+	AccessTokenLifetime  time.Duration
+	RefreshTokenLifetime time.Duration
+	model.DataModel
+}
+
+func NewClient(c *client.Client) *Client {
+	return &Client{
+		ID:                   c.ID.String(),
+		Type:                 c.Type,
+		Name:                 c.Name,
+		Secret:               c.Secret,
+		RedirectURIs:         c.RedirectURIs,
+		GrantTypes:           c.GrantTypes,
+		Scopes:               c.Scopes,
+		Roles:                c.Roles,
+		Audiences:            c.Audiences,
+		AccessTokenLifetime:  c.Lifetimes.AccessToken,
+		RefreshTokenLifetime: c.Lifetimes.RefreshToken,
+		DataModel: model.DataModel{
+			CreatedAt: c.CreatedAt,
+			UpdatedAt: c.UpdatedAt,
+		},
+	}
+}
+
+func (c *Client) reconstitute() (*client.Client, error) {
+	id, err := client.ParseID(c.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &client.Client{
+		ID:   id,
+		Type: c.Type,
+		Settings: client.Settings{
+			Name:         c.Name,
+			RedirectURIs: nonNil(c.RedirectURIs),
+			GrantTypes:   nonNil(c.GrantTypes),
+			Scopes:       nonNil(c.Scopes),
+			Roles:        nonNil(c.Roles),
+			Audiences:    nonNil(c.Audiences),
+			Lifetimes: client.Lifetimes{
+				AccessToken:  c.AccessTokenLifetime,
+				RefreshToken: c.RefreshTokenLifetime,
+			},
+		},
+		Secret: c.Secret,
+		Model: model.Model{
+			CreatedAt: c.CreatedAt,
+			UpdatedAt: c.UpdatedAt,
+		},
+
+		EventStore: events.NewEventStore(),
+	}, nil
+}
+
+func nonNil(ss []string) []string {
+	if ss == nil {
+		return make([]string, 0)
+	}
+
+	return ss
+}
+
+type Group struct {
+	ID      string `gorm:"primaryKey"`
+	Name    string
+	Parent  string   `gorm:"index"`
+	Roles   []string `gorm:"serializer:json"`
+	Members []*GroupMember
+	model.DataModel
+}
+
+func NewGroup(g *group.Group) *Group {
+	members := make([]*GroupMember, len(g.Members))
+	for i, id := range g.Members {
+		members[i] = &GroupMember{
+			GroupID: g.ID.String(),
+			UserID:  id.String(),
+		}
+	}
+
+	return &Group{
+		ID:      g.ID.String(),
+		Name:    g.Name,
+		Parent:  g.Parent.String(),
+		Roles:   g.Roles,
+		Members: members,
+		DataModel: model.DataModel{
+			CreatedAt: g.CreatedAt,
+			UpdatedAt: g.UpdatedAt,
+		},
+	}
+}
+
+func (g *Group) reconstitute() (*group.Group, error) {
+	id, err := group.ParseID(g.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]user.UserID, len(g.Members))
+	for i, m := range g.Members {
+		userID, err := user.ParseID(m.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		members[i] = userID
+	}
+
+	return &group.Group{
+		ID:      id,
+		Name:    g.Name,
+		Parent:  group.GroupID(g.Parent),
+		Members: members,
+		Roles:   nonNil(g.Roles),
+		Model: model.Model{
+			CreatedAt: g.CreatedAt,
+			UpdatedAt: g.UpdatedAt,
+		},
+
+		EventStore: events.NewEventStore(),
+	}, nil
+}
+
+type GroupMember struct {
+	GroupID string `gorm:"primaryKey"`
+	UserID  string `gorm:"primaryKey;index"`
+}
+
+type Tenant struct {
+	ID   string `gorm:"primaryKey"`
+	Name string
+	model.DataModel
+}
+
+func NewTenant(t *tenant.Tenant) *Tenant {
+	return &Tenant{
+		ID:   t.ID.String(),
+		Name: t.Name,
+		DataModel: model.DataModel{
+			CreatedAt: t.CreatedAt,
+			UpdatedAt: t.UpdatedAt,
+		},
+	}
+}
+
+func (t *Tenant) reconstitute() *tenant.Tenant {
+	return &tenant.Tenant{
+		ID:   tenant.TenantID(t.ID),
+		Name: t.Name,
+		Model: model.Model{
+			CreatedAt: t.CreatedAt,
+			UpdatedAt: t.UpdatedAt,
+		},
+
+		EventStore: events.NewEventStore(),
+	}
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/mirror520/identity/tenant"
+)
+
+type tenantRepository struct {
+	db *gorm.DB
+}
+
+// NewTenantRepository shares the database of the users.
+func NewTenantRepository(db *gorm.DB) (tenant.Repository, error) {
+	if err := db.AutoMigrate(&Tenant{}); err != nil {
+		return nil, err
+	}
+
+	repo := new(tenantRepository)
+	repo.db = db
+	return repo, nil
+}
+
+func (repo *tenantRepository) Store(t *tenant.Tenant) error {
+	return repo.db.Save(NewTenant(t)).Error
+}
+
+func (repo *tenantRepository) Remove(id tenant.TenantID) error {
+	return repo.db.Unscoped().Delete(&Tenant{}, "id = ?", id.String()).Error
+}
+
+func (repo *tenantRepository) Find(id tenant.TenantID) (*tenant.Tenant, error) {
+	var t *Tenant
+
+	result := repo.db.Take(&t, "id = ?", id.String())
+
+	err := result.Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, tenant.ErrTenantNotFound
+		}
+
+		return nil, err
+	}
+
+	return t.reconstitute(), nil
+}
+
+func (repo *tenantRepository) List() ([]*tenant.Tenant, error) {
+	var tenants []*Tenant
+
+	result := repo.db.Order("id").Find(&tenants)
+	if err := result.Error; err != nil {
+		return nil, err
+	}
+
+	ts := make([]*tenant.Tenant, len(tenants))
+	for i, t := range tenants {
+		ts[i] = t.reconstitute()
+	}
+
+	return ts, nil
+}
+
+func (repo *tenantRepository) Close() error {
+	return nil
+}
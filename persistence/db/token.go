@@ -0,0 +1,140 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/mirror520/identity/token"
+	"github.com/mirror520/identity/user"
+)
+
+type tokenRepository struct {
+	db *gorm.DB
+}
+
+// NewTokenRepository shares the database of the users.
+func NewTokenRepository(db *gorm.DB) (token.Repository, error) {
+	if err := db.AutoMigrate(&Family{}); err != nil {
+		return nil, err
+	}
+
+	repo := new(tokenRepository)
+	repo.db = db
+	return repo, nil
+}
+
+func (repo *tokenRepository) Store(f *token.Family) error {
+	family := NewFamily(f) // convert Domain to Data model
+
+	result := repo.db.Save(family)
+	if err := result.Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Rotate updates the family where the hash is still the previous one, the
+// concurrent rotations of the same token update a single row.
+func (repo *tokenRepository) Rotate(f *token.Family, previous string) error {
+	family := NewFamily(f)
+
+	result := repo.db.Model(&Family{ID: family.ID}).
+		Where("hash = ? AND status = ?", previous, token.Active).
+		Select("hash", "used", "updated_at").
+		Updates(family)
+
+	if err := result.Error; err != nil {
+		return err
+	}
+
+	if result.RowsAffected == 0 {
+		return token.ErrTokenReused
+	}
+
+	return nil
+}
+
+func (repo *tokenRepository) Purge(users []user.UserID) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(users))
+	for i, id := range users {
+		ids[i] = id.String()
+	}
+
+	return repo.db.Unscoped().Delete(&Family{}, "user_id IN ?", ids).Error
+}
+
+func (repo *tokenRepository) Find(id token.FamilyID) (*token.Family, error) {
+	var f *Family
+
+	result := repo.db.Take(&f, "id = ?", id.String())
+
+	err := result.Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, token.ErrFamilyNotFound
+		}
+
+		return nil, err
+	}
+
+	return f.reconstitute()
+}
+
+func (repo *tokenRepository) Close() error {
+	return nil
+}
+
+type revocationRepository struct {
+	db *gorm.DB
+}
+
+// NewRevocationRepository shares the database of the users.
+func NewRevocationRepository(db *gorm.DB) (token.RevocationRepository, error) {
+	if err := db.AutoMigrate(&Revocation{}); err != nil {
+		return nil, err
+	}
+
+	repo := new(revocationRepository)
+	repo.db = db
+	return repo, nil
+}
+
+// Store purges the expired revocations as well.
+func (repo *revocationRepository) Store(r *token.Revocation) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&Revocation{}, "expired_at <= ?", time.Now())
+		if err := result.Error; err != nil {
+			return err
+		}
+
+		result = tx.Save(NewRevocation(r))
+		return result.Error
+	})
+}
+
+func (repo *revocationRepository) List(at time.Time) ([]*token.Revocation, error) {
+	var revocations []*Revocation
+
+	result := repo.db.Find(&revocations, "expired_at > ?", at)
+	if err := result.Error; err != nil {
+		return nil, err
+	}
+
+	rs := make([]*token.Revocation, len(revocations))
+	for i, r := range revocations {
+		rs[i] = r.reconstitute()
+	}
+
+	return rs, nil
+}
+
+func (repo *revocationRepository) Close() error {
+	return nil
+}
@@ -2,11 +2,14 @@ package db
 
 import (
 	"errors"
+	"strings"
+	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
 	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/tenant"
 	"github.com/mirror520/identity/user"
 )
 
@@ -25,23 +28,171 @@ func NewUserRepository(cfg conf.Persistence) (user.Repository, error) {
 		return nil, err
 	}
 
-	db.AutoMigrate(
-		&User{}, &SocialAccount{},
-	)
+	if err := db.AutoMigrate(
+		&User{}, &Membership{}, &SocialAccount{}, &Reservation{},
+	); err != nil {
+		return nil, err
+	}
+
+	if err := migrate(db, "default_tenant_memberships", joinDefaultTenant); err != nil {
+		return nil, err
+	}
 
 	repo := new(userRepository)
 	repo.db = db
 	return repo, nil
 }
 
+// Store replaces the memberships of the user as a whole, the email is
+// unique among the members of each tenant, and a user deleted is deleted
+// softly by gorm.
 func (repo *userRepository) Store(u *user.User) error {
 	user := NewUser(u) // convert Domain to Data model
 
-	result := repo.db.Save(user)
-	if err := result.Error; err != nil {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkEmail(tx, user); err != nil {
+			return err
+		}
+
+		if err := tx.Omit("Tenants").Save(user).Error; err != nil {
+			return err
+		}
+
+		if err := storeAccounts(tx, user); err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&Membership{}, "user_id = ?", user.ID).Error; err != nil {
+			return err
+		}
+
+		if len(user.Tenants) == 0 {
+			return nil
+		}
+
+		return tx.Create(user.Tenants).Error
+	})
+}
+
+func (repo *userRepository) ReserveUsername(tenantID tenant.TenantID, username string, id user.UserID, until time.Time) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		var r *Reservation
+
+		err := tx.Take(&r, "tenant_id = ? AND username = ?", tenantID.String(), username).Error
+		switch {
+		case err == nil:
+			if r.UserID != id.String() && time.Now().Before(r.ExpiredAt) {
+				return user.ErrUsernameReserved
+			}
+
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		return tx.Save(&Reservation{
+			TenantID:  tenantID.String(),
+			Username:  username,
+			UserID:    id.String(),
+			ExpiredAt: until,
+		}).Error
+	})
+}
+
+func (repo *userRepository) Purge(before time.Time) ([]user.UserID, error) {
+	var deleted []string
+
+	err := repo.db.Unscoped().Model(&User{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Pluck("id", &deleted).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]user.UserID, len(deleted))
+	for i, id := range deleted {
+		userID, err := user.ParseID(id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids[i] = userID
+	}
+
+	if len(deleted) == 0 {
+		return ids, nil
+	}
+
+	err = repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&Membership{}, "user_id IN ?", deleted).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Delete(&SocialAccount{}, "user_id IN ?", deleted).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&User{}, "id IN ?", deleted).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// storeAccounts deletes the accounts removed softly, and restores the ones
+// linked again.
+func storeAccounts(tx *gorm.DB, u *User) error {
+	ids := make([]user.SocialID, len(u.Accounts))
+	for i, a := range u.Accounts {
+		ids[i] = a.SocialID
+	}
+
+	removed := tx.Where("user_id = ?", u.ID)
+	if len(ids) > 0 {
+		removed = removed.Where("social_id NOT IN ?", ids)
+	}
+
+	if err := removed.Delete(&SocialAccount{}).Error; err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return tx.Unscoped().Model(&SocialAccount{}).
+		Where("user_id = ? AND social_id IN ? AND deleted_at IS NOT NULL", u.ID, ids).
+		Update("deleted_at", nil).Error
+}
+
+func checkEmail(tx *gorm.DB, u *User) error {
+	if u.Email == "" || len(u.Tenants) == 0 || u.DeletedAt.Valid {
+		return nil
+	}
+
+	tenants := make([]string, len(u.Tenants))
+	for i, m := range u.Tenants {
+		tenants[i] = m.TenantID
+	}
+
+	var count int64
+	err := tx.Model(&User{}).
+		Joins("INNER JOIN memberships ON memberships.user_id = users.id").
+		Where("users.email = ? AND users.id <> ?", u.Email, u.ID).
+		Where("memberships.tenant_id IN ?", tenants).
+		Count(&count).Error
+
+	if err != nil {
 		return err
 	}
 
+	if count > 0 {
+		return user.ErrEmailExists
+	}
+
 	return nil
 }
 
@@ -50,8 +201,8 @@ func (repo *userRepository) Find(id user.UserID) (*user.User, error) {
 
 	result := repo.db.
 		Preload("Accounts").
-		Joins("LEFT JOIN social_accounts ON social_accounts.user_id = users.id").
-		Take(&u, "users.id = ? AND social_accounts.deleted_at IS NULL", id.String())
+		Preload("Tenants").
+		Take(&u, "users.id = ?", id.String())
 
 	err := result.Error
 	if err != nil {
@@ -66,13 +217,32 @@ func (repo *userRepository) Find(id user.UserID) (*user.User, error) {
 	return user, nil
 }
 
-func (repo *userRepository) FindByUsername(username string) (*user.User, error) {
+func (repo *userRepository) FindByUsername(tenantID tenant.TenantID, username string) (*user.User, error) {
+	return repo.findMember(tenantID, repo.db.Where("users.username = ?", username))
+}
+
+func (repo *userRepository) FindByEmail(tenantID tenant.TenantID, email string) (*user.User, error) {
+	return repo.findMember(tenantID, repo.db.Where("users.email = ?", email))
+}
+
+func (repo *userRepository) FindBySocialID(tenantID tenant.TenantID, socialID user.SocialID) (*user.User, error) {
+	query := repo.db.
+		Joins("INNER JOIN social_accounts ON social_accounts.user_id = users.id").
+		Where("social_accounts.social_id = ? AND social_accounts.deleted_at IS NULL", socialID)
+
+	return repo.findMember(tenantID, query)
+}
+
+// findMember finds the user of the query among the members of the tenant.
+func (repo *userRepository) findMember(tenantID tenant.TenantID, query *gorm.DB) (*user.User, error) {
 	var u *User
 
-	result := repo.db.
+	result := query.
 		Preload("Accounts").
-		Joins("LEFT JOIN social_accounts ON social_accounts.user_id = users.id").
-		Take(&u, "users.username = ? AND social_accounts.deleted_at IS NULL", username)
+		Preload("Tenants").
+		Joins("INNER JOIN memberships ON memberships.user_id = users.id").
+		Where("memberships.tenant_id = ?", tenantID.String()).
+		Take(&u)
 
 	err := result.Error
 	if err != nil {
@@ -87,26 +257,67 @@ func (repo *userRepository) FindByUsername(username string) (*user.User, error)
 	return user, nil
 }
 
-func (repo *userRepository) FindBySocialID(socialID user.SocialID) (*user.User, error) {
-	var u *User
-	result := repo.db.
-		Preload("Accounts").
-		Joins("INNER JOIN social_accounts ON social_accounts.user_id = users.id").
-		Take(&u, "social_accounts.social_id = ? AND social_accounts.deleted_at IS NULL", socialID)
+func (repo *userRepository) List(q user.Query) ([]*user.User, error) {
+	query := repo.db.Preload("Accounts").Preload("Tenants")
 
-	err := result.Error
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, user.ErrUserNotFound
+	if q.TenantID != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM memberships WHERE memberships.user_id = users.id AND memberships.tenant_id = ?)", q.TenantID.String())
+	}
+
+	if q.Status != nil {
+		query = query.Where("users.status = ?", *q.Status)
+	}
+
+	if q.Provider != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM social_accounts WHERE social_accounts.user_id = users.id AND social_accounts.provider = ? AND social_accounts.deleted_at IS NULL)", q.Provider)
+	}
+
+	if !q.CreatedAfter.IsZero() {
+		query = query.Where("users.created_at >= ?", q.CreatedAfter)
+	}
+
+	if !q.CreatedBefore.IsZero() {
+		query = query.Where("users.created_at < ?", q.CreatedBefore)
+	}
+
+	if q.Text != "" {
+		text := "%" + likeEscaper.Replace(strings.ToLower(q.Text)) + "%"
+		query = query.Where(`(LOWER(users.username) LIKE ? ESCAPE '\' OR LOWER(users.name) LIKE ? ESCAPE '\' OR LOWER(users.email) LIKE ? ESCAPE '\')`, text, text, text)
+	}
+
+	order := "users.id"
+	if q.Desc {
+		order = "users.id DESC"
+	}
+
+	if q.After != nil {
+		if q.Desc {
+			query = query.Where("users.id < ?", q.After.String())
+		} else {
+			query = query.Where("users.id > ?", q.After.String())
 		}
+	}
 
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
+	}
+
+	var us []*User
+	if err := query.Order(order).Find(&us).Error; err != nil {
 		return nil, err
 	}
 
-	user := u.reconstitute()
-	return user, nil
+	users := make([]*user.User, len(us))
+	for i, u := range us {
+		users[i] = u.reconstitute()
+	}
+
+	return users, nil
 }
 
+// likeEscaper escapes the wildcards of LIKE in the text matched.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 func (repo *userRepository) Close() error {
 	return nil
 }
@@ -0,0 +1,39 @@
+package persistence
+
+import (
+	"errors"
+
+	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/group"
+	"github.com/mirror520/identity/persistence/db"
+	"github.com/mirror520/identity/persistence/inmem"
+	"github.com/mirror520/identity/persistence/kv"
+	"github.com/mirror520/identity/user"
+)
+
+// NewGroupRepository shares the database opened by the user repository.
+func NewGroupRepository(cfg conf.Persistence, users user.Repository) (group.Repository, error) {
+	switch cfg.Driver {
+	case conf.SQLite:
+		database, ok := users.(db.Database)
+		if !ok {
+			return nil, errors.New("database not found")
+		}
+
+		return db.NewGroupRepository(database.DB())
+
+	case conf.BadgerDB:
+		database, ok := users.(kv.Database)
+		if !ok {
+			return nil, errors.New("database not found")
+		}
+
+		return kv.NewGroupRepository(database.DB())
+
+	case conf.InMem:
+		return inmem.NewGroupRepository()
+
+	default:
+		return nil, errors.New("driver not supported")
+	}
+}
@@ -0,0 +1,86 @@
+package inmem
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/mirror520/identity/client"
+	"github.com/mirror520/identity/events"
+)
+
+type clientRepository struct {
+	clients map[client.ClientID]*client.Client // map[ClientID]*client.Client
+	sync.RWMutex
+}
+
+func NewClientRepository() (client.Repository, error) {
+	repo := new(clientRepository)
+	repo.clients = make(map[client.ClientID]*client.Client)
+	return repo, nil
+}
+
+func (repo *clientRepository) Store(c *client.Client) error {
+	repo.Lock()
+
+	newClient := copyClient(c)
+	newClient.EventStore = nil
+
+	repo.clients[c.ID] = newClient
+
+	repo.Unlock()
+	return nil
+}
+
+func (repo *clientRepository) Remove(id client.ClientID) error {
+	repo.Lock()
+	delete(repo.clients, id)
+	repo.Unlock()
+	return nil
+}
+
+func (repo *clientRepository) Find(id client.ClientID) (*client.Client, error) {
+	repo.RLock()
+	defer repo.RUnlock()
+
+	c, ok := repo.clients[id]
+	if !ok {
+		return nil, client.ErrClientNotFound
+	}
+
+	result := copyClient(c)
+	result.EventStore = events.NewEventStore()
+	return result, nil
+}
+
+func (repo *clientRepository) List() ([]*client.Client, error) {
+	repo.RLock()
+	defer repo.RUnlock()
+
+	clients := make([]*client.Client, 0, len(repo.clients))
+	for _, c := range repo.clients {
+		result := copyClient(c)
+		result.EventStore = events.NewEventStore()
+		clients = append(clients, result)
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ID < clients[j].ID
+	})
+
+	return clients, nil
+}
+
+func (repo *clientRepository) Close() error {
+	return nil
+}
+
+func copyClient(c *client.Client) *client.Client {
+	result := new(client.Client)
+	*result = *c
+	result.RedirectURIs = append([]string{}, c.RedirectURIs...)
+	result.GrantTypes = append([]string{}, c.GrantTypes...)
+	result.Scopes = append([]string{}, c.Scopes...)
+	result.Roles = append([]string{}, c.Roles...)
+	result.Audiences = append([]string{}, c.Audiences...)
+	return result
+}
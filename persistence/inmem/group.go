@@ -0,0 +1,107 @@
+package inmem
+
+import (
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/group"
+	"github.com/mirror520/identity/user"
+)
+
+type groupRepository struct {
+	groups map[group.GroupID]*group.Group // map[GroupID]*group.Group
+	sync.RWMutex
+}
+
+func NewGroupRepository() (group.Repository, error) {
+	repo := new(groupRepository)
+	repo.groups = make(map[group.GroupID]*group.Group)
+	return repo, nil
+}
+
+func (repo *groupRepository) Store(g *group.Group) error {
+	repo.Lock()
+
+	newGroup := copyGroup(g)
+	newGroup.EventStore = nil
+
+	repo.groups[g.ID] = newGroup
+
+	repo.Unlock()
+	return nil
+}
+
+func (repo *groupRepository) Remove(id group.GroupID) error {
+	repo.Lock()
+	delete(repo.groups, id)
+	repo.Unlock()
+	return nil
+}
+
+func (repo *groupRepository) Find(id group.GroupID) (*group.Group, error) {
+	repo.RLock()
+	defer repo.RUnlock()
+
+	g, ok := repo.groups[id]
+	if !ok {
+		return nil, group.ErrGroupNotFound
+	}
+
+	result := copyGroup(g)
+	result.EventStore = events.NewEventStore()
+	return result, nil
+}
+
+func (repo *groupRepository) FindByMember(id user.UserID) ([]*group.Group, error) {
+	return repo.filter(func(g *group.Group) bool {
+		return g.HasMember(id)
+	})
+}
+
+func (repo *groupRepository) FindByParent(id group.GroupID) ([]*group.Group, error) {
+	return repo.filter(func(g *group.Group) bool {
+		return g.Parent == id
+	})
+}
+
+func (repo *groupRepository) List() ([]*group.Group, error) {
+	return repo.filter(func(g *group.Group) bool {
+		return true
+	})
+}
+
+func (repo *groupRepository) filter(match func(g *group.Group) bool) ([]*group.Group, error) {
+	repo.RLock()
+	defer repo.RUnlock()
+
+	groups := make([]*group.Group, 0)
+	for _, g := range repo.groups {
+		if !match(g) {
+			continue
+		}
+
+		result := copyGroup(g)
+		result.EventStore = events.NewEventStore()
+		groups = append(groups, result)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].ID < groups[j].ID
+	})
+
+	return groups, nil
+}
+
+func (repo *groupRepository) Close() error {
+	return nil
+}
+
+func copyGroup(g *group.Group) *group.Group {
+	result := new(group.Group)
+	*result = *g
+	result.Members = slices.Clone(g.Members)
+	result.Roles = slices.Clone(g.Roles)
+	return result
+}
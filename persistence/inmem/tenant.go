@@ -0,0 +1,78 @@
+package inmem
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/tenant"
+)
+
+type tenantRepository struct {
+	tenants map[tenant.TenantID]*tenant.Tenant // map[TenantID]*tenant.Tenant
+	sync.RWMutex
+}
+
+func NewTenantRepository() (tenant.Repository, error) {
+	repo := new(tenantRepository)
+	repo.tenants = make(map[tenant.TenantID]*tenant.Tenant)
+	return repo, nil
+}
+
+func (repo *tenantRepository) Store(t *tenant.Tenant) error {
+	repo.Lock()
+
+	newTenant := new(tenant.Tenant)
+	*newTenant = *t
+	newTenant.EventStore = nil
+
+	repo.tenants[t.ID] = newTenant
+
+	repo.Unlock()
+	return nil
+}
+
+func (repo *tenantRepository) Remove(id tenant.TenantID) error {
+	repo.Lock()
+	delete(repo.tenants, id)
+	repo.Unlock()
+	return nil
+}
+
+func (repo *tenantRepository) Find(id tenant.TenantID) (*tenant.Tenant, error) {
+	repo.RLock()
+	defer repo.RUnlock()
+
+	t, ok := repo.tenants[id]
+	if !ok {
+		return nil, tenant.ErrTenantNotFound
+	}
+
+	result := new(tenant.Tenant)
+	*result = *t
+	result.EventStore = events.NewEventStore()
+	return result, nil
+}
+
+func (repo *tenantRepository) List() ([]*tenant.Tenant, error) {
+	repo.RLock()
+	defer repo.RUnlock()
+
+	tenants := make([]*tenant.Tenant, 0, len(repo.tenants))
+	for _, t := range repo.tenants {
+		result := new(tenant.Tenant)
+		*result = *t
+		result.EventStore = events.NewEventStore()
+		tenants = append(tenants, result)
+	}
+
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].ID < tenants[j].ID
+	})
+
+	return tenants, nil
+}
+
+func (repo *tenantRepository) Close() error {
+	return nil
+}
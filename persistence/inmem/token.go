@@ -0,0 +1,141 @@
+package inmem
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/token"
+	"github.com/mirror520/identity/user"
+)
+
+type tokenRepository struct {
+	families map[token.FamilyID]*token.Family // map[FamilyID]*token.Family
+	sync.RWMutex
+}
+
+func NewTokenRepository() (token.Repository, error) {
+	repo := new(tokenRepository)
+	repo.families = make(map[token.FamilyID]*token.Family)
+	return repo, nil
+}
+
+func (repo *tokenRepository) Store(f *token.Family) error {
+	repo.Lock()
+	repo.store(f)
+	repo.Unlock()
+	return nil
+}
+
+func (repo *tokenRepository) Rotate(f *token.Family, previous string) error {
+	repo.Lock()
+	defer repo.Unlock()
+
+	current, ok := repo.families[f.ID]
+	if !ok || current.Status != token.Active || current.Hash != previous {
+		return token.ErrTokenReused
+	}
+
+	repo.store(f)
+	return nil
+}
+
+func (repo *tokenRepository) Purge(users []user.UserID) error {
+	repo.Lock()
+	defer repo.Unlock()
+
+	for id, f := range repo.families {
+		if slices.Contains(users, f.UserID) {
+			delete(repo.families, id)
+		}
+	}
+
+	return nil
+}
+
+func (repo *tokenRepository) store(f *token.Family) {
+	newFamily := new(token.Family)
+	*newFamily = *f
+
+	f = newFamily
+	f.Used = append([]string{}, f.Used...)
+	f.Scope = slices.Clone(f.Scope)
+	f.AMR = slices.Clone(f.AMR)
+	f.EventStore = nil
+
+	repo.families[f.ID] = f
+}
+
+func (repo *tokenRepository) Find(id token.FamilyID) (*token.Family, error) {
+	repo.RLock()
+	defer repo.RUnlock()
+
+	f, ok := repo.families[id]
+	if !ok {
+		return nil, token.ErrFamilyNotFound
+	}
+
+	result := new(token.Family)
+	*result = *f
+	result.Used = append([]string{}, f.Used...)
+	result.EventStore = events.NewEventStore()
+	return result, nil
+}
+
+func (repo *tokenRepository) Close() error {
+	return nil
+}
+
+type revocationRepository struct {
+	revocations map[string]*token.Revocation // map[jti]*token.Revocation
+	sync.RWMutex
+}
+
+func NewRevocationRepository() (token.RevocationRepository, error) {
+	repo := new(revocationRepository)
+	repo.revocations = make(map[string]*token.Revocation)
+	return repo, nil
+}
+
+func (repo *revocationRepository) Store(r *token.Revocation) error {
+	repo.Lock()
+	defer repo.Unlock()
+
+	now := time.Now()
+	for jti, old := range repo.revocations {
+		if old.Expired(now) {
+			delete(repo.revocations, jti)
+		}
+	}
+
+	newRevocation := new(token.Revocation)
+	*newRevocation = *r
+	newRevocation.EventStore = nil
+
+	repo.revocations[r.ID] = newRevocation
+	return nil
+}
+
+func (repo *revocationRepository) List(at time.Time) ([]*token.Revocation, error) {
+	repo.RLock()
+	defer repo.RUnlock()
+
+	revocations := make([]*token.Revocation, 0, len(repo.revocations))
+	for _, r := range repo.revocations {
+		if r.Expired(at) {
+			continue
+		}
+
+		result := new(token.Revocation)
+		*result = *r
+		result.EventStore = events.NewEventStore()
+		revocations = append(revocations, result)
+	}
+
+	return revocations, nil
+}
+
+func (repo *revocationRepository) Close() error {
+	return nil
+}
@@ -1,29 +1,56 @@
 package inmem
 
 import (
+	"bytes"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/tenant"
 	"github.com/mirror520/identity/user"
 )
 
 type userRepository struct {
-	users     map[user.UserID]*user.User   // map[UserID]*user.User
-	usernames map[string]*user.User        // map[Username]*user.User
-	socials   map[user.SocialID]*user.User // map[SocialID]*user.User
+	users        map[user.UserID]*user.User // map[UserID]*user.User
+	usernames    map[string]*user.User      // map[TenantID:Username]*user.User
+	emails       map[string]*user.User      // map[TenantID:Email]*user.User
+	socials      map[string]*user.User      // map[TenantID:SocialID]*user.User
+	reservations map[string]reservation     // map[TenantID:Username]reservation
 	sync.RWMutex
 }
 
+type reservation struct {
+	userID user.UserID
+	until  time.Time
+}
+
 func NewUserRepository() (user.Repository, error) {
 	repo := new(userRepository)
 	repo.users = make(map[user.UserID]*user.User)
 	repo.usernames = make(map[string]*user.User)
-	repo.socials = make(map[user.SocialID]*user.User)
+	repo.emails = make(map[string]*user.User)
+	repo.socials = make(map[string]*user.User)
+	repo.reservations = make(map[string]reservation)
 	return repo, nil
 }
 
+// Store reindexes the user as a whole, the email is unique among the
+// members of each tenant and the deleted users are indexed by nothing.
 func (repo *userRepository) Store(u *user.User) error {
 	repo.Lock()
+	defer repo.Unlock()
+
+	deleted := !u.DeletedAt.IsZero()
+
+	if u.Email != "" && !deleted {
+		for _, m := range u.Tenants {
+			other, ok := repo.emails[tenantKey(m.TenantID, u.Email)]
+			if ok && other.ID != u.ID {
+				return user.ErrEmailExists
+			}
+		}
+	}
 
 	newUser := new(user.User)
 	*newUser = *u
@@ -32,13 +59,47 @@ func (repo *userRepository) Store(u *user.User) error {
 	u.EventStore = nil
 
 	repo.users[u.ID] = u
-	repo.usernames[u.Username] = u
 
-	for _, account := range u.Accounts {
-		repo.socials[account.SocialID] = u
+	// the keys of the username, the email and the tenants replaced
+	for _, index := range []map[string]*user.User{repo.usernames, repo.emails, repo.socials} {
+		for key, indexed := range index {
+			if indexed.ID == u.ID {
+				delete(index, key)
+			}
+		}
+	}
+
+	if deleted {
+		return nil
+	}
+
+	for _, m := range u.Tenants {
+		repo.usernames[tenantKey(m.TenantID, u.Username)] = u
+
+		if u.Email != "" {
+			repo.emails[tenantKey(m.TenantID, u.Email)] = u
+		}
+
+		for _, account := range u.Accounts {
+			repo.socials[tenantKey(m.TenantID, string(account.SocialID))] = u
+		}
 	}
 
-	repo.Unlock()
+	return nil
+}
+
+func (repo *userRepository) ReserveUsername(tenantID tenant.TenantID, username string, id user.UserID, until time.Time) error {
+	repo.Lock()
+	defer repo.Unlock()
+
+	key := tenantKey(tenantID, username)
+
+	r, ok := repo.reservations[key]
+	if ok && r.userID != id && time.Now().Before(r.until) {
+		return user.ErrUsernameReserved
+	}
+
+	repo.reservations[key] = reservation{id, until}
 	return nil
 }
 
@@ -47,7 +108,7 @@ func (repo *userRepository) Find(id user.UserID) (*user.User, error) {
 	defer repo.RUnlock()
 
 	u, ok := repo.users[id]
-	if !ok {
+	if !ok || !u.DeletedAt.IsZero() {
 		return nil, user.ErrUserNotFound
 	}
 
@@ -55,12 +116,25 @@ func (repo *userRepository) Find(id user.UserID) (*user.User, error) {
 	return u, nil
 }
 
-func (repo *userRepository) FindByUsername(username string) (*user.User, error) {
+func (repo *userRepository) FindByUsername(tenantID tenant.TenantID, username string) (*user.User, error) {
+	return repo.find(repo.usernames, tenantID, username)
+}
+
+func (repo *userRepository) FindByEmail(tenantID tenant.TenantID, email string) (*user.User, error) {
+	return repo.find(repo.emails, tenantID, email)
+}
+
+func (repo *userRepository) FindBySocialID(tenantID tenant.TenantID, socialID user.SocialID) (*user.User, error) {
+	return repo.find(repo.socials, tenantID, string(socialID))
+}
+
+// find skips the users which have left the tenant since indexed.
+func (repo *userRepository) find(index map[string]*user.User, tenantID tenant.TenantID, key string) (*user.User, error) {
 	repo.RLock()
 	defer repo.RUnlock()
 
-	u, ok := repo.usernames[username]
-	if !ok {
+	u, ok := index[tenantKey(tenantID, key)]
+	if !ok || u.Membership(tenantID) == nil {
 		return nil, user.ErrUserNotFound
 	}
 
@@ -68,19 +142,55 @@ func (repo *userRepository) FindByUsername(username string) (*user.User, error)
 	return u, nil
 }
 
-func (repo *userRepository) FindBySocialID(socialID user.SocialID) (*user.User, error) {
+func (repo *userRepository) List(q user.Query) ([]*user.User, error) {
 	repo.RLock()
 	defer repo.RUnlock()
 
-	u, ok := repo.socials[socialID]
-	if !ok {
-		return nil, user.ErrUserNotFound
+	users := make([]*user.User, 0)
+	for _, u := range repo.users {
+		if q.Beyond(u.ID) && q.Match(u) {
+			users = append(users, u)
+		}
 	}
 
-	u.EventStore = events.NewEventStore()
-	return u, nil
+	slices.SortFunc(users, func(a, b *user.User) int {
+		if q.Desc {
+			return bytes.Compare(b.ID.Bytes(), a.ID.Bytes())
+		}
+
+		return bytes.Compare(a.ID.Bytes(), b.ID.Bytes())
+	})
+
+	if q.Limit > 0 && len(users) > q.Limit {
+		users = users[:q.Limit]
+	}
+
+	for _, u := range users {
+		u.EventStore = events.NewEventStore()
+	}
+
+	return users, nil
+}
+
+func (repo *userRepository) Purge(before time.Time) ([]user.UserID, error) {
+	repo.Lock()
+	defer repo.Unlock()
+
+	ids := make([]user.UserID, 0)
+	for id, u := range repo.users {
+		if !u.DeletedAt.IsZero() && u.DeletedAt.Before(before) {
+			delete(repo.users, id)
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
 }
 
 func (repo *userRepository) Close() error {
 	return nil
 }
+
+func tenantKey(tenantID tenant.TenantID, key string) string {
+	return tenantID.String() + ":" + key
+}
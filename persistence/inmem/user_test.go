@@ -1,10 +1,13 @@
 package inmem
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 
+	"github.com/mirror520/identity/tenant"
 	"github.com/mirror520/identity/user"
 )
 
@@ -21,14 +24,159 @@ func (suite *userRepositoryTestSuite) SetupSuite() {
 		return
 	}
 
-	u := user.NewUser("mirror770109", "Lin, Ying-Chin", "mirror770109@gmail.com")
+	u := user.NewUser(tenant.Default, "mirror770109", "Lin, Ying-Chin", "mirror770109@gmail.com")
 	u.AddSocialAccount(user.GOOGLE, "100043685676652067799")
+	u.GrantRole(user.RoleAdmin)
 	users.Store(u)
 
 	suite.users = users
 	suite.user = u
 }
 
+func (suite *userRepositoryTestSuite) TestChangeUsername() {
+	u := user.NewUser(tenant.Default, "renamer01", "Renamer01", "renamer01@example.com")
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u.Username = "renamer01a"
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// the old username is found no more
+	_, err := suite.users.FindByUsername(tenant.Default, "renamer01")
+	suite.ErrorIs(err, user.ErrUserNotFound)
+
+	found, err := suite.users.FindByUsername(tenant.Default, "renamer01a")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(u.ID, found.ID)
+
+	// the old username is held for the user
+	until := time.Now().Add(time.Hour)
+	suite.NoError(suite.users.ReserveUsername(tenant.Default, "renamer01", u.ID, until))
+	suite.NoError(suite.users.ReserveUsername(tenant.Default, "renamer01", u.ID, until))
+
+	other := user.NewUser(tenant.Default, "renamer01", "Renamer02", "renamer02@example.com")
+	err = suite.users.ReserveUsername(tenant.Default, "renamer01", other.ID, until)
+	suite.ErrorIs(err, user.ErrUsernameReserved)
+
+	err = suite.users.ReserveUsername("acme", "renamer01", other.ID, until)
+	suite.NoError(err)
+}
+
+func (suite *userRepositoryTestSuite) TestDeleteAndPurge() {
+	u := user.NewUser(tenant.Default, "deleter01", "Deleter01", "deleter01@example.com")
+	u.AddSocialAccount(user.GOOGLE, "deleter01")
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	u.Delete()
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// the deleted user is found by none of the finders
+	_, err := suite.users.Find(u.ID)
+	suite.ErrorIs(err, user.ErrUserNotFound)
+
+	_, err = suite.users.FindByUsername(tenant.Default, u.Username)
+	suite.ErrorIs(err, user.ErrUserNotFound)
+
+	_, err = suite.users.FindByEmail(tenant.Default, u.Email)
+	suite.ErrorIs(err, user.ErrUserNotFound)
+
+	_, err = suite.users.FindBySocialID(tenant.Default, "deleter01")
+	suite.ErrorIs(err, user.ErrUserNotFound)
+
+	users, err := suite.users.List(user.Query{Text: "deleter01"})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Empty(users)
+
+	// the email is free
+	other := user.NewUser(tenant.Default, "deleter02", "Deleter02", u.Email)
+	suite.NoError(suite.users.Store(other))
+
+	ids, err := suite.users.Purge(u.DeletedAt)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Empty(ids)
+
+	ids, err = suite.users.Purge(time.Now())
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal([]user.UserID{u.ID}, ids)
+
+	_, err = suite.users.Find(other.ID)
+	suite.NoError(err)
+}
+
+func (suite *userRepositoryTestSuite) TestRemoveSocialAccount() {
+	u := user.NewUser(tenant.Default, "unlinker01", "Unlinker01", "unlinker01@example.com")
+	u.AddSocialAccount(user.GOOGLE, "unlinker01")
+	u.ChangePassword("hash")
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := u.RemoveSocialAccount(user.GOOGLE, "unlinker01"); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err := suite.users.FindBySocialID(tenant.Default, "unlinker01")
+	suite.ErrorIs(err, user.ErrUserNotFound)
+
+	found, err := suite.users.Find(u.ID)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Empty(found.Accounts)
+
+	// linked again
+	u.AddSocialAccount(user.GOOGLE, "unlinker01")
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	found, err = suite.users.FindBySocialID(tenant.Default, "unlinker01")
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(u.ID, found.ID)
+	suite.Len(found.Accounts, 1)
+}
+
 func (suite *userRepositoryTestSuite) TestFind() {
 	user, err := suite.users.Find(suite.user.ID)
 	if err != nil {
@@ -37,10 +185,11 @@ func (suite *userRepositoryTestSuite) TestFind() {
 	}
 
 	suite.Equal("mirror770109", user.Username)
+	suite.Equal([]string{"user", "admin"}, user.Roles)
 }
 
 func (suite *userRepositoryTestSuite) TestFindByUsername() {
-	user, err := suite.users.FindByUsername(suite.user.Username)
+	user, err := suite.users.FindByUsername(tenant.Default, suite.user.Username)
 	if err != nil {
 		suite.Fail(err.Error())
 		return
@@ -52,7 +201,7 @@ func (suite *userRepositoryTestSuite) TestFindByUsername() {
 func (suite *userRepositoryTestSuite) TestFindBySocialID() {
 	sid := suite.user.Accounts[0].SocialID
 
-	user, err := suite.users.FindBySocialID(sid)
+	user, err := suite.users.FindBySocialID(tenant.Default, sid)
 	if err != nil {
 		suite.Fail(err.Error())
 		return
@@ -62,6 +211,137 @@ func (suite *userRepositoryTestSuite) TestFindBySocialID() {
 	suite.Equal(sid, user.Accounts[0].SocialID)
 }
 
+func (suite *userRepositoryTestSuite) TestFindByTenant() {
+	found, err := suite.users.FindByEmail(tenant.Default, suite.user.Email)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(suite.user.ID, found.ID)
+	suite.Equal(tenant.Default, found.Tenants[0].TenantID)
+
+	// the same username is another user in another tenant
+	_, err = suite.users.FindByUsername("acme", suite.user.Username)
+	suite.ErrorIs(err, user.ErrUserNotFound)
+
+	_, err = suite.users.FindBySocialID("acme", suite.user.Accounts[0].SocialID)
+	suite.ErrorIs(err, user.ErrUserNotFound)
+}
+
+func (suite *userRepositoryTestSuite) TestList() {
+	ids := make([]user.UserID, 3)
+	for i := range ids {
+		username := fmt.Sprintf("lister%02d", i)
+
+		u := user.NewUser("acme", username, "Lister", username+"@example.com")
+		suite.users.Store(u)
+
+		ids[i] = u.ID
+	}
+
+	q := user.Query{TenantID: "acme", Text: "LISTER", Limit: 2}
+
+	users, err := suite.users.List(q)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(ids[:2], userIDs(users))
+
+	q.After = &ids[1]
+
+	users, err = suite.users.List(q)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(ids[2:], userIDs(users))
+
+	users, err = suite.users.List(user.Query{Text: "lister", Desc: true, Limit: 2})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal([]user.UserID{ids[2], ids[1]}, userIDs(users))
+
+	users, err = suite.users.List(user.Query{Provider: user.GOOGLE})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal([]user.UserID{suite.user.ID}, userIDs(users))
+
+	activated := user.Activated
+	users, err = suite.users.List(user.Query{
+		Status:       &activated,
+		CreatedAfter: ids[0].Time(),
+	})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Empty(users)
+
+	users, err = suite.users.List(user.Query{TenantID: tenant.Default, Text: "lister"})
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Empty(users)
+}
+
+func (suite *userRepositoryTestSuite) TestStoreEmail() {
+	u := user.NewUser(tenant.Default, "emailer01", "Emailer01", "emailer01@example.com")
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	// the email is unique among the members of the tenant
+	other := user.NewUser(tenant.Default, "emailer02", "Emailer02", u.Email)
+	suite.ErrorIs(suite.users.Store(other), user.ErrEmailExists)
+
+	other = user.NewUser("acme", "emailer02", "Emailer02", u.Email)
+	suite.NoError(suite.users.Store(other))
+
+	// the old email is free once changed
+	u.Email = "emailer01@example.org"
+	if err := suite.users.Store(u); err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	_, err := suite.users.FindByEmail(tenant.Default, "emailer01@example.com")
+	suite.ErrorIs(err, user.ErrUserNotFound)
+
+	found, err := suite.users.FindByEmail(tenant.Default, u.Email)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.Equal(u.ID, found.ID)
+
+	other = user.NewUser(tenant.Default, "emailer03", "Emailer03", "emailer01@example.com")
+	suite.NoError(suite.users.Store(other))
+}
+
+func userIDs(users []*user.User) []user.UserID {
+	ids := make([]user.UserID, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+
+	return ids
+}
+
 func TestUserRepositoryTestSuite(t *testing.T) {
 	suite.Run(t, new(userRepositoryTestSuite))
 }
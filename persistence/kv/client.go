@@ -0,0 +1,95 @@
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/mirror520/identity/client"
+)
+
+type clientRepository struct {
+	db *badger.DB
+}
+
+// NewClientRepository shares the database of the users, badger locks its directory.
+func NewClientRepository(db *badger.DB) (client.Repository, error) {
+	repo := new(clientRepository)
+	repo.db = db
+	return repo, nil
+}
+
+func (repo *clientRepository) Store(c *client.Client) error {
+	bs, err := json.Marshal(NewClient(c))
+	if err != nil {
+		return err
+	}
+
+	return repo.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("client:"+c.ID.String()), bs)
+	})
+}
+
+func (repo *clientRepository) Remove(id client.ClientID) error {
+	return repo.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte("client:" + id.String()))
+	})
+}
+
+func (repo *clientRepository) Find(id client.ClientID) (*client.Client, error) {
+	var c *Client
+
+	if err := repo.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("client:" + id.String()))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return client.ErrClientNotFound
+			}
+
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &c)
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	return c.reconstitute(), nil
+}
+
+func (repo *clientRepository) List() ([]*client.Client, error) {
+	clients := make([]*client.Client, 0)
+
+	err := repo.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("client:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var c *Client
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &c)
+			}); err != nil {
+				return err
+			}
+
+			clients = append(clients, c.reconstitute())
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// Close leaves the shared database to the users.
+func (repo *clientRepository) Close() error {
+	return nil
+}
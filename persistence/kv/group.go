@@ -0,0 +1,120 @@
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/group"
+	"github.com/mirror520/identity/user"
+)
+
+type groupRepository struct {
+	db *badger.DB
+}
+
+// NewGroupRepository shares the database of the users, badger locks its directory.
+func NewGroupRepository(db *badger.DB) (group.Repository, error) {
+	repo := new(groupRepository)
+	repo.db = db
+	return repo, nil
+}
+
+func (repo *groupRepository) Store(g *group.Group) error {
+	bs, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+
+	return repo.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("group:"+g.ID.String()), bs)
+	})
+}
+
+func (repo *groupRepository) Remove(id group.GroupID) error {
+	return repo.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte("group:" + id.String()))
+	})
+}
+
+func (repo *groupRepository) Find(id group.GroupID) (*group.Group, error) {
+	var g *group.Group
+
+	if err := repo.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("group:" + id.String()))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return group.ErrGroupNotFound
+			}
+
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &g)
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	g.EventStore = events.NewEventStore()
+	return g, nil
+}
+
+// FindByMember scans the groups, the groups are far fewer than the users.
+func (repo *groupRepository) FindByMember(id user.UserID) ([]*group.Group, error) {
+	return repo.filter(func(g *group.Group) bool {
+		return g.HasMember(id)
+	})
+}
+
+func (repo *groupRepository) FindByParent(id group.GroupID) ([]*group.Group, error) {
+	return repo.filter(func(g *group.Group) bool {
+		return g.Parent == id
+	})
+}
+
+func (repo *groupRepository) List() ([]*group.Group, error) {
+	return repo.filter(func(g *group.Group) bool {
+		return true
+	})
+}
+
+func (repo *groupRepository) filter(match func(g *group.Group) bool) ([]*group.Group, error) {
+	groups := make([]*group.Group, 0)
+
+	err := repo.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("group:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var g *group.Group
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &g)
+			}); err != nil {
+				return err
+			}
+
+			if match(g) {
+				g.EventStore = events.NewEventStore()
+				groups = append(groups, g)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// Close leaves the shared database to the users.
+func (repo *groupRepository) Close() error {
+	return nil
+}
@@ -0,0 +1,153 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/user"
+)
+
+// migrate applies the migration unless applied, the migration records
+// itself under "migration:<id>" once done.
+func migrate(db *badger.DB, id string, apply func(db *badger.DB) error) error {
+	key := []byte("migration:" + id)
+
+	err := db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(key)
+		return err
+	})
+
+	switch {
+	case err == nil:
+		return nil
+
+	case !errors.Is(err, badger.ErrKeyNotFound):
+		return err
+	}
+
+	if err := apply(db); err != nil {
+		return err
+	}
+
+	return db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, []byte(time.Now().Format(time.RFC3339)))
+	})
+}
+
+// legacyUserKeyLen is the length of the keys of the users stored by the
+// IDs alone, which are the bytes of the ULIDs.
+const legacyUserKeyLen = 16
+
+// rekeyUsers moves the users stored before the tenants, keyed by the ID
+// bytes, "username:<username>" and "social:<social id>", to the keys of
+// the tenants, the users join the default tenant as they were created.
+// The users are moved one by one, a migration interrupted is resumed.
+func (repo *userRepository) rekeyUsers(db *badger.DB) error {
+	legacy := make([]*User, 0)
+
+	if err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			if len(item.Key()) != legacyUserKeyLen {
+				continue
+			}
+
+			// the other keys of the length are not of the users
+			var u *User
+			if err := item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &u)
+			}); err != nil || u == nil || u.User == nil {
+				continue
+			}
+
+			if !bytes.Equal(item.Key(), u.ID.Bytes()) {
+				continue
+			}
+
+			legacy = append(legacy, u)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, u := range legacy {
+		if err := repo.rekeyUser(db, u); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (repo *userRepository) rekeyUser(db *badger.DB, legacy *User) error {
+	u := legacy.reconstitute()
+
+	// the users stored since keep their records, the legacy keys go
+	err := db.View(func(txn *badger.Txn) error {
+		_, err := get(txn, userKey(u.ID))
+		return err
+	})
+
+	switch {
+	case errors.Is(err, user.ErrUserNotFound):
+		if len(u.Tenants) == 0 {
+			u.Tenants = []*user.Membership{
+				{
+					TenantID: tenant.Default,
+					Roles:    make([]string, 0),
+					JoinedAt: u.CreatedAt,
+				},
+			}
+		}
+
+		if err := repo.Store(u); err != nil {
+			return err
+		}
+
+	case err != nil:
+		return err
+	}
+
+	return db.Update(func(txn *badger.Txn) error {
+		keys := [][]byte{
+			[]byte("username:" + u.Username),
+		}
+
+		for _, account := range u.Accounts {
+			keys = append(keys, []byte("social:"+string(account.SocialID)))
+		}
+
+		// the legacy indexes of the other users are left as they are
+		for _, key := range keys {
+			indexed, err := get(txn, key)
+			switch {
+			case errors.Is(err, user.ErrUserNotFound):
+				continue
+
+			case err != nil:
+				return err
+			}
+
+			if indexed.ID != u.ID {
+				continue
+			}
+
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		return txn.Delete(u.ID.Bytes())
+	})
+}
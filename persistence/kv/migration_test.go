@@ -0,0 +1,110 @@
+package kv
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/user"
+)
+
+// legacyUser is a user stored by the baseline, before the tenants.
+const legacyUser = `{"id":"01M56HJST596Z67XB7519DM1T1","username":"legacy01","name":"Legacy01","email":"legacy01@example.com","status":"registered","accounts":[{"social_id":"100000000000000000001","social_provider":"google","created_at":"2026-10-18T03:41:12.901231259Z","updated_at":"2026-10-18T03:41:12.901231379Z","deleted_at":"0001-01-01T00:00:00Z"}],"avatar":"","token":{"token":"","expired_at":"0001-01-01T00:00:00Z"},"created_at":"2026-10-18T03:41:12.901Z","updated_at":"2026-10-18T03:41:12.901231379Z","deleted_at":"0001-01-01T00:00:00Z"}`
+
+func TestMigrateLegacyUsers(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := conf.Persistence{
+		Driver: conf.BadgerDB,
+		Host:   t.TempDir(),
+		Name:   "identity",
+	}
+
+	id, err := user.ParseID("01M56HJST596Z67XB7519DM1T1")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	legacyKeys := [][]byte{
+		id.Bytes(),
+		[]byte("username:legacy01"),
+		[]byte("social:100000000000000000001"),
+	}
+
+	db, err := badger.Open(badger.DefaultOptions(cfg.Host + "/" + cfg.Name))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	err = db.Update(func(txn *badger.Txn) error {
+		for _, key := range legacyKeys {
+			if err := txn.Set(key, []byte(legacyUser)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	db.Close()
+
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	users, err := NewUserRepository(cfg)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	u, err := users.FindByUsername(tenant.Default, "legacy01")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(id, u.ID)
+	assert.Equal(user.Registered, u.Status)
+	assert.NotNil(u.Membership(tenant.Default))
+
+	u, err = users.FindBySocialID(tenant.Default, "100000000000000000001")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(id, u.ID)
+
+	_, err = users.FindByEmail(tenant.Default, "legacy01@example.com")
+	assert.NoError(err)
+
+	// the legacy keys are deleted
+	err = users.(Database).DB().View(func(txn *badger.Txn) error {
+		for _, key := range legacyKeys {
+			_, err := txn.Get(key)
+			assert.ErrorIs(err, badger.ErrKeyNotFound, string(key))
+		}
+
+		return nil
+	})
+	assert.NoError(err)
+
+	// the migration applies once
+	users.Close()
+
+	users, err = NewUserRepository(cfg)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+	defer users.Close()
+
+	_, err = users.Find(id)
+	assert.NoError(err)
+}
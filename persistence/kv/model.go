@@ -0,0 +1,59 @@
+package kv
+
+import (
+	"github.com/mirror520/identity/client"
+	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/user"
+)
+
+// User keeps the credentials hidden from the JSON of the domain model.
+type User struct {
+	*user.User
+	Password     *user.Password         `json:"password,omitempty"`
+	OTP          *user.OTP              `json:"otp,omitempty"`
+	PendingOTP   *user.OTP              `json:"pending_otp,omitempty"`
+	Verification *user.VerificationCode `json:"verification,omitempty"`
+	EmailChange  *user.EmailChange      `json:"email_change,omitempty"`
+}
+
+func NewUser(u *user.User) *User {
+	return &User{
+		User:         u,
+		Password:     u.Password,
+		OTP:          u.OTP,
+		PendingOTP:   u.PendingOTP,
+		Verification: u.Verification,
+		EmailChange:  u.EmailChange,
+	}
+}
+
+func (u *User) reconstitute() *user.User {
+	result := u.User
+	result.Password = u.Password
+	result.OTP = u.OTP
+	result.PendingOTP = u.PendingOTP
+	result.Verification = u.Verification
+	result.EmailChange = u.EmailChange
+	result.EventStore = events.NewEventStore()
+	return result
+}
+
+// Client keeps the hash of the secret hidden from the JSON of the domain model.
+type Client struct {
+	*client.Client
+	Secret string `json:"secret_hash,omitempty"`
+}
+
+func NewClient(c *client.Client) *Client {
+	return &Client{
+		Client: c,
+		Secret: c.Secret,
+	}
+}
+
+func (c *Client) reconstitute() *client.Client {
+	result := c.Client
+	result.Secret = c.Secret
+	result.EventStore = events.NewEventStore()
+	return result
+}
@@ -0,0 +1,98 @@
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/tenant"
+)
+
+type tenantRepository struct {
+	db *badger.DB
+}
+
+// NewTenantRepository shares the database of the users, badger locks its directory.
+func NewTenantRepository(db *badger.DB) (tenant.Repository, error) {
+	repo := new(tenantRepository)
+	repo.db = db
+	return repo, nil
+}
+
+func (repo *tenantRepository) Store(t *tenant.Tenant) error {
+	bs, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	return repo.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("tenant:"+t.ID.String()), bs)
+	})
+}
+
+func (repo *tenantRepository) Remove(id tenant.TenantID) error {
+	return repo.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte("tenant:" + id.String()))
+	})
+}
+
+func (repo *tenantRepository) Find(id tenant.TenantID) (*tenant.Tenant, error) {
+	var t *tenant.Tenant
+
+	if err := repo.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("tenant:" + id.String()))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return tenant.ErrTenantNotFound
+			}
+
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &t)
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	t.EventStore = events.NewEventStore()
+	return t, nil
+}
+
+func (repo *tenantRepository) List() ([]*tenant.Tenant, error) {
+	tenants := make([]*tenant.Tenant, 0)
+
+	err := repo.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("tenant:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var t *tenant.Tenant
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &t)
+			}); err != nil {
+				return err
+			}
+
+			t.EventStore = events.NewEventStore()
+			tenants = append(tenants, t)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tenants, nil
+}
+
+// Close leaves the shared database to the users.
+func (repo *tenantRepository) Close() error {
+	return nil
+}
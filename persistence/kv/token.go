@@ -0,0 +1,209 @@
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/token"
+	"github.com/mirror520/identity/user"
+)
+
+type tokenRepository struct {
+	db *badger.DB
+}
+
+// NewTokenRepository shares the database of the users, badger locks its directory.
+func NewTokenRepository(db *badger.DB) (token.Repository, error) {
+	repo := new(tokenRepository)
+	repo.db = db
+	return repo, nil
+}
+
+func (repo *tokenRepository) Store(f *token.Family) error {
+	bs, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	return repo.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("family:"+f.ID.String()), bs)
+	})
+}
+
+// Rotate compares the hash in the transaction, badger conflicts the
+// concurrent rotations of the same token.
+func (repo *tokenRepository) Rotate(f *token.Family, previous string) error {
+	bs, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	key := []byte("family:" + f.ID.String())
+
+	err = repo.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return token.ErrTokenReused
+			}
+
+			return err
+		}
+
+		var current *token.Family
+		if err := item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &current)
+		}); err != nil {
+			return err
+		}
+
+		if current.Status != token.Active || current.Hash != previous {
+			return token.ErrTokenReused
+		}
+
+		return txn.Set(key, bs)
+	})
+
+	if errors.Is(err, badger.ErrConflict) {
+		return token.ErrTokenReused
+	}
+
+	return err
+}
+
+func (repo *tokenRepository) Purge(users []user.UserID) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	return repo.db.Update(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("family:")
+
+		keys := make([][]byte, 0)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var f *token.Family
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &f)
+			}); err != nil {
+				return err
+			}
+
+			if slices.Contains(users, f.UserID) {
+				keys = append(keys, it.Item().KeyCopy(nil))
+			}
+		}
+
+		for _, key := range keys {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (repo *tokenRepository) Find(id token.FamilyID) (*token.Family, error) {
+	var f *token.Family
+
+	if err := repo.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("family:" + id.String()))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return token.ErrFamilyNotFound
+			}
+
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &f)
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	f.EventStore = events.NewEventStore()
+	return f, nil
+}
+
+// Close leaves the shared database to the users.
+func (repo *tokenRepository) Close() error {
+	return nil
+}
+
+type revocationRepository struct {
+	db *badger.DB
+}
+
+// NewRevocationRepository shares the database of the users, the entries
+// are expired by badger along with the tokens.
+func NewRevocationRepository(db *badger.DB) (token.RevocationRepository, error) {
+	repo := new(revocationRepository)
+	repo.db = db
+	return repo, nil
+}
+
+func (repo *revocationRepository) Store(r *token.Revocation) error {
+	ttl := time.Until(r.ExpiredAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	bs, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return repo.db.Update(func(txn *badger.Txn) error {
+		e := badger.NewEntry([]byte("revocation:"+r.ID), bs).WithTTL(ttl)
+		return txn.SetEntry(e)
+	})
+}
+
+func (repo *revocationRepository) List(at time.Time) ([]*token.Revocation, error) {
+	revocations := make([]*token.Revocation, 0)
+
+	err := repo.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("revocation:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var r *token.Revocation
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &r)
+			}); err != nil {
+				return err
+			}
+
+			if r.Expired(at) {
+				continue
+			}
+
+			r.EventStore = events.NewEventStore()
+			revocations = append(revocations, r)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return revocations, nil
+}
+
+// Close leaves the shared database to the users.
+func (repo *revocationRepository) Close() error {
+	return nil
+}
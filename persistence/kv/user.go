@@ -3,11 +3,12 @@ package kv
 import (
 	"encoding/json"
 	"errors"
+	"time"
 
 	"github.com/dgraph-io/badger/v4"
 
 	"github.com/mirror520/identity/conf"
-	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/tenant"
 	"github.com/mirror520/identity/user"
 )
 
@@ -29,9 +30,16 @@ func NewUserRepository(cfg conf.Persistence) (user.Repository, error) {
 	repo := new(userRepository)
 	repo.db = db
 
+	if err := migrate(db, "tenant_keys", repo.rekeyUsers); err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	return repo, nil
 }
 
+// Store reindexes the user as a whole, the email is unique among the
+// members of each tenant and the deleted users are indexed by nothing.
 func (repo *userRepository) Store(u *user.User) error {
 	newUser := new(user.User)
 	*newUser = *u
@@ -39,25 +47,43 @@ func (repo *userRepository) Store(u *user.User) error {
 	u = newUser
 	u.EventStore = nil
 
-	bs, err := json.Marshal(u)
+	bs, err := json.Marshal(NewUser(u))
 	if err != nil {
 		return err
 	}
 
 	return repo.db.Update(func(txn *badger.Txn) error {
-		err := txn.Set(u.ID.Bytes(), bs)
-		if err != nil {
+		if u.Email != "" && u.DeletedAt.IsZero() {
+			for _, m := range u.Tenants {
+				other, err := get(txn, tenantKey("email", m.TenantID, u.Email))
+				switch {
+				case err == nil && other.ID != u.ID:
+					return user.ErrEmailExists
+				case err != nil && !errors.Is(err, user.ErrUserNotFound):
+					return err
+				}
+			}
+		}
+
+		// the keys of the username, the email and the tenants replaced
+		previous, err := get(txn, userKey(u.ID))
+		switch {
+		case err == nil:
+			for _, key := range indexKeys(previous.User) {
+				if err := txn.Delete(key); err != nil {
+					return err
+				}
+			}
+		case !errors.Is(err, user.ErrUserNotFound):
 			return err
 		}
 
-		err = txn.Set([]byte("username:"+u.Username), bs)
-		if err != nil {
+		if err := txn.Set(userKey(u.ID), bs); err != nil {
 			return err
 		}
 
-		for _, account := range u.Accounts {
-			err := txn.Set([]byte("social:"+account.SocialID), bs)
-			if err != nil {
+		for _, key := range indexKeys(u) {
+			if err := txn.Set(key, bs); err != nil {
 				return err
 			}
 		}
@@ -66,45 +92,237 @@ func (repo *userRepository) Store(u *user.User) error {
 	})
 }
 
+// indexKeys returns the keys which the user is found by.
+func indexKeys(u *user.User) [][]byte {
+	keys := make([][]byte, 0)
+	if !u.DeletedAt.IsZero() {
+		return keys
+	}
+
+	for _, m := range u.Tenants {
+		keys = append(keys, tenantKey("username", m.TenantID, u.Username))
+
+		if u.Email != "" {
+			keys = append(keys, tenantKey("email", m.TenantID, u.Email))
+		}
+
+		for _, account := range u.Accounts {
+			keys = append(keys, tenantKey("social", m.TenantID, string(account.SocialID)))
+		}
+	}
+
+	return keys
+}
+
+// ReserveUsername expires the reservation by the TTL of badger. The
+// database is of this instance, the conflicting transactions are of its
+// own requests; the other instances are kept off by the service's leases.
+func (repo *userRepository) ReserveUsername(tenantID tenant.TenantID, username string, id user.UserID, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+
+	key := tenantKey("reservation", tenantID, username)
+
+	err := repo.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		switch {
+		case err == nil:
+			if err := item.Value(func(val []byte) error {
+				if string(val) != id.String() {
+					return user.ErrUsernameReserved
+				}
+
+				return nil
+			}); err != nil {
+				return err
+			}
+
+		case !errors.Is(err, badger.ErrKeyNotFound):
+			return err
+		}
+
+		e := badger.NewEntry(key, []byte(id.String())).WithTTL(ttl)
+		return txn.SetEntry(e)
+	})
+
+	if errors.Is(err, badger.ErrConflict) {
+		return user.ErrUsernameReserved
+	}
+
+	return err
+}
+
 func (repo *userRepository) Find(id user.UserID) (*user.User, error) {
-	return repo.find(id.Bytes())
+	return repo.find(userKey(id))
+}
+
+func (repo *userRepository) FindByUsername(tenantID tenant.TenantID, username string) (*user.User, error) {
+	return repo.findMember(tenantID, tenantKey("username", tenantID, username))
 }
 
-func (repo *userRepository) FindByUsername(username string) (*user.User, error) {
-	return repo.find([]byte("username:" + username))
+func (repo *userRepository) FindByEmail(tenantID tenant.TenantID, email string) (*user.User, error) {
+	return repo.findMember(tenantID, tenantKey("email", tenantID, email))
 }
 
-func (repo *userRepository) FindBySocialID(socialID user.SocialID) (*user.User, error) {
-	return repo.find([]byte("social:" + socialID))
+func (repo *userRepository) FindBySocialID(tenantID tenant.TenantID, socialID user.SocialID) (*user.User, error) {
+	return repo.findMember(tenantID, tenantKey("social", tenantID, string(socialID)))
+}
+
+// findMember skips the users which have left the tenant since indexed.
+func (repo *userRepository) findMember(tenantID tenant.TenantID, key []byte) (*user.User, error) {
+	u, err := repo.find(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Membership(tenantID) == nil {
+		return nil, user.ErrUserNotFound
+	}
+
+	return u, nil
 }
 
 func (repo *userRepository) find(key []byte) (*user.User, error) {
-	var u *user.User
+	var u *User
 
 	if err := repo.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(key)
-		if err != nil {
-			if errors.Is(err, badger.ErrKeyNotFound) {
-				return user.ErrUserNotFound
+		var err error
+		u, err = get(txn, key)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	if !u.DeletedAt.IsZero() {
+		return nil, user.ErrUserNotFound
+	}
+
+	return u.reconstitute(), nil
+}
+
+func get(txn *badger.Txn, key []byte) (*User, error) {
+	item, err := txn.Get(key)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, user.ErrUserNotFound
+		}
+
+		return nil, err
+	}
+
+	var u *User
+	if err := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &u)
+	}); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// List iterates the users by the prefix, the keys are ordered by the IDs.
+func (repo *userRepository) List(q user.Query) ([]*user.User, error) {
+	users := make([]*user.User, 0)
+
+	err := repo.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = q.Desc
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte("user:")
+
+		seek := prefix
+		switch {
+		case q.After != nil:
+			seek = userKey(*q.After)
+		case q.Desc:
+			seek = append([]byte("user:"), 0xFF)
+		}
+
+		for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+			if q.Limit > 0 && len(users) >= q.Limit {
+				break
 			}
 
-			return err
+			var u *User
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &u)
+			}); err != nil {
+				return err
+			}
+
+			if q.Beyond(u.ID) && q.Match(u.User) {
+				users = append(users, u.reconstitute())
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (repo *userRepository) Purge(before time.Time) ([]user.UserID, error) {
+	ids := make([]user.UserID, 0)
+
+	err := repo.db.Update(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("user:")
+
+		keys := make([][]byte, 0)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var u *User
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &u)
+			}); err != nil {
+				return err
+			}
+
+			if !u.DeletedAt.IsZero() && u.DeletedAt.Before(before) {
+				keys = append(keys, it.Item().KeyCopy(nil))
+				ids = append(ids, u.ID)
+			}
+		}
+
+		// the records left under the legacy keys go along
+		for _, id := range ids {
+			keys = append(keys, id.Bytes())
 		}
 
-		return item.Value(func(val []byte) error {
-			err := json.Unmarshal(val, &u)
-			if err != nil {
+		for _, key := range keys {
+			if err := txn.Delete(key); err != nil {
 				return err
 			}
+		}
 
-			u.EventStore = events.NewEventStore()
-			return nil
-		})
-	}); err != nil {
+		return nil
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
-	return u, nil
+	return ids, nil
+}
+
+func userKey(id user.UserID) []byte {
+	return []byte("user:" + id.String())
+}
+
+// tenantKey returns "<index>:<tenant id>:<value>", the tenant IDs have no
+// colons.
+func tenantKey(index string, tenantID tenant.TenantID, value string) []byte {
+	return []byte(index + ":" + tenantID.String() + ":" + value)
 }
 
 func (repo *userRepository) DB() *badger.DB {
@@ -0,0 +1,66 @@
+package persistence
+
+import (
+	"errors"
+
+	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/persistence/db"
+	"github.com/mirror520/identity/persistence/inmem"
+	"github.com/mirror520/identity/persistence/kv"
+	"github.com/mirror520/identity/token"
+	"github.com/mirror520/identity/user"
+)
+
+// NewTokenRepository shares the database opened by the user repository.
+func NewTokenRepository(cfg conf.Persistence, users user.Repository) (token.Repository, error) {
+	switch cfg.Driver {
+	case conf.SQLite:
+		database, ok := users.(db.Database)
+		if !ok {
+			return nil, errors.New("database not found")
+		}
+
+		return db.NewTokenRepository(database.DB())
+
+	case conf.BadgerDB:
+		database, ok := users.(kv.Database)
+		if !ok {
+			return nil, errors.New("database not found")
+		}
+
+		return kv.NewTokenRepository(database.DB())
+
+	case conf.InMem:
+		return inmem.NewTokenRepository()
+
+	default:
+		return nil, errors.New("driver not supported")
+	}
+}
+
+// NewRevocationRepository shares the database opened by the user repository.
+func NewRevocationRepository(cfg conf.Persistence, users user.Repository) (token.RevocationRepository, error) {
+	switch cfg.Driver {
+	case conf.SQLite:
+		database, ok := users.(db.Database)
+		if !ok {
+			return nil, errors.New("database not found")
+		}
+
+		return db.NewRevocationRepository(database.DB())
+
+	case conf.BadgerDB:
+		database, ok := users.(kv.Database)
+		if !ok {
+			return nil, errors.New("database not found")
+		}
+
+		return kv.NewRevocationRepository(database.DB())
+
+	case conf.InMem:
+		return inmem.NewRevocationRepository()
+
+	default:
+		return nil, errors.New("driver not supported")
+	}
+}
@@ -140,6 +140,739 @@ func (suite *policyTestSuite) TestEvalUpdateUsersWithUserRoleAndNotAdmin() {
 	suite.False(accepted)
 }
 
+func (suite *policyTestSuite) TestEvalLockUsersWithUserRoleAndNotAdmin() {
+	input := map[string]any{
+		"domain":    "identity::users",
+		"action":    "lock",
+		"object":    "mirror520",
+		"who_flags": 0b1000,
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"user"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalGrantRoleWithAdminRoleAndAdmin() {
+	input := map[string]any{
+		"domain":    "identity::users",
+		"action":    "grant_role",
+		"object":    "01HZX3F6Q8V9K2M4N5P6R7S8T9",
+		"who_flags": 0b1000,
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin", "user"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalGrantRoleWithUserRoleAndNotAdmin() {
+	input := map[string]any{
+		"domain":    "identity::users",
+		"action":    "grant_role",
+		"object":    "mirror520",
+		"who_flags": 0b1000,
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"user"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalViewGroupsWithUserRoleAndGroupMember() {
+	input := map[string]any{
+		"domain":    "identity::groups",
+		"action":    "view",
+		"object":    "engineering",
+		"who_flags": 0b1010,
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"user"},
+		},
+		"groups": []map[string]any{
+			{"id": "engineering", "roles": []string{}, "direct": true},
+			{"id": "company", "roles": []string{}, "direct": false},
+		},
+		"object_groups": []string{"engineering"},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalViewGroupsWithUserRoleAndNotGroupMember() {
+	input := map[string]any{
+		"domain":    "identity::groups",
+		"action":    "view",
+		"object":    "sales",
+		"who_flags": 0b1010,
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"user"},
+		},
+		"groups": []map[string]any{
+			{"id": "engineering", "roles": []string{}, "direct": true},
+		},
+		"object_groups": []string{"sales"},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalViewGroupsWithUserRoleAndAncestorMember() {
+	input := map[string]any{
+		"domain":    "identity::groups",
+		"action":    "view",
+		"object":    "engineering",
+		"who_flags": 0b1010,
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"user"},
+		},
+		"groups": []map[string]any{
+			{"id": "platform", "roles": []string{}, "direct": true},
+			{"id": "engineering", "roles": []string{}, "direct": false},
+		},
+		"object_groups": []string{"engineering"},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalViewUsersWithUserRoleAndParentGroupMember() {
+	input := map[string]any{
+		"domain":    "identity::users",
+		"action":    "view",
+		"object":    "01HZX3F6Q8V9K2M4N5P6R7S8T9",
+		"who_flags": 0b1011,
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"user"},
+		},
+		"groups": []map[string]any{
+			{"id": "engineering", "roles": []string{}, "direct": true},
+		},
+		"object_groups": []string{"platform", "engineering"},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalViewUsersWithUserRoleAndSiblingGroupMember() {
+	input := map[string]any{
+		"domain":    "identity::users",
+		"action":    "view",
+		"object":    "01HZX3F6Q8V9K2M4N5P6R7S8T9",
+		"who_flags": 0b1011,
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"user"},
+		},
+		"groups": []map[string]any{
+			{"id": "platform", "roles": []string{}, "direct": true},
+			{"id": "engineering", "roles": []string{}, "direct": false},
+		},
+		"object_groups": []string{"mobile", "engineering"},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalLockUsersWithGroupAdminRoleAndAdmin() {
+	input := map[string]any{
+		"domain":    "identity::users",
+		"action":    "lock",
+		"object":    "01HZX3F6Q8V9K2M4N5P6R7S8T9",
+		"who_flags": 0b1000,
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"user"},
+		},
+		"groups": []map[string]any{
+			{"id": "operators", "roles": []string{"admin"}},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalCreateClientsWithAdminRoleAndAdmin() {
+	input := map[string]any{
+		"domain":    "identity::clients",
+		"action":    "create",
+		"who_flags": 0b1000,
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalRotateClientsWithUserRoleAndNotAdmin() {
+	input := map[string]any{
+		"domain":    "identity::clients",
+		"action":    "rotate",
+		"who_flags": 0b1000,
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"user"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalListUsersWithServiceAndNotService() {
+	input := map[string]any{
+		"domain":    "identity::users",
+		"action":    "list",
+		"who_flags": 0b1000,
+		"claims": map[string]any{
+			"sub":       "worker",
+			"roles":     []string{"admin"},
+			"token_use": "service",
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalListUsersWithServiceAndService() {
+	input := map[string]any{
+		"domain":    "identity::users",
+		"action":    "list",
+		"who_flags": 0b101000,
+		"claims": map[string]any{
+			"sub":       "worker",
+			"roles":     []string{"admin"},
+			"token_use": "service",
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalImpersonateUserWithSupportRole() {
+	input := map[string]any{
+		"domain": "identity::tokens",
+		"action": "impersonate",
+		"object": "mirror",
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"support", "user"},
+		},
+		"subject": map[string]any{
+			"sub":   "mirror",
+			"roles": []string{"user"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalNotImpersonateAdminWithSupportRole() {
+	input := map[string]any{
+		"domain": "identity::tokens",
+		"action": "impersonate",
+		"object": "mirror",
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"support"},
+		},
+		"subject": map[string]any{
+			"sub":   "mirror",
+			"roles": []string{"admin"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalViewUsersWithTenantAdminRoleAndSameTenant() {
+	input := map[string]any{
+		"domain":         "identity::users",
+		"action":         "view",
+		"object":         "mirror",
+		"who_flags":      0b1000,
+		"tenant":         "acme",
+		"object_tenants": []string{"default", "acme"},
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalNotViewUsersWithTenantAdminRoleAndOtherTenant() {
+	input := map[string]any{
+		"domain":         "identity::users",
+		"action":         "view",
+		"object":         "mirror",
+		"who_flags":      0b1000,
+		"tenant":         "acme",
+		"object_tenants": []string{"default"},
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalViewUsersWithOperator() {
+	input := map[string]any{
+		"domain":         "identity::users",
+		"action":         "view",
+		"object":         "mirror",
+		"who_flags":      0b1000,
+		"tenant":         "default",
+		"object_tenants": []string{"acme"},
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalNotListTenantsWithTenantAdminRole() {
+	input := map[string]any{
+		"domain":    "identity::tenants",
+		"action":    "list",
+		"who_flags": 0b1000,
+		"tenant":    "acme",
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalNotGrantRoleWithTenantAdminRole() {
+	input := map[string]any{
+		"domain":         "identity::users",
+		"action":         "grant_role",
+		"object":         "mirror",
+		"who_flags":      0b1000,
+		"tenant":         "acme",
+		"object_tenants": []string{"acme"},
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalNotListUsersWithTenantAdminRole() {
+	input := map[string]any{
+		"domain":    "identity::users",
+		"action":    "list",
+		"who_flags": 0b1000,
+		"tenant":    "acme",
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalListUsersWithTenantAdminRoleAndSameTenant() {
+	input := map[string]any{
+		"domain":         "identity::users",
+		"action":         "list",
+		"who_flags":      0b1000,
+		"tenant":         "acme",
+		"object_tenants": []string{"acme"},
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalNotLockUsersWithTenantAdminRoleAndSharedUser() {
+	input := map[string]any{
+		"domain":         "identity::users",
+		"action":         "lock",
+		"who_flags":      0b1000,
+		"tenant":         "acme",
+		"object":         "01HBGZ5QW2JY6X4K0Q1W9DSJ3T",
+		"object_tenants": []string{"default", "acme"},
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalNotUpdateUsersWithTenantAdminRoleAndSharedUser() {
+	input := map[string]any{
+		"domain":         "identity::users",
+		"action":         "update",
+		"who_flags":      0b1000,
+		"tenant":         "acme",
+		"object":         "01HBGZ5QW2JY6X4K0Q1W9DSJ3T",
+		"object_tenants": []string{"default", "acme"},
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalLockUsersWithTenantAdminRoleAndSameTenant() {
+	input := map[string]any{
+		"domain":         "identity::users",
+		"action":         "lock",
+		"who_flags":      0b1000,
+		"tenant":         "acme",
+		"object":         "01HBGZ5QW2JY6X4K0Q1W9DSJ3T",
+		"object_tenants": []string{"acme"},
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalNotAddMemberWithTenantAdminRole() {
+	input := map[string]any{
+		"domain":         "identity::tenants",
+		"action":         "add_member",
+		"who_flags":      0b1000,
+		"tenant":         "acme",
+		"object":         "acme",
+		"object_tenants": []string{"acme"},
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalAddMemberWithOperator() {
+	input := map[string]any{
+		"domain":         "identity::tenants",
+		"action":         "add_member",
+		"who_flags":      0b1000,
+		"tenant":         "default",
+		"object":         "acme",
+		"object_tenants": []string{"acme"},
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalNotCreateGroupsWithTenantAdminRole() {
+	input := map[string]any{
+		"domain":    "identity::groups",
+		"action":    "create",
+		"who_flags": 0b1000,
+		"tenant":    "acme",
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalNotAddGroupMemberWithTenantAdminRole() {
+	input := map[string]any{
+		"domain":    "identity::groups",
+		"action":    "add_member",
+		"object":    "engineering",
+		"who_flags": 0b1000,
+		"tenant":    "acme",
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+		"object_groups": []string{"engineering"},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalAddGroupMemberWithOperator() {
+	input := map[string]any{
+		"domain":    "identity::groups",
+		"action":    "add_member",
+		"object":    "engineering",
+		"who_flags": 0b1000,
+		"tenant":    "default",
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+		"object_groups": []string{"engineering"},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.True(accepted)
+}
+
+func (suite *policyTestSuite) TestEvalNotViewGroupsWithTenantAdminRoleAndNotGroupMember() {
+	input := map[string]any{
+		"domain":    "identity::groups",
+		"action":    "view",
+		"object":    "engineering",
+		"who_flags": 0b1010,
+		"tenant":    "acme",
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"admin"},
+		},
+		"object_groups": []string{"engineering"},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
+// the admin role granted by a group makes no operator
+func (suite *policyTestSuite) TestEvalNotViewUsersWithGroupAdminRoleAndOtherTenant() {
+	input := map[string]any{
+		"domain":         "identity::users",
+		"action":         "view",
+		"object":         "mirror",
+		"who_flags":      0b1000,
+		"tenant":         "default",
+		"object_tenants": []string{"acme"},
+		"claims": map[string]any{
+			"sub":   "mirror520",
+			"roles": []string{"user"},
+		},
+		"groups": []map[string]any{
+			{"id": "operators", "roles": []string{"admin"}, "direct": true},
+		},
+	}
+
+	accepted, err := suite.policy.Eval(context.TODO(), input)
+	if err != nil {
+		suite.Fail(err.Error())
+		return
+	}
+
+	suite.False(accepted)
+}
+
 func TestPolicyTestSuite(t *testing.T) {
 	suite.Run(t, new(policyTestSuite))
 }
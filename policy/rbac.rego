@@ -7,20 +7,137 @@ import future.keywords.in
 default allow := false
 
 allow if {
+	not is_service
+	not escalates
+	not outside_tenant
 	is_admin
 	is_authorized
 }
 
 allow if {
+	not is_service
+	not escalates
+	not outside_tenant
 	is_owner
 	is_authorized
 }
 
 allow if {
+	not is_service
+	not escalates
+	not outside_tenant
+	is_group_member
+	is_authorized
+}
+
+allow if {
+	not is_service
+	not escalates
+	not outside_tenant
 	is_authorized
 	count(authorized_users) == 0
 }
 
+# the service principals are only allowed where the rule opts in
+allow if {
+	is_service
+	not escalates
+	is_authorized
+	"service" in authorized_users
+}
+
+# nobody acts on behalf of a subject holding a role the actor doesn't hold
+escalates if {
+	input.action == "impersonate"
+	some role in input.subject.roles
+	not role in input.claims.roles
+}
+
+# the users of a tenant only reach the objects of the tenant, the
+# operators reach every tenant
+outside_tenant if {
+	input.object_tenants
+	not tenant in input.object_tenants
+	not is_operator
+}
+
+# the tenants themselves and the clients are shared by every tenant
+outside_tenant if {
+	input.domain == "identity::tenants"
+	not input.object
+	not is_operator
+}
+
+outside_tenant if {
+	input.domain == "identity::clients"
+	not is_operator
+}
+
+# the members of the other tenants are theirs too, only the operators act
+# on a user unless every tenant of the user is the caller's
+outside_tenant if {
+	input.domain == "identity::users"
+	input.action in {"update", "remove", "lock", "unlock", "revoke"}
+	input.object != input.claims.sub
+	some t in input.object_tenants
+	t != tenant
+	not is_operator
+}
+
+# joining brings an existing user of any tenant under the tenant's admins
+outside_tenant if {
+	input.domain == "identity::tenants"
+	input.action == "add_member"
+	not is_operator
+}
+
+# the groups are shared by every tenant and their roles apply in every
+# tenant, only the operators manage them
+outside_tenant if {
+	input.domain == "identity::groups"
+	input.action != "view"
+	not is_operator
+}
+
+# the members view their groups, the admins of a tenant don't view the
+# others
+outside_tenant if {
+	input.domain == "identity::groups"
+	input.action == "view"
+	not is_group_member
+	not is_operator
+}
+
+# the roles of the users apply in every tenant
+outside_tenant if {
+	input.domain == "identity::users"
+	input.action in {"grant_role", "revoke_role"}
+	not is_operator
+}
+
+# the listing spans every tenant unless filtered by one
+outside_tenant if {
+	input.domain == "identity::users"
+	input.action == "list"
+	not input.object_tenants
+	not is_operator
+}
+
+# the admins of the default tenant, by the roles of their own. The roles
+# of the groups never make an operator, the groups are shared by every
+# tenant.
+is_operator if {
+	tenant == data.default_tenant
+	"admin" in input.claims.roles
+}
+
+# the tokens issued before the tenants are of the default tenant
+tenant := object.get(input, "tenant", data.default_tenant)
+
+is_service if {
+	input.claims.token_use == "service"
+}
+
 is_authorized if {
 	some permission in permissions
 	input.domain == permission.domain
@@ -33,7 +150,7 @@ is_admin if {
 	some user in authorized_users
 	user == "admin"
 
-	some role in input.claims.roles
+	some role in roles
 	role == user
 }
 
@@ -43,11 +160,33 @@ is_owner if {
 	input.object == input.claims.sub
 }
 
+# a direct member of a group owning the object. The owners of a user
+# include the ancestors of the user's groups, so a group reaches its
+# subgroups but never its siblings.
+is_group_member if {
+	some user in authorized_users
+	user == "group"
+
+	some group in input.groups
+	group.direct
+	group.id in input.object_groups
+}
+
 permissions contains permission if {
-	some role in input.claims.roles
+	some role in roles
 	some permission in data.role_permissions[role]
 }
 
+roles contains role if {
+	some role in input.claims.roles
+}
+
+# the roles of the groups are granted to their members
+roles contains role if {
+	some group in input.groups
+	some role in group.roles
+}
+
 authorized_users contains who if {
 	some who, flag in data.who_enum
 	bits.and(input.who_flags, flag) > 0
@@ -8,7 +8,14 @@ import (
 
 	"github.com/go-kit/kit/endpoint"
 
+	"github.com/mirror520/identity/client"
+	"github.com/mirror520/identity/group"
+	"github.com/mirror520/identity/oidc"
+	"github.com/mirror520/identity/otp"
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/token"
 	"github.com/mirror520/identity/user"
+	"github.com/mirror520/identity/verifier"
 )
 
 type Instance struct {
@@ -103,21 +110,30 @@ func (mw *proxyingMiddleware) Endpoint(method string) (endpoint.Endpoint, bool)
 	}
 }
 
-func (mw *proxyingMiddleware) Register(username string, name string, email string) (*user.User, error) {
-	return mw.next.Register(username, name, email)
+func (mw *proxyingMiddleware) Register(tenantID tenant.TenantID, username string, name string, email string, password string) (*user.User, error) {
+	return mw.next.Register(tenantID, username, name, email, password)
 }
 
-func (mw *proxyingMiddleware) OTPVerify(otp string, id user.UserID) (*user.User, error) {
-	return mw.next.OTPVerify(otp, id)
+func (mw *proxyingMiddleware) OTPEnroll(code string, id user.UserID) (*otp.Enrollment, error) {
+	return mw.next.OTPEnroll(code, id)
 }
 
-func (mw *proxyingMiddleware) SignIn(credential string, provider user.SocialProvider) (*user.User, error) {
+func (mw *proxyingMiddleware) VerifyUser(ctx context.Context, code string, id user.UserID) (*user.User, error) {
+	return mw.next.VerifyUser(ctx, code, id)
+}
+
+func (mw *proxyingMiddleware) OTPVerify(ctx context.Context, otp string, id user.UserID) (*user.User, error) {
+	return mw.next.OTPVerify(ctx, otp, id)
+}
+
+func (mw *proxyingMiddleware) SignIn(tenantID tenant.TenantID, credential string, provider user.SocialProvider) (*user.User, error) {
 	endpoint, ok := mw.Endpoint("SignIn")
 	if !ok {
-		return mw.next.SignIn(credential, provider)
+		return mw.next.SignIn(tenantID, credential, provider)
 	}
 
 	req := &SignInRequest{
+		TenantID:   tenantID,
 		Credential: credential,
 		Provider:   provider,
 	}
@@ -135,10 +151,214 @@ func (mw *proxyingMiddleware) SignIn(credential string, provider user.SocialProv
 	return u, nil
 }
 
+func (mw *proxyingMiddleware) SignInWithPassword(ctx context.Context, tenantID tenant.TenantID, username string, password string) (*user.User, error) {
+	return mw.next.SignInWithPassword(ctx, tenantID, username, password)
+}
+
+func (mw *proxyingMiddleware) ChangePassword(id user.UserID, authTime time.Time, oldPassword string, newPassword string) (*user.User, error) {
+	return mw.next.ChangePassword(id, authTime, oldPassword, newPassword)
+}
+
 func (mw *proxyingMiddleware) AddSocialAccount(credential string, provider user.SocialProvider, id user.UserID) (*user.User, error) {
 	return mw.next.AddSocialAccount(credential, provider, id)
 }
 
+func (mw *proxyingMiddleware) RemoveSocialAccount(id user.UserID, provider user.SocialProvider, socialID user.SocialID) (*user.User, error) {
+	return mw.next.RemoveSocialAccount(id, provider, socialID)
+}
+
+func (mw *proxyingMiddleware) LockUser(id user.UserID, reason string) (*user.User, error) {
+	return mw.next.LockUser(id, reason)
+}
+
+func (mw *proxyingMiddleware) UnlockUser(id user.UserID) (*user.User, error) {
+	return mw.next.UnlockUser(id)
+}
+
+func (mw *proxyingMiddleware) RevokeUser(id user.UserID, reason string) (*user.User, error) {
+	return mw.next.RevokeUser(id, reason)
+}
+
+func (mw *proxyingMiddleware) GrantRole(id user.UserID, role string) (*user.User, error) {
+	return mw.next.GrantRole(id, role)
+}
+
+func (mw *proxyingMiddleware) RevokeRole(id user.UserID, role string) (*user.User, error) {
+	return mw.next.RevokeRole(id, role)
+}
+
+func (mw *proxyingMiddleware) UpdateProfile(id user.UserID, profile user.Profile) (*user.User, error) {
+	return mw.next.UpdateProfile(id, profile)
+}
+
+func (mw *proxyingMiddleware) ChangeUsername(id user.UserID, username string) (*user.User, error) {
+	return mw.next.ChangeUsername(id, username)
+}
+
+func (mw *proxyingMiddleware) DeleteUser(id user.UserID) error {
+	return mw.next.DeleteUser(id)
+}
+
+func (mw *proxyingMiddleware) PurgeUsers() ([]user.UserID, error) {
+	return mw.next.PurgeUsers()
+}
+
+func (mw *proxyingMiddleware) RequestEmailChange(id user.UserID, email string) (*user.User, error) {
+	return mw.next.RequestEmailChange(id, email)
+}
+
+func (mw *proxyingMiddleware) ConfirmEmailChange(ctx context.Context, code string, id user.UserID) (*user.User, error) {
+	return mw.next.ConfirmEmailChange(ctx, code, id)
+}
+
+func (mw *proxyingMiddleware) ListUsers(q user.Query) (*user.Page, error) {
+	return mw.next.ListUsers(q)
+}
+
+func (mw *proxyingMiddleware) RefreshToken(clientID string, clientSecret string, refreshToken string) (*RefreshGrant, error) {
+	return mw.next.RefreshToken(clientID, clientSecret, refreshToken)
+}
+
+func (mw *proxyingMiddleware) RevokeToken(clientID string, clientSecret string, tokenStr string) error {
+	return mw.next.RevokeToken(clientID, clientSecret, tokenStr)
+}
+
+func (mw *proxyingMiddleware) Introspect(clientID string, clientSecret string, tokenStr string) (*Introspection, error) {
+	return mw.next.Introspect(clientID, clientSecret, tokenStr)
+}
+
+func (mw *proxyingMiddleware) Authorize(claims *verifier.Claims, req *oidc.AuthorizationRequest) (string, error) {
+	return mw.next.Authorize(claims, req)
+}
+
+func (mw *proxyingMiddleware) ExchangeCode(clientID string, clientSecret string, code string, redirectURI string, codeVerifier string) (*oidc.Grant, error) {
+	return mw.next.ExchangeCode(clientID, clientSecret, code, redirectURI, codeVerifier)
+}
+
+func (mw *proxyingMiddleware) ClientCredentials(clientID string, clientSecret string, scope oidc.Scope) (*ServiceGrant, error) {
+	return mw.next.ClientCredentials(clientID, clientSecret, scope)
+}
+
+func (mw *proxyingMiddleware) ExchangeToken(ctx context.Context, clientID string, clientSecret string, req *token.ExchangeRequest) (*token.Exchange, error) {
+	return mw.next.ExchangeToken(ctx, clientID, clientSecret, req)
+}
+
+func (mw *proxyingMiddleware) UserInfo(id user.UserID, scope oidc.Scope) (*oidc.UserInfo, error) {
+	return mw.next.UserInfo(id, scope)
+}
+
+func (mw *proxyingMiddleware) RegisterClient(typ client.Type, settings client.Settings) (*RegisteredClient, error) {
+	return mw.next.RegisterClient(typ, settings)
+}
+
+func (mw *proxyingMiddleware) UpdateClient(id client.ClientID, settings client.Settings) (*client.Client, error) {
+	return mw.next.UpdateClient(id, settings)
+}
+
+func (mw *proxyingMiddleware) RotateClientSecret(id client.ClientID) (*RegisteredClient, error) {
+	return mw.next.RotateClientSecret(id)
+}
+
+func (mw *proxyingMiddleware) DeleteClient(id client.ClientID) error {
+	return mw.next.DeleteClient(id)
+}
+
+func (mw *proxyingMiddleware) FindClient(id client.ClientID) (*client.Client, error) {
+	return mw.next.FindClient(id)
+}
+
+func (mw *proxyingMiddleware) ListClients() ([]*client.Client, error) {
+	return mw.next.ListClients()
+}
+
+func (mw *proxyingMiddleware) CreateGroup(name string, parent group.GroupID) (*group.Group, error) {
+	return mw.next.CreateGroup(name, parent)
+}
+
+func (mw *proxyingMiddleware) UpdateGroup(id group.GroupID, name string, parent group.GroupID) (*group.Group, error) {
+	return mw.next.UpdateGroup(id, name, parent)
+}
+
+func (mw *proxyingMiddleware) DeleteGroup(id group.GroupID) error {
+	return mw.next.DeleteGroup(id)
+}
+
+func (mw *proxyingMiddleware) FindGroup(id group.GroupID) (*group.Group, error) {
+	return mw.next.FindGroup(id)
+}
+
+func (mw *proxyingMiddleware) ListGroups() ([]*group.Group, error) {
+	return mw.next.ListGroups()
+}
+
+func (mw *proxyingMiddleware) AddGroupMember(id group.GroupID, userID user.UserID) (*group.Group, error) {
+	return mw.next.AddGroupMember(id, userID)
+}
+
+func (mw *proxyingMiddleware) RemoveGroupMember(id group.GroupID, userID user.UserID) (*group.Group, error) {
+	return mw.next.RemoveGroupMember(id, userID)
+}
+
+func (mw *proxyingMiddleware) GrantGroupRole(id group.GroupID, role string) (*group.Group, error) {
+	return mw.next.GrantGroupRole(id, role)
+}
+
+func (mw *proxyingMiddleware) RevokeGroupRole(id group.GroupID, role string) (*group.Group, error) {
+	return mw.next.RevokeGroupRole(id, role)
+}
+
+func (mw *proxyingMiddleware) Memberships(id user.UserID) ([]*group.Group, error) {
+	return mw.next.Memberships(id)
+}
+
+func (mw *proxyingMiddleware) CreateTenant(id tenant.TenantID, name string) (*tenant.Tenant, error) {
+	return mw.next.CreateTenant(id, name)
+}
+
+func (mw *proxyingMiddleware) UpdateTenant(id tenant.TenantID, name string) (*tenant.Tenant, error) {
+	return mw.next.UpdateTenant(id, name)
+}
+
+func (mw *proxyingMiddleware) DeleteTenant(id tenant.TenantID) error {
+	return mw.next.DeleteTenant(id)
+}
+
+func (mw *proxyingMiddleware) FindTenant(id tenant.TenantID) (*tenant.Tenant, error) {
+	return mw.next.FindTenant(id)
+}
+
+func (mw *proxyingMiddleware) ListTenants() ([]*tenant.Tenant, error) {
+	return mw.next.ListTenants()
+}
+
+func (mw *proxyingMiddleware) JoinTenant(id tenant.TenantID, userID user.UserID) (*user.User, error) {
+	return mw.next.JoinTenant(id, userID)
+}
+
+func (mw *proxyingMiddleware) LeaveTenant(id tenant.TenantID, userID user.UserID) (*user.User, error) {
+	return mw.next.LeaveTenant(id, userID)
+}
+
+func (mw *proxyingMiddleware) GrantTenantRole(id tenant.TenantID, userID user.UserID, role string) (*user.User, error) {
+	return mw.next.GrantTenantRole(id, userID, role)
+}
+
+func (mw *proxyingMiddleware) RevokeTenantRole(id tenant.TenantID, userID user.UserID, role string) (*user.User, error) {
+	return mw.next.RevokeTenantRole(id, userID, role)
+}
+
+func (mw *proxyingMiddleware) Tenants(id user.UserID) ([]tenant.TenantID, error) {
+	return mw.next.Tenants(id)
+}
+
+func (mw *proxyingMiddleware) CheckStatus(id user.UserID) error {
+	return mw.next.CheckStatus(id)
+}
+
+func (mw *proxyingMiddleware) CheckClient(id client.ClientID) error {
+	return mw.next.CheckClient(id)
+}
+
 func (mw *proxyingMiddleware) CheckHealth(ctx context.Context) error {
 	return mw.next.CheckHealth(ctx)
 }
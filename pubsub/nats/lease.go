@@ -0,0 +1,118 @@
+package nats
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/mirror520/identity/lease"
+)
+
+// LeaseStore grants the leases through a JetStream key-value bucket, the
+// leases expire by the bucket's TTL no matter their holders.
+func (ps *pubSub) LeaseStore(bucket string, ttl time.Duration) (lease.Store, error) {
+	kv, err := ps.js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = ps.js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "identity:leases",
+			TTL:         ttl,
+		})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &leaseStore{kv}, nil
+}
+
+type leaseStore struct {
+	kv nats.KeyValue
+}
+
+type leaseValue struct {
+	Holder string    `json:"holder"`
+	Until  time.Time `json:"until"`
+}
+
+// Acquire creates the key, or updates it at the revision read if the lease
+// is the holder's or expired. Both fail with ErrKeyExists on a concurrent
+// write, the other instance holds it then.
+func (s *leaseStore) Acquire(key string, holder string, until time.Time) error {
+	// the keys of a bucket are limited to the tokens of a subject
+	key = base64.RawURLEncoding.EncodeToString([]byte(key))
+
+	val, err := json.Marshal(&leaseValue{holder, until})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.kv.Create(key, val)
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, nats.ErrKeyExists) {
+		return err
+	}
+
+	entry, err := s.kv.Get(key)
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return lease.ErrHeld // expired in between, the caller tries again
+		}
+
+		return err
+	}
+
+	var held *leaseValue
+	if err := json.Unmarshal(entry.Value(), &held); err != nil {
+		return err
+	}
+
+	if held.Holder != holder && time.Now().Before(held.Until) {
+		return lease.ErrHeld
+	}
+
+	_, err = s.kv.Update(key, val, entry.Revision())
+	if errors.Is(err, nats.ErrKeyExists) {
+		return lease.ErrHeld
+	}
+
+	return err
+}
+
+// Release deletes the key at the revision read if the lease is the
+// holder's, a concurrent write leaves it to the new holder.
+func (s *leaseStore) Release(key string, holder string) error {
+	key = base64.RawURLEncoding.EncodeToString([]byte(key))
+
+	entry, err := s.kv.Get(key)
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	var held *leaseValue
+	if err := json.Unmarshal(entry.Value(), &held); err != nil {
+		return err
+	}
+
+	if held.Holder != holder {
+		return nil
+	}
+
+	err = s.kv.Delete(key, nats.LastRevision(entry.Revision()))
+	if errors.Is(err, nats.ErrKeyExists) {
+		return nil
+	}
+
+	return err
+}
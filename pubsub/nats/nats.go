@@ -6,12 +6,14 @@ import (
 	"errors"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
 	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/lease"
 	"github.com/mirror520/identity/model"
 	"github.com/mirror520/identity/pubsub"
 )
@@ -30,6 +32,7 @@ type NATSPubSub interface {
 	AddStream(name string, raw json.RawMessage) error
 	AddConsumer(name string, stream string, raw json.RawMessage) error
 	PullSubscribe(consumer string, stream string, callback pubsub.MessageHandler) error
+	LeaseStore(bucket string, ttl time.Duration) (lease.Store, error)
 }
 
 func NewPubSub(cfg conf.Instance) (pubsub.PubSub, error) {
@@ -86,6 +89,7 @@ func (ps *pubSub) Subscribe(topic string, callback pubsub.MessageHandler) error
 	sub, err := ps.nc.Subscribe(topic, func(m *nats.Msg) {
 		msg := &pubsub.Message{
 			Topic:    m.Subject,
+			Header:   pubsub.Header(m.Header),
 			Data:     m.Data,
 			Response: m.Respond,
 		}
@@ -184,8 +188,9 @@ func (ps *pubSub) pull(ctx context.Context, sub *nats.Subscription, callback pub
 
 			for _, m := range msgs {
 				msg := &pubsub.Message{
-					Topic: m.Subject,
-					Data:  m.Data,
+					Topic:  m.Subject,
+					Header: pubsub.Header(m.Header),
+					Data:   m.Data,
 				}
 
 				err := callback(context.Background(), msg)
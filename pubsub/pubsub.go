@@ -20,6 +20,19 @@ type MessageResponse func(data []byte) error
 
 type Message struct {
 	Topic    string
+	Header   Header
 	Data     []byte
 	Response MessageResponse
 }
+
+// Header is the multi-valued header of a message, the keys are case
+// sensitive.
+type Header map[string][]string
+
+func (h Header) Get(key string) string {
+	if values := h[key]; len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
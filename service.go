@@ -3,27 +3,111 @@ package identity
 import (
 	"context"
 	"errors"
+	"slices"
 	"strings"
+	"time"
 
-	"google.golang.org/api/idtoken"
+	"github.com/golang-jwt/jwt/v5"
 
+	"github.com/mirror520/identity/client"
 	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/group"
+	"github.com/mirror520/identity/lease"
+	"github.com/mirror520/identity/mail"
+	"github.com/mirror520/identity/model"
+	"github.com/mirror520/identity/oidc"
+	"github.com/mirror520/identity/otp"
+	"github.com/mirror520/identity/password"
+	"github.com/mirror520/identity/policy"
+	"github.com/mirror520/identity/social"
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/throttle"
+	"github.com/mirror520/identity/token"
 	"github.com/mirror520/identity/user"
+	"github.com/mirror520/identity/verifier"
 )
 
 var (
-	ErrProviderNotSupported = errors.New("provider not supported")
-	ErrClientIDNotFound     = errors.New("client id not found")
+	ErrProviderNotSupported = social.ErrProviderNotSupported
+	ErrClientIDNotFound     = social.ErrClientIDNotFound
 	ErrEmailNotFound        = errors.New("email not found")
 	ErrNameNotFound         = errors.New("name not found")
 	ErrPictureNotFound      = errors.New("picture not found")
+	ErrInvalidCredentials   = errors.New("invalid username or password")
+	ErrPasswordEmpty        = errors.New("password empty")
+	ErrReauthRequired       = errors.New("reauthentication required")
+	ErrRefreshDisabled      = errors.New("token refresh disabled")
+	ErrInvalidClient        = errors.New("invalid client")
+	ErrTokenNotIssued       = errors.New("token not issued to the client")
+	ErrUserExists           = errors.New("user exists")
+	ErrEmailExists          = user.ErrEmailExists
+	ErrDelegatedToken       = errors.New("delegated token not accepted")
+)
+
+// the sizes of a page of the users listed
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
 )
 
 type Service interface {
-	Register(username string, name string, email string) (*user.User, error)
-	OTPVerify(otp string, id user.UserID) (*user.User, error)
-	SignIn(credential string, provider user.SocialProvider) (*user.User, error)
+	Register(tenantID tenant.TenantID, username string, name string, email string, password string) (*user.User, error)
+	OTPEnroll(code string, id user.UserID) (*otp.Enrollment, error)
+	VerifyUser(ctx context.Context, code string, id user.UserID) (*user.User, error)
+	OTPVerify(ctx context.Context, otp string, id user.UserID) (*user.User, error)
+	SignIn(tenantID tenant.TenantID, credential string, provider user.SocialProvider) (*user.User, error)
+	SignInWithPassword(ctx context.Context, tenantID tenant.TenantID, username string, password string) (*user.User, error)
+	ChangePassword(id user.UserID, authTime time.Time, oldPassword string, newPassword string) (*user.User, error)
 	AddSocialAccount(credential string, provider user.SocialProvider, id user.UserID) (*user.User, error)
+	RemoveSocialAccount(id user.UserID, provider user.SocialProvider, socialID user.SocialID) (*user.User, error)
+	LockUser(id user.UserID, reason string) (*user.User, error)
+	UnlockUser(id user.UserID) (*user.User, error)
+	RevokeUser(id user.UserID, reason string) (*user.User, error)
+	GrantRole(id user.UserID, role string) (*user.User, error)
+	RevokeRole(id user.UserID, role string) (*user.User, error)
+	UpdateProfile(id user.UserID, profile user.Profile) (*user.User, error)
+	ChangeUsername(id user.UserID, username string) (*user.User, error)
+	DeleteUser(id user.UserID) error
+	PurgeUsers() ([]user.UserID, error)
+	RequestEmailChange(id user.UserID, email string) (*user.User, error)
+	ConfirmEmailChange(ctx context.Context, code string, id user.UserID) (*user.User, error)
+	ListUsers(q user.Query) (*user.Page, error)
+	RefreshToken(clientID string, clientSecret string, refreshToken string) (*RefreshGrant, error)
+	RevokeToken(clientID string, clientSecret string, tokenStr string) error
+	Introspect(clientID string, clientSecret string, tokenStr string) (*Introspection, error)
+	Authorize(claims *verifier.Claims, req *oidc.AuthorizationRequest) (string, error)
+	ExchangeCode(clientID string, clientSecret string, code string, redirectURI string, codeVerifier string) (*oidc.Grant, error)
+	ClientCredentials(clientID string, clientSecret string, scope oidc.Scope) (*ServiceGrant, error)
+	ExchangeToken(ctx context.Context, clientID string, clientSecret string, req *token.ExchangeRequest) (*token.Exchange, error)
+	UserInfo(id user.UserID, scope oidc.Scope) (*oidc.UserInfo, error)
+	RegisterClient(typ client.Type, settings client.Settings) (*RegisteredClient, error)
+	UpdateClient(id client.ClientID, settings client.Settings) (*client.Client, error)
+	RotateClientSecret(id client.ClientID) (*RegisteredClient, error)
+	DeleteClient(id client.ClientID) error
+	FindClient(id client.ClientID) (*client.Client, error)
+	ListClients() ([]*client.Client, error)
+	CreateGroup(name string, parent group.GroupID) (*group.Group, error)
+	UpdateGroup(id group.GroupID, name string, parent group.GroupID) (*group.Group, error)
+	DeleteGroup(id group.GroupID) error
+	FindGroup(id group.GroupID) (*group.Group, error)
+	ListGroups() ([]*group.Group, error)
+	AddGroupMember(id group.GroupID, userID user.UserID) (*group.Group, error)
+	RemoveGroupMember(id group.GroupID, userID user.UserID) (*group.Group, error)
+	GrantGroupRole(id group.GroupID, role string) (*group.Group, error)
+	RevokeGroupRole(id group.GroupID, role string) (*group.Group, error)
+	Memberships(id user.UserID) ([]*group.Group, error)
+	CreateTenant(id tenant.TenantID, name string) (*tenant.Tenant, error)
+	UpdateTenant(id tenant.TenantID, name string) (*tenant.Tenant, error)
+	DeleteTenant(id tenant.TenantID) error
+	FindTenant(id tenant.TenantID) (*tenant.Tenant, error)
+	ListTenants() ([]*tenant.Tenant, error)
+	JoinTenant(id tenant.TenantID, userID user.UserID) (*user.User, error)
+	LeaveTenant(id tenant.TenantID, userID user.UserID) (*user.User, error)
+	GrantTenantRole(id tenant.TenantID, userID user.UserID, role string) (*user.User, error)
+	RevokeTenantRole(id tenant.TenantID, userID user.UserID, role string) (*user.User, error)
+	Tenants(id user.UserID) ([]tenant.TenantID, error)
+	CheckStatus(id user.UserID) error
+	CheckClient(id client.ClientID) error
 	CheckHealth(ctx context.Context) error
 
 	Handler() (EventHandler, error)
@@ -33,21 +117,107 @@ type EventHandler interface {
 	UserRegisteredHandler(e *user.UserRegisteredEvent) error
 	UserActivatedHandler(e *user.UserActivatedEvent) error
 	UserSocialAccountAddedHandler(e *user.UserSocialAccountAddedEvent) error
+	UserOTPEnrolledHandler(e *user.UserOTPEnrolledEvent) error
+	UserOTPVerifiedHandler(e *user.UserOTPVerifiedEvent) error
+	UserOTPConfirmedHandler(e *user.UserOTPConfirmedEvent) error
+	UserVerificationCodeIssuedHandler(e *user.UserVerificationCodeIssuedEvent) error
+	UserVerificationCodeFailedHandler(e *user.UserVerificationCodeFailedEvent) error
+	UserVerificationCodeVerifiedHandler(e *user.UserVerificationCodeVerifiedEvent) error
+	UserPasswordChangedHandler(e *user.UserPasswordChangedEvent) error
+	UserLockedHandler(e *user.UserLockedEvent) error
+	UserUnlockedHandler(e *user.UserUnlockedEvent) error
+	UserRevokedHandler(e *user.UserRevokedEvent) error
+	UserRoleGrantedHandler(e *user.UserRoleGrantedEvent) error
+	UserRoleRevokedHandler(e *user.UserRoleRevokedEvent) error
+	UserTenantJoinedHandler(e *user.UserTenantJoinedEvent) error
+	UserTenantLeftHandler(e *user.UserTenantLeftEvent) error
+	UserTenantRoleGrantedHandler(e *user.UserTenantRoleGrantedEvent) error
+	UserTenantRoleRevokedHandler(e *user.UserTenantRoleRevokedEvent) error
+	UserProfileUpdatedHandler(e *user.UserProfileUpdatedEvent) error
+	UserEmailChangeRequestedHandler(e *user.UserEmailChangeRequestedEvent) error
+	UserEmailChangedHandler(e *user.UserEmailChangedEvent) error
+	UserUsernameChangedHandler(e *user.UserUsernameChangedEvent) error
+	UserDeletedHandler(e *user.UserDeletedEvent) error
+	UserSocialAccountRemovedHandler(e *user.UserSocialAccountRemovedEvent) error
+	FamilyIssuedHandler(e *token.FamilyIssuedEvent) error
+	FamilyRotatedHandler(e *token.FamilyRotatedEvent) error
+	FamilyRevokedHandler(e *token.FamilyRevokedEvent) error
+	TokenDeniedHandler(e *token.TokenDeniedEvent) error
+	TokenExchangedHandler(e *token.TokenExchangedEvent) error
+	ClientRegisteredHandler(e *client.ClientRegisteredEvent) error
+	ClientUpdatedHandler(e *client.ClientUpdatedEvent) error
+	ClientSecretRotatedHandler(e *client.ClientSecretRotatedEvent) error
+	ClientDeletedHandler(e *client.ClientDeletedEvent) error
+	GroupCreatedHandler(e *group.GroupCreatedEvent) error
+	GroupUpdatedHandler(e *group.GroupUpdatedEvent) error
+	GroupMemberAddedHandler(e *group.GroupMemberAddedEvent) error
+	GroupMemberRemovedHandler(e *group.GroupMemberRemovedEvent) error
+	GroupRoleGrantedHandler(e *group.GroupRoleGrantedEvent) error
+	GroupRoleRevokedHandler(e *group.GroupRoleRevokedEvent) error
+	GroupDeletedHandler(e *group.GroupDeletedEvent) error
+	TenantCreatedHandler(e *tenant.TenantCreatedEvent) error
+	TenantUpdatedHandler(e *tenant.TenantUpdatedEvent) error
+	TenantDeletedHandler(e *tenant.TenantDeletedEvent) error
 }
 
 type ServiceMiddleware func(Service) Service
 
 type service struct {
-	users     user.Repository
-	clientIDs map[user.SocialProvider]string
+	users      user.Repository
+	tokens     token.Repository
+	clients    client.Repository
+	groups     group.Repository
+	tenants    tenant.Repository
+	denylist   *token.Denylist
+	verifier   *verifier.Verifier
+	policy     policy.Policy
+	authCodes  *oidc.Codes
+	mailer     mail.Mailer
+	passwords  *password.Hasher
+	maxAuthAge time.Duration // of the sign-in setting the first password
+	attempts   *throttle.Tracker
+	leases     lease.Store // shared by the instances
+	instance   string      // the holder of the leases
+	totp       *otp.TOTP
+	hasher     *otp.CodeHasher
+	codes      conf.EmailOTP
+	templates  conf.MailTemplates
+	usernames  conf.Usernames
+	retention  time.Duration // of the deleted users
+	refresh    conf.Refresh
+	timeout    time.Duration // lifetime of the access tokens
+	providers  *social.Registry
+
+	overwriteProfile bool // the profiles of the providers overwrite the edits
 }
 
-func NewService(users user.Repository, cfg conf.Providers) Service {
+func NewService(users user.Repository, tokens token.Repository, clients client.Repository, groups group.Repository, tenants tenant.Repository, denylist *token.Denylist, v *verifier.Verifier, p policy.Policy, codes *oidc.Codes, mailer mail.Mailer, providers *social.Registry, attempts *throttle.Tracker, leases lease.Store, cfg *conf.Config) Service {
 	svc := new(service)
 	svc.users = users
-	svc.clientIDs = map[user.SocialProvider]string{
-		user.GOOGLE: cfg.Google.Client.ID,
-	}
+	svc.tokens = tokens
+	svc.clients = clients
+	svc.groups = groups
+	svc.tenants = tenants
+	svc.denylist = denylist
+	svc.verifier = v
+	svc.policy = p
+	svc.authCodes = codes
+	svc.mailer = mailer
+	svc.providers = providers
+	svc.attempts = attempts
+	svc.leases = leases
+	svc.instance = cfg.Name
+	svc.refresh = cfg.JWT.Refresh
+	svc.timeout = cfg.JWT.Timeout
+	svc.passwords = password.NewHasher(cfg.Password.Argon2)
+	svc.maxAuthAge = cfg.Password.MaxAuthAge
+	svc.totp = otp.NewTOTP(cfg.OTP)
+	svc.hasher = otp.NewCodeHasher(cfg.OTP.Key)
+	svc.codes = cfg.OTP.Email
+	svc.templates = cfg.Mail.Templates
+	svc.usernames = cfg.Usernames
+	svc.retention = cfg.Deletion.Retention
+	svc.overwriteProfile = cfg.Providers.OverwriteProfile
 	return svc
 }
 
@@ -55,135 +225,2195 @@ func (svc *service) Handler() (EventHandler, error) {
 	return svc, nil
 }
 
-func (svc *service) Register(username string, name string, email string) (*user.User, error) {
-	_, err := svc.users.FindByUsername(username)
-	if err == nil {
-		return nil, errors.New("user exists")
+// Register registers the user to the tenant, the default tenant if none.
+func (svc *service) Register(tenantID tenant.TenantID, username string, name string, email string, password string) (*user.User, error) {
+	tenantID, err := svc.resolveTenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	u := user.NewUser(tenantID, username, name, email)
+
+	if err := svc.checkUnique(tenantID, u); err != nil {
+		return nil, err
+	}
+
+	if password != "" {
+		hash, err := svc.passwords.Hash(password)
+		if err != nil {
+			return nil, err
+		}
+
+		u.ChangePassword(hash)
 	}
 
-	u := user.NewUser(username, name, email)
+	// the code is sent once the registration is handled
 	defer u.Notify()
 
 	return u, nil
 }
 
-func (svc *service) OTPVerify(otp string, id user.UserID) (*user.User, error) {
+// resolveTenant returns the tenant to sign in to, the default tenant is
+// never stored and always exists.
+func (svc *service) resolveTenant(id tenant.TenantID) (tenant.TenantID, error) {
+	if id == "" || id == tenant.Default {
+		return tenant.Default, nil
+	}
+
+	if _, err := tenant.ParseID(id.String()); err != nil {
+		return "", err
+	}
+
+	if _, err := svc.tenants.Find(id); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// checkUnique tells whether the username or the email of the user is taken
+// by another member of the tenant.
+func (svc *service) checkUnique(tenantID tenant.TenantID, u *user.User) error {
+	if err := svc.checkUsername(tenantID, u.ID, u.Username); err != nil {
+		return err
+	}
+
+	return svc.checkEmail(tenantID, u.ID, u.Email)
+}
+
+// checkUsername tells whether the username is taken by another member of
+// the tenant, and reserves it against the other instances until the change
+// is applied by them. The lease is taken by a compare-and-set shared by the
+// instances, the local reservation holds the usernames in the cool-down.
+func (svc *service) checkUsername(tenantID tenant.TenantID, id user.UserID, username string) error {
+	other, err := svc.users.FindByUsername(tenantID, username)
+	switch {
+	case err == nil && other.ID != id:
+		return ErrUserExists
+	case err != nil && !errors.Is(err, user.ErrUserNotFound):
+		return err
+	}
+
+	until := time.Now().Add(svc.usernames.Reservation)
+	if err := svc.users.ReserveUsername(tenantID, username, id, until); err != nil {
+		return err
+	}
+
+	key := usernameKey(tenantID, username)
+	if err := svc.leases.Acquire(key, id.String(), until); err != nil {
+		if errors.Is(err, lease.ErrHeld) {
+			return user.ErrUsernameReserved
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func usernameKey(tenantID tenant.TenantID, username string) string {
+	return "username:" + tenantID.String() + ":" + username
+}
+
+// checkEmail tells whether the email is taken by another member of the
+// tenant.
+func (svc *service) checkEmail(tenantID tenant.TenantID, id user.UserID, email string) error {
+	if email == "" {
+		return nil
+	}
+
+	other, err := svc.users.FindByEmail(tenantID, email)
+	switch {
+	case err == nil && other.ID != id:
+		return ErrEmailExists
+	case err != nil && !errors.Is(err, user.ErrUserNotFound):
+		return err
+	}
+
+	return nil
+}
+
+func (svc *service) sendVerificationCode(u *user.User) error {
+	code, err := otp.GenerateCode(svc.codes.Length)
+	if err != nil {
+		return err
+	}
+
+	hash := svc.hasher.Hash(u.ID.String(), code)
+	u.IssueVerificationCode(hash, svc.codes.TTL, svc.codes.MaxAttempts)
+
+	data := map[string]any{
+		"Username": u.Username,
+		"Name":     u.Name,
+		"Code":     code,
+		"TTL":      svc.codes.TTL,
+	}
+
+	msg, err := mail.NewMessage(svc.templates.Verification, data, u.Email)
+	if err != nil {
+		return err
+	}
+
+	return svc.mailer.Send(msg)
+}
+
+// OTPEnroll holds a new secret pending until its first code. The user with
+// a secret in use proves it by a current code first, the failures count
+// toward the lockout as the ones of OTPVerify.
+func (svc *service) OTPEnroll(code string, id user.UserID) (*otp.Enrollment, error) {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer u.Notify()
+
+	if u.OTP != nil {
+		if err := svc.checkAttempts(u); err != nil {
+			return nil, err
+		}
+
+		step, err := svc.validateOTP(u, u.OTP, code)
+		if err == nil {
+			err = u.VerifyOTP(step)
+		}
+
+		if err != nil {
+			if err := svc.failAttempt(u); err != nil {
+				return nil, err
+			}
+
+			return nil, err
+		}
+
+		svc.attempts.Reset(throttle.UserKey(u.ID))
+	}
+
+	secret, err := otp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := svc.totp.Seal(secret, u.ID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	enrollment, err := svc.totp.Enroll(secret, u.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	u.EnrollOTP(sealed)
+
+	return enrollment, nil
+}
+
+// VerifyUser activates the user registered with the code sent by email,
+// the request is unauthenticated so the failures back off the client
+// but never lock the user.
+func (svc *service) VerifyUser(ctx context.Context, code string, id user.UserID) (*user.User, error) {
+	ip := clientIP(ctx)
+	if err := svc.checkBackoff(ip, nil); err != nil {
+		return nil, err
+	}
+
 	u, err := svc.users.Find(id)
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO: otp verify
+	if u.Status != user.Registered {
+		return nil, user.ErrUserNotRegistered
+	}
+
+	if err := svc.checkBackoff(ip, u); err != nil {
+		return nil, err
+	}
+
+	defer u.Notify()
+
+	if u.Verification == nil {
+		return nil, user.ErrVerificationCodeNotFound
+	}
+
+	hash := svc.hasher.Hash(u.ID.String(), code)
+	if err := u.VerifyCode(hash); err != nil {
+		if err := svc.failBackoff(ip, u); err != nil {
+			return nil, err
+		}
+
+		return nil, err
+	}
+
+	svc.resetBackoff(ip, u)
+
 	u.Activate()
+	return u, nil
+}
+
+// OTPVerify steps up the user signed in, the failures count toward the
+// lockout of the user.
+func (svc *service) OTPVerify(ctx context.Context, code string, id user.UserID) (*user.User, error) {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.checkAttempts(u); err != nil {
+		return nil, err
+	}
+
 	defer u.Notify()
 
+	if err := svc.verifyCode(u, code); err != nil {
+		if err := svc.failAttempt(u); err != nil {
+			return nil, err
+		}
+
+		return nil, err
+	}
+
+	svc.attempts.Reset(throttle.UserKey(u.ID))
+
+	if u.Status == user.Registered {
+		u.Activate()
+	}
+
 	return u, nil
 }
 
-func (svc *service) SignIn(credential string, provider user.SocialProvider) (*user.User, error) {
-	switch provider {
-	case user.GOOGLE:
-		return svc.signInWithGoogle(credential)
+// verifyCode accepts either a TOTP code or the code sent by email, the
+// first code of a pending secret puts it in use.
+func (svc *service) verifyCode(u *user.User, code string) error {
+	if u.PendingOTP != nil {
+		step, err := svc.validateOTP(u, u.PendingOTP, code)
+		if err == nil {
+			return u.ConfirmOTP(step)
+		}
+
+		if u.OTP == nil && u.Verification == nil {
+			return err
+		}
+	}
+
+	if u.OTP != nil {
+		step, err := svc.validateOTP(u, u.OTP, code)
+		if err == nil {
+			return u.VerifyOTP(step)
+		}
+
+		if u.Verification == nil {
+			return err
+		}
+	}
+
+	if u.Verification != nil {
+		hash := svc.hasher.Hash(u.ID.String(), code)
+		return u.VerifyCode(hash)
+	}
+
+	return user.ErrOTPNotEnrolled
+}
+
+// validateOTP opens the sealed secret of the user to validate the code.
+func (svc *service) validateOTP(u *user.User, o *user.OTP, code string) (uint64, error) {
+	secret, err := svc.totp.Open(o.Secret, u.ID.String())
+	if err != nil {
+		return 0, err
 	}
 
-	return nil, ErrProviderNotSupported
+	return svc.totp.Validate(secret, code, time.Now())
 }
 
-func (svc *service) signInWithGoogle(token string) (*user.User, error) {
-	clientID, ok := svc.clientIDs[user.GOOGLE]
-	if !ok {
-		return nil, ErrClientIDNotFound
+func (svc *service) SignIn(tenantID tenant.TenantID, credential string, provider user.SocialProvider) (*user.User, error) {
+	tenantID, err := svc.resolveTenant(tenantID)
+	if err != nil {
+		return nil, err
 	}
 
-	payload, err := idtoken.Validate(context.Background(), token, clientID)
+	identity, err := svc.providers.Verify(context.Background(), provider, credential)
 	if err != nil {
 		return nil, err
 	}
 
-	socialID := user.SocialID(payload.Subject)
-	u, err := svc.users.FindBySocialID(socialID)
+	socialID := identity.SocialID
+	u, err := svc.users.FindBySocialID(tenantID, socialID)
 	if err != nil {
 		if !errors.Is(err, user.ErrUserNotFound) {
 			return nil, err
 		}
 
 		// New User
-		email, ok := payload.Claims["email"].(string)
-		if !ok {
+		if identity.Email == "" {
 			return nil, ErrEmailNotFound
 		}
 
-		name, ok := payload.Claims["name"].(string)
-		if !ok {
+		if identity.Name == "" {
 			return nil, ErrNameNotFound
 		}
 
-		username := strings.Split(email, "@")[0]
+		username := strings.Split(identity.Email, "@")[0]
+
+		u = user.NewUser(tenantID, username, identity.Name, identity.Email)
+
+		if err := svc.checkUnique(tenantID, u); err != nil {
+			return nil, err
+		}
+		u.AddSocialAccount(provider, socialID)
+		u.Activate() // verified by the provider
+	}
+
+	if err := u.CheckStatus(); err != nil {
+		return nil, err
+	}
+
+	if !u.ProfileEdited || svc.overwriteProfile {
+		u.SyncProfile(provider, user.Profile{
+			Name:   &identity.Name,
+			Avatar: &identity.Picture,
+		})
+	}
 
-		u = user.NewUser(username, name, email)
-		u.AddSocialAccount(user.GOOGLE, socialID)
+	// the registration carries the user, it's published before the user
+	// holds the refresh token
+	u.Notify()
 
-		defer u.Notify()
+	u.Token.TenantID = tenantID
+	g := token.Grant{
+		AuthTime: time.Now(),
+		AMR:      []string{"fed"},
 	}
 
-	picture, ok := payload.Claims["picture"].(string)
-	if ok {
-		u.Avatar = picture
+	if err := svc.issueRefreshToken(u, g, 0); err != nil {
+		return nil, err
 	}
 
 	return u, nil
 }
 
-func (svc *service) AddSocialAccount(credential string, provider user.SocialProvider, id user.UserID) (*user.User, error) {
-	u, err := svc.users.Find(id)
+func (svc *service) SignInWithPassword(ctx context.Context, tenantID tenant.TenantID, username string, password string) (*user.User, error) {
+	ip := clientIP(ctx)
+	if err := svc.checkBackoff(ip, nil); err != nil {
+		return nil, err
+	}
+
+	tenantID, err := svc.resolveTenant(tenantID)
 	if err != nil {
 		return nil, err
 	}
 
-	clientID, ok := svc.clientIDs[user.GOOGLE]
-	if !ok {
-		return nil, ErrClientIDNotFound
+	// the username may be the email, the email pending is not taken until
+	// it is confirmed
+	var u *user.User
+	if strings.Contains(username, "@") {
+		u, err = svc.users.FindByEmail(tenantID, username)
+	} else {
+		u, err = svc.users.FindByUsername(tenantID, username)
 	}
 
-	payload, err := idtoken.Validate(context.Background(), credential, clientID)
 	if err != nil {
-		return nil, err
+		if !errors.Is(err, user.ErrUserNotFound) {
+			return nil, err
+		}
+
+		svc.passwords.Mimic(password)
+		if err := svc.failBackoff(ip, nil); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrInvalidCredentials
 	}
 
-	socialID := user.SocialID(payload.Subject)
-	_, err = svc.users.FindBySocialID(socialID)
-	if err == nil {
-		return nil, errors.New("account exists")
+	if err := svc.checkBackoff(ip, u); err != nil {
+		return nil, err
 	}
 
-	u.AddSocialAccount(provider, socialID)
 	defer u.Notify()
 
+	if u.Password == nil {
+		svc.passwords.Mimic(password)
+		if err := svc.failBackoff(ip, u); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrInvalidCredentials
+	}
+
+	ok, err := svc.passwords.Verify(password, u.Password.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		if err := svc.failBackoff(ip, u); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := u.CheckStatus(); err != nil {
+		return nil, err
+	}
+
+	svc.resetBackoff(ip, u)
+
+	// upgrade the hash with the current parameters
+	if svc.passwords.NeedsRehash(u.Password.Hash) {
+		hash, err := svc.passwords.Hash(password)
+		if err != nil {
+			return nil, err
+		}
+
+		u.ChangePassword(hash)
+	}
+
+	u.Token.TenantID = tenantID
+	g := token.Grant{
+		AuthTime: time.Now(),
+		AMR:      []string{"pwd"},
+	}
+
+	if err := svc.issueRefreshToken(u, g, 0); err != nil {
+		return nil, err
+	}
+
 	return u, nil
 }
 
-func (svc *service) CheckHealth(ctx context.Context) error {
+// issueRefreshToken starts a token family renewing the grant of the user
+// signed in to the tenant of the token, the lifetime of the client
+// overrides the configured one.
+func (svc *service) issueRefreshToken(u *user.User, g token.Grant, ttl time.Duration) error {
+	if !svc.refresh.Enabled {
+		return nil
+	}
+
+	if ttl <= 0 {
+		ttl = svc.refresh.Maximum
+	}
+
+	f, refreshToken, err := token.Issue(u.ID, u.Token.TenantID, g, ttl)
+	if err != nil {
+		return err
+	}
+	defer f.Notify()
+
+	u.Token.RefreshToken = refreshToken
 	return nil
 }
 
-func (svc *service) UserRegisteredHandler(e *user.UserRegisteredEvent) error {
-	return svc.users.Store(e.User)
+func clientIP(ctx context.Context) string {
+	if info, ok := ctx.Value(model.REQUEST_INFO).(*RequestInfo); ok {
+		return info.ClientIP
+	}
+
+	return ""
 }
 
-func (svc *service) UserActivatedHandler(e *user.UserActivatedEvent) error {
-	u, err := svc.users.Find(e.UserID)
-	if err != nil {
+// checkBackoff rejects the client IP, or the user from any or the client
+// IP, still backing off.
+func (svc *service) checkBackoff(ip string, u *user.User) error {
+	now := time.Now()
+
+	if ip != "" {
+		if err := svc.attempts.Check(throttle.IPKey(ip), now); err != nil {
+			return err
+		}
+	}
+
+	if u != nil {
+		if err := svc.attempts.Check(throttle.SignInKey(u.ID), now); err != nil {
+			return err
+		}
+
+		if err := svc.attempts.Check(throttle.IPUserKey(ip, u.ID), now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// failBackoff records the failure of the client IP, of the user from any
+// client IP, and of the user from the client IP. Anyone may fail the
+// unauthenticated requests, so they back off but never lock the user.
+func (svc *service) failBackoff(ip string, u *user.User) error {
+	now := time.Now()
+
+	if ip != "" {
+		if _, err := svc.attempts.Fail(throttle.IPKey(ip), now); err != nil {
+			return err
+		}
+	}
+
+	if u == nil {
+		return nil
+	}
+
+	if _, err := svc.attempts.Fail(throttle.SignInKey(u.ID), now); err != nil {
 		return err
 	}
 
-	u.Status = e.Status
-	u.UpdatedAt = e.OccuredAt
+	_, err := svc.attempts.Fail(throttle.IPUserKey(ip, u.ID), now)
+	return err
+}
 
-	return svc.users.Store(u)
+// resetBackoff clears the failures of the user succeeding from the client
+// IP.
+func (svc *service) resetBackoff(ip string, u *user.User) {
+	svc.attempts.Reset(throttle.SignInKey(u.ID))
+	svc.attempts.Reset(throttle.IPUserKey(ip, u.ID))
 }
 
-func (svc *service) UserSocialAccountAddedHandler(e *user.UserSocialAccountAddedEvent) error {
-	u, err := svc.users.Find(e.UserID)
+// checkAttempts rejects the user still backing off.
+func (svc *service) checkAttempts(u *user.User) error {
+	return svc.attempts.Check(throttle.UserKey(u.ID), time.Now())
+}
+
+// failAttempt records the failure of the user signed in, and locks the
+// user once the failures reach the limit.
+func (svc *service) failAttempt(u *user.User) error {
+	n, err := svc.attempts.Fail(throttle.UserKey(u.ID), time.Now())
+	if err != nil {
+		return err
+	}
+
+	if !svc.attempts.Exceeded(n) {
+		return nil
+	}
+
+	if err := u.Lock("too many failed attempts"); err != nil {
+		return nil // locked or revoked already
+	}
+
+	return user.ErrUserLocked
+}
+
+// ChangePassword proves the user by the old password, or by a recent
+// sign-in for the first password.
+func (svc *service) ChangePassword(id user.UserID, authTime time.Time, oldPassword string, newPassword string) (*user.User, error) {
+	if newPassword == "" {
+		return nil, ErrPasswordEmpty
+	}
+
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Password == nil && time.Since(authTime) > svc.maxAuthAge {
+		return nil, ErrReauthRequired
+	}
+
+	if u.Password != nil {
+		ok, err := svc.passwords.Verify(oldPassword, u.Password.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return nil, ErrInvalidCredentials
+		}
+	}
+
+	hash, err := svc.passwords.Hash(newPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	u.ChangePassword(hash)
+	defer u.Notify()
+
+	return u, nil
+}
+
+func (svc *service) AddSocialAccount(credential string, provider user.SocialProvider, id user.UserID) (*user.User, error) {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := svc.providers.Verify(context.Background(), provider, credential)
+	if err != nil {
+		return nil, err
+	}
+
+	socialID := identity.SocialID
+	for _, m := range u.Tenants {
+		_, err = svc.users.FindBySocialID(m.TenantID, socialID)
+		if err == nil {
+			return nil, errors.New("account exists")
+		}
+	}
+
+	u.AddSocialAccount(provider, socialID)
+	defer u.Notify()
+
+	return u, nil
+}
+
+func (svc *service) RemoveSocialAccount(id user.UserID, provider user.SocialProvider, socialID user.SocialID) (*user.User, error) {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.RemoveSocialAccount(provider, socialID); err != nil {
+		return nil, err
+	}
+	defer u.Notify()
+
+	return u, nil
+}
+
+func (svc *service) LockUser(id user.UserID, reason string) (*user.User, error) {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.Lock(reason); err != nil {
+		return nil, err
+	}
+	defer u.Notify()
+
+	return u, nil
+}
+
+func (svc *service) UnlockUser(id user.UserID) (*user.User, error) {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.Unlock(); err != nil {
+		return nil, err
+	}
+	defer u.Notify()
+
+	svc.attempts.Reset(throttle.UserKey(u.ID))
+
+	return u, nil
+}
+
+func (svc *service) RevokeUser(id user.UserID, reason string) (*user.User, error) {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.Revoke(reason); err != nil {
+		return nil, err
+	}
+	defer u.Notify()
+
+	return u, nil
+}
+
+// GrantRole takes effect on the tokens issued afterwards, the issued ones
+// keep their roles until they expire.
+func (svc *service) GrantRole(id user.UserID, role string) (*user.User, error) {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.GrantRole(role); err != nil {
+		return nil, err
+	}
+	defer u.Notify()
+
+	return u, nil
+}
+
+func (svc *service) RevokeRole(id user.UserID, role string) (*user.User, error) {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.RevokeRole(role); err != nil {
+		return nil, err
+	}
+	defer u.Notify()
+
+	return u, nil
+}
+
+func (svc *service) UpdateProfile(id user.UserID, profile user.Profile) (*user.User, error) {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.UpdateProfile(profile); err != nil {
+		return nil, err
+	}
+	defer u.Notify()
+
+	return u, nil
+}
+
+// ChangeUsername renames the user in every tenant joined, the leases taken
+// are released when the change fails.
+func (svc *service) ChangeUsername(id user.UserID, username string) (u *user.User, err error) {
+	u, err = svc.users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range u.Tenants {
+		if err = svc.checkUsername(m.TenantID, u.ID, username); err != nil {
+			return nil, err
+		}
+
+		key := usernameKey(m.TenantID, username)
+		defer func() {
+			if err != nil {
+				svc.leases.Release(key, id.String())
+			}
+		}()
+	}
+
+	if err = u.ChangeUsername(username); err != nil {
+		return nil, err
+	}
+	defer u.Notify()
+
+	return u, nil
+}
+
+// DeleteUser deletes the user softly, the user is found no more and purged
+// after the retention.
+func (svc *service) DeleteUser(id user.UserID) error {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return err
+	}
+
+	if err := u.Delete(); err != nil {
+		return err
+	}
+	defer u.Notify()
+
+	return nil
+}
+
+// PurgeUsers removes the users deleted before the retention for good,
+// along with their token families and their memberships of the groups.
+// Every instance purges its own stores.
+func (svc *service) PurgeUsers() ([]user.UserID, error) {
+	before := time.Now().Add(-svc.retention)
+
+	ids, err := svc.users.Purge(before)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return ids, nil
+	}
+
+	errs := make([]error, 0)
+	if err := svc.tokens.Purge(ids); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, id := range ids {
+		if err := svc.purgeMember(id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return ids, errors.Join(errs...)
+}
+
+// purgeMember removes the purged user from the groups, the groups are
+// stored as the handlers of the events do.
+func (svc *service) purgeMember(id user.UserID) error {
+	groups, err := svc.groups.FindByMember(id)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		g.Members = slices.DeleteFunc(slices.Clone(g.Members), func(member user.UserID) bool {
+			return member == id
+		})
+		g.UpdatedAt = time.Now()
+
+		if err := svc.groups.Store(g); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RequestEmailChange sends a code to the new email and a notice to the old
+// one, the email is changed only after the code is confirmed.
+func (svc *service) RequestEmailChange(id user.UserID, email string) (*user.User, error) {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range u.Tenants {
+		if err := svc.checkEmail(m.TenantID, u.ID, email); err != nil {
+			return nil, err
+		}
+	}
+
+	code, err := otp.GenerateCode(svc.codes.Length)
+	if err != nil {
+		return nil, err
+	}
+
+	// the code is bound to the new email
+	hash := svc.hasher.Hash(u.ID.String()+":"+email, code)
+	if err := u.RequestEmailChange(email, hash, svc.codes.TTL); err != nil {
+		return nil, err
+	}
+
+	data := map[string]any{
+		"Username": u.Username,
+		"Name":     u.Name,
+		"Email":    email,
+		"Code":     code,
+		"TTL":      svc.codes.TTL,
+	}
+
+	msg, err := mail.NewMessage(svc.templates.EmailChange, data, email)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.mailer.Send(msg); err != nil {
+		return nil, err
+	}
+
+	if u.Email != "" {
+		delete(data, "Code")
+
+		msg, err := mail.NewMessage(svc.templates.EmailChangeNotice, data, u.Email)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := svc.mailer.Send(msg); err != nil {
+			return nil, err
+		}
+	}
+
+	defer u.Notify()
+
+	return u, nil
+}
+
+// ConfirmEmailChange changes the email with the code sent to it, the
+// failures count toward the lockout as the ones of OTPVerify.
+func (svc *service) ConfirmEmailChange(ctx context.Context, code string, id user.UserID) (*user.User, error) {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.checkAttempts(u); err != nil {
+		return nil, err
+	}
+
+	if u.EmailChange == nil {
+		return nil, user.ErrEmailChangeNotFound
+	}
+
+	// the email may be taken since requested
+	email := u.EmailChange.Email
+	for _, m := range u.Tenants {
+		if err := svc.checkEmail(m.TenantID, u.ID, email); err != nil {
+			return nil, err
+		}
+	}
+
+	defer u.Notify()
+
+	hash := svc.hasher.Hash(u.ID.String()+":"+email, code)
+	if err := u.ConfirmEmailChange(hash); err != nil {
+		if errors.Is(err, user.ErrVerificationCodeInvalid) {
+			if err := svc.failAttempt(u); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, err
+	}
+
+	svc.attempts.Reset(throttle.UserKey(u.ID))
+
+	return u, nil
+}
+
+// ListUsers pages the users of the query, one more user is queried to
+// tell whether the page is the last one.
+func (svc *service) ListUsers(q user.Query) (*user.Page, error) {
+	switch {
+	case q.Limit <= 0:
+		q.Limit = DefaultPageSize
+	case q.Limit > MaxPageSize:
+		q.Limit = MaxPageSize
+	}
+
+	limit := q.Limit
+	q.Limit++
+
+	users, err := svc.users.List(q)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &user.Page{
+		Users: users,
+	}
+
+	if len(users) > limit {
+		page.Users = users[:limit]
+		page.Next = users[limit-1].ID.String()
+	}
+
+	return page, nil
+}
+
+// RefreshGrant is the grant renewed by a refresh token, the client is nil
+// for the first party.
+type RefreshGrant struct {
+	User   *user.User
+	Grant  token.Grant
+	Client *client.Client
+}
+
+// RefreshToken renews the grant of the family, the client the family is
+// issued to authenticates before the token is rotated.
+func (svc *service) RefreshToken(clientID string, clientSecret string, refreshToken string) (*RefreshGrant, error) {
+	if !svc.refresh.Enabled {
+		return nil, ErrRefreshDisabled
+	}
+
+	id, err := token.Parse(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := svc.tokens.Find(id)
+	if err != nil {
+		if errors.Is(err, token.ErrFamilyNotFound) {
+			return nil, token.ErrInvalidToken
+		}
+
+		return nil, err
+	}
+
+	c, err := svc.refreshClient(f, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := svc.rotate(f, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Notify()
+
+	u, err := svc.users.Find(f.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.CheckStatus(); err != nil {
+		f.Revoke(err.Error())
+		return nil, err
+	}
+
+	// the user may have left the tenant, or the tenant is deleted
+	tenantID, err := svc.resolveTenant(f.TenantID)
+	if err != nil {
+		f.Revoke(err.Error())
+		return nil, err
+	}
+
+	if u.Membership(tenantID) == nil {
+		f.Revoke(user.ErrTenantNotJoined.Error())
+		return nil, user.ErrTenantNotJoined
+	}
+
+	u.Token.TenantID = tenantID
+	u.Token.RefreshToken = next
+
+	return &RefreshGrant{
+		User:   u,
+		Grant:  f.Grant,
+		Client: c,
+	}, nil
+}
+
+// rotate stores the rotation before the next token is handed out. The
+// presented token is leased across the instances and compared in the
+// store, whoever loses the race presents a rotated token and revokes the
+// family.
+func (svc *service) rotate(f *token.Family, refreshToken string) (string, error) {
+	previous := f.Hash
+
+	next, err := f.Rotate(refreshToken)
+	if err != nil {
+		f.Notify() // the reused token revokes the family
+		return "", err
+	}
+
+	err = svc.leases.Acquire("refresh:"+previous, f.Hash, f.ExpiredAt)
+	if err == nil {
+		err = svc.tokens.Rotate(f, previous)
+	}
+
+	if err != nil {
+		if !errors.Is(err, lease.ErrHeld) && !errors.Is(err, token.ErrTokenReused) {
+			return "", err
+		}
+
+		current, err := svc.tokens.Find(f.ID)
+		if err != nil {
+			return "", err
+		}
+
+		if err := current.Revoke("refresh token reused"); err == nil {
+			current.Notify()
+		}
+
+		return "", token.ErrTokenReused
+	}
+
+	return next, nil
+}
+
+// refreshClient authenticates the client the family is issued to, the
+// families of the first party are refreshed without a client.
+func (svc *service) refreshClient(f *token.Family, clientID string, clientSecret string) (*client.Client, error) {
+	if f.ClientID == "" {
+		if clientID != "" {
+			return nil, token.ErrInvalidToken
+		}
+
+		return nil, nil
+	}
+
+	c, err := svc.authenticateClient(clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ID.String() != f.ClientID {
+		return nil, token.ErrInvalidToken
+	}
+
+	if !c.AllowsGrant(client.GrantRefreshToken) {
+		return nil, client.ErrGrantNotAllowed
+	}
+
+	return c, nil
+}
+
+// RevokeToken denies the access token on every instance until it
+// expires, or revokes the family of the refresh token. Only the client
+// the token is issued to revokes it as RFC 7009 §2.1 requires, the tokens
+// of the first party are revoked without a client. The invalid tokens are
+// ignored.
+func (svc *service) RevokeToken(clientID string, clientSecret string, tokenStr string) error {
+	claims, err := svc.verifier.Verify(tokenStr)
+	if err != nil {
+		return svc.revokeRefreshToken(clientID, clientSecret, tokenStr)
+	}
+
+	if err := svc.revokingClient(claims.ClientID, claims.Audience, clientID, clientSecret); err != nil {
+		return err
+	}
+
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+
+	r := token.NewRevocation(claims.ID, claims.ExpiresAt.Time)
+	defer r.Notify()
+
+	return nil
+}
+
+func (svc *service) revokeRefreshToken(clientID string, clientSecret string, refreshToken string) error {
+	id, err := token.Parse(refreshToken)
+	if err != nil {
+		return nil
+	}
+
+	f, err := svc.tokens.Find(id)
+	if err != nil {
+		if errors.Is(err, token.ErrFamilyNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	if !f.Owns(refreshToken) {
+		return nil
+	}
+
+	if err := svc.revokingClient(f.ClientID, nil, clientID, clientSecret); err != nil {
+		return err
+	}
+
+	if err := f.Revoke("revoked by client"); err != nil {
+		return nil
+	}
+	defer f.Notify()
+
+	return nil
+}
+
+// revokingClient authenticates the client revoking a token issued to
+// issuedTo, or to one of the audience.
+func (svc *service) revokingClient(issuedTo string, audience []string, clientID string, clientSecret string) error {
+	if issuedTo == "" {
+		if clientID != "" {
+			return ErrTokenNotIssued
+		}
+
+		return nil
+	}
+
+	c, err := svc.authenticateClient(clientID, clientSecret)
+	if err != nil {
+		return err
+	}
+
+	if id := c.ID.String(); id != issuedTo && !slices.Contains(audience, id) {
+		return ErrTokenNotIssued
+	}
+
+	return nil
+}
+
+// Introspection follows RFC 7662, an inactive token tells nothing else.
+type Introspection struct {
+	Active    bool     `json:"active"`
+	Subject   string   `json:"sub,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TenantID  string   `json:"tenant_id,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	Status    string   `json:"status,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	TokenUse  string   `json:"token_use,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	TokenID   string   `json:"jti,omitempty"`
+}
+
+// Introspect tells the gateways whether the access token is active, both
+// the revocations and the current status of the user are checked.
+func (svc *service) Introspect(clientID string, clientSecret string, tokenStr string) (*Introspection, error) {
+	c, err := svc.authenticateClient(clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the confidential clients introspect
+	if !c.Confidential() {
+		return nil, ErrInvalidClient
+	}
+
+	inactive := &Introspection{Active: false}
+
+	claims, err := svc.verifier.Verify(tokenStr)
+	if err != nil {
+		return inactive, nil
+	}
+
+	if claims.Service() {
+		return svc.introspectService(claims)
+	}
+
+	id, err := user.ParseID(claims.Subject)
+	if err != nil {
+		return inactive, nil
+	}
+
+	u, err := svc.users.Find(id)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return inactive, nil
+		}
+
+		return nil, err
+	}
+
+	if err := u.CheckStatus(); err != nil {
+		return inactive, nil
+	}
+
+	result := &Introspection{
+		Active:    true,
+		Subject:   claims.Subject,
+		Username:  u.Username,
+		TenantID:  claims.Tenant().String(),
+		Roles:     claims.Roles,
+		Status:    u.Status.String(),
+		TokenType: "Bearer",
+		TokenUse:  verifier.TokenUseUser,
+		TokenID:   claims.ID,
+	}
+
+	result.times(claims)
+	return result, nil
+}
+
+// introspectService tells the token of a client active while the client
+// is still registered.
+func (svc *service) introspectService(claims *verifier.Claims) (*Introspection, error) {
+	if err := svc.CheckClient(client.ClientID(claims.Subject)); err != nil {
+		if errors.Is(err, client.ErrClientNotFound) {
+			return &Introspection{Active: false}, nil
+		}
+
+		return nil, err
+	}
+
+	result := &Introspection{
+		Active:    true,
+		Subject:   claims.Subject,
+		ClientID:  claims.ClientID,
+		Roles:     claims.Roles,
+		Scope:     claims.Scope,
+		TokenType: "Bearer",
+		TokenUse:  verifier.TokenUseService,
+		TokenID:   claims.ID,
+	}
+
+	result.times(claims)
+	return result, nil
+}
+
+func (i *Introspection) times(claims *verifier.Claims) {
+	if claims.ExpiresAt != nil {
+		i.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+
+	if claims.IssuedAt != nil {
+		i.IssuedAt = claims.IssuedAt.Unix()
+	}
+}
+
+// authenticateClient authenticates a confidential client by its secret, a
+// public client presents none.
+func (svc *service) authenticateClient(clientID string, clientSecret string) (*client.Client, error) {
+	c, err := svc.findClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.Authenticate(clientSecret); err != nil {
+		return nil, ErrInvalidClient
+	}
+
+	return c, nil
+}
+
+// findClient tells nothing more than ErrInvalidClient about the unknown clients.
+func (svc *service) findClient(clientID string) (*client.Client, error) {
+	id, err := client.ParseID(clientID)
+	if err != nil {
+		return nil, ErrInvalidClient
+	}
+
+	c, err := svc.clients.Find(id)
+	if err != nil {
+		if errors.Is(err, client.ErrClientNotFound) {
+			return nil, ErrInvalidClient
+		}
+
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Authorize grants the signed-in user's authorization to the client, the
+// returned code is redeemed by ExchangeCode.
+func (svc *service) Authorize(claims *verifier.Claims, req *oidc.AuthorizationRequest) (string, error) {
+	c, err := svc.findClient(req.ClientID)
+	if err != nil {
+		return "", err
+	}
+
+	// an actor never authorizes a client on behalf of the subject
+	if claims.Act != nil {
+		return "", ErrDelegatedToken
+	}
+
+	if !c.AllowsRedirectURI(req.RedirectURI) {
+		return "", oidc.ErrInvalidRedirectURI
+	}
+
+	if !c.AllowsGrant(client.GrantAuthorizationCode) {
+		return "", client.ErrGrantNotAllowed
+	}
+
+	if err := req.Validate(); err != nil {
+		return "", err
+	}
+
+	scope := oidc.ParseScope(req.Scope)
+	if !c.AllowsScopes(scope) {
+		return "", client.ErrScopeNotAllowed
+	}
+
+	id, err := user.ParseID(claims.Subject)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return "", err
+	}
+
+	if err := u.CheckStatus(); err != nil {
+		return "", err
+	}
+
+	if u.Membership(claims.Tenant()) == nil {
+		return "", user.ErrTenantNotJoined
+	}
+
+	authTime := claims.IssuedAt
+	if claims.AuthTime != nil {
+		authTime = claims.AuthTime
+	}
+
+	code := &oidc.Code{
+		ClientID:    c.ID.String(),
+		RedirectURI: req.RedirectURI,
+		UserID:      u.ID,
+		TenantID:    claims.Tenant(),
+		Scope:       scope,
+		Nonce:       req.Nonce,
+		Challenge:   req.CodeChallenge,
+		AMR:         claims.AMR,
+	}
+
+	if authTime != nil {
+		code.AuthTime = authTime.Time
+	}
+
+	return svc.authCodes.Issue(code)
+}
+
+// ExchangeCode redeems the authorization code of the client, the tokens
+// are issued to the user of the grant.
+func (svc *service) ExchangeCode(clientID string, clientSecret string, code string, redirectURI string, codeVerifier string) (*oidc.Grant, error) {
+	c, err := svc.authenticateClient(clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.AllowsGrant(client.GrantAuthorizationCode) {
+		return nil, client.ErrGrantNotAllowed
+	}
+
+	grant, err := svc.authCodes.Redeem(code)
+	if err != nil {
+		return nil, err
+	}
+
+	if grant.ClientID != c.ID.String() || grant.RedirectURI != redirectURI {
+		return nil, oidc.ErrCodeNotFound
+	}
+
+	if err := grant.Verify(codeVerifier); err != nil {
+		return nil, err
+	}
+
+	u, err := svc.users.Find(grant.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.CheckStatus(); err != nil {
+		return nil, err
+	}
+
+	tenantID, err := svc.resolveTenant(grant.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Membership(tenantID) == nil {
+		return nil, user.ErrTenantNotJoined
+	}
+
+	u.Token.TenantID = tenantID
+	if c.AllowsGrant(client.GrantRefreshToken) {
+		g := token.Grant{
+			ClientID: c.ID.String(),
+			Scope:    grant.Scope,
+			AuthTime: grant.AuthTime,
+			AMR:      grant.AMR,
+		}
+
+		if err := svc.issueRefreshToken(u, g, c.Lifetimes.RefreshToken); err != nil {
+			return nil, err
+		}
+	}
+
+	return &oidc.Grant{
+		User:   u,
+		Code:   grant,
+		Client: c,
+	}, nil
+}
+
+// ServiceGrant is the authorization of a client acting on its own behalf.
+type ServiceGrant struct {
+	Client *client.Client
+	Scope  oidc.Scope
+}
+
+// ClientCredentials grants a confidential client by its own credentials,
+// all the scopes of the client are granted if none is requested.
+func (svc *service) ClientCredentials(clientID string, clientSecret string, scope oidc.Scope) (*ServiceGrant, error) {
+	c, err := svc.authenticateClient(clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.Confidential() {
+		return nil, ErrInvalidClient
+	}
+
+	if !c.AllowsGrant(client.GrantClientCredentials) {
+		return nil, client.ErrGrantNotAllowed
+	}
+
+	if len(scope) == 0 {
+		scope = oidc.Scope(c.Scopes)
+	}
+
+	if !c.AllowsScopes(scope) {
+		return nil, client.ErrScopeNotAllowed
+	}
+
+	return &ServiceGrant{
+		Client: c,
+		Scope:  scope,
+	}, nil
+}
+
+// whoService is the who_enum of the policy opting in the service principals.
+const whoService = 1 << 5
+
+// ExchangeToken mints a token of the subject for the actor, the client
+// itself acts if no actor token is presented. The actor acting on behalf
+// of another subject is decided by the policy.
+func (svc *service) ExchangeToken(ctx context.Context, clientID string, clientSecret string, req *token.ExchangeRequest) (*token.Exchange, error) {
+	c, err := svc.authenticateClient(clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.Confidential() {
+		return nil, ErrInvalidClient
+	}
+
+	if !c.AllowsGrant(client.GrantTokenExchange) {
+		return nil, client.ErrGrantNotAllowed
+	}
+
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	subject, err := svc.verifyPrincipal(req.SubjectToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// the client exchanges only the tokens issued to it
+	if !slices.Contains(subject.Audience, c.ID.String()) {
+		return nil, token.ErrSubjectTokenAudience
+	}
+
+	// the client acts itself unless the actor token tells who acts
+	claims := &verifier.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: c.ID.String(),
+		},
+		Roles:    c.Roles,
+		TokenUse: verifier.TokenUseService,
+		ClientID: c.ID.String(),
+	}
+
+	if req.ActorToken != "" {
+		claims, err = svc.verifyPrincipal(req.ActorToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if claims.Subject != subject.Subject {
+		if err := svc.authorizeImpersonation(ctx, claims, subject); err != nil {
+			return nil, err
+		}
+	}
+
+	actor := &verifier.Actor{
+		Subject:  claims.Subject,
+		TokenUse: verifier.TokenUseUser,
+	}
+
+	if claims.Service() {
+		actor.TokenUse = verifier.TokenUseService
+	}
+
+	scope, err := narrowScope(c, oidc.ParseScope(subject.Scope), req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	audience := req.Audience
+	if len(audience) == 0 {
+		audience = []string{c.ID.String()}
+	}
+
+	if !c.AllowsAudiences(audience) {
+		return nil, client.ErrAudienceNotAllowed
+	}
+
+	// the token grants no role the actor doesn't hold
+	roles := make([]string, 0)
+	for _, role := range subject.Roles {
+		if slices.Contains(claims.Roles, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	ttl := svc.timeout
+	if c.Lifetimes.AccessToken > 0 {
+		ttl = c.Lifetimes.AccessToken
+	}
+
+	x := token.NewExchange(c.ID.String(), subject, actor, roles, audience, scope, ttl)
+	defer x.Notify()
+
+	return x, nil
+}
+
+// verifyPrincipal verifies the token and its subject is still active.
+func (svc *service) verifyPrincipal(tokenStr string) (*verifier.Claims, error) {
+	claims, err := svc.verifier.Verify(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Service() {
+		if err := svc.CheckClient(client.ClientID(claims.Subject)); err != nil {
+			return nil, err
+		}
+
+		return claims, nil
+	}
+
+	id, err := user.ParseID(claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.CheckStatus(id); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (svc *service) authorizeImpersonation(ctx context.Context, actor *verifier.Claims, subject *verifier.Claims) error {
+	var flags byte
+	if actor.Service() {
+		flags = whoService
+	}
+
+	input := map[string]any{
+		"domain":    "identity::tokens",
+		"action":    "impersonate",
+		"who_flags": flags,
+		"object":    subject.Subject,
+		"claims":    actor.Map(),
+		"subject":   subject.Map(),
+	}
+
+	// nobody acts on behalf of a subject of another tenant
+	if !actor.Service() {
+		input["tenant"] = actor.Tenant().String()
+	}
+
+	if !subject.Service() {
+		input["object_tenants"] = []string{subject.Tenant().String()}
+	}
+
+	allowed, err := svc.policy.Eval(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return token.ErrImpersonationDenied
+	}
+
+	return nil
+}
+
+// narrowScope never widens the scope of the subject token, nor grants a
+// scope the client isn't allowed. The subject token without scope is
+// narrowed to the client.
+func narrowScope(c *client.Client, granted oidc.Scope, requested oidc.Scope) (oidc.Scope, error) {
+	scope := requested
+	if len(scope) == 0 {
+		scope = granted
+	}
+
+	if len(scope) == 0 {
+		scope = oidc.Scope(c.Scopes)
+	}
+
+	if len(granted) > 0 {
+		for _, s := range scope {
+			if !granted.Has(s) {
+				return nil, client.ErrScopeNotAllowed
+			}
+		}
+	}
+
+	if !c.AllowsScopes(scope) {
+		return nil, client.ErrScopeNotAllowed
+	}
+
+	return scope, nil
+}
+
+func (svc *service) UserInfo(id user.UserID, scope oidc.Scope) (*oidc.UserInfo, error) {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.CheckStatus(); err != nil {
+		return nil, err
+	}
+
+	return oidc.NewUserInfo(u, scope), nil
+}
+
+// RegisteredClient returns the secret of a confidential client once, only
+// its hash is kept.
+type RegisteredClient struct {
+	*client.Client
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+func (svc *service) RegisterClient(typ client.Type, settings client.Settings) (*RegisteredClient, error) {
+	c, secret, err := client.NewClient(typ, settings)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Notify()
+
+	return &RegisteredClient{
+		Client:       c,
+		ClientSecret: secret,
+	}, nil
+}
+
+func (svc *service) UpdateClient(id client.ClientID, settings client.Settings) (*client.Client, error) {
+	c, err := svc.clients.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.Update(settings); err != nil {
+		return nil, err
+	}
+	defer c.Notify()
+
+	return c, nil
+}
+
+// RotateClientSecret replaces the secret at once, the old one is refused
+// as soon as the event is applied.
+func (svc *service) RotateClientSecret(id client.ClientID) (*RegisteredClient, error) {
+	c, err := svc.clients.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := c.RotateSecret()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Notify()
+
+	return &RegisteredClient{
+		Client:       c,
+		ClientSecret: secret,
+	}, nil
+}
+
+func (svc *service) DeleteClient(id client.ClientID) error {
+	c, err := svc.clients.Find(id)
+	if err != nil {
+		return err
+	}
+
+	c.Delete()
+	defer c.Notify()
+
+	return nil
+}
+
+func (svc *service) FindClient(id client.ClientID) (*client.Client, error) {
+	return svc.clients.Find(id)
+}
+
+func (svc *service) ListClients() ([]*client.Client, error) {
+	return svc.clients.List()
+}
+
+func (svc *service) CreateGroup(name string, parent group.GroupID) (*group.Group, error) {
+	g, err := group.NewGroup(name, parent)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.checkParent(g.ID, parent); err != nil {
+		return nil, err
+	}
+	defer g.Notify()
+
+	return g, nil
+}
+
+func (svc *service) UpdateGroup(id group.GroupID, name string, parent group.GroupID) (*group.Group, error) {
+	g, err := svc.groups.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.checkParent(g.ID, parent); err != nil {
+		return nil, err
+	}
+
+	if err := g.Update(name, parent); err != nil {
+		return nil, err
+	}
+	defer g.Notify()
+
+	return g, nil
+}
+
+// checkParent walks up from the parent, the group must not be one of its
+// ancestors.
+func (svc *service) checkParent(id group.GroupID, parent group.GroupID) error {
+	for depth := 0; parent != ""; depth++ {
+		if parent == id {
+			return group.ErrCyclicGroup
+		}
+
+		if depth >= group.MaxDepth {
+			return group.ErrNestedTooDeep
+		}
+
+		g, err := svc.groups.Find(parent)
+		if err != nil {
+			if errors.Is(err, group.ErrGroupNotFound) {
+				return group.ErrParentNotFound
+			}
+
+			return err
+		}
+
+		parent = g.Parent
+	}
+
+	return nil
+}
+
+// DeleteGroup refuses a group with subgroups, the subgroups are moved or
+// deleted first.
+func (svc *service) DeleteGroup(id group.GroupID) error {
+	g, err := svc.groups.Find(id)
+	if err != nil {
+		return err
+	}
+
+	subgroups, err := svc.groups.FindByParent(id)
+	if err != nil {
+		return err
+	}
+
+	if len(subgroups) > 0 {
+		return group.ErrHasSubgroups
+	}
+
+	g.Delete()
+	defer g.Notify()
+
+	return nil
+}
+
+func (svc *service) FindGroup(id group.GroupID) (*group.Group, error) {
+	return svc.groups.Find(id)
+}
+
+func (svc *service) ListGroups() ([]*group.Group, error) {
+	return svc.groups.List()
+}
+
+func (svc *service) AddGroupMember(id group.GroupID, userID user.UserID) (*group.Group, error) {
+	if _, err := svc.users.Find(userID); err != nil {
+		return nil, err
+	}
+
+	g, err := svc.groups.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.AddMember(userID); err != nil {
+		return nil, err
+	}
+	defer g.Notify()
+
+	return g, nil
+}
+
+func (svc *service) RemoveGroupMember(id group.GroupID, userID user.UserID) (*group.Group, error) {
+	g, err := svc.groups.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.RemoveMember(userID); err != nil {
+		return nil, err
+	}
+	defer g.Notify()
+
+	return g, nil
+}
+
+func (svc *service) GrantGroupRole(id group.GroupID, role string) (*group.Group, error) {
+	g, err := svc.groups.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.GrantRole(role); err != nil {
+		return nil, err
+	}
+	defer g.Notify()
+
+	return g, nil
+}
+
+func (svc *service) RevokeGroupRole(id group.GroupID, role string) (*group.Group, error) {
+	g, err := svc.groups.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.RevokeRole(role); err != nil {
+		return nil, err
+	}
+	defer g.Notify()
+
+	return g, nil
+}
+
+// Memberships returns the groups of the user, the ancestors of the groups
+// the user is a direct member of are included. The callers tell the direct
+// ones by the members.
+func (svc *service) Memberships(id user.UserID) ([]*group.Group, error) {
+	direct, err := svc.groups.FindByMember(id)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[group.GroupID]bool)
+	groups := make([]*group.Group, 0, len(direct))
+
+	for _, g := range direct {
+		for depth := 0; g != nil && !seen[g.ID] && depth <= group.MaxDepth; depth++ {
+			seen[g.ID] = true
+			groups = append(groups, g)
+
+			if g.Parent == "" {
+				break
+			}
+
+			parent, err := svc.groups.Find(g.Parent)
+			if err != nil {
+				if errors.Is(err, group.ErrGroupNotFound) {
+					break
+				}
+
+				return nil, err
+			}
+
+			g = parent
+		}
+	}
+
+	return groups, nil
+}
+
+// CreateTenant hosts an organization, the ID is chosen by the organization
+// as its users enter it on signing in.
+func (svc *service) CreateTenant(id tenant.TenantID, name string) (*tenant.Tenant, error) {
+	if id == tenant.Default {
+		return nil, tenant.ErrTenantExists
+	}
+
+	_, err := svc.tenants.Find(id)
+	if err == nil {
+		return nil, tenant.ErrTenantExists
+	}
+
+	if !errors.Is(err, tenant.ErrTenantNotFound) {
+		return nil, err
+	}
+
+	t, err := tenant.NewTenant(id, name)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Notify()
+
+	return t, nil
+}
+
+func (svc *service) UpdateTenant(id tenant.TenantID, name string) (*tenant.Tenant, error) {
+	t, err := svc.tenants.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.Update(name); err != nil {
+		return nil, err
+	}
+	defer t.Notify()
+
+	return t, nil
+}
+
+// DeleteTenant leaves the memberships, the refresh tokens of the tenant
+// are refused from then on.
+func (svc *service) DeleteTenant(id tenant.TenantID) error {
+	if id == tenant.Default {
+		return tenant.ErrDefaultTenant
+	}
+
+	t, err := svc.tenants.Find(id)
+	if err != nil {
+		return err
+	}
+
+	if err := t.Delete(); err != nil {
+		return err
+	}
+	defer t.Notify()
+
+	return nil
+}
+
+func (svc *service) FindTenant(id tenant.TenantID) (*tenant.Tenant, error) {
+	return svc.tenants.Find(id)
+}
+
+func (svc *service) ListTenants() ([]*tenant.Tenant, error) {
+	return svc.tenants.List()
+}
+
+// JoinTenant makes the user a member of another tenant, the username and
+// the email must not be taken in the tenant.
+func (svc *service) JoinTenant(id tenant.TenantID, userID user.UserID) (*user.User, error) {
+	id, err := svc.resolveTenant(id)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := svc.users.Find(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.checkUnique(id, u); err != nil {
+		return nil, err
+	}
+
+	if err := u.JoinTenant(id); err != nil {
+		return nil, err
+	}
+	defer u.Notify()
+
+	return u, nil
+}
+
+func (svc *service) LeaveTenant(id tenant.TenantID, userID user.UserID) (*user.User, error) {
+	u, err := svc.users.Find(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.LeaveTenant(id); err != nil {
+		return nil, err
+	}
+	defer u.Notify()
+
+	return u, nil
+}
+
+// GrantTenantRole takes effect on the tokens issued in the tenant
+// afterwards, like GrantRole.
+func (svc *service) GrantTenantRole(id tenant.TenantID, userID user.UserID, role string) (*user.User, error) {
+	u, err := svc.users.Find(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.GrantTenantRole(id, role); err != nil {
+		return nil, err
+	}
+	defer u.Notify()
+
+	return u, nil
+}
+
+func (svc *service) RevokeTenantRole(id tenant.TenantID, userID user.UserID, role string) (*user.User, error) {
+	u, err := svc.users.Find(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.RevokeTenantRole(id, role); err != nil {
+		return nil, err
+	}
+	defer u.Notify()
+
+	return u, nil
+}
+
+// Tenants returns the tenants the user belongs to, the policy keeps the
+// tokens of a tenant to the users of the tenant.
+func (svc *service) Tenants(id user.UserID) ([]tenant.TenantID, error) {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := make([]tenant.TenantID, len(u.Tenants))
+	for i, m := range u.Tenants {
+		tenants[i] = m.TenantID
+	}
+
+	return tenants, nil
+}
+
+func (svc *service) CheckStatus(id user.UserID) error {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return err
+	}
+
+	return u.CheckStatus()
+}
+
+// CheckClient tells whether the client of a service token still exists.
+func (svc *service) CheckClient(id client.ClientID) error {
+	_, err := svc.clients.Find(id)
+	return err
+}
+
+func (svc *service) CheckHealth(ctx context.Context) error {
+	return nil
+}
+
+// UserRegisteredHandler sends the code to verify the email of the user.
+// The instances race for the sending by a lease, and the events replayed
+// past the lifetime of the codes send none.
+func (svc *service) UserRegisteredHandler(e *user.UserRegisteredEvent) error {
+	_, err := svc.users.Find(e.UserID)
+	switch {
+	case err == nil:
+		return nil // replayed, the later events are applied already
+	case !errors.Is(err, user.ErrUserNotFound):
+		return err
+	}
+
+	if err := svc.users.Store(e.User); err != nil {
+		return err
+	}
+
+	if e.User.Email == "" || time.Since(e.OccuredAt) > svc.codes.TTL {
+		return nil
+	}
+
+	key := "verification:" + e.UserID.String()
+	until := e.OccuredAt.Add(svc.codes.TTL)
+	if err := svc.leases.Acquire(key, svc.instance, until); err != nil {
+		if errors.Is(err, lease.ErrHeld) {
+			return nil // sent by another instance
+		}
+
+		return err
+	}
+
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	if u.Status != user.Registered || u.Verification != nil {
+		return nil // verified or sent already
+	}
+
+	if err := svc.sendVerificationCode(u); err != nil {
+		return err
+	}
+	defer u.Notify()
+
+	// stored ahead of the event, the code is checked by this instance first
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserActivatedHandler(e *user.UserActivatedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	u.Status = e.Status
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserSocialAccountAddedHandler(e *user.UserSocialAccountAddedEvent) error {
+	u, err := svc.users.Find(e.UserID)
 	if err != nil {
 		return err
 	}
@@ -193,3 +2423,566 @@ func (svc *service) UserSocialAccountAddedHandler(e *user.UserSocialAccountAdded
 
 	return svc.users.Store(u)
 }
+
+// UserSocialAccountRemovedHandler is idempotent, the account may have been
+// removed by the replay of the stream.
+func (svc *service) UserSocialAccountRemovedHandler(e *user.UserSocialAccountRemovedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	u.Accounts = slices.DeleteFunc(u.Accounts, func(a *user.SocialAccount) bool {
+		return a.Provider == e.Provider && a.SocialID == e.SocialID
+	})
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserOTPEnrolledHandler(e *user.UserOTPEnrolledEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	otp := e.OTP
+	u.PendingOTP = &otp
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserOTPConfirmedHandler(e *user.UserOTPConfirmedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	if u.PendingOTP == nil {
+		if u.OTP != nil && u.OTP.LastStep >= e.Step {
+			return nil // confirmed already
+		}
+
+		return user.ErrOTPNotEnrolled
+	}
+
+	otp := *u.PendingOTP
+	otp.LastStep = e.Step
+
+	u.OTP = &otp
+	u.PendingOTP = nil
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserOTPVerifiedHandler(e *user.UserOTPVerifiedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	if u.OTP == nil {
+		return user.ErrOTPNotEnrolled
+	}
+
+	u.OTP.LastStep = e.Step
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserVerificationCodeIssuedHandler(e *user.UserVerificationCodeIssuedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	code := e.Code
+	u.Verification = &code
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserVerificationCodeFailedHandler(e *user.UserVerificationCodeFailedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	if u.Verification == nil {
+		return user.ErrVerificationCodeNotFound
+	}
+
+	u.Verification.Attempts = e.Attempts
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserVerificationCodeVerifiedHandler(e *user.UserVerificationCodeVerifiedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	u.Verification = nil
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserPasswordChangedHandler(e *user.UserPasswordChangedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	password := e.Password
+	u.Password = &password
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserLockedHandler(e *user.UserLockedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	u.Restriction = &user.Restriction{
+		Reason:   e.Reason,
+		Previous: u.Status,
+		Since:    e.OccuredAt,
+	}
+	u.Status = e.Status
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserUnlockedHandler(e *user.UserUnlockedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	u.Restriction = nil
+	u.Status = e.Status
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserRevokedHandler(e *user.UserRevokedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	u.Restriction = &user.Restriction{
+		Reason:   e.Reason,
+		Previous: u.Status,
+		Since:    e.OccuredAt,
+	}
+	u.Status = e.Status
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+// UserRoleGrantedHandler is idempotent, the role may have been granted by
+// the replay of the stream.
+func (svc *service) UserRoleGrantedHandler(e *user.UserRoleGrantedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	if !u.HasRole(e.Role) {
+		u.Roles = append(slices.Clone(u.Roles), e.Role)
+	}
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserRoleRevokedHandler(e *user.UserRoleRevokedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	u.Roles = slices.DeleteFunc(slices.Clone(u.Roles), func(role string) bool {
+		return role == e.Role
+	})
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserProfileUpdatedHandler(e *user.UserProfileUpdatedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	if e.Profile.Name != nil {
+		u.Name = *e.Profile.Name
+	}
+
+	if e.Profile.Avatar != nil {
+		u.Avatar = *e.Profile.Avatar
+	}
+
+	if e.Provider == "" {
+		u.ProfileEdited = true
+	}
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserEmailChangeRequestedHandler(e *user.UserEmailChangeRequestedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	change := e.Change
+	u.EmailChange = &change
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserEmailChangedHandler(e *user.UserEmailChangedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	u.Email = e.Email
+	u.EmailChange = nil
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+// UserUsernameChangedHandler holds the old username from the others for the
+// cool-down.
+func (svc *service) UserUsernameChangedHandler(e *user.UserUsernameChangedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	u.Username = e.Username
+	u.UpdatedAt = e.OccuredAt
+
+	if err := svc.users.Store(u); err != nil {
+		return err
+	}
+
+	until := e.OccuredAt.Add(svc.usernames.Cooldown)
+	for _, m := range u.Tenants {
+		if err := svc.users.ReserveUsername(m.TenantID, e.Previous, u.ID, until); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UserDeletedHandler frees the email and the social accounts of the user,
+// and holds the username from the others for the cool-down.
+func (svc *service) UserDeletedHandler(e *user.UserDeletedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return nil // deleted already
+		}
+
+		return err
+	}
+
+	u.UpdatedAt = e.OccuredAt
+	u.DeletedAt = e.OccuredAt
+
+	if err := svc.users.Store(u); err != nil {
+		return err
+	}
+
+	until := e.OccuredAt.Add(svc.usernames.Cooldown)
+	for _, m := range u.Tenants {
+		if err := svc.users.ReserveUsername(m.TenantID, e.Username, u.ID, until); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (svc *service) UserTenantJoinedHandler(e *user.UserTenantJoinedEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	if u.Membership(e.TenantID) == nil {
+		m := &user.Membership{
+			TenantID: e.TenantID,
+			Roles:    make([]string, 0),
+			JoinedAt: e.OccuredAt,
+		}
+
+		u.Tenants = append(slices.Clone(u.Tenants), m)
+	}
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserTenantLeftHandler(e *user.UserTenantLeftEvent) error {
+	u, err := svc.users.Find(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	u.Tenants = slices.DeleteFunc(slices.Clone(u.Tenants), func(m *user.Membership) bool {
+		return m.TenantID == e.TenantID
+	})
+	u.UpdatedAt = e.OccuredAt
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) UserTenantRoleGrantedHandler(e *user.UserTenantRoleGrantedEvent) error {
+	return svc.updateMembership(e.UserID, e.TenantID, e.OccuredAt, func(roles []string) []string {
+		if slices.Contains(roles, e.Role) {
+			return roles
+		}
+
+		return append(roles, e.Role)
+	})
+}
+
+func (svc *service) UserTenantRoleRevokedHandler(e *user.UserTenantRoleRevokedEvent) error {
+	return svc.updateMembership(e.UserID, e.TenantID, e.OccuredAt, func(roles []string) []string {
+		return slices.DeleteFunc(roles, func(role string) bool {
+			return role == e.Role
+		})
+	})
+}
+
+// updateMembership replaces the roles of the membership on copies, the
+// stored users may share the memberships.
+func (svc *service) updateMembership(id user.UserID, tenantID tenant.TenantID, at time.Time, update func(roles []string) []string) error {
+	u, err := svc.users.Find(id)
+	if err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(u.Tenants, func(m *user.Membership) bool {
+		return m.TenantID == tenantID
+	})
+
+	if i < 0 {
+		return user.ErrTenantNotJoined
+	}
+
+	m := *u.Tenants[i]
+	m.Roles = update(slices.Clone(m.Roles))
+
+	u.Tenants = slices.Clone(u.Tenants)
+	u.Tenants[i] = &m
+	u.UpdatedAt = at
+
+	return svc.users.Store(u)
+}
+
+func (svc *service) FamilyIssuedHandler(e *token.FamilyIssuedEvent) error {
+	return svc.tokens.Store(e.Family)
+}
+
+func (svc *service) FamilyRotatedHandler(e *token.FamilyRotatedEvent) error {
+	f, err := svc.tokens.Find(e.FamilyID)
+	if err != nil {
+		return err
+	}
+
+	// rotated by this instance already
+	if f.Hash == e.Hash {
+		return nil
+	}
+
+	f.Used = append(f.Used, f.Hash)
+	f.Hash = e.Hash
+	f.UpdatedAt = e.OccuredAt
+
+	return svc.tokens.Store(f)
+}
+
+func (svc *service) FamilyRevokedHandler(e *token.FamilyRevokedEvent) error {
+	f, err := svc.tokens.Find(e.FamilyID)
+	if err != nil {
+		return err
+	}
+
+	f.Status = token.Revoked
+	f.Reason = e.Reason
+	f.UpdatedAt = e.OccuredAt
+
+	return svc.tokens.Store(f)
+}
+
+func (svc *service) TokenDeniedHandler(e *token.TokenDeniedEvent) error {
+	r := &token.Revocation{
+		ID:        e.TokenID,
+		ExpiredAt: e.ExpiredAt,
+		RevokedAt: e.OccuredAt,
+	}
+
+	return svc.denylist.Deny(r)
+}
+
+func (svc *service) ClientRegisteredHandler(e *client.ClientRegisteredEvent) error {
+	c := e.Client
+	c.Secret = e.Secret
+
+	return svc.clients.Store(c)
+}
+
+func (svc *service) ClientUpdatedHandler(e *client.ClientUpdatedEvent) error {
+	c, err := svc.clients.Find(e.ClientID)
+	if err != nil {
+		return err
+	}
+
+	c.Settings = e.Settings
+	c.UpdatedAt = e.OccuredAt
+
+	return svc.clients.Store(c)
+}
+
+func (svc *service) ClientSecretRotatedHandler(e *client.ClientSecretRotatedEvent) error {
+	c, err := svc.clients.Find(e.ClientID)
+	if err != nil {
+		return err
+	}
+
+	c.Secret = e.Secret
+	c.UpdatedAt = e.OccuredAt
+
+	return svc.clients.Store(c)
+}
+
+func (svc *service) ClientDeletedHandler(e *client.ClientDeletedEvent) error {
+	return svc.clients.Remove(e.ClientID)
+}
+
+func (svc *service) GroupCreatedHandler(e *group.GroupCreatedEvent) error {
+	return svc.groups.Store(e.Group)
+}
+
+func (svc *service) GroupUpdatedHandler(e *group.GroupUpdatedEvent) error {
+	g, err := svc.groups.Find(e.GroupID)
+	if err != nil {
+		return err
+	}
+
+	g.Name = e.GroupName
+	g.Parent = e.Parent
+	g.UpdatedAt = e.OccuredAt
+
+	return svc.groups.Store(g)
+}
+
+func (svc *service) GroupMemberAddedHandler(e *group.GroupMemberAddedEvent) error {
+	g, err := svc.groups.Find(e.GroupID)
+	if err != nil {
+		return err
+	}
+
+	if !g.HasMember(e.UserID) {
+		g.Members = append(slices.Clone(g.Members), e.UserID)
+	}
+	g.UpdatedAt = e.OccuredAt
+
+	return svc.groups.Store(g)
+}
+
+func (svc *service) GroupMemberRemovedHandler(e *group.GroupMemberRemovedEvent) error {
+	g, err := svc.groups.Find(e.GroupID)
+	if err != nil {
+		return err
+	}
+
+	g.Members = slices.DeleteFunc(slices.Clone(g.Members), func(id user.UserID) bool {
+		return id == e.UserID
+	})
+	g.UpdatedAt = e.OccuredAt
+
+	return svc.groups.Store(g)
+}
+
+func (svc *service) GroupRoleGrantedHandler(e *group.GroupRoleGrantedEvent) error {
+	g, err := svc.groups.Find(e.GroupID)
+	if err != nil {
+		return err
+	}
+
+	if !g.HasRole(e.Role) {
+		g.Roles = append(slices.Clone(g.Roles), e.Role)
+	}
+	g.UpdatedAt = e.OccuredAt
+
+	return svc.groups.Store(g)
+}
+
+func (svc *service) GroupRoleRevokedHandler(e *group.GroupRoleRevokedEvent) error {
+	g, err := svc.groups.Find(e.GroupID)
+	if err != nil {
+		return err
+	}
+
+	g.Roles = slices.DeleteFunc(slices.Clone(g.Roles), func(role string) bool {
+		return role == e.Role
+	})
+	g.UpdatedAt = e.OccuredAt
+
+	return svc.groups.Store(g)
+}
+
+func (svc *service) GroupDeletedHandler(e *group.GroupDeletedEvent) error {
+	return svc.groups.Remove(e.GroupID)
+}
+
+func (svc *service) TenantCreatedHandler(e *tenant.TenantCreatedEvent) error {
+	return svc.tenants.Store(e.Tenant)
+}
+
+func (svc *service) TenantUpdatedHandler(e *tenant.TenantUpdatedEvent) error {
+	t, err := svc.tenants.Find(e.TenantID)
+	if err != nil {
+		return err
+	}
+
+	t.Name = e.TenantName
+	t.UpdatedAt = e.OccuredAt
+
+	return svc.tenants.Store(t)
+}
+
+func (svc *service) TenantDeletedHandler(e *tenant.TenantDeletedEvent) error {
+	return svc.tenants.Remove(e.TenantID)
+}
+
+// TokenExchangedHandler leaves the audit trail to the stream, the exchanged
+// tokens are never stored.
+func (svc *service) TokenExchangedHandler(e *token.TokenExchangedEvent) error {
+	return nil
+}
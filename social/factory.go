@@ -0,0 +1,53 @@
+package social
+
+import (
+	"context"
+
+	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/user"
+)
+
+type factory func(cfg conf.Providers) (SocialProviderVerifier, error)
+
+var factories = make(map[user.SocialProvider]factory)
+
+func AddFactory(provider user.SocialProvider, factory factory) {
+	factories[provider] = factory
+}
+
+// Registry routes the credentials to the verifiers of their providers.
+type Registry struct {
+	verifiers map[user.SocialProvider]SocialProviderVerifier
+}
+
+// NewRegistry registers the verifiers of the providers added, the
+// providers are added by importing their packages.
+func NewRegistry(cfg conf.Providers) (*Registry, error) {
+	r := &Registry{
+		verifiers: make(map[user.SocialProvider]SocialProviderVerifier),
+	}
+
+	for provider, factory := range factories {
+		v, err := factory(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		r.Register(provider, v)
+	}
+
+	return r, nil
+}
+
+func (r *Registry) Register(provider user.SocialProvider, v SocialProviderVerifier) {
+	r.verifiers[provider] = v
+}
+
+func (r *Registry) Verify(ctx context.Context, provider user.SocialProvider, credential string) (*Identity, error) {
+	v, ok := r.verifiers[provider]
+	if !ok {
+		return nil, ErrProviderNotSupported
+	}
+
+	return v.Verify(ctx, credential)
+}
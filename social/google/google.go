@@ -0,0 +1,46 @@
+package google
+
+import (
+	"context"
+
+	"google.golang.org/api/idtoken"
+
+	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/social"
+	"github.com/mirror520/identity/user"
+)
+
+func init() {
+	social.AddFactory(user.GOOGLE, NewVerifier)
+}
+
+func NewVerifier(cfg conf.Providers) (social.SocialProviderVerifier, error) {
+	return &verifier{cfg.Google.Client.ID}, nil
+}
+
+// verifier validates the ID tokens issued to the client by Google.
+type verifier struct {
+	clientID string
+}
+
+func (v *verifier) Verify(ctx context.Context, credential string) (*social.Identity, error) {
+	if v.clientID == "" {
+		return nil, social.ErrClientIDNotFound
+	}
+
+	payload, err := idtoken.Validate(ctx, credential, v.clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	email, _ := payload.Claims["email"].(string)
+	name, _ := payload.Claims["name"].(string)
+	picture, _ := payload.Claims["picture"].(string)
+
+	return &social.Identity{
+		SocialID: user.SocialID(payload.Subject),
+		Email:    email,
+		Name:     name,
+		Picture:  picture,
+	}, nil
+}
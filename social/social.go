@@ -0,0 +1,27 @@
+package social
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mirror520/identity/user"
+)
+
+var (
+	ErrProviderNotSupported = errors.New("provider not supported")
+	ErrClientIDNotFound     = errors.New("client id not found")
+)
+
+// Identity is the account of a provider verified by its credential, the
+// profile is empty if the provider tells none.
+type Identity struct {
+	SocialID user.SocialID
+	Email    string
+	Name     string
+	Picture  string
+}
+
+// SocialProviderVerifier verifies the credentials issued by a provider.
+type SocialProviderVerifier interface {
+	Verify(ctx context.Context, credential string) (*Identity, error)
+}
@@ -0,0 +1,125 @@
+package tenant
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/mirror520/identity/events"
+)
+
+type EventName int
+
+const (
+	Unknown EventName = iota
+	TenantCreated
+	TenantUpdated
+	TenantDeleted
+)
+
+func ParseEventName(s string) EventName {
+	switch s {
+	case "tenant_created":
+		return TenantCreated
+	case "tenant_updated":
+		return TenantUpdated
+	case "tenant_deleted":
+		return TenantDeleted
+	default:
+		return Unknown
+	}
+}
+
+func (name EventName) String() string {
+	switch name {
+	case TenantCreated:
+		return "tenant_created"
+	case TenantUpdated:
+		return "tenant_updated"
+	case TenantDeleted:
+		return "tenant_deleted"
+	default:
+		return ""
+	}
+}
+
+func (name EventName) MarshalJSON() ([]byte, error) {
+	return json.Marshal(name.String())
+}
+
+func (name *EventName) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	*name = ParseEventName(s)
+	return nil
+}
+
+type Event struct {
+	Domain    string    `json:"domain"`
+	Name      EventName `json:"name"`
+	TenantID  TenantID  `json:"tenant_id"` // AggreagateRoot
+	OccuredAt time.Time `json:"occured_at"`
+}
+
+func NewEvent(name EventName, t *Tenant) *Event {
+	return &Event{
+		Domain:    "identity:tenants",
+		Name:      name,
+		TenantID:  t.ID,
+		OccuredAt: t.UpdatedAt,
+	}
+}
+
+func (e *Event) EventName() string {
+	return e.Name.String()
+}
+
+func (e *Event) Topic() string {
+	return "tenants." + e.TenantID.String() + "." + e.Name.TopicName()
+}
+
+// TopicName is the last token of the topic, "tenants.<id>.<name>".
+func (name EventName) TopicName() string {
+	return strings.TrimPrefix(name.String(), "tenant_")
+}
+
+func ParseTopicName(s string) EventName {
+	return ParseEventName("tenant_" + s)
+}
+
+type TenantCreatedEvent struct {
+	*Event
+	Tenant *Tenant `json:"tenant"`
+}
+
+func NewTenantCreatedEvent(t *Tenant) events.DomainEvent {
+	return &TenantCreatedEvent{
+		Event:  NewEvent(TenantCreated, t),
+		Tenant: t,
+	}
+}
+
+type TenantUpdatedEvent struct {
+	*Event
+	TenantName string `json:"tenant_name"`
+}
+
+func NewTenantUpdatedEvent(t *Tenant) events.DomainEvent {
+	return &TenantUpdatedEvent{
+		Event:      NewEvent(TenantUpdated, t),
+		TenantName: t.Name,
+	}
+}
+
+type TenantDeletedEvent struct {
+	*Event
+}
+
+func NewTenantDeletedEvent(t *Tenant) events.DomainEvent {
+	return &TenantDeletedEvent{
+		Event: NewEvent(TenantDeleted, t),
+	}
+}
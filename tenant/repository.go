@@ -0,0 +1,15 @@
+package tenant
+
+type Repository interface {
+	// Command
+
+	Store(t *Tenant) error
+	Remove(id TenantID) error
+
+	// Query
+
+	Find(id TenantID) (*Tenant, error)
+	List() ([]*Tenant, error)
+
+	Close() error
+}
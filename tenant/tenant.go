@@ -0,0 +1,100 @@
+package tenant
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/model"
+)
+
+var (
+	ErrTenantNotFound  = errors.New("tenant not found")
+	ErrTenantExists    = errors.New("tenant exists")
+	ErrInvalidTenantID = errors.New("invalid tenant id")
+	ErrNameEmpty       = errors.New("tenant name empty")
+	ErrDefaultTenant   = errors.New("default tenant can't be deleted")
+)
+
+// Default is the tenant of the deployments hosting a single organization
+// and of the tokens issued before the tenants. It always exists, and its
+// admins operate every tenant.
+const Default TenantID = "default"
+
+type TenantID string // AggregateRoot
+
+// ParseID rejects the IDs which can't be a token of the topics, an
+// organization chooses its own ID as it's entered on signing in.
+func ParseID(id string) (TenantID, error) {
+	if id == "" || strings.ContainsAny(id, ".*>: \t\r\n") {
+		return "", ErrInvalidTenantID
+	}
+
+	return TenantID(id), nil
+}
+
+func (id TenantID) String() string {
+	return string(id)
+}
+
+// Tenant is an organization hosted on the deployment, the users belong to
+// the tenants by their memberships.
+type Tenant struct {
+	ID   TenantID `json:"id"`
+	Name string   `json:"name"`
+
+	model.Model
+
+	events.EventStore `json:"-"`
+}
+
+func NewTenant(id TenantID, name string) (*Tenant, error) {
+	if name == "" {
+		return nil, ErrNameEmpty
+	}
+
+	now := time.Now()
+	t := &Tenant{
+		ID:   id,
+		Name: name,
+		Model: model.Model{
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+
+		EventStore: events.NewEventStore(),
+	}
+
+	t.AddEvent(NewTenantCreatedEvent(t))
+	return t, nil
+}
+
+func (t *Tenant) Update(name string) error {
+	if name == "" {
+		return ErrNameEmpty
+	}
+
+	t.Name = name
+	t.UpdatedAt = time.Now()
+
+	e := NewTenantUpdatedEvent(t)
+	t.AddEvent(e)
+	return nil
+}
+
+// Delete leaves the memberships of the users, the users can't sign in to
+// a deleted tenant anymore.
+func (t *Tenant) Delete() error {
+	if t.ID == Default {
+		return ErrDefaultTenant
+	}
+
+	now := time.Now()
+	t.UpdatedAt = now
+	t.DeletedAt = now
+
+	e := NewTenantDeletedEvent(t)
+	t.AddEvent(e)
+	return nil
+}
@@ -0,0 +1,75 @@
+package tenant
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenant(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewTenant("acme", "")
+	assert.ErrorIs(err, ErrNameEmpty)
+
+	acme, err := NewTenant("acme", "ACME")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.ErrorIs(acme.Update(""), ErrNameEmpty)
+	assert.NoError(acme.Update("ACME Corp."))
+	assert.Equal("ACME Corp.", acme.Name)
+
+	assert.NoError(acme.Delete())
+	assert.False(acme.DeletedAt.IsZero())
+
+	names := make([]string, 0)
+	for _, e := range acme.Events() {
+		names = append(names, e.EventName())
+	}
+
+	assert.Equal([]string{
+		TenantCreated.String(),
+		TenantUpdated.String(),
+		TenantDeleted.String(),
+	}, names)
+
+	assert.Equal("tenants.acme.updated", acme.Events()[1].Topic())
+	assert.Equal(TenantUpdated, ParseTopicName("updated"))
+
+	bs, err := json.Marshal(acme.Events()[1])
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	var e *TenantUpdatedEvent
+	if err := json.Unmarshal(bs, &e); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("ACME Corp.", e.TenantName)
+	assert.Equal(TenantID("acme"), e.TenantID)
+}
+
+func TestDefault(t *testing.T) {
+	assert := assert.New(t)
+
+	d, err := NewTenant(Default, "Default")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.ErrorIs(d.Delete(), ErrDefaultTenant)
+
+	_, err = ParseID("acme.*")
+	assert.ErrorIs(err, ErrInvalidTenantID)
+
+	_, err = ParseID("")
+	assert.ErrorIs(err, ErrInvalidTenantID)
+}
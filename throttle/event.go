@@ -0,0 +1,99 @@
+package throttle
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mirror520/identity/events"
+)
+
+type EventName int
+
+const (
+	Unknown EventName = iota
+	AttemptFailed
+	AttemptsReset
+)
+
+func ParseEventName(s string) EventName {
+	switch s {
+	case "attempt_failed":
+		return AttemptFailed
+	case "attempts_reset":
+		return AttemptsReset
+	default:
+		return Unknown
+	}
+}
+
+func (name EventName) String() string {
+	switch name {
+	case AttemptFailed:
+		return "attempt_failed"
+	case AttemptsReset:
+		return "attempts_reset"
+	default:
+		return ""
+	}
+}
+
+func (name EventName) MarshalJSON() ([]byte, error) {
+	return json.Marshal(name.String())
+}
+
+func (name *EventName) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	*name = ParseEventName(s)
+	return nil
+}
+
+type Event struct {
+	Domain    string    `json:"domain"`
+	Name      EventName `json:"name"`
+	Key       string    `json:"key"`
+	Origin    string    `json:"origin"` // instance
+	OccuredAt time.Time `json:"occured_at"`
+}
+
+func NewEvent(name EventName, key string, origin string, at time.Time) *Event {
+	return &Event{
+		Domain:    "identity:attempts",
+		Name:      name,
+		Key:       key,
+		Origin:    origin,
+		OccuredAt: at,
+	}
+}
+
+func (e *Event) EventName() string {
+	return e.Name.String()
+}
+
+// Topic leaves the key in the payload, an IP contains the token separator.
+func (e *Event) Topic() string {
+	return "attempts." + e.Name.String()
+}
+
+type AttemptFailedEvent struct {
+	*Event
+}
+
+func NewAttemptFailedEvent(key string, origin string, at time.Time) events.DomainEvent {
+	return &AttemptFailedEvent{
+		Event: NewEvent(AttemptFailed, key, origin, at),
+	}
+}
+
+type AttemptsResetEvent struct {
+	*Event
+}
+
+func NewAttemptsResetEvent(key string, origin string, at time.Time) events.DomainEvent {
+	return &AttemptsResetEvent{
+		Event: NewEvent(AttemptsReset, key, origin, at),
+	}
+}
@@ -0,0 +1,77 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+type Store interface {
+	Add(key string, at time.Time) error
+	Failures(key string, since time.Time) ([]time.Time, error)
+	Reset(key string) error
+}
+
+type inMemStore struct {
+	failures map[string][]time.Time // map[key][]time.Time
+	sync.Mutex
+}
+
+func NewInMemStore() Store {
+	return &inMemStore{
+		failures: make(map[string][]time.Time),
+	}
+}
+
+func (s *inMemStore) Add(key string, at time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	failures := s.failures[key]
+
+	// keep them in order, the replayed failures may arrive late
+	i := len(failures)
+	for i > 0 && failures[i-1].After(at) {
+		i--
+	}
+
+	failures = append(failures, time.Time{})
+	copy(failures[i+1:], failures[i:])
+	failures[i] = at
+
+	s.failures[key] = failures
+	return nil
+}
+
+// Failures returns the failures since the given time and drops the older ones.
+func (s *inMemStore) Failures(key string, since time.Time) ([]time.Time, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	failures := s.failures[key]
+
+	i := 0
+	for i < len(failures) && failures[i].Before(since) {
+		i++
+	}
+
+	failures = failures[i:]
+	if len(failures) == 0 {
+		delete(s.failures, key)
+		return nil, nil
+	}
+
+	s.failures[key] = failures
+
+	result := make([]time.Time, len(failures))
+	copy(result, failures)
+
+	return result, nil
+}
+
+func (s *inMemStore) Reset(key string) error {
+	s.Lock()
+	delete(s.failures, key)
+	s.Unlock()
+
+	return nil
+}
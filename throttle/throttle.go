@@ -0,0 +1,151 @@
+package throttle
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/user"
+)
+
+var ErrTooManyAttempts = errors.New("too many attempts")
+
+type BackoffError struct {
+	RetryAfter time.Duration
+}
+
+func (e *BackoffError) Error() string {
+	return ErrTooManyAttempts.Error() + ", retry after " + e.RetryAfter.Round(time.Second).String()
+}
+
+func (e *BackoffError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+func UserKey(id user.UserID) string {
+	return "user:" + id.String()
+}
+
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// SignInKey is the key of a user failing to sign in from any client IP,
+// the user backs off but is never locked by it.
+func SignInKey(id user.UserID) string {
+	return "signin:" + id.String()
+}
+
+// IPUserKey is the key of a user failing from a client IP.
+func IPUserKey(ip string, id user.UserID) string {
+	return "ip:" + ip + ":user:" + id.String()
+}
+
+// Tracker counts the failed attempts of each key within a sliding window.
+// The failures are broadcast on the event bus, so that every instance
+// backs off the same keys.
+type Tracker struct {
+	store  Store
+	cfg    conf.Throttle
+	origin string
+}
+
+func NewTracker(store Store, cfg conf.Throttle, origin string) *Tracker {
+	return &Tracker{
+		store:  store,
+		cfg:    cfg,
+		origin: origin,
+	}
+}
+
+// Check returns a BackoffError until the backoff of the last failure elapsed.
+func (t *Tracker) Check(key string, at time.Time) error {
+	failures, err := t.store.Failures(key, at.Add(-t.cfg.Window))
+	if err != nil {
+		return err
+	}
+
+	n := len(failures)
+	if n < t.cfg.Threshold || n == 0 {
+		return nil
+	}
+
+	next := failures[n-1].Add(t.backoff(n))
+	if at.Before(next) {
+		return &BackoffError{RetryAfter: next.Sub(at)}
+	}
+
+	return nil
+}
+
+// backoff doubles the base delay on every failure beyond the threshold.
+func (t *Tracker) backoff(n int) time.Duration {
+	delay := t.cfg.Backoff.Base
+	for i := t.cfg.Threshold; i < n; i++ {
+		delay *= 2
+		if delay >= t.cfg.Backoff.Max {
+			return t.cfg.Backoff.Max
+		}
+	}
+
+	return delay
+}
+
+// Fail records a failure and returns the number of failures within the window.
+func (t *Tracker) Fail(key string, at time.Time) (int, error) {
+	if err := t.store.Add(key, at); err != nil {
+		return 0, err
+	}
+
+	t.notify(NewAttemptFailedEvent(key, t.origin, at))
+
+	failures, err := t.store.Failures(key, at.Add(-t.cfg.Window))
+	if err != nil {
+		return 0, err
+	}
+
+	return len(failures), nil
+}
+
+func (t *Tracker) Reset(key string) error {
+	if err := t.store.Reset(key); err != nil {
+		return err
+	}
+
+	t.notify(NewAttemptsResetEvent(key, t.origin, time.Now()))
+	return nil
+}
+
+// Exceeded reports whether the failures of a user reach the lockout limit.
+func (t *Tracker) Exceeded(n int) bool {
+	return t.cfg.LockAfter > 0 && n >= t.cfg.LockAfter
+}
+
+func (t *Tracker) notify(e events.DomainEvent) {
+	s := events.NewEventStore()
+	s.AddEvent(e)
+	s.Notify() // best effort, the local store is already updated
+}
+
+// Apply replays the failures recorded by the other instances.
+func (t *Tracker) Apply(e events.DomainEvent) error {
+	switch e := e.(type) {
+	case *AttemptFailedEvent:
+		if e.Origin == t.origin {
+			return nil
+		}
+
+		return t.store.Add(e.Key, e.OccuredAt)
+
+	case *AttemptsResetEvent:
+		if e.Origin == t.origin {
+			return nil
+		}
+
+		return t.store.Reset(e.Key)
+
+	default:
+		return errors.New("invalid event")
+	}
+}
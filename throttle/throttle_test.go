@@ -0,0 +1,102 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/user"
+)
+
+func TestBackoff(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := conf.Throttle{
+		Window:    time.Hour,
+		Threshold: 2,
+		Backoff: conf.Backoff{
+			Base: time.Second,
+			Max:  5 * time.Second,
+		},
+		LockAfter: 5,
+	}
+
+	tracker := NewTracker(NewInMemStore(), cfg, "test")
+	now := time.Now()
+	key := IPKey("192.0.2.1")
+
+	n, _ := tracker.Fail(key, now)
+	assert.Equal(1, n)
+	assert.NoError(tracker.Check(key, now))
+
+	n, _ = tracker.Fail(key, now)
+	assert.Equal(2, n)
+
+	err := tracker.Check(key, now)
+	assert.ErrorIs(err, ErrTooManyAttempts)
+
+	var backoff *BackoffError
+	if assert.ErrorAs(err, &backoff) {
+		assert.Equal(time.Second, backoff.RetryAfter)
+	}
+
+	assert.NoError(tracker.Check(key, now.Add(time.Second)))
+
+	tracker.Fail(key, now)
+	tracker.Fail(key, now)
+	assert.NoError(tracker.Check(key, now.Add(4*time.Second)))
+
+	n, _ = tracker.Fail(key, now)
+	assert.True(tracker.Exceeded(n))
+	assert.ErrorIs(tracker.Check(key, now.Add(4*time.Second)), ErrTooManyAttempts)
+	assert.NoError(tracker.Check(key, now.Add(5*time.Second))) // capped
+
+	// slide out of the window
+	assert.NoError(tracker.Check(key, now.Add(2*time.Hour)))
+
+	assert.NoError(tracker.Reset(key))
+	n, _ = tracker.Fail(key, now)
+	assert.Equal(1, n)
+}
+
+func TestApply(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := conf.Throttle{
+		Window:    time.Hour,
+		Threshold: 1,
+		Backoff: conf.Backoff{
+			Base: time.Minute,
+			Max:  time.Hour,
+		},
+	}
+
+	store := NewInMemStore()
+	tracker := NewTracker(store, cfg, "instance01")
+	now := time.Now()
+	key := UserKey(user.MakeID())
+
+	// recorded by itself
+	e := NewAttemptFailedEvent(key, "instance01", now)
+	assert.NoError(tracker.Apply(e))
+	assert.NoError(tracker.Check(key, now))
+
+	// recorded by the others
+	e = NewAttemptFailedEvent(key, "instance02", now)
+	assert.NoError(tracker.Apply(e))
+	assert.ErrorIs(tracker.Check(key, now), ErrTooManyAttempts)
+
+	// arrived late
+	e = NewAttemptFailedEvent(key, "instance02", now.Add(-time.Minute))
+	assert.NoError(tracker.Apply(e))
+
+	failures, _ := store.Failures(key, now.Add(-time.Hour))
+	assert.Len(failures, 2)
+	assert.Equal(now, failures[1])
+
+	e = NewAttemptsResetEvent(key, "instance02", now)
+	assert.NoError(tracker.Apply(e))
+	assert.NoError(tracker.Check(key, now))
+}
@@ -0,0 +1,204 @@
+package token
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/mirror520/identity/events"
+)
+
+type EventName int
+
+const (
+	Unknown EventName = iota
+	FamilyIssued
+	FamilyRotated
+	FamilyRevoked
+	TokenDenied
+	TokenExchanged
+)
+
+func ParseEventName(s string) EventName {
+	switch s {
+	case "family_issued":
+		return FamilyIssued
+	case "family_rotated":
+		return FamilyRotated
+	case "family_revoked":
+		return FamilyRevoked
+	case "token_denied":
+		return TokenDenied
+	case "token_exchanged":
+		return TokenExchanged
+	default:
+		return Unknown
+	}
+}
+
+func (name EventName) String() string {
+	switch name {
+	case FamilyIssued:
+		return "family_issued"
+	case FamilyRotated:
+		return "family_rotated"
+	case FamilyRevoked:
+		return "family_revoked"
+	case TokenDenied:
+		return "token_denied"
+	case TokenExchanged:
+		return "token_exchanged"
+	default:
+		return ""
+	}
+}
+
+func (name EventName) MarshalJSON() ([]byte, error) {
+	return json.Marshal(name.String())
+}
+
+func (name *EventName) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	*name = ParseEventName(s)
+	return nil
+}
+
+type Event struct {
+	Domain    string    `json:"domain"`
+	Name      EventName `json:"name"`
+	FamilyID  FamilyID  `json:"family_id"` // AggreagateRoot
+	OccuredAt time.Time `json:"occured_at"`
+}
+
+func NewEvent(name EventName, f *Family) *Event {
+	return &Event{
+		Domain:    "identity:tokens",
+		Name:      name,
+		FamilyID:  f.ID,
+		OccuredAt: f.UpdatedAt,
+	}
+}
+
+func (e *Event) EventName() string {
+	return e.Name.String()
+}
+
+func (e *Event) Topic() string {
+	return "tokens." + e.FamilyID.String() + "." + e.Name.TopicName()
+}
+
+// TopicName is the last token of the topic, "tokens.<id>.<name>".
+func (name EventName) TopicName() string {
+	s := name.String()
+	if name == TokenDenied || name == TokenExchanged {
+		return strings.TrimPrefix(s, "token_")
+	}
+
+	return strings.TrimPrefix(s, "family_")
+}
+
+func ParseTopicName(s string) EventName {
+	switch s {
+	case TokenDenied.TopicName():
+		return TokenDenied
+	case TokenExchanged.TopicName():
+		return TokenExchanged
+	}
+
+	return ParseEventName("family_" + s)
+}
+
+type FamilyIssuedEvent struct {
+	*Event
+	Family *Family `json:"family"`
+}
+
+func NewFamilyIssuedEvent(f *Family) events.DomainEvent {
+	return &FamilyIssuedEvent{
+		Event:  NewEvent(FamilyIssued, f),
+		Family: f,
+	}
+}
+
+type FamilyRotatedEvent struct {
+	*Event
+	Hash string `json:"hash"`
+}
+
+func NewFamilyRotatedEvent(f *Family, hash string) events.DomainEvent {
+	return &FamilyRotatedEvent{
+		Event: NewEvent(FamilyRotated, f),
+		Hash:  hash,
+	}
+}
+
+type FamilyRevokedEvent struct {
+	*Event
+	Reason string `json:"reason"`
+}
+
+func NewFamilyRevokedEvent(f *Family, reason string) events.DomainEvent {
+	return &FamilyRevokedEvent{
+		Event:  NewEvent(FamilyRevoked, f),
+		Reason: reason,
+	}
+}
+
+// TokenDeniedEvent denies the access token until it expires.
+type TokenDeniedEvent struct {
+	Domain    string    `json:"domain"`
+	Name      EventName `json:"name"`
+	TokenID   string    `json:"jti"` // AggregateRoot
+	ExpiredAt time.Time `json:"expired_at"`
+	OccuredAt time.Time `json:"occured_at"`
+}
+
+func NewTokenDeniedEvent(r *Revocation) events.DomainEvent {
+	return &TokenDeniedEvent{
+		Domain:    "identity:tokens",
+		Name:      TokenDenied,
+		TokenID:   r.ID,
+		ExpiredAt: r.ExpiredAt,
+		OccuredAt: r.RevokedAt,
+	}
+}
+
+func (e *TokenDeniedEvent) EventName() string {
+	return e.Name.String()
+}
+
+func (e *TokenDeniedEvent) Topic() string {
+	return "tokens." + e.TokenID + "." + e.Name.TopicName()
+}
+
+// TokenExchangedEvent is the audit trail of a token exchange, kept by the
+// stream rather than the repositories.
+type TokenExchangedEvent struct {
+	Domain    string    `json:"domain"`
+	Name      EventName `json:"name"`
+	TokenID   string    `json:"jti"` // AggregateRoot
+	Exchange  *Exchange `json:"exchange"`
+	OccuredAt time.Time `json:"occured_at"`
+}
+
+func NewTokenExchangedEvent(x *Exchange) events.DomainEvent {
+	return &TokenExchangedEvent{
+		Domain:    "identity:tokens",
+		Name:      TokenExchanged,
+		TokenID:   x.ID,
+		Exchange:  x,
+		OccuredAt: x.ExchangedAt,
+	}
+}
+
+func (e *TokenExchangedEvent) EventName() string {
+	return e.Name.String()
+}
+
+func (e *TokenExchangedEvent) Topic() string {
+	return "tokens." + e.TokenID + "." + e.Name.TopicName()
+}
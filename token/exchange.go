@@ -0,0 +1,117 @@
+package token
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/verifier"
+)
+
+// the token types of RFC 8693, only the access tokens are exchanged
+const (
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeJWT         = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+var (
+	ErrUnsupportedTokenType = errors.New("token type not supported")
+	ErrSubjectTokenNotFound = errors.New("subject_token not found")
+	ErrImpersonationDenied  = errors.New("impersonation denied")
+	ErrSubjectTokenAudience = errors.New("subject_token not issued to the client")
+)
+
+// ExchangeRequest is the token exchange request of RFC 8693 §2.1.
+type ExchangeRequest struct {
+	SubjectToken       string
+	SubjectTokenType   string
+	ActorToken         string
+	ActorTokenType     string
+	RequestedTokenType string
+	Audience           []string
+	Scope              []string
+}
+
+func (req *ExchangeRequest) Validate() error {
+	if req.SubjectToken == "" {
+		return ErrSubjectTokenNotFound
+	}
+
+	if !accessTokenType(req.SubjectTokenType) {
+		return ErrUnsupportedTokenType
+	}
+
+	if req.ActorToken != "" && !accessTokenType(req.ActorTokenType) {
+		return ErrUnsupportedTokenType
+	}
+
+	if req.RequestedTokenType != "" && req.RequestedTokenType != TokenTypeAccessToken {
+		return ErrUnsupportedTokenType
+	}
+
+	return nil
+}
+
+func accessTokenType(typ string) bool {
+	return typ == TokenTypeAccessToken || typ == TokenTypeJWT
+}
+
+// Exchange is an access token minted from the subject token for the
+// actor, it never outlives the subject token nor widens its scope.
+type Exchange struct {
+	ID          string          `json:"jti"`
+	ClientID    string          `json:"client_id"`
+	Subject     string          `json:"sub"`
+	TenantID    tenant.TenantID `json:"tenant_id,omitempty"`
+	TokenUse    string          `json:"token_use"`
+	Roles       []string        `json:"roles"`
+	Audience    []string        `json:"aud"`
+	Scope       []string        `json:"scope"`
+	Actor       *verifier.Actor `json:"act"`
+	ExpiredAt   time.Time       `json:"expired_at"`
+	ExchangedAt time.Time       `json:"exchanged_at"`
+
+	events.EventStore `json:"-"`
+}
+
+// NewExchange chains the actor to the actors of the subject token, the
+// roles are the ones of the subject held by the actor as well.
+func NewExchange(clientID string, subject *verifier.Claims, actor *verifier.Actor, roles []string, audience []string, scope []string, ttl time.Duration) *Exchange {
+	now := time.Now()
+
+	expiredAt := now.Add(ttl)
+	if subject.ExpiresAt != nil && subject.ExpiresAt.Time.Before(expiredAt) {
+		expiredAt = subject.ExpiresAt.Time
+	}
+
+	tokenUse := verifier.TokenUseUser
+	if subject.Service() {
+		tokenUse = verifier.TokenUseService
+	}
+
+	act := *actor
+	act.Act = subject.Act
+
+	x := &Exchange{
+		ID:          ulid.Make().String(),
+		ClientID:    clientID,
+		Subject:     subject.Subject,
+		TenantID:    subject.Tenant(),
+		TokenUse:    tokenUse,
+		Roles:       slices.Clone(roles),
+		Audience:    slices.Clone(audience),
+		Scope:       slices.Clone(scope),
+		Actor:       &act,
+		ExpiredAt:   expiredAt,
+		ExchangedAt: now,
+
+		EventStore: events.NewEventStore(),
+	}
+
+	x.AddEvent(NewTokenExchangedEvent(x))
+	return x
+}
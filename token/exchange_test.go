@@ -0,0 +1,72 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mirror520/identity/verifier"
+)
+
+func TestNewExchange(t *testing.T) {
+	assert := assert.New(t)
+
+	expiredAt := time.Now().Add(5 * time.Minute)
+	subject := &verifier.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "01HZX3F6Q8V9K2M4N5P6R7S8T9",
+			ExpiresAt: jwt.NewNumericDate(expiredAt),
+		},
+		Roles:    []string{"user"},
+		TokenUse: verifier.TokenUseUser,
+		Act: &verifier.Actor{
+			Subject:  "gateway",
+			TokenUse: verifier.TokenUseService,
+		},
+	}
+
+	actor := &verifier.Actor{
+		Subject:  "01HZX3F6Q8V9K2M4N5P6R7S8TA",
+		TokenUse: verifier.TokenUseUser,
+	}
+
+	x := NewExchange("support", subject, actor, []string{"user"}, []string{"support"}, []string{"profile"}, time.Hour)
+
+	// never outlives the subject token
+	assert.Equal(expiredAt.Unix(), x.ExpiredAt.Unix())
+	assert.Equal(subject.Subject, x.Subject)
+	assert.Equal(verifier.TokenUseUser, x.TokenUse)
+	assert.Equal([]string{"user"}, x.Roles)
+
+	// the actor is chained to the actors of the subject token
+	assert.Equal(actor.Subject, x.Actor.Subject)
+	assert.Equal("gateway", x.Actor.Act.Subject)
+	assert.Nil(actor.Act)
+
+	assert.Equal(TokenExchanged.String(), x.Events()[0].EventName())
+	assert.Equal("tokens."+x.ID+".exchanged", x.Events()[0].Topic())
+	assert.Equal(TokenExchanged, ParseTopicName("exchanged"))
+}
+
+func TestValidateExchangeRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	req := &ExchangeRequest{SubjectTokenType: TokenTypeAccessToken}
+	assert.ErrorIs(req.Validate(), ErrSubjectTokenNotFound)
+
+	req.SubjectToken = "token"
+	assert.NoError(req.Validate())
+
+	req.SubjectTokenType = "urn:ietf:params:oauth:token-type:id_token"
+	assert.ErrorIs(req.Validate(), ErrUnsupportedTokenType)
+
+	req.SubjectTokenType = TokenTypeJWT
+	req.ActorToken = "token"
+	assert.ErrorIs(req.Validate(), ErrUnsupportedTokenType)
+
+	req.ActorTokenType = TokenTypeAccessToken
+	req.RequestedTokenType = "urn:ietf:params:oauth:token-type:refresh_token"
+	assert.ErrorIs(req.Validate(), ErrUnsupportedTokenType)
+}
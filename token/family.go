@@ -0,0 +1,279 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/model"
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/user"
+)
+
+var (
+	ErrFamilyNotFound = errors.New("token family not found")
+	ErrFamilyRevoked  = errors.New("token family revoked")
+	ErrInvalidToken   = errors.New("invalid refresh token")
+	ErrTokenExpired   = errors.New("refresh token expired")
+	ErrTokenReused    = errors.New("refresh token reused")
+)
+
+type Status int
+
+const (
+	Active Status = iota
+	Revoked
+)
+
+func ParseStatus(status string) (Status, error) {
+	switch strings.ToLower(status) {
+	case "active":
+		return Active, nil
+	case "revoked":
+		return Revoked, nil
+	default:
+		return -1, errors.New("invalid status")
+	}
+}
+
+func (s Status) String() string {
+	switch s {
+	case Active:
+		return "active"
+	case Revoked:
+		return "revoked"
+	default:
+		return "unknown"
+	}
+}
+
+func (s *Status) MarshalJSON() ([]byte, error) {
+	jsonStr := `"` + s.String() + `"`
+	return []byte(jsonStr), nil
+}
+
+func (s *Status) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	status, err := ParseStatus(raw)
+	if err != nil {
+		return err
+	}
+
+	*s = status
+	return nil
+}
+
+type FamilyID ulid.ULID // AggregateRoot
+
+func MakeID() FamilyID {
+	return FamilyID(ulid.Make())
+}
+
+func ParseID(id string) (FamilyID, error) {
+	familyID, err := ulid.Parse(id)
+	if err != nil {
+		return FamilyID{}, err
+	}
+	return FamilyID(familyID), nil
+}
+
+func (id FamilyID) Bytes() []byte {
+	return id[:]
+}
+
+func (id FamilyID) String() string {
+	return ulid.ULID(id).String()
+}
+
+func (id *FamilyID) MarshalJSON() ([]byte, error) {
+	jsonStr := `"` + id.String() + `"`
+	return []byte(jsonStr), nil
+}
+
+func (id *FamilyID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	familyID, err := ParseID(s)
+	if err != nil {
+		return err
+	}
+
+	*id = familyID
+	return nil
+}
+
+// Grant is what the sign-in granted, the refresh tokens of the family
+// renew the same grant. The client is empty for the first party.
+type Grant struct {
+	ClientID string    `json:"client_id,omitempty"`
+	Scope    []string  `json:"scope,omitempty"`
+	AuthTime time.Time `json:"auth_time"`
+	AMR      []string  `json:"amr,omitempty"`
+}
+
+// Family chains the refresh tokens rotated from the same sign-in, only
+// the hashes are kept.
+type Family struct {
+	ID        FamilyID        `json:"id"`
+	UserID    user.UserID     `json:"user_id"`
+	TenantID  tenant.TenantID `json:"tenant_id"` // signed in to
+	Hash      string          `json:"hash"`      // current refresh token
+	Used      []string        `json:"used"`      // rotated refresh tokens
+	Status    Status          `json:"status"`
+	Reason    string          `json:"reason,omitempty"`
+	ExpiredAt time.Time       `json:"expired_at"`
+
+	Grant
+
+	model.Model
+
+	events.EventStore `json:"-"`
+}
+
+// Issue starts a family and returns its first refresh token, the tokens
+// are refreshed in the tenant signed in to.
+func Issue(userID user.UserID, tenantID tenant.TenantID, grant Grant, ttl time.Duration) (*Family, string, error) {
+	id := MakeID()
+
+	tokenStr, err := generate(id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	f := &Family{
+		ID:        id,
+		UserID:    userID,
+		TenantID:  tenantID,
+		Hash:      Hash(tokenStr),
+		Used:      make([]string, 0),
+		Status:    Active,
+		ExpiredAt: now.Add(ttl),
+		Grant:     grant,
+		Model: model.Model{
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+
+		EventStore: events.NewEventStore(),
+	}
+
+	f.AddEvent(NewFamilyIssuedEvent(f))
+	return f, tokenStr, nil
+}
+
+// Rotate exchanges the current refresh token for the next one, a rotated
+// token presented again revokes the whole family.
+func (f *Family) Rotate(tokenStr string) (string, error) {
+	if f.Status == Revoked {
+		return "", ErrFamilyRevoked
+	}
+
+	if time.Now().After(f.ExpiredAt) {
+		return "", ErrTokenExpired
+	}
+
+	hash := Hash(tokenStr)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(f.Hash)) != 1 {
+		for _, used := range f.Used {
+			if subtle.ConstantTimeCompare([]byte(hash), []byte(used)) == 1 {
+				f.Revoke("refresh token reused")
+				return "", ErrTokenReused
+			}
+		}
+
+		return "", ErrInvalidToken
+	}
+
+	next, err := generate(f.ID)
+	if err != nil {
+		return "", err
+	}
+
+	f.Used = append(f.Used, f.Hash)
+	f.Hash = Hash(next)
+	f.UpdatedAt = time.Now()
+
+	e := NewFamilyRotatedEvent(f, f.Hash)
+	f.AddEvent(e)
+
+	return next, nil
+}
+
+func (f *Family) Revoke(reason string) error {
+	if f.Status == Revoked {
+		return ErrFamilyRevoked
+	}
+
+	f.Status = Revoked
+	f.Reason = reason
+	f.UpdatedAt = time.Now()
+
+	e := NewFamilyRevokedEvent(f, reason)
+	f.AddEvent(e)
+
+	return nil
+}
+
+// Owns tells whether the refresh token was ever issued in the family.
+func (f *Family) Owns(tokenStr string) bool {
+	hash := Hash(tokenStr)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(f.Hash)) == 1 {
+		return true
+	}
+
+	for _, used := range f.Used {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(used)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// generate returns "<family id>.<secret>", the family is found without
+// storing the token itself.
+func generate(id FamilyID) (string, error) {
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+
+	return id.String() + "." + base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+// Parse returns the family of the refresh token.
+func Parse(tokenStr string) (FamilyID, error) {
+	id, _, ok := strings.Cut(tokenStr, ".")
+	if !ok {
+		return FamilyID{}, ErrInvalidToken
+	}
+
+	familyID, err := ParseID(id)
+	if err != nil {
+		return FamilyID{}, ErrInvalidToken
+	}
+
+	return familyID, nil
+}
+
+func Hash(tokenStr string) string {
+	sum := sha256.Sum256([]byte(tokenStr))
+	return hex.EncodeToString(sum[:])
+}
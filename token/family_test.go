@@ -0,0 +1,85 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/user"
+)
+
+func TestRotate(t *testing.T) {
+	assert := assert.New(t)
+
+	f, first, err := Issue(user.MakeID(), tenant.Default, Grant{}, time.Hour)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	id, err := Parse(first)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal(f.ID, id)
+
+	second, err := f.Rotate(first)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.NotEqual(first, second)
+	assert.Equal(Hash(second), f.Hash)
+	assert.Equal([]string{Hash(first)}, f.Used)
+
+	_, err = f.Rotate("invalid")
+	assert.ErrorIs(err, ErrInvalidToken)
+	assert.Equal(Active, f.Status)
+
+	// the rotated token is presented again
+	_, err = f.Rotate(first)
+	assert.ErrorIs(err, ErrTokenReused)
+	assert.Equal(Revoked, f.Status)
+
+	_, err = f.Rotate(second)
+	assert.ErrorIs(err, ErrFamilyRevoked)
+
+	names := make([]string, 0)
+	for _, e := range f.Events() {
+		names = append(names, e.EventName())
+	}
+
+	assert.Equal([]string{
+		FamilyIssued.String(),
+		FamilyRotated.String(),
+		FamilyRevoked.String(),
+	}, names)
+}
+
+func TestRotateExpired(t *testing.T) {
+	assert := assert.New(t)
+
+	f, tokenStr, err := Issue(user.MakeID(), tenant.Default, Grant{}, -time.Second)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	_, err = f.Rotate(tokenStr)
+	assert.ErrorIs(err, ErrTokenExpired)
+}
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Parse("invalid")
+	assert.ErrorIs(err, ErrInvalidToken)
+
+	_, err = Parse("invalid.secret")
+	assert.ErrorIs(err, ErrInvalidToken)
+}
@@ -0,0 +1,39 @@
+package token
+
+import (
+	"time"
+
+	"github.com/mirror520/identity/user"
+)
+
+type Repository interface {
+	// Command
+
+	Store(f *Family) error
+
+	// Rotate stores the rotated family only if its current refresh token
+	// is still the previous one, or returns ErrTokenReused.
+	Rotate(f *Family, previous string) error
+
+	// Purge removes the families of the purged users for good
+	Purge(users []user.UserID) error
+
+	// Query
+
+	Find(id FamilyID) (*Family, error)
+
+	Close() error
+}
+
+type RevocationRepository interface {
+	// Command
+
+	Store(r *Revocation) error
+
+	// Query
+
+	// List returns the revocations not expired at the given time.
+	List(at time.Time) ([]*Revocation, error)
+
+	Close() error
+}
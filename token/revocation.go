@@ -0,0 +1,92 @@
+package token
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mirror520/identity/events"
+)
+
+// Revocation denies an access token by its jti, it is kept until the
+// token expires.
+type Revocation struct {
+	ID        string    `json:"jti"`
+	ExpiredAt time.Time `json:"expired_at"`
+	RevokedAt time.Time `json:"revoked_at"`
+
+	events.EventStore `json:"-"`
+}
+
+func NewRevocation(jti string, expiredAt time.Time) *Revocation {
+	r := &Revocation{
+		ID:        jti,
+		ExpiredAt: expiredAt,
+		RevokedAt: time.Now(),
+
+		EventStore: events.NewEventStore(),
+	}
+
+	r.AddEvent(NewTokenDeniedEvent(r))
+	return r
+}
+
+func (r *Revocation) Expired(at time.Time) bool {
+	return !at.Before(r.ExpiredAt)
+}
+
+// Denylist caches the revocations in memory, so that parsing a token
+// never reads the repository.
+type Denylist struct {
+	repo RevocationRepository
+	jtis map[string]time.Time // map[jti]ExpiredAt
+	sync.RWMutex
+}
+
+func NewDenylist(repo RevocationRepository) (*Denylist, error) {
+	revocations, err := repo.List(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Denylist{
+		repo: repo,
+		jtis: make(map[string]time.Time),
+	}
+
+	for _, r := range revocations {
+		l.jtis[r.ID] = r.ExpiredAt
+	}
+
+	return l, nil
+}
+
+func (l *Denylist) Deny(r *Revocation) error {
+	if err := l.repo.Store(r); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	l.Lock()
+	for jti, expiredAt := range l.jtis {
+		if !now.Before(expiredAt) {
+			delete(l.jtis, jti)
+		}
+	}
+	l.jtis[r.ID] = r.ExpiredAt
+	l.Unlock()
+
+	return nil
+}
+
+func (l *Denylist) Denied(jti string, at time.Time) bool {
+	l.RLock()
+	defer l.RUnlock()
+
+	expiredAt, ok := l.jtis[jti]
+	if !ok {
+		return false
+	}
+
+	return at.Before(expiredAt)
+}
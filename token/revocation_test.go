@@ -0,0 +1,72 @@
+package token
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type revocationRepository struct {
+	revocations []*Revocation
+	sync.Mutex
+}
+
+func (repo *revocationRepository) Store(r *Revocation) error {
+	repo.Lock()
+	repo.revocations = append(repo.revocations, r)
+	repo.Unlock()
+	return nil
+}
+
+func (repo *revocationRepository) List(at time.Time) ([]*Revocation, error) {
+	repo.Lock()
+	defer repo.Unlock()
+
+	revocations := make([]*Revocation, 0)
+	for _, r := range repo.revocations {
+		if !r.Expired(at) {
+			revocations = append(revocations, r)
+		}
+	}
+
+	return revocations, nil
+}
+
+func (repo *revocationRepository) Close() error {
+	return nil
+}
+
+func TestDenylist(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	repo := &revocationRepository{
+		revocations: []*Revocation{
+			{ID: "stored", ExpiredAt: now.Add(time.Hour)},
+			{ID: "expired", ExpiredAt: now.Add(-time.Hour)},
+		},
+	}
+
+	l, err := NewDenylist(repo)
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.True(l.Denied("stored", now))
+	assert.False(l.Denied("expired", now))
+
+	r := NewRevocation("jti", now.Add(time.Minute))
+	assert.Equal("tokens.jti.denied", r.Events()[0].Topic())
+
+	if err := l.Deny(r); err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.True(l.Denied("jti", now))
+	assert.False(l.Denied("jti", now.Add(time.Minute)))
+	assert.Len(repo.revocations, 3)
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/mirror520/identity"
+	"github.com/mirror520/identity/client"
+	"github.com/mirror520/identity/model"
+)
+
+// ClientRequest registers or updates a client, the type can't be changed
+// once registered.
+type ClientRequest struct {
+	Type string `json:"type"`
+	client.Settings
+}
+
+func RegisterClientHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req ClientRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		typ := client.Confidential
+		if req.Type != "" {
+			t, err := client.ParseType(req.Type)
+			if err != nil {
+				result := model.FailureResult(err)
+				ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+				return
+			}
+
+			typ = t
+		}
+
+		resp, err := endpoint(ctx, identity.RegisterClientRequest{
+			Type:     typ,
+			Settings: req.Settings,
+		})
+
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(clientStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("client registered")
+		result.Data = resp
+		ctx.JSON(http.StatusCreated, result)
+	}
+}
+
+func UpdateClientHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := client.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		var req ClientRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, identity.UpdateClientRequest{
+			ClientID: id,
+			Settings: req.Settings,
+		})
+
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(clientStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("client updated")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func RotateClientSecretHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return clientHandler(endpoint, "client secret rotated")
+}
+
+func DeleteClientHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return clientHandler(endpoint, "client deleted")
+}
+
+func FindClientHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return clientHandler(endpoint, "client found")
+}
+
+func ListClientsHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		resp, err := endpoint(ctx, nil)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, result)
+			return
+		}
+
+		result := model.SuccessResult("clients found")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// clientHandler serves the requests which take nothing but the client ID.
+func clientHandler(endpoint endpoint.Endpoint, msg string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := client.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, id)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(clientStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult(msg)
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func clientStatusCode(err error) int {
+	switch {
+	case errors.Is(err, client.ErrClientNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, client.ErrPublicClient):
+		return http.StatusConflict
+	case errors.Is(err, client.ErrNameEmpty),
+		errors.Is(err, client.ErrInvalidRedirectURI),
+		errors.Is(err, client.ErrGrantTypeUnsupported):
+		return http.StatusBadRequest
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
@@ -0,0 +1,323 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/mirror520/identity"
+	"github.com/mirror520/identity/group"
+	"github.com/mirror520/identity/model"
+	"github.com/mirror520/identity/user"
+)
+
+// GroupRequest creates or updates a group, an empty parent makes a root
+// group.
+type GroupRequest struct {
+	Name   string `json:"name"`
+	Parent string `json:"parent"`
+}
+
+func CreateGroupHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		req, err := groupRequest(ctx)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, req)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(groupStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("group created")
+		result.Data = resp
+		ctx.JSON(http.StatusCreated, result)
+	}
+}
+
+func UpdateGroupHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := group.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		req, err := groupRequest(ctx)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+		req.GroupID = id
+
+		resp, err := endpoint(ctx, req)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(groupStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("group updated")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func groupRequest(ctx *gin.Context) (identity.GroupRequest, error) {
+	var req GroupRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		return identity.GroupRequest{}, err
+	}
+
+	var parent group.GroupID
+	if req.Parent != "" {
+		id, err := group.ParseID(req.Parent)
+		if err != nil {
+			return identity.GroupRequest{}, err
+		}
+
+		parent = id
+	}
+
+	return identity.GroupRequest{
+		Name:   req.Name,
+		Parent: parent,
+	}, nil
+}
+
+func DeleteGroupHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return groupHandler(endpoint, "group deleted")
+}
+
+func FindGroupHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return groupHandler(endpoint, "group found")
+}
+
+func ListGroupsHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		resp, err := endpoint(ctx, nil)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, result)
+			return
+		}
+
+		result := model.SuccessResult("groups found")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// groupHandler serves the requests which take nothing but the group ID.
+func groupHandler(endpoint endpoint.Endpoint, msg string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := group.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, id)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(groupStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult(msg)
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func AddGroupMemberHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := group.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		var req struct {
+			UserID string `json:"user_id"`
+		}
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		userID, err := user.ParseID(req.UserID)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, identity.GroupMemberRequest{
+			GroupID: id,
+			UserID:  userID,
+		})
+
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(groupStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("group member added")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func RemoveGroupMemberHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := group.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		userID, err := user.ParseID(ctx.Param("user"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, identity.GroupMemberRequest{
+			GroupID: id,
+			UserID:  userID,
+		})
+
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(groupStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("group member removed")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func GrantGroupRoleHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := group.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		var req struct {
+			Role string `json:"role"`
+		}
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, identity.GroupRoleRequest{
+			GroupID: id,
+			Role:    req.Role,
+		})
+
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(groupStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("group role granted")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func RevokeGroupRoleHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := group.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, identity.GroupRoleRequest{
+			GroupID: id,
+			Role:    ctx.Param("role"),
+		})
+
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(groupStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("group role revoked")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func MembershipsHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := user.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, id)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, result)
+			return
+		}
+
+		result := model.SuccessResult("groups found")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func groupStatusCode(err error) int {
+	switch {
+	case errors.Is(err, group.ErrGroupNotFound),
+		errors.Is(err, user.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, group.ErrNameEmpty),
+		errors.Is(err, group.ErrParentNotFound),
+		errors.Is(err, user.ErrInvalidRole):
+		return http.StatusBadRequest
+	case errors.Is(err, group.ErrCyclicGroup),
+		errors.Is(err, group.ErrNestedTooDeep),
+		errors.Is(err, group.ErrHasSubgroups),
+		errors.Is(err, group.ErrMemberExists),
+		errors.Is(err, group.ErrMemberNotFound),
+		errors.Is(err, user.ErrRoleGranted),
+		errors.Is(err, user.ErrRoleNotGranted):
+		return http.StatusConflict
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
@@ -3,26 +3,21 @@ package http
 import (
 	"errors"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/go-kit/kit/endpoint"
 
+	"github.com/mirror520/identity"
+	"github.com/mirror520/identity/client"
+	"github.com/mirror520/identity/group"
 	"github.com/mirror520/identity/policy"
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/user"
+	"github.com/mirror520/identity/verifier"
 )
 
-type Claims struct {
-	jwt.RegisteredClaims
-	Roles []string `json:"roles"`
-}
-
-func (c *Claims) Map() map[string]any {
-	return map[string]any{
-		"sub":   c.Subject,
-		"roles": c.Roles,
-	}
-}
-
 type Who byte
 
 const (
@@ -31,11 +26,14 @@ const (
 	Others
 	Admin
 	All
+	Service // the clients granted by client credentials
 )
 
 type GinAuth func(rule string, who ...Who) gin.HandlerFunc
 
-func Authorizator(policy policy.Policy) GinAuth {
+// Authorizator evaluates the policy with the groups of the user as well, a
+// group grants its roles to the members and owns the objects of Group.
+func Authorizator(v *verifier.Verifier, policy policy.Policy, checkStatus endpoint.Endpoint, memberships endpoint.Endpoint, tenants endpoint.Endpoint) GinAuth {
 	return func(rule string, who ...Who) gin.HandlerFunc {
 		rules := strings.Split(rule, ".")
 		domain := rules[0]
@@ -47,12 +45,32 @@ func Authorizator(policy policy.Policy) GinAuth {
 		}
 
 		return func(ctx *gin.Context) {
-			var claims Claims
-			if err := ParseToken(ctx, &claims); err != nil {
+			claims, err := v.VerifyHeader(ctx.GetHeader("Authorization"))
+			if err != nil {
+				unauthorized(ctx, http.StatusUnauthorized, err)
+				return
+			}
+
+			// the roles of the user aren't narrowed by the grant, the
+			// relying parties never act as the user on the API
+			if claims.Granted() {
+				unauthorized(ctx, http.StatusUnauthorized, ErrGrantedToken)
+				return
+			}
+
+			ctx.Set(verifier.ClaimsKey, claims)
+
+			req, err := checkStatusRequest(claims)
+			if err != nil {
 				unauthorized(ctx, http.StatusUnauthorized, err)
 				return
 			}
 
+			if _, err := checkStatus(ctx, req); err != nil {
+				unauthorized(ctx, statusCode(err, http.StatusUnauthorized), err)
+				return
+			}
+
 			input := map[string]any{
 				"domain":    domain,
 				"action":    action,
@@ -60,8 +78,35 @@ func Authorizator(policy policy.Policy) GinAuth {
 				"claims":    claims.Map(),
 			}
 
+			if !claims.Service() {
+				groups, err := memberships(ctx, req.UserID)
+				if err != nil {
+					unauthorized(ctx, http.StatusExpectationFailed, err)
+					return
+				}
+
+				input["groups"] = groupsInput(groups, req.UserID)
+				input["tenant"] = claims.Tenant().String()
+			}
+
 			if id := ctx.Param("id"); id != "" {
 				input["object"] = id
+
+				if flags&byte(Group) > 0 {
+					owners, err := objectGroups(ctx, memberships, domain, id)
+					if err != nil {
+						unauthorized(ctx, http.StatusExpectationFailed, err)
+						return
+					}
+
+					input["object_groups"] = owners
+				}
+
+				if owners, ok := objectTenants(ctx, tenants, domain, id); ok {
+					input["object_tenants"] = owners
+				}
+			} else if id := ctx.Query("tenant_id"); id != "" {
+				input["object_tenants"] = []string{id}
 			}
 
 			allowed, err := policy.Eval(ctx, input)
@@ -79,3 +124,103 @@ func Authorizator(policy policy.Policy) GinAuth {
 		}
 	}
 }
+
+// groupsInput marks the groups the user is a direct member of, the
+// ancestors only grant their roles.
+func groupsInput(resp any, id user.UserID) []map[string]any {
+	groups, _ := resp.([]*group.Group)
+
+	input := make([]map[string]any, len(groups))
+	for i, g := range groups {
+		input[i] = map[string]any{
+			"id":     g.ID.String(),
+			"roles":  g.Roles,
+			"direct": slices.Contains(g.Members, id),
+		}
+	}
+
+	return input
+}
+
+// objectTenants returns the tenants of the object, only the tenants and
+// the users belong to tenants. An unknown user belongs to none.
+func objectTenants(ctx *gin.Context, tenants endpoint.Endpoint, domain string, object string) ([]string, bool) {
+	switch domain {
+	case "identity::tenants":
+		return []string{object}, true
+
+	case "identity::users":
+		owners := make([]string, 0)
+
+		id, err := user.ParseID(object)
+		if err != nil {
+			return owners, true
+		}
+
+		resp, err := tenants(ctx, id)
+		if err != nil {
+			return owners, true
+		}
+
+		ids, _ := resp.([]tenant.TenantID)
+		for _, id := range ids {
+			owners = append(owners, id.String())
+		}
+
+		return owners, true
+
+	default:
+		return nil, false
+	}
+}
+
+// objectGroups returns the groups owning the object, a group owns itself
+// and a user is owned by the groups of the user.
+func objectGroups(ctx *gin.Context, memberships endpoint.Endpoint, domain string, object string) ([]string, error) {
+	switch domain {
+	case "identity::groups":
+		return []string{object}, nil
+
+	case "identity::users":
+		id, err := user.ParseID(object)
+		if err != nil {
+			return []string{}, nil
+		}
+
+		resp, err := memberships(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		groups, _ := resp.([]*group.Group)
+
+		owners := make([]string, len(groups))
+		for i, g := range groups {
+			owners[i] = g.ID.String()
+		}
+
+		return owners, nil
+
+	default:
+		return []string{}, nil
+	}
+}
+
+// checkStatusRequest checks the client of a service token, or the user.
+func checkStatusRequest(claims *verifier.Claims) (identity.CheckStatusRequest, error) {
+	if claims.Service() {
+		clientID, err := client.ParseID(claims.Subject)
+		if err != nil {
+			return identity.CheckStatusRequest{}, err
+		}
+
+		return identity.CheckStatusRequest{ClientID: clientID}, nil
+	}
+
+	userID, err := user.ParseID(claims.Subject)
+	if err != nil {
+		return identity.CheckStatusRequest{}, err
+	}
+
+	return identity.CheckStatusRequest{UserID: userID}, nil
+}
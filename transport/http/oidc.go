@@ -0,0 +1,192 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/mirror520/identity"
+	"github.com/mirror520/identity/client"
+	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/keys"
+	"github.com/mirror520/identity/oidc"
+	"github.com/mirror520/identity/user"
+	"github.com/mirror520/identity/verifier"
+)
+
+var (
+	ErrUserTokenRequired = errors.New("user token required")
+	ErrGrantedToken      = errors.New("token granted to a client not accepted")
+)
+
+// DiscoveryHandler publishes the provider metadata of OpenID Connect.
+func DiscoveryHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		cfg := oidc.NewConfiguration(conf.G().Issuer(), keys.G().Algorithms())
+
+		ctx.Header("Cache-Control", "public, max-age=3600")
+		ctx.JSON(http.StatusOK, cfg)
+	}
+}
+
+// AuthorizeHandler is the authorization endpoint of the code flow. A user
+// without the token is sent to the login page along with the request, and
+// the page posts it back with the token of the signed-in user.
+func AuthorizeHandler(v *verifier.Verifier, endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req oidc.AuthorizationRequest
+		if err := ctx.ShouldBind(&req); err != nil {
+			tokenError(ctx, http.StatusBadRequest, "invalid_request", err)
+			return
+		}
+
+		claims, err := v.VerifyHeader(ctx.GetHeader("Authorization"))
+		if err == nil && claims.Service() {
+			err = ErrUserTokenRequired // a client never authorizes itself
+		}
+
+		if err == nil && claims.Granted() {
+			err = ErrGrantedToken // nor authorizes the others for the user
+		}
+
+		if err != nil {
+			loginURL := conf.G().OIDC.LoginURL
+			if ctx.Request.Method != http.MethodGet || loginURL == "" {
+				unauthorized(ctx, http.StatusUnauthorized, err)
+				return
+			}
+
+			ctx.Redirect(http.StatusFound, loginURL+"?"+ctx.Request.URL.RawQuery)
+			return
+		}
+
+		resp, err := endpoint(ctx, identity.AuthorizeRequest{
+			Claims:               claims,
+			AuthorizationRequest: req,
+		})
+
+		if err != nil {
+			// never redirect to a client unregistered
+			if errors.Is(err, identity.ErrInvalidClient) {
+				tokenError(ctx, http.StatusBadRequest, "invalid_client", err)
+				return
+			}
+
+			if errors.Is(err, oidc.ErrInvalidRedirectURI) {
+				tokenError(ctx, http.StatusBadRequest, "invalid_request", err)
+				return
+			}
+
+			authorizeRedirect(ctx, req.RedirectURI, url.Values{
+				"error":             {authorizeError(err)},
+				"error_description": {err.Error()},
+				"state":             {req.State},
+			})
+			return
+		}
+
+		code, ok := resp.(string)
+		if !ok {
+			err := errors.New("invalid code")
+			tokenError(ctx, http.StatusInternalServerError, "server_error", err)
+			return
+		}
+
+		authorizeRedirect(ctx, req.RedirectURI, url.Values{
+			"code":  {code},
+			"state": {req.State},
+		})
+	}
+}
+
+// authorizeRedirect redirects the browser back to the client, the login
+// page posting the request gets the location instead.
+func authorizeRedirect(ctx *gin.Context, redirectURI string, params url.Values) {
+	location, err := url.Parse(redirectURI)
+	if err != nil {
+		tokenError(ctx, http.StatusBadRequest, "invalid_request", err)
+		return
+	}
+
+	query := location.Query()
+	for k, vs := range params {
+		if vs[0] != "" {
+			query.Set(k, vs[0])
+		}
+	}
+	location.RawQuery = query.Encode()
+
+	if ctx.Request.Method == http.MethodGet {
+		ctx.Redirect(http.StatusFound, location.String())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"redirect_uri": location.String(),
+	})
+}
+
+// authorizeError maps the errors of the authorization to RFC 6749 §4.1.2.1.
+func authorizeError(err error) string {
+	switch {
+	case errors.Is(err, oidc.ErrUnsupportedResponseType):
+		return "unsupported_response_type"
+	case errors.Is(err, oidc.ErrInvalidRequest):
+		return "invalid_request"
+	case errors.Is(err, client.ErrGrantNotAllowed):
+		return "unauthorized_client"
+	case errors.Is(err, client.ErrScopeNotAllowed):
+		return "invalid_scope"
+	case errors.Is(err, identity.ErrDelegatedToken),
+		errors.Is(err, user.ErrUserNotActivated),
+		errors.Is(err, user.ErrUserLocked),
+		errors.Is(err, user.ErrUserRevoked):
+		return "access_denied"
+	default:
+		return "server_error"
+	}
+}
+
+// UserInfoHandler returns the claims of the user granted by the scope of
+// the access token, the tokens signed in directly are granted all of them.
+func UserInfoHandler(v *verifier.Verifier, endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, err := v.VerifyHeader(ctx.GetHeader("Authorization"))
+		if err == nil && claims.Service() {
+			err = ErrUserTokenRequired
+		}
+
+		if err != nil {
+			ctx.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			tokenError(ctx, http.StatusUnauthorized, "invalid_token", err)
+			return
+		}
+
+		userID, err := user.ParseID(claims.Subject)
+		if err != nil {
+			ctx.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			tokenError(ctx, http.StatusUnauthorized, "invalid_token", err)
+			return
+		}
+
+		scope := oidc.ParseScope(claims.Scope)
+		if len(scope) == 0 {
+			scope = oidc.Scope{"openid", "profile", "email"}
+		}
+
+		resp, err := endpoint(ctx, identity.UserInfoRequest{
+			UserID: userID,
+			Scope:  scope,
+		})
+
+		if err != nil {
+			tokenError(ctx, statusCode(err, http.StatusUnauthorized), "invalid_token", err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, resp)
+	}
+}
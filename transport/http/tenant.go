@@ -0,0 +1,333 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/mirror520/identity"
+	"github.com/mirror520/identity/model"
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/user"
+)
+
+// TenantRequest creates or updates a tenant, the ID is only taken on
+// creating.
+type TenantRequest struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func CreateTenantHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req TenantRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		id, err := tenant.ParseID(req.ID)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, identity.TenantRequest{
+			TenantID: id,
+			Name:     req.Name,
+		})
+
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(tenantStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("tenant created")
+		result.Data = resp
+		ctx.JSON(http.StatusCreated, result)
+	}
+}
+
+func UpdateTenantHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := tenant.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		var req TenantRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, identity.TenantRequest{
+			TenantID: id,
+			Name:     req.Name,
+		})
+
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(tenantStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("tenant updated")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func DeleteTenantHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return tenantHandler(endpoint, "tenant deleted")
+}
+
+func FindTenantHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return tenantHandler(endpoint, "tenant found")
+}
+
+func ListTenantsHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		resp, err := endpoint(ctx, nil)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, result)
+			return
+		}
+
+		result := model.SuccessResult("tenants found")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// tenantHandler serves the requests which take nothing but the tenant ID.
+func tenantHandler(endpoint endpoint.Endpoint, msg string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := tenant.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, id)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(tenantStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult(msg)
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func JoinTenantHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := tenant.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		var req struct {
+			UserID string `json:"user_id"`
+		}
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		userID, err := user.ParseID(req.UserID)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, identity.TenantMemberRequest{
+			TenantID: id,
+			UserID:   userID,
+		})
+
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(tenantStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("tenant joined")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func LeaveTenantHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := tenant.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		userID, err := user.ParseID(ctx.Param("user"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, identity.TenantMemberRequest{
+			TenantID: id,
+			UserID:   userID,
+		})
+
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(tenantStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("tenant left")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func GrantTenantRoleHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := tenant.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		userID, err := user.ParseID(ctx.Param("user"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		var req struct {
+			Role string `json:"role"`
+		}
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, identity.TenantRoleRequest{
+			TenantID: id,
+			UserID:   userID,
+			Role:     req.Role,
+		})
+
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(tenantStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("tenant role granted")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func RevokeTenantRoleHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := tenant.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		userID, err := user.ParseID(ctx.Param("user"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, identity.TenantRoleRequest{
+			TenantID: id,
+			UserID:   userID,
+			Role:     ctx.Param("role"),
+		})
+
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(tenantStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("tenant role revoked")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func TenantsHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := user.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, id)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(tenantStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("tenants found")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func tenantStatusCode(err error) int {
+	switch {
+	case errors.Is(err, tenant.ErrTenantNotFound),
+		errors.Is(err, user.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, tenant.ErrInvalidTenantID),
+		errors.Is(err, tenant.ErrNameEmpty),
+		errors.Is(err, user.ErrInvalidRole):
+		return http.StatusBadRequest
+	case errors.Is(err, tenant.ErrTenantExists),
+		errors.Is(err, tenant.ErrDefaultTenant),
+		errors.Is(err, identity.ErrUserExists),
+		errors.Is(err, identity.ErrEmailExists),
+		errors.Is(err, user.ErrUsernameReserved),
+		errors.Is(err, user.ErrTenantJoined),
+		errors.Is(err, user.ErrTenantNotJoined),
+		errors.Is(err, user.ErrRoleGranted),
+		errors.Is(err, user.ErrRoleNotGranted):
+		return http.StatusConflict
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
@@ -1,47 +1,20 @@
 package http
 
 import (
-	"errors"
-	"strings"
-	"sync"
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 
-	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/keys"
 )
 
-var ErrInvalidToken = errors.New("invalid token")
+// JWKSHandler publishes the public keys to verify the tokens.
+func JWKSHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		jwks := keys.NewJWKS(keys.G().Keys(time.Now())...)
 
-var (
-	keyFn jwt.Keyfunc
-	once  sync.Once
-)
-
-func KeyFn() jwt.Keyfunc {
-	once.Do(func() {
-		secret := conf.G().JWT.Secret
-		keyFn = func(t *jwt.Token) (interface{}, error) {
-			return secret, nil
-		}
-	})
-
-	return keyFn
-}
-
-func ParseToken(ctx *gin.Context, claims jwt.Claims) error {
-	tokenStr := ctx.GetHeader("Authorization")
-	if !strings.HasPrefix(tokenStr, "Bearer ") {
-		return ErrInvalidToken
+		ctx.Header("Cache-Control", "public, max-age=3600")
+		ctx.JSON(http.StatusOK, jwks)
 	}
-
-	tokenStr = strings.TrimPrefix(tokenStr, "Bearer ")
-
-	_, err := jwt.ParseWithClaims(tokenStr, claims, KeyFn(),
-		jwt.WithIssuer(conf.G().BaseURL),
-		jwt.WithLeeway(10*time.Second),
-	)
-
-	return err
 }
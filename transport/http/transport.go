@@ -3,7 +3,9 @@ package http
 import (
 	"context"
 	"errors"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,9 +14,16 @@ import (
 	"github.com/oklog/ulid/v2"
 
 	"github.com/mirror520/identity"
+	"github.com/mirror520/identity/client"
 	"github.com/mirror520/identity/conf"
+	"github.com/mirror520/identity/keys"
 	"github.com/mirror520/identity/model"
+	"github.com/mirror520/identity/oidc"
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/throttle"
+	"github.com/mirror520/identity/token"
 	"github.com/mirror520/identity/user"
+	"github.com/mirror520/identity/verifier"
 )
 
 func RegisterHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
@@ -39,6 +48,85 @@ func RegisterHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
 	}
 }
 
+func OTPEnrollHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+		if id == "" {
+			err := errors.New("id not found")
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		userID, err := user.ParseID(id)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		// the first enrollment comes without a code
+		var req identity.OTPEnrollRequest
+		if err := ctx.ShouldBind(&req); err != nil && !errors.Is(err, io.EOF) {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+		req.UserID = userID
+
+		resp, err := endpoint(ctx, req)
+		if err != nil {
+			retryAfter(ctx, err)
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(statusCode(err, http.StatusForbidden), result)
+			return
+		}
+
+		result := model.SuccessResult("otp enrolled")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func VerifyUserHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+		if id == "" {
+			err := errors.New("id not found")
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		userID, err := user.ParseID(id)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		var req identity.VerifyUserRequest
+		if err := ctx.ShouldBind(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+		req.UserID = userID
+
+		resp, err := endpoint(requestContext(ctx), req)
+		if err != nil {
+			retryAfter(ctx, err)
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(statusCode(err, http.StatusForbidden), result)
+			return
+		}
+
+		result := model.SuccessResult("user verified")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
 func OTPVerifyHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		id := ctx.Param("id")
@@ -56,7 +144,840 @@ func OTPVerifyHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
 			return
 		}
 
-		var req identity.OTPVerifyRequest
+		var req identity.OTPVerifyRequest
+		if err := ctx.ShouldBind(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+		req.UserID = userID
+
+		resp, err := endpoint(requestContext(ctx), req)
+		if err != nil {
+			retryAfter(ctx, err)
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(statusCode(err, http.StatusForbidden), result)
+			return
+		}
+
+		result := model.SuccessResult("user verified")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func SignInHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req identity.SignInRequest
+		err := ctx.ShouldBind(&req)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, req)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(statusCode(err, http.StatusExpectationFailed), result)
+			return
+		}
+
+		signIn(ctx, resp, "fed")
+	}
+}
+
+func SignInWithPasswordHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req identity.SignInWithPasswordRequest
+		err := ctx.ShouldBind(&req)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(requestContext(ctx), req)
+		if err != nil {
+			retryAfter(ctx, err)
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(statusCode(err, http.StatusUnauthorized), result)
+			return
+		}
+
+		signIn(ctx, resp, "pwd")
+	}
+}
+
+// signIn issues the token to the signed-in user, amr tells how the user
+// is authenticated.
+func signIn(ctx *gin.Context, resp any, amr ...string) {
+	u, ok := resp.(*user.User)
+	if !ok {
+		err := errors.New("invalid user")
+		unauthorized(ctx, http.StatusExpectationFailed, err)
+		return
+	}
+
+	g := grant{
+		authTime: time.Now(),
+		amr:      amr,
+	}
+
+	if err := issueToken(u, g); err != nil {
+		unauthorized(ctx, http.StatusExpectationFailed, err)
+		return
+	}
+
+	result := model.SuccessResult("user signed in")
+	result.Data = &signedIn{
+		User:  u,
+		Token: signedInToken{u.Token, u.Token.RefreshToken},
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+// signedIn answers the user along with the refresh token, which the user
+// itself never marshals.
+type signedIn struct {
+	*user.User
+	Token signedInToken `json:"token"`
+}
+
+type signedInToken struct {
+	user.Token
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// grant is what the access token carries besides the user.
+type grant struct {
+	clientID string // the client the token is issued to, empty for the first party
+	scope    oidc.Scope
+	authTime time.Time
+	amr      []string
+	ttl      time.Duration // lifetime of the client, zero for the configured one
+}
+
+// issueToken signs the access token, the refresh token has been issued
+// by the service.
+func issueToken(u *user.User, g grant) error {
+	cfg := conf.G()
+	now := time.Now()
+
+	ttl := cfg.JWT.Timeout
+	if g.ttl > 0 {
+		ttl = g.ttl
+	}
+
+	claims := verifier.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    cfg.Issuer(),
+			Subject:   u.ID.String(),
+			Audience:  jwt.ClaimStrings{u.Username},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        ulid.Make().String(),
+		},
+		Roles:    u.RolesIn(u.Token.TenantID),
+		Scope:    g.scope.String(),
+		TokenUse: verifier.TokenUseUser,
+		AMR:      g.amr,
+		TenantID: u.Token.TenantID,
+	}
+
+	if !g.authTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(g.authTime)
+	}
+
+	if g.clientID != "" {
+		claims.Audience = append(claims.Audience, g.clientID)
+		claims.ClientID = g.clientID
+	}
+
+	tokenStr, err := keys.G().SignAccessToken(claims)
+	if err != nil {
+		return err
+	}
+
+	u.Token.Token = tokenStr
+	u.Token.ExpiredAt = now.Add(ttl)
+	return nil
+}
+
+// issueServiceToken signs the access token of a client acting on its own
+// behalf, the subject is the client and the roles are of the client.
+func issueServiceToken(g *identity.ServiceGrant) (string, time.Time, error) {
+	cfg := conf.G()
+	now := time.Now()
+
+	ttl := cfg.JWT.Timeout
+	if g.Client.Lifetimes.AccessToken > 0 {
+		ttl = g.Client.Lifetimes.AccessToken
+	}
+
+	claims := verifier.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    cfg.Issuer(),
+			Subject:   g.Client.ID.String(),
+			Audience:  jwt.ClaimStrings{g.Client.ID.String()},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        ulid.Make().String(),
+		},
+		Roles:    g.Client.Roles,
+		Scope:    g.Scope.String(),
+		TokenUse: verifier.TokenUseService,
+		ClientID: g.Client.ID.String(),
+	}
+
+	tokenStr, err := keys.G().SignAccessToken(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenStr, now.Add(ttl), nil
+}
+
+// issueExchangedToken signs the token minted by the exchange, the act
+// claim tells who acts on behalf of the subject.
+func issueExchangedToken(x *token.Exchange) (string, error) {
+	claims := verifier.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    conf.G().Issuer(),
+			Subject:   x.Subject,
+			Audience:  jwt.ClaimStrings(x.Audience),
+			ExpiresAt: jwt.NewNumericDate(x.ExpiredAt),
+			IssuedAt:  jwt.NewNumericDate(x.ExchangedAt),
+			ID:        x.ID,
+		},
+		Roles:    x.Roles,
+		Scope:    oidc.Scope(x.Scope).String(),
+		TokenUse: x.TokenUse,
+		ClientID: x.ClientID,
+		Act:      x.Actor,
+		TenantID: x.TenantID,
+	}
+
+	return keys.G().SignAccessToken(claims)
+}
+
+func unauthorized(ctx *gin.Context, code int, err error) {
+	realm := conf.G().BaseURL
+
+	ctx.Abort()
+	ctx.Header("WWW-Authenticate", "Bearer realm="+realm)
+	ctx.String(code, err.Error())
+}
+
+// statusCode tells apart the reasons why a user isn't active, other
+// errors fall back to the given code.
+func statusCode(err error, fallback int) int {
+	switch {
+	case errors.Is(err, user.ErrUserNotActivated):
+		return http.StatusForbidden
+	case errors.Is(err, user.ErrUserLocked):
+		return http.StatusLocked
+	case errors.Is(err, user.ErrUserRevoked):
+		return http.StatusGone
+	case errors.Is(err, throttle.ErrTooManyAttempts):
+		return http.StatusTooManyRequests
+	case errors.Is(err, identity.ErrReauthRequired):
+		return http.StatusUnauthorized
+	default:
+		return fallback
+	}
+}
+
+func retryAfter(ctx *gin.Context, err error) {
+	var backoff *throttle.BackoffError
+	if errors.As(err, &backoff) {
+		seconds := int(backoff.RetryAfter.Seconds()) + 1
+		ctx.Header("Retry-After", strconv.Itoa(seconds))
+	}
+}
+
+// requestContext carries the client of the request to the service.
+func requestContext(ctx *gin.Context) context.Context {
+	info := &identity.RequestInfo{
+		ClientIP:  ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+	}
+
+	return context.WithValue(context.Background(), model.REQUEST_INFO, info)
+}
+
+type TokenRequest struct {
+	GrantType    string `form:"grant_type" json:"grant_type" binding:"required"`
+	RefreshToken string `form:"refresh_token" json:"refresh_token"`
+	Code         string `form:"code" json:"code"`
+	RedirectURI  string `form:"redirect_uri" json:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier" json:"code_verifier"`
+	Scope        string `form:"scope" json:"scope"`
+	ClientID     string `form:"client_id" json:"client_id"`
+	ClientSecret string `form:"client_secret" json:"client_secret"`
+
+	// token exchange of RFC 8693
+	SubjectToken       string   `form:"subject_token" json:"subject_token"`
+	SubjectTokenType   string   `form:"subject_token_type" json:"subject_token_type"`
+	ActorToken         string   `form:"actor_token" json:"actor_token"`
+	ActorTokenType     string   `form:"actor_token_type" json:"actor_token_type"`
+	RequestedTokenType string   `form:"requested_token_type" json:"requested_token_type"`
+	Audience           []string `form:"audience" json:"audience"`
+}
+
+// TokenResponse follows RFC 6749 §5.1, the ID token is issued to the
+// openid scope.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+
+	IssuedTokenType string `json:"issued_token_type,omitempty"` // token exchange only
+}
+
+// TokenHandler is the token endpoint of RFC 6749, the refresh token is
+// rotated on every use.
+func TokenHandler(refreshToken endpoint.Endpoint, exchangeCode endpoint.Endpoint, clientCredentials endpoint.Endpoint, exchangeToken endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Header("Cache-Control", "no-store")
+
+		var req TokenRequest
+		if err := ctx.ShouldBind(&req); err != nil {
+			tokenError(ctx, http.StatusBadRequest, "invalid_request", err)
+			return
+		}
+
+		if id, secret, ok := ctx.Request.BasicAuth(); ok {
+			req.ClientID = id
+			req.ClientSecret = secret
+		}
+
+		var (
+			u    *user.User
+			g    grant
+			code *oidc.Code
+		)
+
+		switch req.GrantType {
+		case "refresh_token":
+			if req.RefreshToken == "" {
+				err := errors.New("refresh_token not found")
+				tokenError(ctx, http.StatusBadRequest, "invalid_request", err)
+				return
+			}
+
+			resp, err := refreshToken(ctx, identity.RefreshTokenRequest{
+				ClientID:     req.ClientID,
+				ClientSecret: req.ClientSecret,
+				RefreshToken: req.RefreshToken,
+			})
+
+			if err != nil {
+				grantError(ctx, err)
+				return
+			}
+
+			granted, ok := resp.(*identity.RefreshGrant)
+			if !ok {
+				err := errors.New("invalid grant")
+				tokenError(ctx, http.StatusInternalServerError, "server_error", err)
+				return
+			}
+
+			// the original grant is renewed as it was
+			u = granted.User
+			g = grant{
+				clientID: granted.Grant.ClientID,
+				scope:    granted.Grant.Scope,
+				authTime: granted.Grant.AuthTime,
+				amr:      granted.Grant.AMR,
+			}
+
+			if granted.Client != nil {
+				g.ttl = granted.Client.Lifetimes.AccessToken
+			}
+
+		case "authorization_code":
+			if req.Code == "" || req.CodeVerifier == "" {
+				err := errors.New("code or code_verifier not found")
+				tokenError(ctx, http.StatusBadRequest, "invalid_request", err)
+				return
+			}
+
+			resp, err := exchangeCode(ctx, identity.ExchangeCodeRequest{
+				ClientID:     req.ClientID,
+				ClientSecret: req.ClientSecret,
+				Code:         req.Code,
+				RedirectURI:  req.RedirectURI,
+				CodeVerifier: req.CodeVerifier,
+			})
+
+			if err != nil {
+				grantError(ctx, err)
+				return
+			}
+
+			granted, ok := resp.(*oidc.Grant)
+			if !ok {
+				err := errors.New("invalid grant")
+				tokenError(ctx, http.StatusInternalServerError, "server_error", err)
+				return
+			}
+
+			u = granted.User
+			code = granted.Code
+			g = grant{
+				scope:    code.Scope,
+				authTime: code.AuthTime,
+				amr:      code.AMR,
+			}
+
+			if granted.Client != nil {
+				g.clientID = granted.Client.ID.String()
+				g.ttl = granted.Client.Lifetimes.AccessToken
+			}
+
+		case "client_credentials":
+			resp, err := clientCredentials(ctx, identity.ClientCredentialsRequest{
+				ClientID:     req.ClientID,
+				ClientSecret: req.ClientSecret,
+				Scope:        oidc.ParseScope(req.Scope),
+			})
+
+			if err != nil {
+				grantError(ctx, err)
+				return
+			}
+
+			granted, ok := resp.(*identity.ServiceGrant)
+			if !ok {
+				err := errors.New("invalid grant")
+				tokenError(ctx, http.StatusInternalServerError, "server_error", err)
+				return
+			}
+
+			tokenStr, expiredAt, err := issueServiceToken(granted)
+			if err != nil {
+				tokenError(ctx, http.StatusInternalServerError, "server_error", err)
+				return
+			}
+
+			// no refresh token, the client authenticates again instead
+			ctx.JSON(http.StatusOK, TokenResponse{
+				AccessToken: tokenStr,
+				TokenType:   "Bearer",
+				ExpiresIn:   int64(time.Until(expiredAt).Seconds()),
+				Scope:       granted.Scope.String(),
+			})
+			return
+
+		case client.GrantTokenExchange:
+			resp, err := exchangeToken(ctx, identity.ExchangeTokenRequest{
+				ClientID:     req.ClientID,
+				ClientSecret: req.ClientSecret,
+				ExchangeRequest: token.ExchangeRequest{
+					SubjectToken:       req.SubjectToken,
+					SubjectTokenType:   req.SubjectTokenType,
+					ActorToken:         req.ActorToken,
+					ActorTokenType:     req.ActorTokenType,
+					RequestedTokenType: req.RequestedTokenType,
+					Audience:           req.Audience,
+					Scope:              oidc.ParseScope(req.Scope),
+				},
+			})
+
+			if err != nil {
+				grantError(ctx, err)
+				return
+			}
+
+			x, ok := resp.(*token.Exchange)
+			if !ok {
+				err := errors.New("invalid exchange")
+				tokenError(ctx, http.StatusInternalServerError, "server_error", err)
+				return
+			}
+
+			tokenStr, err := issueExchangedToken(x)
+			if err != nil {
+				tokenError(ctx, http.StatusInternalServerError, "server_error", err)
+				return
+			}
+
+			ctx.JSON(http.StatusOK, TokenResponse{
+				AccessToken:     tokenStr,
+				TokenType:       "Bearer",
+				ExpiresIn:       int64(time.Until(x.ExpiredAt).Seconds()),
+				Scope:           oidc.Scope(x.Scope).String(),
+				IssuedTokenType: token.TokenTypeAccessToken,
+			})
+			return
+
+		default:
+			err := errors.New("grant_type not supported")
+			tokenError(ctx, http.StatusBadRequest, "unsupported_grant_type", err)
+			return
+		}
+
+		if err := issueToken(u, g); err != nil {
+			tokenError(ctx, http.StatusInternalServerError, "server_error", err)
+			return
+		}
+
+		resp := TokenResponse{
+			AccessToken:  u.Token.Token,
+			TokenType:    "Bearer",
+			ExpiresIn:    int64(time.Until(u.Token.ExpiredAt).Seconds()),
+			RefreshToken: u.Token.RefreshToken,
+			Scope:        g.scope.String(),
+		}
+
+		if code != nil && code.Scope.Has("openid") {
+			cfg := conf.G()
+			claims := oidc.NewIDClaims(cfg.Issuer(), cfg.JWT.Timeout, code, u)
+
+			idToken, err := keys.G().Sign(claims)
+			if err != nil {
+				tokenError(ctx, http.StatusInternalServerError, "server_error", err)
+				return
+			}
+
+			resp.IDToken = idToken
+		}
+
+		ctx.JSON(http.StatusOK, resp)
+	}
+}
+
+type RefreshRequest struct {
+	RefreshToken string `form:"refresh_token" json:"refresh_token" binding:"required"`
+}
+
+// RefreshHandler keeps PATCH /token/refresh for the first-party clients
+// of the former API, the token is answered in the former result. The
+// access token alone no longer refreshes, the refresh token issued by the
+// sign-in is rotated as by POST /token.
+//
+// Deprecated: use POST /token with grant_type=refresh_token.
+func RefreshHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Header("Cache-Control", "no-store")
+
+		var req RefreshRequest
+		if err := ctx.ShouldBind(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, identity.RefreshTokenRequest{
+			RefreshToken: req.RefreshToken,
+		})
+
+		if err != nil {
+			status := http.StatusUnauthorized
+			if errors.Is(err, identity.ErrRefreshDisabled) {
+				status = http.StatusForbidden
+			}
+
+			unauthorized(ctx, status, err)
+			return
+		}
+
+		granted, ok := resp.(*identity.RefreshGrant)
+		if !ok {
+			err := errors.New("invalid grant")
+			unauthorized(ctx, http.StatusExpectationFailed, err)
+			return
+		}
+
+		u := granted.User
+		g := grant{
+			clientID: granted.Grant.ClientID,
+			scope:    granted.Grant.Scope,
+			authTime: granted.Grant.AuthTime,
+			amr:      granted.Grant.AMR,
+		}
+
+		if err := issueToken(u, g); err != nil {
+			unauthorized(ctx, http.StatusExpectationFailed, err)
+			return
+		}
+
+		result := model.SuccessResult("token refreshed")
+		result.Data = signedInToken{u.Token, u.Token.RefreshToken}
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// grantError maps the errors of the grants to RFC 6749 §5.2.
+func grantError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, identity.ErrInvalidClient):
+		ctx.Header("WWW-Authenticate", `Basic realm="identity"`)
+		tokenError(ctx, http.StatusUnauthorized, "invalid_client", err)
+	case errors.Is(err, identity.ErrRefreshDisabled):
+		tokenError(ctx, http.StatusBadRequest, "unsupported_grant_type", err)
+	case errors.Is(err, client.ErrGrantNotAllowed):
+		tokenError(ctx, http.StatusBadRequest, "unauthorized_client", err)
+	case errors.Is(err, client.ErrScopeNotAllowed):
+		tokenError(ctx, http.StatusBadRequest, "invalid_scope", err)
+	case errors.Is(err, client.ErrAudienceNotAllowed):
+		tokenError(ctx, http.StatusBadRequest, "invalid_target", err)
+	case errors.Is(err, token.ErrSubjectTokenNotFound),
+		errors.Is(err, token.ErrUnsupportedTokenType):
+		tokenError(ctx, http.StatusBadRequest, "invalid_request", err)
+	default:
+		tokenError(ctx, http.StatusBadRequest, "invalid_grant", err)
+	}
+}
+
+type RevokeTokenRequest struct {
+	Token         string `form:"token" json:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint" json:"token_type_hint"`
+	ClientID      string `form:"client_id" json:"client_id"`
+	ClientSecret  string `form:"client_secret" json:"client_secret"`
+}
+
+// RevokeTokenHandler is the revocation endpoint of RFC 7009, the client
+// is authenticated by HTTP Basic or the form and revokes its own tokens
+// only. The invalid and expired tokens are answered with 200 as well.
+func RevokeTokenHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req RevokeTokenRequest
+		if err := ctx.ShouldBind(&req); err != nil {
+			tokenError(ctx, http.StatusBadRequest, "invalid_request", err)
+			return
+		}
+
+		if id, secret, ok := ctx.Request.BasicAuth(); ok {
+			req.ClientID = id
+			req.ClientSecret = secret
+		}
+
+		// the hint is optional, the type is told by the format
+		switch req.TokenTypeHint {
+		case "", "access_token", "refresh_token":
+		default:
+			err := errors.New("token_type_hint not supported")
+			tokenError(ctx, http.StatusBadRequest, "unsupported_token_type", err)
+			return
+		}
+
+		_, err := endpoint(ctx, identity.RevokeTokenRequest{
+			ClientID:     req.ClientID,
+			ClientSecret: req.ClientSecret,
+			Token:        req.Token,
+		})
+
+		if err != nil {
+			switch {
+			case errors.Is(err, identity.ErrInvalidClient):
+				ctx.Header("WWW-Authenticate", `Basic realm="identity"`)
+				tokenError(ctx, http.StatusUnauthorized, "invalid_client", err)
+			case errors.Is(err, identity.ErrTokenNotIssued):
+				tokenError(ctx, http.StatusBadRequest, "invalid_request", err)
+			default:
+				tokenError(ctx, http.StatusServiceUnavailable, "server_error", err)
+			}
+
+			return
+		}
+
+		ctx.Status(http.StatusOK)
+	}
+}
+
+type IntrospectRequest struct {
+	Token         string `form:"token" json:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint" json:"token_type_hint"`
+	ClientID      string `form:"client_id" json:"client_id"`
+	ClientSecret  string `form:"client_secret" json:"client_secret"`
+}
+
+// IntrospectHandler is the introspection endpoint of RFC 7662, the client
+// is authenticated by HTTP Basic or the form.
+func IntrospectHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Header("Cache-Control", "no-store")
+
+		var req IntrospectRequest
+		if err := ctx.ShouldBind(&req); err != nil {
+			tokenError(ctx, http.StatusBadRequest, "invalid_request", err)
+			return
+		}
+
+		if id, secret, ok := ctx.Request.BasicAuth(); ok {
+			req.ClientID = id
+			req.ClientSecret = secret
+		}
+
+		resp, err := endpoint(ctx, identity.IntrospectRequest{
+			ClientID:     req.ClientID,
+			ClientSecret: req.ClientSecret,
+			Token:        req.Token,
+		})
+
+		if err != nil {
+			if errors.Is(err, identity.ErrInvalidClient) {
+				ctx.Header("WWW-Authenticate", `Basic realm="identity"`)
+				tokenError(ctx, http.StatusUnauthorized, "invalid_client", err)
+				return
+			}
+
+			tokenError(ctx, http.StatusServiceUnavailable, "server_error", err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, resp)
+	}
+}
+
+// tokenError follows RFC 6749 §5.2.
+func tokenError(ctx *gin.Context, code int, errCode string, err error) {
+	ctx.AbortWithStatusJSON(code, gin.H{
+		"error":             errCode,
+		"error_description": err.Error(),
+	})
+}
+
+func ChangePasswordHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+		if id == "" {
+			err := errors.New("id not found")
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		userID, err := user.ParseID(id)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		var req identity.ChangePasswordRequest
+		if err := ctx.ShouldBind(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+		req.UserID = userID
+
+		// the first password is set by a recent sign-in
+		if claims, ok := ctx.Value(verifier.ClaimsKey).(*verifier.Claims); ok && claims.AuthTime != nil {
+			req.AuthTime = claims.AuthTime.Time
+		}
+
+		resp, err := endpoint(ctx, req)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(statusCode(err, http.StatusForbidden), result)
+			return
+		}
+
+		result := model.SuccessResult("password changed")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func AddSocialAccountHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+		if id == "" {
+			err := errors.New("id not found")
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+		}
+
+		userID, err := user.ParseID(id)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+		}
+
+		var req identity.AddSocialAccountRequest
+		if err := ctx.ShouldBind(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+		req.UserID = userID
+
+		resp, err := endpoint(ctx, req)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusForbidden, result)
+			return
+		}
+
+		result := model.SuccessResult("user social account added")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func RemoveSocialAccountHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, err := user.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, identity.RemoveSocialAccountRequest{
+			UserID:   userID,
+			Provider: user.SocialProvider(ctx.Param("provider")),
+			SocialID: user.SocialID(ctx.Param("socialID")),
+		})
+
+		if err != nil {
+			result := model.FailureResult(err)
+
+			code := http.StatusForbidden
+			switch {
+			case errors.Is(err, user.ErrUserNotFound),
+				errors.Is(err, user.ErrSocialAccountNotFound):
+				code = http.StatusNotFound
+			case errors.Is(err, user.ErrLastCredential):
+				code = http.StatusConflict
+			}
+
+			ctx.AbortWithStatusJSON(code, result)
+			return
+		}
+
+		result := model.SuccessResult("user social account removed")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func LockHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+		if id == "" {
+			err := errors.New("id not found")
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		userID, err := user.ParseID(id)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		var req identity.LockRequest
 		if err := ctx.ShouldBind(&req); err != nil {
 			result := model.FailureResult(err)
 			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
@@ -71,135 +992,395 @@ func OTPVerifyHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
 			return
 		}
 
-		result := model.SuccessResult("user verified")
+		result := model.SuccessResult("user locked")
 		result.Data = resp
 		ctx.JSON(http.StatusOK, result)
 	}
 }
 
-func SignInHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+func UnlockHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		var req identity.SignInRequest
-		err := ctx.ShouldBind(&req)
+		id := ctx.Param("id")
+		if id == "" {
+			err := errors.New("id not found")
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		userID, err := user.ParseID(id)
 		if err != nil {
 			result := model.FailureResult(err)
 			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
 			return
 		}
 
+		req := identity.UnlockRequest{
+			UserID: userID,
+		}
+
 		resp, err := endpoint(ctx, req)
 		if err != nil {
 			result := model.FailureResult(err)
-			ctx.AbortWithStatusJSON(http.StatusExpectationFailed, result)
+			ctx.AbortWithStatusJSON(http.StatusForbidden, result)
 			return
 		}
 
-		u, ok := resp.(*user.User)
-		if !ok {
-			err := errors.New("invalid user")
-			unauthorized(ctx, http.StatusExpectationFailed, err)
+		result := model.SuccessResult("user unlocked")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func RevokeHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+		if id == "" {
+			err := errors.New("id not found")
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		userID, err := user.ParseID(id)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
 			return
 		}
 
-		cfg := conf.G()
-		now := time.Now()
-		claims := Claims{
-			RegisteredClaims: jwt.RegisteredClaims{
-				Issuer:    cfg.BaseURL,
-				Subject:   u.ID.String(),
-				Audience:  jwt.ClaimStrings{u.Username},
-				ExpiresAt: jwt.NewNumericDate(now.Add(cfg.JWT.Timeout)),
-				IssuedAt:  jwt.NewNumericDate(now),
-				ID:        ulid.Make().String(),
-			},
-			Roles: []string{"admin"},
+		var req identity.RevokeRequest
+		if err := ctx.ShouldBind(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
 		}
+		req.UserID = userID
 
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-		tokenStr, err := token.SignedString(cfg.JWT.Secret)
+		resp, err := endpoint(ctx, req)
 		if err != nil {
-			unauthorized(ctx, http.StatusExpectationFailed, err)
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusForbidden, result)
+			return
+		}
+
+		result := model.SuccessResult("user revoked")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdateProfileHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, err := user.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		var profile user.Profile
+		if err := ctx.ShouldBindJSON(&profile); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
 			return
 		}
 
-		u.Token = user.Token{
-			Token:     tokenStr,
-			ExpiredAt: now.Add(cfg.JWT.Timeout),
+		req := identity.UpdateProfileRequest{
+			UserID:  userID,
+			Profile: profile,
+		}
+
+		resp, err := endpoint(ctx, req)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(profileStatusCode(err), result)
+			return
 		}
 
-		result := model.SuccessResult("user signed in")
+		result := model.SuccessResult("profile updated")
 		result.Data = resp
 		ctx.JSON(http.StatusOK, result)
 	}
 }
 
-func unauthorized(ctx *gin.Context, code int, err error) {
-	realm := conf.G().BaseURL
+func profileStatusCode(err error) int {
+	switch {
+	case errors.Is(err, user.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, user.ErrNameEmpty):
+		return http.StatusBadRequest
+	default:
+		return http.StatusForbidden
+	}
+}
 
-	ctx.Abort()
-	ctx.Header("WWW-Authenticate", "Bearer realm="+realm)
-	ctx.String(code, err.Error())
+func ChangeUsernameHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, err := user.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		var req struct {
+			Username string `json:"username"`
+		}
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, identity.ChangeUsernameRequest{
+			UserID:   userID,
+			Username: req.Username,
+		})
+
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(usernameStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("username changed")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
 }
 
-func RefreshHandler(ctx *gin.Context) {
-	cfg := conf.G()
-	if !cfg.JWT.Refresh.Enabled {
-		ctx.Abort()
-		ctx.String(http.StatusForbidden, "token refresh disabled")
-		return
+func usernameStatusCode(err error) int {
+	switch {
+	case errors.Is(err, user.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, user.ErrUsernameEmpty),
+		errors.Is(err, user.ErrUsernameUnchanged):
+		return http.StatusBadRequest
+	case errors.Is(err, identity.ErrUserExists),
+		errors.Is(err, user.ErrUsernameReserved):
+		return http.StatusConflict
+	default:
+		return http.StatusForbidden
 	}
+}
 
-	var claims Claims
-	if err := ParseToken(ctx, &claims); err != nil {
-		unauthorized(ctx, http.StatusUnauthorized, err)
-		return
+func DeleteUserHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := user.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		if _, err := endpoint(ctx, id); err != nil {
+			result := model.FailureResult(err)
+
+			code := http.StatusUnprocessableEntity
+			if errors.Is(err, user.ErrUserNotFound) {
+				code = http.StatusNotFound
+			}
+
+			ctx.AbortWithStatusJSON(code, result)
+			return
+		}
+
+		result := model.SuccessResult("user deleted")
+		ctx.JSON(http.StatusOK, result)
 	}
+}
 
-	if time.Since(claims.IssuedAt.Time) > cfg.JWT.Refresh.Maximum {
-		err := errors.New("token beyond refresh time")
-		unauthorized(ctx, http.StatusForbidden, err)
-		return
+func RequestEmailChangeHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, err := user.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		var req struct {
+			Email string `json:"email"`
+		}
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(ctx, identity.EmailChangeRequest{
+			UserID: userID,
+			Email:  req.Email,
+		})
+
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(emailStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("email change requested")
+		result.Data = resp
+		ctx.JSON(http.StatusAccepted, result)
 	}
+}
 
-	now := time.Now()
-	claims.ExpiresAt = jwt.NewNumericDate(now.Add(cfg.JWT.Timeout))
-	claims.IssuedAt = jwt.NewNumericDate(now)
-	claims.ID = ulid.Make().String()
+func ConfirmEmailChangeHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, err := user.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, err := token.SignedString(cfg.JWT.Secret)
-	if err != nil {
-		unauthorized(ctx, http.StatusExpectationFailed, err)
-		return
+		var req struct {
+			Code string `json:"code"`
+		}
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		resp, err := endpoint(requestContext(ctx), identity.ConfirmEmailChangeRequest{
+			UserID: userID,
+			Code:   req.Code,
+		})
+
+		if err != nil {
+			retryAfter(ctx, err)
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(emailStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("email changed")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
 	}
+}
 
-	t := user.Token{
-		Token:     tokenStr,
-		ExpiredAt: now.Add(cfg.JWT.Timeout),
+func emailStatusCode(err error) int {
+	switch {
+	case errors.Is(err, user.ErrUserNotFound),
+		errors.Is(err, user.ErrEmailChangeNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, user.ErrEmailEmpty),
+		errors.Is(err, user.ErrEmailUnchanged):
+		return http.StatusBadRequest
+	case errors.Is(err, user.ErrEmailExists):
+		return http.StatusConflict
+	default:
+		return statusCode(err, http.StatusForbidden)
 	}
+}
 
-	result := model.SuccessResult("token refreshed")
-	result.Data = t
-	ctx.JSON(http.StatusOK, result)
+// ListUsersRequest is the query of the users listed, the times are in
+// RFC 3339 and the order is either asc or desc by the time registered.
+type ListUsersRequest struct {
+	TenantID      string    `form:"tenant_id"`
+	Status        string    `form:"status"`
+	Provider      string    `form:"provider"`
+	CreatedAfter  time.Time `form:"created_after"`
+	CreatedBefore time.Time `form:"created_before"`
+	Text          string    `form:"q"`
+	Order         string    `form:"order"`
+	Cursor        string    `form:"cursor"`
+	Limit         int       `form:"limit"`
 }
 
-func AddSocialAccountHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+func (req ListUsersRequest) Query() (user.Query, error) {
+	q := user.Query{
+		Provider:      user.SocialProvider(req.Provider),
+		CreatedAfter:  req.CreatedAfter,
+		CreatedBefore: req.CreatedBefore,
+		Text:          req.Text,
+		Limit:         req.Limit,
+	}
+
+	if req.TenantID != "" {
+		id, err := tenant.ParseID(req.TenantID)
+		if err != nil {
+			return user.Query{}, err
+		}
+
+		q.TenantID = id
+	}
+
+	if req.Status != "" {
+		status, err := user.ParseStatus(req.Status)
+		if err != nil {
+			return user.Query{}, err
+		}
+
+		q.Status = &status
+	}
+
+	switch req.Order {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return user.Query{}, errors.New("invalid order")
+	}
+
+	if req.Cursor != "" {
+		id, err := user.ParseID(req.Cursor)
+		if err != nil {
+			return user.Query{}, err
+		}
+
+		q.After = &id
+	}
+
+	return q, nil
+}
+
+func ListUsersHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		id := ctx.Param("id")
-		if id == "" {
-			err := errors.New("id not found")
+		var req ListUsersRequest
+		if err := ctx.ShouldBindQuery(&req); err != nil {
 			result := model.FailureResult(err)
 			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
 		}
 
-		userID, err := user.ParseID(id)
+		q, err := req.Query()
 		if err != nil {
 			result := model.FailureResult(err)
 			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
 		}
 
-		var req identity.AddSocialAccountRequest
-		if err := ctx.ShouldBind(&req); err != nil {
+		resp, err := endpoint(ctx, q)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, result)
+			return
+		}
+
+		result := model.SuccessResult("users found")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func GrantRoleHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, err := user.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		var req identity.RoleRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
 			result := model.FailureResult(err)
 			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
 			return
@@ -209,25 +1390,60 @@ func AddSocialAccountHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
 		resp, err := endpoint(ctx, req)
 		if err != nil {
 			result := model.FailureResult(err)
-			ctx.AbortWithStatusJSON(http.StatusForbidden, result)
+			ctx.AbortWithStatusJSON(roleStatusCode(err), result)
 			return
 		}
 
-		result := model.SuccessResult("user social account added")
+		result := model.SuccessResult("role granted")
 		result.Data = resp
 		ctx.JSON(http.StatusOK, result)
 	}
 }
 
-func CheckHealthHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		info := &identity.RequestInfo{
-			ClientIP:  c.ClientIP(),
-			UserAgent: c.Request.UserAgent(),
+func RevokeRoleHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, err := user.ParseID(ctx.Param("id"))
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, result)
+			return
+		}
+
+		req := identity.RoleRequest{
+			UserID: userID,
+			Role:   ctx.Param("role"),
 		}
 
-		ctx := context.WithValue(context.Background(), model.REQUEST_INFO, info)
-		_, err := endpoint(ctx, nil)
+		resp, err := endpoint(ctx, req)
+		if err != nil {
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(roleStatusCode(err), result)
+			return
+		}
+
+		result := model.SuccessResult("role revoked")
+		result.Data = resp
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func roleStatusCode(err error) int {
+	switch {
+	case errors.Is(err, user.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, user.ErrInvalidRole):
+		return http.StatusBadRequest
+	case errors.Is(err, user.ErrRoleGranted),
+		errors.Is(err, user.ErrRoleNotGranted):
+		return http.StatusConflict
+	default:
+		return http.StatusForbidden
+	}
+}
+
+func CheckHealthHandler(endpoint endpoint.Endpoint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, err := endpoint(requestContext(c), nil)
 		if err != nil {
 			result := model.FailureResult(err)
 			c.AbortWithStatusJSON(http.StatusExpectationFailed, result)
@@ -9,15 +9,67 @@ import (
 	"github.com/go-kit/kit/endpoint"
 
 	"github.com/mirror520/identity"
+	"github.com/mirror520/identity/client"
+	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/group"
+	"github.com/mirror520/identity/keys"
 	"github.com/mirror520/identity/model"
+	"github.com/mirror520/identity/oidc"
 	"github.com/mirror520/identity/pubsub"
+	"github.com/mirror520/identity/tenant"
+	"github.com/mirror520/identity/throttle"
+	"github.com/mirror520/identity/token"
 	"github.com/mirror520/identity/user"
 )
 
 func EventHandler(endpoint endpoint.Endpoint) pubsub.MessageHandler {
 	return func(ctx context.Context, msg *pubsub.Message) error {
 		ss := strings.Split(msg.Topic, ".")
-		if len(ss) != 3 || ss[0] != "users" {
+		if len(ss) != 3 {
+			return errors.New("invalid event")
+		}
+
+		if ss[0] == "tokens" {
+			event, err := tokenEvent(ss[2], msg.Data)
+			if err != nil {
+				return err
+			}
+
+			_, err = endpoint(ctx, event)
+			return err
+		}
+
+		if ss[0] == "clients" {
+			event, err := clientEvent(ss[2], msg.Data)
+			if err != nil {
+				return err
+			}
+
+			_, err = endpoint(ctx, event)
+			return err
+		}
+
+		if ss[0] == "groups" {
+			event, err := groupEvent(ss[2], msg.Data)
+			if err != nil {
+				return err
+			}
+
+			_, err = endpoint(ctx, event)
+			return err
+		}
+
+		if ss[0] == "tenants" {
+			event, err := tenantEvent(ss[2], msg.Data)
+			if err != nil {
+				return err
+			}
+
+			_, err = endpoint(ctx, event)
+			return err
+		}
+
+		if ss[0] != "users" {
 			return errors.New("invalid event")
 		}
 
@@ -46,6 +98,160 @@ func EventHandler(endpoint endpoint.Endpoint) pubsub.MessageHandler {
 			}
 			event = e
 
+		case user.UserOTPEnrolled:
+			var e *user.UserOTPEnrolledEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserOTPVerified:
+			var e *user.UserOTPVerifiedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserOTPConfirmed:
+			var e *user.UserOTPConfirmedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserVerificationCodeIssued:
+			var e *user.UserVerificationCodeIssuedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserVerificationCodeFailed:
+			var e *user.UserVerificationCodeFailedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserVerificationCodeVerified:
+			var e *user.UserVerificationCodeVerifiedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserPasswordChanged:
+			var e *user.UserPasswordChangedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserLocked:
+			var e *user.UserLockedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserUnlocked:
+			var e *user.UserUnlockedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserRevoked:
+			var e *user.UserRevokedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserRoleGranted:
+			var e *user.UserRoleGrantedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserRoleRevoked:
+			var e *user.UserRoleRevokedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserTenantJoined:
+			var e *user.UserTenantJoinedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserTenantLeft:
+			var e *user.UserTenantLeftEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserTenantRoleGranted:
+			var e *user.UserTenantRoleGrantedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserTenantRoleRevoked:
+			var e *user.UserTenantRoleRevokedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserProfileUpdated:
+			var e *user.UserProfileUpdatedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserEmailChangeRequested:
+			var e *user.UserEmailChangeRequestedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserEmailChanged:
+			var e *user.UserEmailChangedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserUsernameChanged:
+			var e *user.UserUsernameChangedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserDeleted:
+			var e *user.UserDeletedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case user.UserSocialAccountRemoved:
+			var e *user.UserSocialAccountRemovedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
 		default:
 			return errors.New("invalid event")
 		}
@@ -55,6 +261,254 @@ func EventHandler(endpoint endpoint.Endpoint) pubsub.MessageHandler {
 	}
 }
 
+func tokenEvent(name string, data []byte) (any, error) {
+	switch token.ParseTopicName(name) {
+	case token.FamilyIssued:
+		var e *token.FamilyIssuedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case token.FamilyRotated:
+		var e *token.FamilyRotatedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case token.FamilyRevoked:
+		var e *token.FamilyRevokedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case token.TokenDenied:
+		var e *token.TokenDeniedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case token.TokenExchanged:
+		var e *token.TokenExchangedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	default:
+		return nil, errors.New("invalid event")
+	}
+}
+
+func clientEvent(name string, data []byte) (any, error) {
+	switch client.ParseTopicName(name) {
+	case client.ClientRegistered:
+		var e *client.ClientRegisteredEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case client.ClientUpdated:
+		var e *client.ClientUpdatedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case client.ClientSecretRotated:
+		var e *client.ClientSecretRotatedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case client.ClientDeleted:
+		var e *client.ClientDeletedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	default:
+		return nil, errors.New("invalid event")
+	}
+}
+
+func groupEvent(name string, data []byte) (any, error) {
+	switch group.ParseTopicName(name) {
+	case group.GroupCreated:
+		var e *group.GroupCreatedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case group.GroupUpdated:
+		var e *group.GroupUpdatedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case group.GroupMemberAdded:
+		var e *group.GroupMemberAddedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case group.GroupMemberRemoved:
+		var e *group.GroupMemberRemovedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case group.GroupRoleGranted:
+		var e *group.GroupRoleGrantedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case group.GroupRoleRevoked:
+		var e *group.GroupRoleRevokedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case group.GroupDeleted:
+		var e *group.GroupDeletedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	default:
+		return nil, errors.New("invalid event")
+	}
+}
+
+func tenantEvent(name string, data []byte) (any, error) {
+	switch tenant.ParseTopicName(name) {
+	case tenant.TenantCreated:
+		var e *tenant.TenantCreatedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case tenant.TenantUpdated:
+		var e *tenant.TenantUpdatedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case tenant.TenantDeleted:
+		var e *tenant.TenantDeletedEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	default:
+		return nil, errors.New("invalid event")
+	}
+}
+
+// AttemptEventHandler replays the failed attempts of the other instances.
+func AttemptEventHandler(tracker *throttle.Tracker) pubsub.MessageHandler {
+	return func(ctx context.Context, msg *pubsub.Message) error {
+		ss := strings.Split(msg.Topic, ".")
+		if len(ss) != 2 || ss[0] != "attempts" {
+			return errors.New("invalid event")
+		}
+
+		var event events.DomainEvent
+		switch throttle.ParseEventName(ss[1]) {
+		case throttle.AttemptFailed:
+			var e *throttle.AttemptFailedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case throttle.AttemptsReset:
+			var e *throttle.AttemptsResetEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		default:
+			return errors.New("invalid event")
+		}
+
+		return tracker.Apply(event)
+	}
+}
+
+// CodeEventHandler replays the authorization codes of the other instances.
+func CodeEventHandler(codes *oidc.Codes) pubsub.MessageHandler {
+	return func(ctx context.Context, msg *pubsub.Message) error {
+		ss := strings.Split(msg.Topic, ".")
+		if len(ss) != 2 || ss[0] != "codes" {
+			return errors.New("invalid event")
+		}
+
+		var event events.DomainEvent
+		switch oidc.ParseEventName("code_" + ss[1]) {
+		case oidc.CodeIssued:
+			var e *oidc.CodeIssuedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		case oidc.CodeRedeemed:
+			var e *oidc.CodeRedeemedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+			event = e
+
+		default:
+			return errors.New("invalid event")
+		}
+
+		return codes.Apply(event)
+	}
+}
+
+// KeyEventHandler reloads the key ring once a key is rotated.
+func KeyEventHandler(ring *keys.Ring) pubsub.MessageHandler {
+	return func(ctx context.Context, msg *pubsub.Message) error {
+		ss := strings.Split(msg.Topic, ".")
+		if len(ss) != 2 || ss[0] != "keys" {
+			return errors.New("invalid event")
+		}
+
+		switch keys.ParseEventName("key_" + ss[1]) {
+		case keys.KeyRotated:
+			var e *keys.KeyRotatedEvent
+			if err := json.Unmarshal(msg.Data, &e); err != nil {
+				return err
+			}
+
+			return ring.Reload()
+
+		default:
+			return errors.New("invalid event")
+		}
+	}
+}
+
 func SignInHandler(endpoint endpoint.Endpoint) pubsub.MessageHandler {
 	return func(ctx context.Context, msg *pubsub.Message) error {
 		var req identity.SignInRequest
@@ -90,6 +544,43 @@ func SignInHandler(endpoint endpoint.Endpoint) pubsub.MessageHandler {
 	}
 }
 
+// IntrospectHandler answers the introspection requests of the gateways,
+// the request carries the client credentials as well.
+func IntrospectHandler(endpoint endpoint.Endpoint) pubsub.MessageHandler {
+	return func(ctx context.Context, msg *pubsub.Message) error {
+		var req identity.IntrospectRequest
+
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			result := model.FailureResult(err)
+			bs, err := result.Bytes()
+			if err != nil {
+				return err
+			}
+			return msg.Response(bs)
+		}
+
+		resp, err := endpoint(ctx, req)
+		if err != nil {
+			result := model.FailureResult(err)
+			bs, err := result.Bytes()
+			if err != nil {
+				return err
+			}
+			return msg.Response(bs)
+		}
+
+		result := model.SuccessResult("token introspected")
+		result.Data = resp
+
+		bs, err := result.Bytes()
+		if err != nil {
+			return err
+		}
+
+		return msg.Response(bs)
+	}
+}
+
 func CheckHealthHandler(endpoint endpoint.Endpoint) pubsub.MessageHandler {
 	return func(_ context.Context, msg *pubsub.Message) error {
 		var info *identity.RequestInfo
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/mirror520/identity/events"
+	"github.com/mirror520/identity/tenant"
 )
 
 type EventName int
@@ -14,6 +15,28 @@ const (
 	UserRegistered
 	UserActivated
 	UserSocialAccountAdded
+	UserOTPEnrolled
+	UserOTPVerified
+	UserVerificationCodeIssued
+	UserVerificationCodeFailed
+	UserVerificationCodeVerified
+	UserPasswordChanged
+	UserLocked
+	UserUnlocked
+	UserRevoked
+	UserRoleGranted
+	UserRoleRevoked
+	UserTenantJoined
+	UserTenantLeft
+	UserTenantRoleGranted
+	UserTenantRoleRevoked
+	UserProfileUpdated
+	UserEmailChangeRequested
+	UserEmailChanged
+	UserUsernameChanged
+	UserDeleted
+	UserSocialAccountRemoved
+	UserOTPConfirmed
 )
 
 func ParseEventName(s string) EventName {
@@ -24,6 +47,50 @@ func ParseEventName(s string) EventName {
 		return UserActivated
 	case "user_social_account_added":
 		return UserSocialAccountAdded
+	case "user_otp_enrolled":
+		return UserOTPEnrolled
+	case "user_otp_verified":
+		return UserOTPVerified
+	case "user_verification_code_issued":
+		return UserVerificationCodeIssued
+	case "user_verification_code_failed":
+		return UserVerificationCodeFailed
+	case "user_verification_code_verified":
+		return UserVerificationCodeVerified
+	case "user_password_changed":
+		return UserPasswordChanged
+	case "user_locked":
+		return UserLocked
+	case "user_unlocked":
+		return UserUnlocked
+	case "user_revoked":
+		return UserRevoked
+	case "user_role_granted":
+		return UserRoleGranted
+	case "user_role_revoked":
+		return UserRoleRevoked
+	case "user_tenant_joined":
+		return UserTenantJoined
+	case "user_tenant_left":
+		return UserTenantLeft
+	case "user_tenant_role_granted":
+		return UserTenantRoleGranted
+	case "user_tenant_role_revoked":
+		return UserTenantRoleRevoked
+	case "user_profile_updated":
+		return UserProfileUpdated
+	case "user_email_change_requested":
+		return UserEmailChangeRequested
+	case "user_email_changed":
+		return UserEmailChanged
+	case "user_username_changed":
+		return UserUsernameChanged
+	case "user_deleted":
+		return UserDeleted
+	case "user_social_account_removed":
+		return UserSocialAccountRemoved
+	case "user_otp_confirmed":
+		return UserOTPConfirmed
 	default:
 		return Unknown
 	}
@@ -37,6 +104,50 @@ func (name EventName) String() string {
 		return "user_activated"
 	case UserSocialAccountAdded:
 		return "user_social_account_added"
+	case UserOTPEnrolled:
+		return "user_otp_enrolled"
+	case UserOTPVerified:
+		return "user_otp_verified"
+	case UserVerificationCodeIssued:
+		return "user_verification_code_issued"
+	case UserVerificationCodeFailed:
+		return "user_verification_code_failed"
+	case UserVerificationCodeVerified:
+		return "user_verification_code_verified"
+	case UserPasswordChanged:
+		return "user_password_changed"
+	case UserLocked:
+		return "user_locked"
+	case UserUnlocked:
+		return "user_unlocked"
+	case UserRevoked:
+		return "user_revoked"
+	case UserRoleGranted:
+		return "user_role_granted"
+	case UserRoleRevoked:
+		return "user_role_revoked"
+	case UserTenantJoined:
+		return "user_tenant_joined"
+	case UserTenantLeft:
+		return "user_tenant_left"
+	case UserTenantRoleGranted:
+		return "user_tenant_role_granted"
+	case UserTenantRoleRevoked:
+		return "user_tenant_role_revoked"
+	case UserProfileUpdated:
+		return "user_profile_updated"
+	case UserEmailChangeRequested:
+		return "user_email_change_requested"
+	case UserEmailChanged:
+		return "user_email_changed"
+	case UserUsernameChanged:
+		return "user_username_changed"
+	case UserDeleted:
+		return "user_deleted"
+	case UserSocialAccountRemoved:
+		return "user_social_account_removed"
+	case UserOTPConfirmed:
+		return "user_otp_confirmed"
 	default:
 		return ""
 	}
@@ -107,3 +218,283 @@ func NewUserSocialAccountAddedEvent(u *User, account *SocialAccount) events.Doma
 		Account: account,
 	}
 }
+
+type UserOTPEnrolledEvent struct {
+	*Event
+	OTP OTP `json:"otp"`
+}
+
+func NewUserOTPEnrolledEvent(u *User, otp OTP) events.DomainEvent {
+	return &UserOTPEnrolledEvent{
+		Event: NewEvent(UserOTPEnrolled, u),
+		OTP:   otp,
+	}
+}
+
+type UserOTPVerifiedEvent struct {
+	*Event
+	Step uint64 `json:"step"`
+}
+
+func NewUserOTPVerifiedEvent(u *User, step uint64) events.DomainEvent {
+	return &UserOTPVerifiedEvent{
+		Event: NewEvent(UserOTPVerified, u),
+		Step:  step,
+	}
+}
+
+type UserOTPConfirmedEvent struct {
+	*Event
+	Step uint64 `json:"step"`
+}
+
+func NewUserOTPConfirmedEvent(u *User, step uint64) events.DomainEvent {
+	return &UserOTPConfirmedEvent{
+		Event: NewEvent(UserOTPConfirmed, u),
+		Step:  step,
+	}
+}
+
+type UserVerificationCodeIssuedEvent struct {
+	*Event
+	Code VerificationCode `json:"code"`
+}
+
+func NewUserVerificationCodeIssuedEvent(u *User, code VerificationCode) events.DomainEvent {
+	return &UserVerificationCodeIssuedEvent{
+		Event: NewEvent(UserVerificationCodeIssued, u),
+		Code:  code,
+	}
+}
+
+type UserVerificationCodeFailedEvent struct {
+	*Event
+	Attempts int `json:"attempts"`
+}
+
+func NewUserVerificationCodeFailedEvent(u *User, attempts int) events.DomainEvent {
+	return &UserVerificationCodeFailedEvent{
+		Event:    NewEvent(UserVerificationCodeFailed, u),
+		Attempts: attempts,
+	}
+}
+
+type UserVerificationCodeVerifiedEvent struct {
+	*Event
+}
+
+func NewUserVerificationCodeVerifiedEvent(u *User) events.DomainEvent {
+	return &UserVerificationCodeVerifiedEvent{
+		Event: NewEvent(UserVerificationCodeVerified, u),
+	}
+}
+
+type UserPasswordChangedEvent struct {
+	*Event
+	Password Password `json:"password"`
+}
+
+func NewUserPasswordChangedEvent(u *User, password Password) events.DomainEvent {
+	return &UserPasswordChangedEvent{
+		Event:    NewEvent(UserPasswordChanged, u),
+		Password: password,
+	}
+}
+
+type UserLockedEvent struct {
+	*Event
+	Status Status `json:"status"`
+	Reason string `json:"reason"`
+}
+
+func NewUserLockedEvent(u *User, status Status, reason string) events.DomainEvent {
+	return &UserLockedEvent{
+		Event:  NewEvent(UserLocked, u),
+		Status: status,
+		Reason: reason,
+	}
+}
+
+type UserUnlockedEvent struct {
+	*Event
+	Status Status `json:"status"`
+}
+
+func NewUserUnlockedEvent(u *User, status Status) events.DomainEvent {
+	return &UserUnlockedEvent{
+		Event:  NewEvent(UserUnlocked, u),
+		Status: status,
+	}
+}
+
+type UserRevokedEvent struct {
+	*Event
+	Status Status `json:"status"`
+	Reason string `json:"reason"`
+}
+
+func NewUserRevokedEvent(u *User, status Status, reason string) events.DomainEvent {
+	return &UserRevokedEvent{
+		Event:  NewEvent(UserRevoked, u),
+		Status: status,
+		Reason: reason,
+	}
+}
+
+type UserRoleGrantedEvent struct {
+	*Event
+	Role string `json:"role"`
+}
+
+func NewUserRoleGrantedEvent(u *User, role string) events.DomainEvent {
+	return &UserRoleGrantedEvent{
+		Event: NewEvent(UserRoleGranted, u),
+		Role:  role,
+	}
+}
+
+type UserRoleRevokedEvent struct {
+	*Event
+	Role string `json:"role"`
+}
+
+func NewUserRoleRevokedEvent(u *User, role string) events.DomainEvent {
+	return &UserRoleRevokedEvent{
+		Event: NewEvent(UserRoleRevoked, u),
+		Role:  role,
+	}
+}
+
+type UserTenantJoinedEvent struct {
+	*Event
+	TenantID tenant.TenantID `json:"tenant_id"`
+}
+
+func NewUserTenantJoinedEvent(u *User, id tenant.TenantID) events.DomainEvent {
+	return &UserTenantJoinedEvent{
+		Event:    NewEvent(UserTenantJoined, u),
+		TenantID: id,
+	}
+}
+
+type UserTenantLeftEvent struct {
+	*Event
+	TenantID tenant.TenantID `json:"tenant_id"`
+}
+
+func NewUserTenantLeftEvent(u *User, id tenant.TenantID) events.DomainEvent {
+	return &UserTenantLeftEvent{
+		Event:    NewEvent(UserTenantLeft, u),
+		TenantID: id,
+	}
+}
+
+type UserTenantRoleGrantedEvent struct {
+	*Event
+	TenantID tenant.TenantID `json:"tenant_id"`
+	Role     string          `json:"role"`
+}
+
+func NewUserTenantRoleGrantedEvent(u *User, id tenant.TenantID, role string) events.DomainEvent {
+	return &UserTenantRoleGrantedEvent{
+		Event:    NewEvent(UserTenantRoleGranted, u),
+		TenantID: id,
+		Role:     role,
+	}
+}
+
+type UserTenantRoleRevokedEvent struct {
+	*Event
+	TenantID tenant.TenantID `json:"tenant_id"`
+	Role     string          `json:"role"`
+}
+
+func NewUserTenantRoleRevokedEvent(u *User, id tenant.TenantID, role string) events.DomainEvent {
+	return &UserTenantRoleRevokedEvent{
+		Event:    NewEvent(UserTenantRoleRevoked, u),
+		TenantID: id,
+		Role:     role,
+	}
+}
+
+// UserProfileUpdatedEvent carries the fields changed only, the provider is
+// empty when edited by the user.
+type UserProfileUpdatedEvent struct {
+	*Event
+	Profile  Profile        `json:"profile"`
+	Provider SocialProvider `json:"provider,omitempty"`
+}
+
+func NewUserProfileUpdatedEvent(u *User, changed Profile, provider SocialProvider) events.DomainEvent {
+	return &UserProfileUpdatedEvent{
+		Event:    NewEvent(UserProfileUpdated, u),
+		Profile:  changed,
+		Provider: provider,
+	}
+}
+
+type UserEmailChangeRequestedEvent struct {
+	*Event
+	Change EmailChange `json:"change"`
+}
+
+func NewUserEmailChangeRequestedEvent(u *User, change EmailChange) events.DomainEvent {
+	return &UserEmailChangeRequestedEvent{
+		Event:  NewEvent(UserEmailChangeRequested, u),
+		Change: change,
+	}
+}
+
+type UserEmailChangedEvent struct {
+	*Event
+	Email    string `json:"email"`
+	Previous string `json:"previous"`
+}
+
+func NewUserEmailChangedEvent(u *User, previous string) events.DomainEvent {
+	return &UserEmailChangedEvent{
+		Event:    NewEvent(UserEmailChanged, u),
+		Email:    u.Email,
+		Previous: previous,
+	}
+}
+
+type UserUsernameChangedEvent struct {
+	*Event
+	Username string `json:"username"`
+	Previous string `json:"previous"`
+}
+
+func NewUserUsernameChangedEvent(u *User, previous string) events.DomainEvent {
+	return &UserUsernameChangedEvent{
+		Event:    NewEvent(UserUsernameChanged, u),
+		Username: u.Username,
+		Previous: previous,
+	}
+}
+
+type UserDeletedEvent struct {
+	*Event
+	Username string `json:"username"`
+}
+
+func NewUserDeletedEvent(u *User) events.DomainEvent {
+	return &UserDeletedEvent{
+		Event:    NewEvent(UserDeleted, u),
+		Username: u.Username,
+	}
+}
+
+type UserSocialAccountRemovedEvent struct {
+	*Event
+	Provider SocialProvider `json:"social_provider"`
+	SocialID SocialID       `json:"social_id"`
+}
+
+func NewUserSocialAccountRemovedEvent(u *User, account *SocialAccount) events.DomainEvent {
+	return &UserSocialAccountRemovedEvent{
+		Event:    NewEvent(UserSocialAccountRemoved, u),
+		Provider: account.Provider,
+		SocialID: account.SocialID,
+	}
+}
@@ -0,0 +1,84 @@
+package user
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/mirror520/identity/tenant"
+)
+
+// Query filters the users, the users are ordered and paged by the ID which
+// tells the time registered.
+type Query struct {
+	TenantID      tenant.TenantID // any tenant if empty
+	Status        *Status
+	Provider      SocialProvider // having an account of the provider
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Text          string // matched in the username, the name and the email
+	Desc          bool
+	After         *UserID // the cursor, the last user of the previous page
+	Limit         int
+}
+
+// Match tells whether the user is of the query, the cursor is left to
+// the repositories and the deleted users are never matched.
+func (q Query) Match(u *User) bool {
+	if !u.DeletedAt.IsZero() {
+		return false
+	}
+
+	if q.TenantID != "" && u.Membership(q.TenantID) == nil {
+		return false
+	}
+
+	if q.Status != nil && u.Status != *q.Status {
+		return false
+	}
+
+	if q.Provider != "" && !u.HasProvider(q.Provider) {
+		return false
+	}
+
+	if !q.CreatedAfter.IsZero() && u.CreatedAt.Before(q.CreatedAfter) {
+		return false
+	}
+
+	if !q.CreatedBefore.IsZero() && !u.CreatedAt.Before(q.CreatedBefore) {
+		return false
+	}
+
+	if q.Text != "" {
+		text := strings.ToLower(q.Text)
+
+		if !strings.Contains(strings.ToLower(u.Username), text) &&
+			!strings.Contains(strings.ToLower(u.Name), text) &&
+			!strings.Contains(strings.ToLower(u.Email), text) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Beyond tells whether the user comes after the cursor in the order.
+func (q Query) Beyond(id UserID) bool {
+	if q.After == nil {
+		return true
+	}
+
+	cmp := bytes.Compare(id.Bytes(), q.After.Bytes())
+	if q.Desc {
+		return cmp < 0
+	}
+
+	return cmp > 0
+}
+
+// Page is a page of the users, Next is the cursor of the next page and
+// empty on the last page.
+type Page struct {
+	Users []*User `json:"users"`
+	Next  string  `json:"next,omitempty"`
+}
@@ -1,15 +1,37 @@
 package user
 
+import (
+	"time"
+
+	"github.com/mirror520/identity/tenant"
+)
+
 type Repository interface {
 	// Command
 
 	Store(u *User) error
 
+	// ReserveUsername holds the username of the tenant for the user until
+	// the time in this repository, ErrUsernameReserved if another user
+	// holds it
+	ReserveUsername(tenantID tenant.TenantID, username string, id UserID, until time.Time) error
+
+	// Purge removes the users deleted before the time for good
+	Purge(before time.Time) ([]UserID, error)
+
 	// Query
 
+	// the users deleted are found by none of the finders
 	Find(id UserID) (*User, error)
-	FindByUsername(username string) (*User, error)
-	FindBySocialID(socialID SocialID) (*User, error)
+
+	// the usernames, the emails and the social accounts are unique in a
+	// tenant, only the members of the tenant are found
+	FindByUsername(tenantID tenant.TenantID, username string) (*User, error)
+	FindByEmail(tenantID tenant.TenantID, email string) (*User, error)
+	FindBySocialID(tenantID tenant.TenantID, socialID SocialID) (*User, error)
+
+	// List returns at most q.Limit users of the query after the cursor
+	List(q Query) ([]*User, error)
 
 	Close() error
 }
@@ -1,8 +1,10 @@
 package user
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
+	"slices"
 	"strings"
 	"time"
 
@@ -10,12 +12,59 @@ import (
 
 	"github.com/mirror520/identity/events"
 	"github.com/mirror520/identity/model"
+	"github.com/mirror520/identity/tenant"
 )
 
 var (
-	ErrUserNotFound = errors.New("user not found")
+	ErrUserNotFound      = errors.New("user not found")
+	ErrUserNotActivated  = errors.New("user not activated")
+	ErrUserNotRegistered = errors.New("user not registered")
+	ErrUserLocked        = errors.New("user locked")
+	ErrUserNotLocked     = errors.New("user not locked")
+	ErrUserRevoked       = errors.New("user revoked")
+	ErrUserDeleted       = errors.New("user deleted")
+	ErrOTPNotEnrolled    = errors.New("otp not enrolled")
+	ErrOTPReplayed       = errors.New("otp replayed")
+	ErrInvalidRole       = errors.New("invalid role")
+	ErrRoleGranted       = errors.New("role already granted")
+	ErrRoleNotGranted    = errors.New("role not granted")
+	ErrTenantJoined      = errors.New("tenant already joined")
+	ErrTenantNotJoined   = errors.New("tenant not joined")
+	ErrNameEmpty         = errors.New("name empty")
+	ErrEmailEmpty        = errors.New("email empty")
+	ErrEmailUnchanged    = errors.New("email unchanged")
+	ErrEmailExists       = errors.New("email exists")
+
+	ErrEmailChangeNotFound = errors.New("email change not found")
+
+	ErrUsernameEmpty     = errors.New("username empty")
+	ErrUsernameUnchanged = errors.New("username unchanged")
+	ErrUsernameReserved  = errors.New("username reserved")
+
+	ErrSocialAccountNotFound = errors.New("social account not found")
+	ErrLastCredential        = errors.New("last credential")
+
+	ErrVerificationCodeNotFound = errors.New("verification code not found")
+	ErrVerificationCodeExpired  = errors.New("verification code expired")
+	ErrVerificationCodeExceeded = errors.New("verification code attempts exceeded")
+	ErrVerificationCodeInvalid  = errors.New("verification code invalid")
 )
 
+// the roles known to the policy, any other role may be granted as well
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// ParseRole rejects the roles which can't be a token of the topics.
+func ParseRole(role string) (string, error) {
+	if role == "" || strings.ContainsAny(role, ".*> \t\r\n") {
+		return "", ErrInvalidRole
+	}
+
+	return role, nil
+}
+
 type Status int
 
 const (
@@ -134,15 +183,32 @@ type User struct {
 	Name     string           `json:"name"`
 	Email    string           `json:"email"`
 	Status   Status           `json:"status"`
+	Roles    []string         `json:"roles"`
+	Tenants  []*Membership    `json:"tenants"`
 	Accounts []*SocialAccount `json:"accounts"`
 	Avatar   string           `json:"avatar"`
 	Token    Token            `json:"token"`
+
+	// edited by the user, kept from the profiles of the providers
+	ProfileEdited bool `json:"profile_edited"`
+
+	Restriction *Restriction `json:"restriction,omitempty"`
+
+	// credentials, never exposed
+	Password     *Password         `json:"-"`
+	OTP          *OTP              `json:"-"`
+	PendingOTP   *OTP              `json:"-"` // enrolled, waiting for its first code
+	Verification *VerificationCode `json:"-"`
+	EmailChange  *EmailChange      `json:"-"`
+
 	model.Model
 
 	events.EventStore `json:"-"`
 }
 
-func NewUser(username string, name string, email string) *User {
+// NewUser registers the user to the tenant, the membership is carried by
+// the registered event.
+func NewUser(tenantID tenant.TenantID, username string, name string, email string) *User {
 	id := MakeID()
 
 	u := &User{
@@ -151,6 +217,14 @@ func NewUser(username string, name string, email string) *User {
 		Name:     name,
 		Email:    email,
 		Status:   Pending,
+		Roles:    []string{RoleUser},
+		Tenants: []*Membership{
+			{
+				TenantID: tenantID,
+				Roles:    make([]string, 0),
+				JoinedAt: id.Time(),
+			},
+		},
 		Model: model.Model{
 			CreatedAt: id.Time(),
 		},
@@ -178,6 +252,266 @@ func (u *User) Activate() {
 	u.AddEvent(e)
 }
 
+func (u *User) Lock(reason string) error {
+	switch u.Status {
+	case Locked:
+		return ErrUserLocked
+	case Revoked:
+		return ErrUserRevoked
+	}
+
+	now := time.Now()
+
+	u.Restriction = &Restriction{
+		Reason:   reason,
+		Previous: u.Status,
+		Since:    now,
+	}
+	u.Status = Locked
+	u.UpdatedAt = now
+
+	e := NewUserLockedEvent(u, Locked, reason)
+	u.AddEvent(e)
+	return nil
+}
+
+// Unlock restores the status before the user was locked.
+func (u *User) Unlock() error {
+	if u.Status != Locked {
+		return ErrUserNotLocked
+	}
+
+	status := Activated
+	if u.Restriction != nil {
+		status = u.Restriction.Previous
+	}
+
+	u.Restriction = nil
+	u.Status = status
+	u.UpdatedAt = time.Now()
+
+	e := NewUserUnlockedEvent(u, status)
+	u.AddEvent(e)
+	return nil
+}
+
+// Revoke is permanent, a revoked user can't be unlocked.
+func (u *User) Revoke(reason string) error {
+	if u.Status == Revoked {
+		return ErrUserRevoked
+	}
+
+	now := time.Now()
+
+	u.Restriction = &Restriction{
+		Reason:   reason,
+		Previous: u.Status,
+		Since:    now,
+	}
+	u.Status = Revoked
+	u.UpdatedAt = now
+
+	e := NewUserRevokedEvent(u, Revoked, reason)
+	u.AddEvent(e)
+	return nil
+}
+
+// Delete deletes the user softly, the user is purged after the retention.
+func (u *User) Delete() error {
+	if !u.DeletedAt.IsZero() {
+		return ErrUserDeleted
+	}
+
+	now := time.Now()
+	u.UpdatedAt = now
+	u.DeletedAt = now
+
+	e := NewUserDeletedEvent(u)
+	u.AddEvent(e)
+	return nil
+}
+
+// CheckStatus returns an error unless the user is activated.
+func (u *User) CheckStatus() error {
+	switch u.Status {
+	case Activated:
+		return nil
+	case Locked:
+		return ErrUserLocked
+	case Revoked:
+		return ErrUserRevoked
+	default:
+		return ErrUserNotActivated
+	}
+}
+
+func (u *User) HasRole(role string) bool {
+	return slices.Contains(u.Roles, role)
+}
+
+func (u *User) GrantRole(role string) error {
+	role, err := ParseRole(role)
+	if err != nil {
+		return err
+	}
+
+	if u.HasRole(role) {
+		return ErrRoleGranted
+	}
+
+	u.Roles = append(slices.Clone(u.Roles), role)
+	u.UpdatedAt = time.Now()
+
+	e := NewUserRoleGrantedEvent(u, role)
+	u.AddEvent(e)
+	return nil
+}
+
+func (u *User) RevokeRole(role string) error {
+	if !u.HasRole(role) {
+		return ErrRoleNotGranted
+	}
+
+	u.Roles = slices.DeleteFunc(slices.Clone(u.Roles), func(r string) bool {
+		return r == role
+	})
+	u.UpdatedAt = time.Now()
+
+	e := NewUserRoleRevokedEvent(u, role)
+	u.AddEvent(e)
+	return nil
+}
+
+// Membership returns the membership of the tenant, nil if the user
+// doesn't belong to the tenant.
+func (u *User) Membership(id tenant.TenantID) *Membership {
+	i := u.membership(id)
+	if i < 0 {
+		return nil
+	}
+
+	return u.Tenants[i]
+}
+
+func (u *User) membership(id tenant.TenantID) int {
+	return slices.IndexFunc(u.Tenants, func(m *Membership) bool {
+		return m.TenantID == id
+	})
+}
+
+// RolesIn returns the roles of the user signed in to the tenant, the
+// roles of the user are granted in every tenant.
+func (u *User) RolesIn(id tenant.TenantID) []string {
+	roles := slices.Clone(u.Roles)
+
+	m := u.Membership(id)
+	if m == nil {
+		return roles
+	}
+
+	for _, role := range m.Roles {
+		if !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
+
+// JoinTenant makes the user a member of the tenant, the service checks
+// that the username and the email aren't taken in the tenant.
+func (u *User) JoinTenant(id tenant.TenantID) error {
+	if u.Membership(id) != nil {
+		return ErrTenantJoined
+	}
+
+	now := time.Now()
+
+	m := &Membership{
+		TenantID: id,
+		Roles:    make([]string, 0),
+		JoinedAt: now,
+	}
+
+	u.Tenants = append(slices.Clone(u.Tenants), m)
+	u.UpdatedAt = now
+
+	e := NewUserTenantJoinedEvent(u, id)
+	u.AddEvent(e)
+	return nil
+}
+
+func (u *User) LeaveTenant(id tenant.TenantID) error {
+	if u.Membership(id) == nil {
+		return ErrTenantNotJoined
+	}
+
+	u.Tenants = slices.DeleteFunc(slices.Clone(u.Tenants), func(m *Membership) bool {
+		return m.TenantID == id
+	})
+	u.UpdatedAt = time.Now()
+
+	e := NewUserTenantLeftEvent(u, id)
+	u.AddEvent(e)
+	return nil
+}
+
+// GrantTenantRole grants the role to the user signed in to the tenant only.
+func (u *User) GrantTenantRole(id tenant.TenantID, role string) error {
+	role, err := ParseRole(role)
+	if err != nil {
+		return err
+	}
+
+	i := u.membership(id)
+	if i < 0 {
+		return ErrTenantNotJoined
+	}
+
+	m := *u.Tenants[i]
+	if slices.Contains(m.Roles, role) {
+		return ErrRoleGranted
+	}
+
+	m.Roles = append(slices.Clone(m.Roles), role)
+	u.setMembership(i, &m)
+
+	e := NewUserTenantRoleGrantedEvent(u, id, role)
+	u.AddEvent(e)
+	return nil
+}
+
+func (u *User) RevokeTenantRole(id tenant.TenantID, role string) error {
+	i := u.membership(id)
+	if i < 0 {
+		return ErrTenantNotJoined
+	}
+
+	m := *u.Tenants[i]
+	if !slices.Contains(m.Roles, role) {
+		return ErrRoleNotGranted
+	}
+
+	m.Roles = slices.DeleteFunc(slices.Clone(m.Roles), func(r string) bool {
+		return r == role
+	})
+	u.setMembership(i, &m)
+
+	e := NewUserTenantRoleRevokedEvent(u, id, role)
+	u.AddEvent(e)
+	return nil
+}
+
+// setMembership replaces the membership on a copy, the stored users may
+// share the memberships.
+func (u *User) setMembership(i int, m *Membership) {
+	tenants := slices.Clone(u.Tenants)
+	tenants[i] = m
+
+	u.Tenants = tenants
+	u.UpdatedAt = time.Now()
+}
+
 func (u *User) AddSocialAccount(provider SocialProvider, socialID SocialID) {
 	account := NewSocialAccount(provider, socialID)
 
@@ -192,6 +526,289 @@ func (u *User) AddSocialAccount(provider SocialProvider, socialID SocialID) {
 	u.AddEvent(e)
 }
 
+// RemoveSocialAccount unlinks the account, the user keeps either the
+// password or another account to sign in with.
+func (u *User) RemoveSocialAccount(provider SocialProvider, socialID SocialID) error {
+	i := slices.IndexFunc(u.Accounts, func(a *SocialAccount) bool {
+		return a.Provider == provider && a.SocialID == socialID
+	})
+
+	if i < 0 {
+		return ErrSocialAccountNotFound
+	}
+
+	if u.Password == nil && len(u.Accounts) == 1 {
+		return ErrLastCredential
+	}
+
+	account := u.Accounts[i]
+
+	u.Accounts = slices.Delete(slices.Clone(u.Accounts), i, i+1)
+	u.UpdatedAt = time.Now()
+
+	e := NewUserSocialAccountRemovedEvent(u, account)
+	u.AddEvent(e)
+	return nil
+}
+
+func (u *User) HasProvider(provider SocialProvider) bool {
+	return slices.ContainsFunc(u.Accounts, func(a *SocialAccount) bool {
+		return a.Provider == provider
+	})
+}
+
+// Profile is the fields of the profile to update, nil for the unchanged.
+type Profile struct {
+	Name   *string `json:"name,omitempty"`
+	Avatar *string `json:"avatar,omitempty"`
+}
+
+// UpdateProfile changes the profile by the user, nothing happens if no field
+// is changed.
+func (u *User) UpdateProfile(p Profile) error {
+	if p.Name != nil && *p.Name == "" {
+		return ErrNameEmpty
+	}
+
+	u.updateProfile(p, "")
+	return nil
+}
+
+// SyncProfile changes the profile by the profile of the provider, the
+// empty fields of the provider are ignored.
+func (u *User) SyncProfile(provider SocialProvider, p Profile) {
+	if p.Name != nil && *p.Name == "" {
+		p.Name = nil
+	}
+
+	if p.Avatar != nil && *p.Avatar == "" {
+		p.Avatar = nil
+	}
+
+	u.updateProfile(p, provider)
+}
+
+func (u *User) updateProfile(p Profile, provider SocialProvider) {
+	var changed Profile
+
+	if p.Name != nil && *p.Name != u.Name {
+		name := *p.Name
+		changed.Name = &name
+		u.Name = name
+	}
+
+	if p.Avatar != nil && *p.Avatar != u.Avatar {
+		avatar := *p.Avatar
+		changed.Avatar = &avatar
+		u.Avatar = avatar
+	}
+
+	if changed.Name == nil && changed.Avatar == nil {
+		return
+	}
+
+	if provider == "" {
+		u.ProfileEdited = true
+	}
+	u.UpdatedAt = time.Now()
+
+	e := NewUserProfileUpdatedEvent(u, changed, provider)
+	u.AddEvent(e)
+}
+
+func (u *User) ChangePassword(hash string) {
+	now := time.Now()
+
+	password := Password{
+		Hash:      hash,
+		ChangedAt: now,
+	}
+
+	u.Password = &password
+	u.UpdatedAt = now
+
+	e := NewUserPasswordChangedEvent(u, password)
+	u.AddEvent(e)
+}
+
+// EnrollOTP holds the sealed secret pending until its first code, the
+// secret enrolled before stays in use until then.
+func (u *User) EnrollOTP(secret string) {
+	now := time.Now()
+
+	otp := OTP{
+		Secret:     secret,
+		EnrolledAt: now,
+	}
+
+	u.PendingOTP = &otp
+	u.UpdatedAt = now
+
+	e := NewUserOTPEnrolledEvent(u, otp)
+	u.AddEvent(e)
+}
+
+// ConfirmOTP puts the pending secret in use by the step of its first code.
+func (u *User) ConfirmOTP(step uint64) error {
+	if u.PendingOTP == nil {
+		return ErrOTPNotEnrolled
+	}
+
+	otp := *u.PendingOTP
+	otp.LastStep = step
+
+	u.OTP = &otp
+	u.PendingOTP = nil
+	u.UpdatedAt = time.Now()
+
+	e := NewUserOTPConfirmedEvent(u, step)
+	u.AddEvent(e)
+	return nil
+}
+
+// VerifyOTP consumes the matched TOTP step, a code can't be used twice
+// within the same step.
+func (u *User) VerifyOTP(step uint64) error {
+	if u.OTP == nil {
+		return ErrOTPNotEnrolled
+	}
+
+	if step <= u.OTP.LastStep {
+		return ErrOTPReplayed
+	}
+
+	u.OTP.LastStep = step
+	u.UpdatedAt = time.Now()
+
+	e := NewUserOTPVerifiedEvent(u, step)
+	u.AddEvent(e)
+	return nil
+}
+
+func (u *User) IssueVerificationCode(hash string, ttl time.Duration, maxAttempts int) {
+	now := time.Now()
+
+	code := VerificationCode{
+		Hash:        hash,
+		MaxAttempts: maxAttempts,
+		ExpiredAt:   now.Add(ttl),
+	}
+
+	u.Verification = &code
+	u.UpdatedAt = now
+
+	e := NewUserVerificationCodeIssuedEvent(u, code)
+	u.AddEvent(e)
+}
+
+// VerifyCode compares the hash of an issued verification code, every
+// mismatch is counted until the code is exhausted.
+func (u *User) VerifyCode(hash string) error {
+	code := u.Verification
+	if code == nil {
+		return ErrVerificationCodeNotFound
+	}
+
+	if time.Now().After(code.ExpiredAt) {
+		return ErrVerificationCodeExpired
+	}
+
+	if code.Attempts >= code.MaxAttempts {
+		return ErrVerificationCodeExceeded
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(code.Hash)) != 1 {
+		code.Attempts++
+		u.UpdatedAt = time.Now()
+
+		e := NewUserVerificationCodeFailedEvent(u, code.Attempts)
+		u.AddEvent(e)
+		return ErrVerificationCodeInvalid
+	}
+
+	u.Verification = nil
+	u.UpdatedAt = time.Now()
+
+	e := NewUserVerificationCodeVerifiedEvent(u)
+	u.AddEvent(e)
+	return nil
+}
+
+// ChangeUsername renames the user, the service holds the old username from
+// the others for a while.
+func (u *User) ChangeUsername(username string) error {
+	if username == "" {
+		return ErrUsernameEmpty
+	}
+
+	if username == u.Username {
+		return ErrUsernameUnchanged
+	}
+
+	previous := u.Username
+
+	u.Username = username
+	u.UpdatedAt = time.Now()
+
+	e := NewUserUsernameChangedEvent(u, previous)
+	u.AddEvent(e)
+	return nil
+}
+
+// RequestEmailChange keeps the email unchanged until the code sent to the
+// new email is confirmed, a request replaces the previous one.
+func (u *User) RequestEmailChange(email string, hash string, ttl time.Duration) error {
+	if email == "" {
+		return ErrEmailEmpty
+	}
+
+	if email == u.Email {
+		return ErrEmailUnchanged
+	}
+
+	now := time.Now()
+
+	change := EmailChange{
+		Email:     email,
+		Hash:      hash,
+		ExpiredAt: now.Add(ttl),
+	}
+
+	u.EmailChange = &change
+	u.UpdatedAt = now
+
+	e := NewUserEmailChangeRequestedEvent(u, change)
+	u.AddEvent(e)
+	return nil
+}
+
+// ConfirmEmailChange commits the email requested, the failures are
+// throttled by the service rather than counted.
+func (u *User) ConfirmEmailChange(hash string) error {
+	change := u.EmailChange
+	if change == nil {
+		return ErrEmailChangeNotFound
+	}
+
+	if time.Now().After(change.ExpiredAt) {
+		return ErrVerificationCodeExpired
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(change.Hash)) != 1 {
+		return ErrVerificationCodeInvalid
+	}
+
+	previous := u.Email
+
+	u.Email = change.Email
+	u.EmailChange = nil
+	u.UpdatedAt = time.Now()
+
+	e := NewUserEmailChangedEvent(u, previous)
+	u.AddEvent(e)
+	return nil
+}
+
 type SocialProvider string
 
 const (
@@ -219,7 +836,50 @@ func NewSocialAccount(provider SocialProvider, id SocialID) *SocialAccount {
 	}
 }
 
-type Token struct {
-	Token     string    `json:"token"`
+// Membership is the belonging of the user to a tenant, the roles are
+// granted in the tenant only.
+type Membership struct {
+	TenantID tenant.TenantID `json:"tenant_id"`
+	Roles    []string        `json:"roles"`
+	JoinedAt time.Time       `json:"joined_at"`
+}
+
+type Restriction struct {
+	Reason   string    `json:"reason"`
+	Previous Status    `json:"previous"`
+	Since    time.Time `json:"since"`
+}
+
+type Password struct {
+	Hash      string    `json:"hash"` // PHC string format
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type OTP struct {
+	Secret     string    `json:"secret"` // sealed by the server key
+	LastStep   uint64    `json:"last_step"`
+	EnrolledAt time.Time `json:"enrolled_at"`
+}
+
+type VerificationCode struct {
+	Hash        string    `json:"hash"`
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"max_attempts"`
+	ExpiredAt   time.Time `json:"expired_at"`
+}
+
+// EmailChange is the email waiting for the code sent to it.
+type EmailChange struct {
+	Email     string    `json:"email"`
+	Hash      string    `json:"hash"`
 	ExpiredAt time.Time `json:"expired_at"`
 }
+
+type Token struct {
+	Token        string    `json:"token"`
+	ExpiredAt    time.Time `json:"expired_at"`
+	RefreshToken string    `json:"-"` // answered by the transport only, never published nor stored
+
+	// the tenant signed in to
+	TenantID tenant.TenantID `json:"tenant_id,omitempty"`
+}
@@ -4,14 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/mirror520/identity/tenant"
 )
 
 func TestRegister(t *testing.T) {
 	assert := assert.New(t)
 
-	u := NewUser("user01", "User01", "user01@example.com")
+	u := NewUser(tenant.Default, "user01", "User01", "user01@example.com")
 	assert.Equal("user01", u.Username)
 	assert.Equal("user01@example.com", u.Email)
 	assert.Equal(Registered, u.Status)
@@ -23,4 +26,247 @@ func TestRegister(t *testing.T) {
 	}
 
 	fmt.Println(string(jsonStr))
+
+	// the registration carries the user, never its refresh token
+	u.Token.RefreshToken = "refresh-token"
+
+	bs, err := json.Marshal(u.Events()[0])
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.NotContains(string(bs), "refresh-token")
+}
+
+func TestVerifyCode(t *testing.T) {
+	assert := assert.New(t)
+
+	u := NewUser(tenant.Default, "user01", "User01", "user01@example.com")
+	u.IssueVerificationCode("hash", 10*time.Minute, 2)
+
+	assert.ErrorIs(u.VerifyCode("wrong"), ErrVerificationCodeInvalid)
+	assert.ErrorIs(u.VerifyCode("wrong"), ErrVerificationCodeInvalid)
+	assert.ErrorIs(u.VerifyCode("hash"), ErrVerificationCodeExceeded)
+
+	u.IssueVerificationCode("hash", 10*time.Minute, 2)
+	assert.NoError(u.VerifyCode("hash"))
+	assert.Nil(u.Verification)
+}
+
+func TestLockAndUnlock(t *testing.T) {
+	assert := assert.New(t)
+
+	u := NewUser(tenant.Default, "user01", "User01", "user01@example.com")
+	assert.ErrorIs(u.CheckStatus(), ErrUserNotActivated)
+
+	assert.NoError(u.Lock("too many attempts"))
+	assert.Equal(Locked, u.Status)
+	assert.ErrorIs(u.CheckStatus(), ErrUserLocked)
+	assert.ErrorIs(u.Lock("again"), ErrUserLocked)
+
+	assert.NoError(u.Unlock())
+	assert.Equal(Registered, u.Status) // restored
+	assert.Nil(u.Restriction)
+
+	assert.NoError(u.Revoke("terminated"))
+	assert.ErrorIs(u.CheckStatus(), ErrUserRevoked)
+	assert.ErrorIs(u.Unlock(), ErrUserNotLocked)
+}
+
+func TestGrantAndRevokeRole(t *testing.T) {
+	assert := assert.New(t)
+
+	u := NewUser(tenant.Default, "user01", "User01", "user01@example.com")
+	assert.Equal([]string{RoleUser}, u.Roles)
+
+	assert.NoError(u.GrantRole(RoleAdmin))
+	assert.True(u.HasRole(RoleAdmin))
+	assert.ErrorIs(u.GrantRole(RoleAdmin), ErrRoleGranted)
+	assert.ErrorIs(u.GrantRole("users.*"), ErrInvalidRole)
+
+	assert.NoError(u.RevokeRole(RoleUser))
+	assert.Equal([]string{RoleAdmin}, u.Roles)
+	assert.ErrorIs(u.RevokeRole(RoleUser), ErrRoleNotGranted)
+
+	e := u.Events()[1]
+	assert.Equal(UserRoleGranted.String(), e.EventName())
+	assert.Equal("users."+u.ID.String()+".role_granted", e.Topic())
+	assert.Equal(UserRoleRevoked.String(), u.Events()[2].EventName())
+}
+
+func TestTenants(t *testing.T) {
+	assert := assert.New(t)
+
+	u := NewUser(tenant.Default, "user01", "User01", "user01@example.com")
+	assert.NotNil(u.Membership(tenant.Default))
+	assert.ErrorIs(u.JoinTenant(tenant.Default), ErrTenantJoined)
+
+	assert.NoError(u.JoinTenant("acme"))
+	assert.ErrorIs(u.JoinTenant("acme"), ErrTenantJoined)
+	assert.ErrorIs(u.GrantTenantRole("other", RoleAdmin), ErrTenantNotJoined)
+
+	assert.NoError(u.GrantTenantRole("acme", RoleAdmin))
+	assert.ErrorIs(u.GrantTenantRole("acme", RoleAdmin), ErrRoleGranted)
+
+	// the roles of a tenant are granted in the tenant only
+	assert.Equal([]string{RoleUser, RoleAdmin}, u.RolesIn("acme"))
+	assert.Equal([]string{RoleUser}, u.RolesIn("other"))
+	assert.Equal([]string{RoleUser}, u.Roles)
+
+	assert.NoError(u.RevokeTenantRole("acme", RoleAdmin))
+	assert.ErrorIs(u.RevokeTenantRole("acme", RoleAdmin), ErrRoleNotGranted)
+
+	assert.NoError(u.LeaveTenant("acme"))
+	assert.Nil(u.Membership("acme"))
+	assert.ErrorIs(u.LeaveTenant("acme"), ErrTenantNotJoined)
+
+	e, ok := u.Events()[2].(*UserTenantRoleGrantedEvent)
+	if !ok {
+		assert.Fail("invalid event")
+		return
+	}
+
+	assert.Equal("users."+u.ID.String()+".tenant_role_granted", e.Topic())
+	assert.Equal(RoleAdmin, e.Role)
+}
+
+func TestProfile(t *testing.T) {
+	assert := assert.New(t)
+
+	u := NewUser(tenant.Default, "user01", "User01", "user01@example.com")
+
+	empty := ""
+	assert.ErrorIs(u.UpdateProfile(Profile{Name: &empty}), ErrNameEmpty)
+
+	name := "User 01"
+	avatar := "https://example.com/user01.png"
+	assert.NoError(u.UpdateProfile(Profile{Name: &name, Avatar: &avatar}))
+	assert.True(u.ProfileEdited)
+
+	// nothing changed, nothing happened
+	events := len(u.Events())
+	assert.NoError(u.UpdateProfile(Profile{Name: &name}))
+	assert.Len(u.Events(), events)
+
+	picture := "https://example.com/google.png"
+	u.SyncProfile(GOOGLE, Profile{Name: &name, Avatar: &picture})
+
+	e, ok := u.Events()[events].(*UserProfileUpdatedEvent)
+	if !ok {
+		assert.Fail("invalid event")
+		return
+	}
+
+	assert.Nil(e.Profile.Name)
+	assert.Equal(picture, *e.Profile.Avatar)
+	assert.Equal(GOOGLE, e.Provider)
+	assert.Equal("users."+u.ID.String()+".profile_updated", e.Topic())
+}
+
+func TestEmailChange(t *testing.T) {
+	assert := assert.New(t)
+
+	u := NewUser(tenant.Default, "user01", "User01", "user01@example.com")
+
+	assert.ErrorIs(u.ConfirmEmailChange("hash"), ErrEmailChangeNotFound)
+	assert.ErrorIs(u.RequestEmailChange("", "hash", 10*time.Minute), ErrEmailEmpty)
+	assert.ErrorIs(u.RequestEmailChange(u.Email, "hash", 10*time.Minute), ErrEmailUnchanged)
+
+	assert.NoError(u.RequestEmailChange("user01@example.org", "hash", -time.Minute))
+	assert.ErrorIs(u.ConfirmEmailChange("hash"), ErrVerificationCodeExpired)
+
+	// the email is kept until confirmed
+	assert.NoError(u.RequestEmailChange("user01@example.org", "hash", 10*time.Minute))
+	assert.Equal("user01@example.com", u.Email)
+
+	assert.ErrorIs(u.ConfirmEmailChange("wrong"), ErrVerificationCodeInvalid)
+	assert.NoError(u.ConfirmEmailChange("hash"))
+	assert.Equal("user01@example.org", u.Email)
+	assert.Nil(u.EmailChange)
+
+	events := u.Events()
+	e, ok := events[len(events)-1].(*UserEmailChangedEvent)
+	if !ok {
+		assert.Fail("invalid event")
+		return
+	}
+
+	assert.Equal("user01@example.org", e.Email)
+	assert.Equal("user01@example.com", e.Previous)
+	assert.Equal("users."+u.ID.String()+".email_changed", e.Topic())
+}
+
+func TestChangeUsername(t *testing.T) {
+	assert := assert.New(t)
+
+	u := NewUser(tenant.Default, "user01", "User01", "user01@example.com")
+
+	assert.ErrorIs(u.ChangeUsername(""), ErrUsernameEmpty)
+	assert.ErrorIs(u.ChangeUsername("user01"), ErrUsernameUnchanged)
+
+	assert.NoError(u.ChangeUsername("user01a"))
+	assert.Equal("user01a", u.Username)
+
+	events := u.Events()
+	e, ok := events[len(events)-1].(*UserUsernameChangedEvent)
+	if !ok {
+		assert.Fail("invalid event")
+		return
+	}
+
+	assert.Equal("user01a", e.Username)
+	assert.Equal("user01", e.Previous)
+	assert.Equal("users."+u.ID.String()+".username_changed", e.Topic())
+}
+
+func TestDelete(t *testing.T) {
+	assert := assert.New(t)
+
+	u := NewUser(tenant.Default, "user01", "User01", "user01@example.com")
+
+	assert.NoError(u.Delete())
+	assert.False(u.DeletedAt.IsZero())
+	assert.ErrorIs(u.Delete(), ErrUserDeleted)
+
+	// the deleted users are never matched
+	assert.False(Query{}.Match(u))
+
+	events := u.Events()
+	e, ok := events[len(events)-1].(*UserDeletedEvent)
+	if !ok {
+		assert.Fail("invalid event")
+		return
+	}
+
+	assert.Equal("user01", e.Username)
+	assert.Equal("users."+u.ID.String()+".deleted", e.Topic())
+}
+
+func TestRemoveSocialAccount(t *testing.T) {
+	assert := assert.New(t)
+
+	u := NewUser(tenant.Default, "user01", "User01", "user01@example.com")
+	u.AddSocialAccount(GOOGLE, "100043685676652067799")
+
+	assert.ErrorIs(u.RemoveSocialAccount(GOOGLE, "unknown"), ErrSocialAccountNotFound)
+	assert.ErrorIs(u.RemoveSocialAccount(LINE, "100043685676652067799"), ErrSocialAccountNotFound)
+
+	// the last credential is kept
+	assert.ErrorIs(u.RemoveSocialAccount(GOOGLE, "100043685676652067799"), ErrLastCredential)
+
+	u.ChangePassword("hash")
+	assert.NoError(u.RemoveSocialAccount(GOOGLE, "100043685676652067799"))
+	assert.Empty(u.Accounts)
+
+	events := u.Events()
+	e, ok := events[len(events)-1].(*UserSocialAccountRemovedEvent)
+	if !ok {
+		assert.Fail("invalid event")
+		return
+	}
+
+	assert.Equal(GOOGLE, e.Provider)
+	assert.Equal(SocialID("100043685676652067799"), e.SocialID)
+	assert.Equal("users."+u.ID.String()+".social_account_removed", e.Topic())
 }
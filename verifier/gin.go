@@ -0,0 +1,30 @@
+package verifier
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mirror520/identity/model"
+)
+
+// ClaimsKey is the key of the claims set to gin.Context.
+const ClaimsKey = "claims"
+
+// GinHandler verifies the requests of gin, the claims are set to both
+// gin.Context and the request context.
+func (v *Verifier) GinHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, err := v.VerifyHeader(ctx.GetHeader("Authorization"))
+		if err != nil {
+			ctx.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			result := model.FailureResult(err)
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, result)
+			return
+		}
+
+		ctx.Set(ClaimsKey, claims)
+		ctx.Request = ctx.Request.WithContext(NewContext(ctx.Request.Context(), claims))
+		ctx.Next()
+	}
+}
@@ -0,0 +1,161 @@
+package verifier
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/mirror520/identity/keys"
+)
+
+// JWKS is the KeySet of the services away from the key ring. The keys are
+// fetched from the JWKS endpoint of the issuer (e.g. the jwks_uri of the
+// discovery) and cached, an unknown kid refetches them for the rotated
+// keys.
+type JWKS struct {
+	url    string
+	client *http.Client
+
+	// the least between the fetches, the unknown kids don't flood the issuer
+	interval time.Duration
+
+	// the keys are refetched once older, the retired keys are dropped
+	maxAge time.Duration
+
+	keys      map[string]*jwksKey // map[kid]*jwksKey
+	fetchedAt time.Time
+	sync.RWMutex
+}
+
+type jwksKey struct {
+	alg    string
+	public any
+}
+
+func NewJWKS(url string) *JWKS {
+	return &JWKS{
+		url: url,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		interval: time.Minute,
+		maxAge:   time.Hour,
+		keys:     make(map[string]*jwksKey),
+	}
+}
+
+// Keyfunc resolves the key by the kid of the token, the tokens without a
+// kid are resolved by the only key.
+func (s *JWKS) Keyfunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	key, ok := s.find(kid)
+	if !ok {
+		if err := s.refresh(); err != nil {
+			return nil, err
+		}
+
+		key, ok = s.find(kid)
+		if !ok {
+			return nil, keys.ErrKeyNotFound
+		}
+	}
+
+	if t.Method.Alg() != key.alg {
+		return nil, keys.ErrKeyMismatched
+	}
+
+	return key.public, nil
+}
+
+// Algorithms are the asymmetric ones only, a symmetric key is never
+// published.
+func (s *JWKS) Algorithms() []string {
+	return []string{"RS256", "ES256", "EdDSA"}
+}
+
+func (s *JWKS) find(kid string) (*jwksKey, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if time.Since(s.fetchedAt) > s.maxAge {
+		return nil, false
+	}
+
+	if kid == "" {
+		if len(s.keys) != 1 {
+			return nil, false
+		}
+
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// refresh fetches the keys unless fetched within the interval.
+func (s *JWKS) refresh() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if time.Since(s.fetchedAt) < s.interval {
+		return nil
+	}
+
+	fetched, err := s.fetch()
+	if err != nil {
+		return err
+	}
+
+	s.keys = fetched
+	s.fetchedAt = time.Now()
+	return nil
+}
+
+func (s *JWKS) fetch() (map[string]*jwksKey, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	var jwks *keys.JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, err
+	}
+
+	fetched := make(map[string]*jwksKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		public, err := jwk.PublicKey()
+		if err != nil {
+			if errors.Is(err, keys.ErrAlgorithmNotSupported) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		fetched[jwk.KeyID] = &jwksKey{
+			alg:    jwk.Algorithm,
+			public: public,
+		}
+	}
+
+	return fetched, nil
+}
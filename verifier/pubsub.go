@@ -0,0 +1,33 @@
+package verifier
+
+import (
+	"context"
+
+	"github.com/mirror520/identity/model"
+	"github.com/mirror520/identity/pubsub"
+)
+
+// MessageHandler verifies the bearer token in the Authorization header of
+// the messages, a request is answered with the failure.
+func (v *Verifier) MessageHandler(next pubsub.MessageHandler) pubsub.MessageHandler {
+	return func(ctx context.Context, msg *pubsub.Message) error {
+		claims, err := v.VerifyHeader(msg.Header.Get("Authorization"))
+		if err != nil {
+			if msg.Response != nil {
+				result := model.FailureResult(err)
+				bs, err := result.Bytes()
+				if err != nil {
+					return err
+				}
+
+				if err := msg.Response(bs); err != nil {
+					return err
+				}
+			}
+
+			return err
+		}
+
+		return next(NewContext(ctx, claims), msg)
+	}
+}
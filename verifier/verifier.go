@@ -0,0 +1,195 @@
+// Package verifier verifies the access tokens issued by identity, so that
+// the other services share the same rules without the gin transport.
+package verifier
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/mirror520/identity/keys"
+	"github.com/mirror520/identity/tenant"
+)
+
+var (
+	ErrTokenNotFound  = errors.New("token not found")
+	ErrTokenRevoked   = errors.New("token revoked")
+	ErrNotAccessToken = errors.New("not an access token")
+)
+
+// KeySet resolves the key to verify a token, keys.Ring is one.
+type KeySet interface {
+	Keyfunc(t *jwt.Token) (any, error)
+	Algorithms() []string
+}
+
+// RevocationChecker tells the revoked tokens, token.Denylist is one.
+type RevocationChecker interface {
+	Denied(jti string, at time.Time) bool
+}
+
+// the principals an access token is issued to, the tokens without
+// token_use are issued to the users
+const (
+	TokenUseUser    = "user"
+	TokenUseService = "service"
+)
+
+type Claims struct {
+	jwt.RegisteredClaims
+	Roles    []string         `json:"roles"`
+	Scope    string           `json:"scope,omitempty"`
+	TokenUse string           `json:"token_use,omitempty"`
+	ClientID string           `json:"client_id,omitempty"`
+	TenantID tenant.TenantID  `json:"tenant_id,omitempty"`
+	Act      *Actor           `json:"act,omitempty"`
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	AMR      []string         `json:"amr,omitempty"`
+}
+
+// Actor is the act claim of RFC 8693, the current actor is outermost and
+// the prior actors are nested.
+type Actor struct {
+	Subject  string `json:"sub"`
+	TokenUse string `json:"token_use,omitempty"`
+	Act      *Actor `json:"act,omitempty"`
+}
+
+// Delegated tells whether the token is used by someone else than the subject.
+func (c *Claims) Delegated() bool {
+	return c.Act != nil
+}
+
+// Service tells whether the subject is a client acting on its own behalf.
+func (c *Claims) Service() bool {
+	return c.TokenUse == TokenUseService
+}
+
+// Granted tells whether the user granted the token to a client, the
+// relying party calls its own APIs and the userinfo with it only.
+func (c *Claims) Granted() bool {
+	return !c.Service() && c.ClientID != ""
+}
+
+// Tenant returns the tenant the user signed in to, the tokens issued before
+// the tenants are of the default tenant. The services belong to no tenant.
+func (c *Claims) Tenant() tenant.TenantID {
+	if c.Service() {
+		return ""
+	}
+
+	if c.TenantID == "" {
+		return tenant.Default
+	}
+
+	return c.TenantID
+}
+
+func (c *Claims) Map() map[string]any {
+	tokenUse := TokenUseUser
+	if c.Service() {
+		tokenUse = TokenUseService
+	}
+
+	m := map[string]any{
+		"sub":       c.Subject,
+		"roles":     c.Roles,
+		"token_use": tokenUse,
+	}
+
+	if !c.Service() {
+		m["tenant_id"] = c.Tenant().String()
+	}
+
+	if c.Act != nil {
+		m["act"] = c.Act
+	}
+
+	return m
+}
+
+type Verifier struct {
+	issuer      string
+	keys        KeySet
+	revocations RevocationChecker
+	leeway      time.Duration
+}
+
+// NewVerifier returns the verifier of the issuer, the revocations are not
+// checked if the checker is nil.
+func NewVerifier(issuer string, keys KeySet, revocations RevocationChecker) *Verifier {
+	return &Verifier{
+		issuer:      issuer,
+		keys:        keys,
+		revocations: revocations,
+		leeway:      10 * time.Second,
+	}
+}
+
+// Verify accepts the access tokens only, the ID tokens and the others
+// signed by the same keys are told apart by the typ header.
+func (v *Verifier) Verify(tokenStr string) (*Claims, error) {
+	claims := new(Claims)
+
+	token, err := jwt.ParseWithClaims(tokenStr, claims, v.keys.Keyfunc,
+		jwt.WithValidMethods(v.keys.Algorithms()),
+		jwt.WithIssuer(v.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.leeway),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !accessTokenType(token.Header["typ"]) {
+		return nil, ErrNotAccessToken
+	}
+
+	if v.revocations != nil && v.revocations.Denied(claims.ID, time.Now()) {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// accessTokenType takes the typ with or without the "application/" prefix
+// as RFC 9068 allows.
+func accessTokenType(typ any) bool {
+	s, _ := typ.(string)
+	s = strings.TrimPrefix(strings.ToLower(s), "application/")
+	return s == keys.AccessTokenType
+}
+
+// VerifyHeader verifies the bearer token of the Authorization header.
+func (v *Verifier) VerifyHeader(header string) (*Claims, error) {
+	tokenStr, err := BearerToken(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.Verify(tokenStr)
+}
+
+func BearerToken(header string) (string, error) {
+	tokenStr, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || tokenStr == "" {
+		return "", ErrTokenNotFound
+	}
+
+	return tokenStr, nil
+}
+
+type claimsKey struct{}
+
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
@@ -0,0 +1,223 @@
+package verifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mirror520/identity/keys"
+	"github.com/mirror520/identity/pubsub"
+)
+
+type denylist map[string]struct{}
+
+func (l denylist) Denied(jti string, at time.Time) bool {
+	_, ok := l[jti]
+	return ok
+}
+
+func sign(t *testing.T, ring *keys.Ring, issuer string, jti string) string {
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   "01HZZZZZZZZZZZZZZZZZZZZZZZ",
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        jti,
+		},
+		Roles: []string{"user"},
+	}
+
+	tokenStr, err := ring.SignAccessToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tokenStr
+}
+
+func TestVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	key, err := keys.GenerateKey("ES256")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	ring := keys.NewRing(key)
+	v := NewVerifier("identity.example.com", ring, denylist{"revoked": {}})
+
+	claims, err := v.Verify(sign(t, ring, "identity.example.com", "valid"))
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	assert.Equal("valid", claims.ID)
+	assert.Equal([]string{"user"}, claims.Roles)
+
+	_, err = v.Verify(sign(t, ring, "identity.example.com", "revoked"))
+	assert.ErrorIs(err, ErrTokenRevoked)
+
+	_, err = v.Verify(sign(t, ring, "evil.example.com", "valid"))
+	assert.ErrorIs(err, jwt.ErrTokenInvalidIssuer)
+
+	// the ID tokens signed by the same keys are no access tokens
+	idToken, err := ring.Sign(jwt.RegisteredClaims{
+		Issuer:    "identity.example.com",
+		Subject:   "01HZZZZZZZZZZZZZZZZZZZZZZZ",
+		Audience:  jwt.ClaimStrings{"console"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	_, err = v.Verify(idToken)
+	assert.ErrorIs(err, ErrNotAccessToken)
+
+	_, err = v.VerifyHeader("Basic dXNlcjpwYXNz")
+	assert.ErrorIs(err, ErrTokenNotFound)
+}
+
+func TestGranted(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.False((&Claims{}).Granted())
+	assert.True((&Claims{ClientID: "console"}).Granted())
+	assert.False((&Claims{ClientID: "gateway", TokenUse: TokenUseService}).Granted())
+}
+
+func TestMiddleware(t *testing.T) {
+	assert := assert.New(t)
+
+	key, err := keys.GenerateKey("ES256")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	ring := keys.NewRing(key)
+	v := NewVerifier("identity.example.com", ring, nil)
+
+	h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := FromContext(r.Context())
+		assert.True(ok)
+		assert.Equal("valid", claims.ID)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(http.StatusUnauthorized, rec.Code)
+
+	req.Header.Set("Authorization", "Bearer "+sign(t, ring, "identity.example.com", "valid"))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(http.StatusOK, rec.Code)
+}
+
+func TestMessageHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	key, err := keys.GenerateKey("ES256")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	ring := keys.NewRing(key)
+	v := NewVerifier("identity.example.com", ring, nil)
+
+	var called bool
+	h := v.MessageHandler(func(ctx context.Context, msg *pubsub.Message) error {
+		_, called = FromContext(ctx)
+		return nil
+	})
+
+	var response []byte
+	msg := &pubsub.Message{
+		Topic: "service.request",
+		Response: func(data []byte) error {
+			response = data
+			return nil
+		},
+	}
+
+	assert.ErrorIs(h(context.Background(), msg), ErrTokenNotFound)
+	assert.NotEmpty(response)
+	assert.False(called)
+
+	msg.Header = pubsub.Header{
+		"Authorization": {"Bearer " + sign(t, ring, "identity.example.com", "valid")},
+	}
+
+	assert.NoError(h(context.Background(), msg))
+	assert.True(called)
+}
+
+func TestJWKS(t *testing.T) {
+	assert := assert.New(t)
+
+	published := make([]*keys.Key, 0)
+	for _, alg := range []string{"ES256", "EdDSA", "RS256"} {
+		key, err := keys.GenerateKey(alg)
+		if err != nil {
+			assert.Fail(err.Error())
+			return
+		}
+
+		published = append(published, key)
+	}
+
+	var fetches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(keys.NewJWKS(published[:fetches]...))
+	}))
+	defer srv.Close()
+
+	jwks := NewJWKS(srv.URL + "/.well-known/jwks.json")
+	jwks.interval = 0
+
+	v := NewVerifier("identity.example.com", jwks, nil)
+
+	_, err := v.Verify(sign(t, keys.NewRing(published[0]), "identity.example.com", "valid"))
+	assert.NoError(err)
+	assert.Equal(1, fetches)
+
+	// cached
+	_, err = v.Verify(sign(t, keys.NewRing(published[0]), "identity.example.com", "valid"))
+	assert.NoError(err)
+	assert.Equal(1, fetches)
+
+	// rotated, the unknown kid refetches the keys
+	_, err = v.Verify(sign(t, keys.NewRing(published[1]), "identity.example.com", "valid"))
+	assert.NoError(err)
+	assert.Equal(2, fetches)
+
+	// the unknown kids refetch no sooner than the interval
+	jwks.interval = time.Minute
+
+	unknown, err := keys.GenerateKey("ES256")
+	if err != nil {
+		assert.Fail(err.Error())
+		return
+	}
+
+	_, err = v.Verify(sign(t, keys.NewRing(unknown), "identity.example.com", "valid"))
+	assert.ErrorIs(err, keys.ErrKeyNotFound)
+
+	_, err = v.Verify(sign(t, keys.NewRing(published[2]), "identity.example.com", "valid"))
+	assert.ErrorIs(err, keys.ErrKeyNotFound)
+	assert.Equal(2, fetches)
+}